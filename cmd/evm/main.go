@@ -223,6 +223,7 @@ func init() {
 		stateTransitionCommand,
 		transactionCommand,
 		blockBuilderCommand,
+		statelessCommand,
 	}
 }
 
@@ -0,0 +1,122 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	WitnessFlag = &cli.StringFlag{
+		Name:  "witness",
+		Usage: "File containing the JSON execution witness of the block",
+	}
+	BlockFlag = &cli.StringFlag{
+		Name:  "block",
+		Usage: "File containing the RLP encoded block, in hex or binary",
+	}
+	ChainConfigFlag = &cli.StringFlag{
+		Name:  "chainconfig",
+		Usage: "File containing the JSON chain configuration (default = mainnet)",
+	}
+)
+
+var statelessCommand = &cli.Command{
+	Action: statelessCmd,
+	Name:   "stateless",
+	Usage:  "executes a block from its execution witness only",
+	Flags: []cli.Flag{
+		WitnessFlag,
+		BlockFlag,
+		ChainConfigFlag,
+	},
+}
+
+// statelessResult is the outcome of a stateless block execution.
+type statelessResult struct {
+	StateRoot   common.Hash `json:"stateRoot"`
+	ReceiptRoot common.Hash `json:"receiptsRoot"`
+}
+
+func statelessCmd(ctx *cli.Context) error {
+	if !ctx.IsSet(WitnessFlag.Name) || !ctx.IsSet(BlockFlag.Name) {
+		return errors.New("both --witness and --block are required")
+	}
+	// Load the chain configuration, the witness and the block
+	config := params.MainnetChainConfig
+	if ctx.IsSet(ChainConfigFlag.Name) {
+		src, err := os.ReadFile(ctx.String(ChainConfigFlag.Name))
+		if err != nil {
+			return err
+		}
+		config = new(params.ChainConfig)
+		if err := json.Unmarshal(src, config); err != nil {
+			return fmt.Errorf("invalid chain config: %v", err)
+		}
+	}
+	src, err := os.ReadFile(ctx.String(WitnessFlag.Name))
+	if err != nil {
+		return err
+	}
+	witness := new(stateless.Witness)
+	if err := json.Unmarshal(src, witness); err != nil {
+		return fmt.Errorf("invalid witness: %v", err)
+	}
+	if src, err = os.ReadFile(ctx.String(BlockFlag.Name)); err != nil {
+		return err
+	}
+	if hex := bytes.TrimSpace(src); len(hex) >= 2 && hex[0] == '0' && (hex[1] == 'x' || hex[1] == 'X') {
+		if src, err = hexutil.Decode(string(hex)); err != nil {
+			return fmt.Errorf("invalid block hex: %v", err)
+		}
+	}
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(src, block); err != nil {
+		return fmt.Errorf("invalid block: %v", err)
+	}
+	// Execute the block and report the roots computed
+	var engine consensus.Engine
+	if config.Clique != nil {
+		engine = clique.New(config.Clique, rawdb.NewMemoryDatabase())
+	} else {
+		engine = beacon.New(ethash.NewFaker())
+	}
+	stateRoot, receiptRoot, err := core.ExecuteStateless(config, engine, block, witness)
+	if stateRoot != (common.Hash{}) {
+		out, _ := json.MarshalIndent(&statelessResult{StateRoot: stateRoot, ReceiptRoot: receiptRoot}, "", "  ")
+		fmt.Println(string(out))
+	}
+	return err
+}
@@ -78,6 +78,7 @@ var (
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolSnapshotFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
 		utils.TxPoolAccountSlotsFlag,
@@ -91,6 +92,14 @@ var (
 		utils.GCModeFlag,
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag,
+		utils.StateSchemeFlag,
+		utils.StateHistoryFlag,
+		utils.StatePruneRateFlag,
+		utils.StateWorkersFlag,
+		utils.StateDiffArchiveFlag,
+		utils.StateCheckpointFlag,
+		utils.HistoryRetainFlag,
+		utils.HistoryCutoffFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -129,6 +138,9 @@ var (
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerifyFlag,
 		utils.MinerNewPayloadTimeout,
+		utils.MinerTxOrderingFlag,
+		utils.MinerBundleScoringFlag,
+		utils.MinerMaxBundlesFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -166,20 +178,35 @@ var (
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
 		utils.HTTPApiFlag,
+		utils.HTTPAllowMethodsFlag,
+		utils.HTTPDenyMethodsFlag,
 		utils.HTTPPathPrefixFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
 		utils.WSApiFlag,
+		utils.WSAllowMethodsFlag,
+		utils.WSDenyMethodsFlag,
 		utils.WSAllowedOriginsFlag,
 		utils.WSPathPrefixFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
+		utils.IPCAllowMethodsFlag,
+		utils.IPCDenyMethodsFlag,
 		utils.InsecureUnlockAllowedFlag,
 		utils.RPCGlobalGasCapFlag,
 		utils.RPCGlobalEVMTimeoutFlag,
 		utils.RPCGlobalTxFeeCapFlag,
+		utils.TraceCacheFlag,
+		utils.TraceCacheRetentionFlag,
+		utils.TraceCacheIndexFlag,
 		utils.AllowUnprotectedTxs,
+		utils.BatchRequestLimit,
+		utils.BatchResponseMaxSize,
+		utils.RPCRateLimitFlag,
+		utils.RPCRateLimitBurstFlag,
+		utils.RPCRateLimitKeyFlag,
+		utils.RPCRateLimitKeysFlag,
 	}
 
 	metricsFlags = []cli.Flag{
@@ -211,6 +238,8 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		importHistoryCommand,
+		exportHistoryCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		removedbCommand,
@@ -22,12 +22,18 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+	trieutils "github.com/ethereum/go-ethereum/trie/utils"
 	"github.com/gballet/go-verkle"
 	cli "github.com/urfave/cli/v2"
 )
@@ -40,6 +46,20 @@ var (
 		Usage:       "A set of experimental verkle tree management commands",
 		Description: "",
 		Subcommands: []*cli.Command{
+			{
+				Name:      "convert",
+				Usage:     "Convert the snapshot of a MPT state into a verkle tree",
+				ArgsUsage: "[<root>]",
+				Action:    convertVerkle,
+				Flags:     flags.Merge(utils.NetworkFlags, utils.DatabasePathFlags),
+				Description: `
+geth verkle convert [<state-root>]
+This command iterates the accounts and storage slots of the snapshot of the given
+state, the head state by default, and inserts them into a new verkle tree. The
+preimages of the account addresses and slot keys must be present in the database,
+so the node must have been synced with --cache.preimages.
+ `,
+			},
 			{
 				Name:      "verify",
 				Usage:     "verify the conversion of a MPT into a verkle tree",
@@ -67,6 +87,163 @@ in which key1, key2, ... are expanded.
 	}
 )
 
+// verkleFlushLeaves is the number of leaves inserted into the verkle tree during
+// a conversion before its nodes are flushed to disk to free up memory.
+const verkleFlushLeaves = 1 << 20
+
+// convertVerkle migrates the snapshot of a MPT state into a verkle tree.
+func convertVerkle(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(ctx, stack, false)
+	defer chaindb.Close()
+
+	headBlock := rawdb.ReadHeadBlock(chaindb)
+	if headBlock == nil {
+		log.Error("Failed to load head block")
+		return errors.New("no head block")
+	}
+	if ctx.NArg() > 1 {
+		log.Error("Too many arguments given")
+		return errors.New("too many arguments")
+	}
+	var (
+		root common.Hash
+		err  error
+	)
+	if ctx.NArg() == 1 {
+		root, err = parseRoot(ctx.Args().First())
+		if err != nil {
+			log.Error("Failed to resolve state root", "error", err)
+			return err
+		}
+	} else {
+		root = headBlock.Root()
+	}
+	snapConfig := snapshot.Config{
+		CacheSize:  256,
+		Recovery:   false,
+		NoBuild:    true,
+		AsyncBuild: false,
+	}
+	snaptree, err := snapshot.New(snapConfig, chaindb, utils.MakeTrieDatabase(ctx, chaindb, false, true), headBlock.Root())
+	if err != nil {
+		log.Error("Failed to open snapshot tree", "err", err)
+		return err
+	}
+	triedb := trie.NewDatabaseWithConfig(chaindb, &trie.Config{Verkle: true})
+	tree, err := trie.NewVerkleTrie(types.EmptyRootHash, triedb)
+	if err != nil {
+		return err
+	}
+	accIt, err := snaptree.AccountIterator(root, common.Hash{})
+	if err != nil {
+		log.Error("Failed to open account iterator", "root", root, "err", err)
+		return err
+	}
+	defer accIt.Release()
+
+	log.Info("Verkle conversion started", "root", root)
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		accounts uint64
+		slots    uint64
+		leaves   int
+	)
+	for accIt.Next() {
+		account, err := snapshot.FullAccount(accIt.Account())
+		if err != nil {
+			return err
+		}
+		preimage := rawdb.ReadPreimage(chaindb, accIt.Hash())
+		if len(preimage) != common.AddressLength {
+			return fmt.Errorf("missing preimage of account %x", accIt.Hash())
+		}
+		addr := common.BytesToAddress(preimage)
+
+		if err := tree.UpdateAccount(addr, &types.StateAccount{
+			Nonce:    account.Nonce,
+			Balance:  account.Balance,
+			Root:     types.EmptyRootHash,
+			CodeHash: account.CodeHash,
+		}); err != nil {
+			return err
+		}
+		leaves += 4 // version, balance, nonce and code hash
+		if !bytes.Equal(account.CodeHash, types.EmptyCodeHash.Bytes()) {
+			code := rawdb.ReadCode(chaindb, common.BytesToHash(account.CodeHash))
+			if len(code) == 0 {
+				return fmt.Errorf("missing code %x of account %x", account.CodeHash, addr)
+			}
+			if err := tree.UpdateContractCode(addr, common.BytesToHash(account.CodeHash), code); err != nil {
+				return err
+			}
+			leaves += len(code)/trieutils.ChunkSize + 1
+		}
+		if !bytes.Equal(account.Root, types.EmptyRootHash.Bytes()) {
+			stIt, err := snaptree.StorageIterator(root, accIt.Hash(), common.Hash{})
+			if err != nil {
+				return err
+			}
+			for stIt.Next() {
+				key := rawdb.ReadPreimage(chaindb, stIt.Hash())
+				if len(key) != common.HashLength {
+					stIt.Release()
+					return fmt.Errorf("missing preimage of slot %x of account %x", stIt.Hash(), addr)
+				}
+				if err := tree.UpdateStorage(addr, key, stIt.Slot()); err != nil {
+					stIt.Release()
+					return err
+				}
+				slots++
+				leaves++
+			}
+			stIt.Release()
+			if err := stIt.Error(); err != nil {
+				return err
+			}
+		}
+		accounts++
+
+		// Flush the tree periodically to keep the memory usage in check
+		if leaves >= verkleFlushLeaves {
+			commitment, _ := tree.Commit(false)
+			if err := triedb.Commit(commitment, false); err != nil {
+				return err
+			}
+			leaves = 0
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verkle conversion in progress", "at", accIt.Hash(), "accounts", accounts, "slots", slots,
+				"elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := accIt.Error(); err != nil {
+		return err
+	}
+	commitment, _ := tree.Commit(false)
+	if err := triedb.Commit(commitment, false); err != nil {
+		return err
+	}
+	log.Info("Verkle conversion complete", "root", root, "commitment", commitment, "accounts", accounts, "slots", slots,
+		"elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verkleResolver returns a resolver of the verkle tree nodes stored in the database.
+func verkleResolver(db ethdb.KeyValueReader) verkle.NodeResolverFn {
+	return func(commitment []byte) ([]byte, error) {
+		blob := rawdb.ReadVerkleNode(db, commitment)
+		if len(blob) == 0 {
+			return nil, fmt.Errorf("verkle node %x missing", commitment)
+		}
+		return blob, nil
+	}
+}
+
 // recurse into each child to ensure they can be loaded from the db. The tree isn't rebuilt
 // (only its nodes are loaded) so there is no need to flush them, the garbage collector should
 // take care of that for us.
@@ -140,7 +317,8 @@ func verifyVerkle(ctx *cli.Context) error {
 		log.Info("Rebuilding the tree", "root", rootC, "number", headBlock.NumberU64())
 	}
 
-	serializedRoot, err := chaindb.Get(rootC[:])
+	resolve := verkleResolver(chaindb)
+	serializedRoot, err := resolve(rootC[:])
 	if err != nil {
 		return err
 	}
@@ -149,7 +327,7 @@ func verifyVerkle(ctx *cli.Context) error {
 		return err
 	}
 
-	if err := checkChildren(root, chaindb.Get); err != nil {
+	if err := checkChildren(root, resolve); err != nil {
 		log.Error("Could not rebuild the tree from the database", "err", err)
 		return err
 	}
@@ -189,7 +367,8 @@ func expandVerkle(ctx *cli.Context) error {
 		return fmt.Errorf("usage: %s root key1 [key 2...]", ctx.App.Name)
 	}
 
-	serializedRoot, err := chaindb.Get(rootC[:])
+	resolve := verkleResolver(chaindb)
+	serializedRoot, err := resolve(rootC[:])
 	if err != nil {
 		return err
 	}
@@ -200,7 +379,7 @@ func expandVerkle(ctx *cli.Context) error {
 
 	for i, key := range keylist {
 		log.Info("Reading key", "index", i, "key", keylist[0])
-		root.Get(key, chaindb.Get)
+		root.Get(key, resolve)
 	}
 
 	if err := os.WriteFile("dump.dot", []byte(verkle.ToDot(root)), 0600); err != nil {
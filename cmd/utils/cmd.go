@@ -23,8 +23,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/big"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
@@ -38,14 +41,21 @@ import (
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/debug"
+	"github.com/ethereum/go-ethereum/internal/era"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 	"github.com/urfave/cli/v2"
 )
 
 const (
 	importBatchSize = 2500
+
+	// historyAccumulators is the name of the file listing the accumulator roots
+	// of the exported Era1 archives, one per epoch.
+	historyAccumulators = "accumulators.txt"
 )
 
 // Fatalf formats a message to standard error and exits the program.
@@ -287,6 +297,219 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// historyNetwork returns the network name used in the Era1 file names.
+func historyNetwork(config *params.ChainConfig) string {
+	if name, ok := params.NetworkNames[config.ChainID.String()]; ok {
+		return strings.ToLower(name)
+	}
+	return "chain" + config.ChainID.String()
+}
+
+// ExportHistory exports the pre-merge blockchain history into Era1 archives of
+// step blocks each, starting at the given epoch aligned block. The accumulator
+// roots of the archives are listed in a separate file to check them against.
+func ExportHistory(bc *core.BlockChain, dir string, first, last, step uint64) error {
+	if step == 0 || step > era.MaxEra1Size {
+		return fmt.Errorf("invalid epoch size %d, must be in [1, %d]", step, era.MaxEra1Size)
+	}
+	if first%step != 0 {
+		return fmt.Errorf("first block %d not aligned to the epoch size %d", first, step)
+	}
+	if head := bc.CurrentBlock().Number.Uint64(); last > head {
+		return fmt.Errorf("last block %d beyond the head block %d", last, head)
+	}
+	log.Info("Exporting blockchain history", "dir", dir, "first", first, "last", last)
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	var (
+		start    = time.Now()
+		reported = time.Now()
+		network  = historyNetwork(bc.Config())
+		roots    []string
+	)
+	for epoch := first / step; epoch*step <= last; epoch++ {
+		end := epoch*step + step - 1
+		if end > last {
+			end = last
+		}
+		root, err := exportEpoch(bc, dir, network, epoch, epoch*step, end)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, root.Hex())
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Exporting blockchain history", "exported", end-first+1, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, historyAccumulators), []byte(strings.Join(roots, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	log.Info("Exported blockchain history", "dir", dir, "files", len(roots), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// exportEpoch writes the blocks of a single epoch into an Era1 archive, named
+// after its accumulator root.
+func exportEpoch(bc *core.BlockChain, dir string, network string, epoch uint64, first, last uint64) (common.Hash, error) {
+	tmp := filepath.Join(dir, fmt.Sprintf("%s-%05d.era1.tmp", network, epoch))
+	f, err := os.Create(tmp)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	defer f.Close()
+
+	builder := era.NewBuilder(f)
+	for number := first; number <= last; number++ {
+		block := bc.GetBlockByNumber(number)
+		if block == nil {
+			return common.Hash{}, fmt.Errorf("export failed on #%d: not found", number)
+		}
+		if number > 0 && block.Difficulty().Sign() == 0 {
+			return common.Hash{}, fmt.Errorf("export failed on #%d: only pre-merge history can be exported", number)
+		}
+		receipts := bc.GetReceiptsByHash(block.Hash())
+		if receipts == nil && len(block.Transactions()) > 0 {
+			return common.Hash{}, fmt.Errorf("export failed on #%d: receipts not found", number)
+		}
+		td := bc.GetTd(block.Hash(), number)
+		if td == nil {
+			return common.Hash{}, fmt.Errorf("export failed on #%d: total difficulty not found", number)
+		}
+		if err := builder.Add(block, receipts, td); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := f.Close(); err != nil {
+		return common.Hash{}, err
+	}
+	return root, os.Rename(tmp, filepath.Join(dir, era.Filename(network, int(epoch), root)))
+}
+
+// ImportHistory imports the Era1 archives of a directory into a database with
+// no history yet, checking each archive against the listed accumulator roots.
+func ImportHistory(chain *core.BlockChain, db ethdb.Database, dir string) error {
+	if frozen, _ := db.Ancients(); frozen > 0 {
+		return errors.New("can't import history into a database with existing ancient data")
+	}
+	blob, err := os.ReadFile(filepath.Join(dir, historyAccumulators))
+	if err != nil {
+		return fmt.Errorf("unable to read accumulator list: %w", err)
+	}
+	roots := strings.Fields(string(blob))
+	names, err := era.ReadDir(dir, historyNetwork(chain.Config()))
+	if err != nil {
+		return err
+	}
+	if len(names) != len(roots) {
+		return fmt.Errorf("have %d era1 files but %d accumulator roots", len(names), len(roots))
+	}
+	var (
+		start    = time.Now()
+		reported = time.Now()
+		imported = 0
+	)
+	for i, name := range names {
+		n, err := importEpoch(chain, filepath.Join(dir, name), common.HexToHash(roots[i]))
+		if err != nil {
+			return fmt.Errorf("error importing %s: %w", name, err)
+		}
+		imported += n
+		if time.Since(reported) >= 8*time.Second {
+			log.Info("Importing blockchain history", "file", name, "imported", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+			reported = time.Now()
+		}
+	}
+	log.Info("Imported blockchain history", "files", len(names), "blocks", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// importEpoch verifies and imports a single Era1 archive, returning the number
+// of imported blocks.
+func importEpoch(chain *core.BlockChain, filename string, want common.Hash) (int, error) {
+	e, err := era.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer e.Close()
+
+	if root, err := e.Accumulator(); err != nil {
+		return 0, err
+	} else if root != want {
+		return 0, fmt.Errorf("accumulator mismatch: have %x, want %x", root, want)
+	}
+	var (
+		it       = era.NewIterator(e)
+		hashes   []common.Hash
+		tds      []*big.Int
+		td       *big.Int
+		headers  []*types.Header
+		blocks   types.Blocks
+		receipts []types.Receipts
+	)
+	for it.Next() {
+		block := it.Block()
+		if err := verifyHistoryBlock(block, it.Receipts()); err != nil {
+			return 0, err
+		}
+		// The total difficulties must add up starting from the local parent
+		if block.NumberU64() == 0 {
+			if block.Hash() != chain.Genesis().Hash() {
+				return 0, fmt.Errorf("genesis mismatch: have %x, want %x", block.Hash(), chain.Genesis().Hash())
+			}
+			td = new(big.Int).Set(block.Difficulty())
+		} else {
+			if td == nil {
+				if td = chain.GetTd(block.ParentHash(), block.NumberU64()-1); td == nil {
+					return 0, fmt.Errorf("unknown parent of block #%d", block.NumberU64())
+				}
+			}
+			td = new(big.Int).Add(td, block.Difficulty())
+			headers, blocks, receipts = append(headers, block.Header()), append(blocks, block), append(receipts, it.Receipts())
+		}
+		if td.Cmp(it.TotalDifficulty()) != 0 {
+			return 0, fmt.Errorf("total difficulty mismatch at #%d: have %v, want %v", block.NumberU64(), it.TotalDifficulty(), td)
+		}
+		hashes, tds = append(hashes, block.Hash()), append(tds, it.TotalDifficulty())
+	}
+	if it.Error() != nil {
+		return 0, it.Error()
+	}
+	if root, err := era.ComputeAccumulator(hashes, tds); err != nil {
+		return 0, err
+	} else if root != want {
+		return 0, fmt.Errorf("content doesn't match accumulator: have %x, want %x", root, want)
+	}
+	if _, err := chain.InsertHeaderChain(headers, 100); err != nil {
+		return 0, err
+	}
+	if _, err := chain.InsertReceiptChain(blocks, receipts, math.MaxUint64); err != nil {
+		return 0, err
+	}
+	return len(blocks), nil
+}
+
+// verifyHistoryBlock checks that the body and receipts of an imported block
+// match its header.
+func verifyHistoryBlock(block *types.Block, receipts types.Receipts) error {
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != block.TxHash() {
+		return fmt.Errorf("transaction root mismatch at #%d: have %x, want %x", block.NumberU64(), hash, block.TxHash())
+	}
+	if hash := types.CalcUncleHash(block.Uncles()); hash != block.UncleHash() {
+		return fmt.Errorf("uncle root mismatch at #%d: have %x, want %x", block.NumberU64(), hash, block.UncleHash())
+	}
+	if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != block.ReceiptHash() {
+		return fmt.Errorf("receipt root mismatch at #%d: have %x, want %x", block.NumberU64(), hash, block.ReceiptHash())
+	}
+	return nil
+}
+
 // ImportPreimages imports a batch of exported hash preimages into the database.
 // It's a part of the deprecated functionality, should be removed in the future.
 func ImportPreimages(db ethdb.Database, fn string) error {
@@ -74,6 +74,7 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
 	"github.com/urfave/cli/v2"
@@ -110,6 +111,16 @@ var (
 		Usage:    "Root directory for ancient data (default = inside chaindata)",
 		Category: flags.EthCategory,
 	}
+	DBSecondaryFlag = &cli.BoolFlag{
+		Name:     "db.secondary",
+		Usage:    "Open the database read-only next to a running node, without locking it (point-in-time view)",
+		Category: flags.EthCategory,
+	}
+	RemoteAncientFlag = &cli.StringFlag{
+		Name:     "datadir.ancient.remote",
+		Usage:    "URL of a read-only ancient store serving the chain freezer files over HTTP (replaces --datadir.ancient)",
+		Category: flags.EthCategory,
+	}
 	MinFreeDiskSpaceFlag = &flags.DirectoryFlag{
 		Name:     "datadir.minfreedisk",
 		Usage:    "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -247,6 +258,48 @@ var (
 		Value:    ethconfig.Defaults.TxLookupLimit,
 		Category: flags.EthCategory,
 	}
+	StateSchemeFlag = &cli.StringFlag{
+		Name:     "state.scheme",
+		Usage:    "Scheme to use for storing ethereum state ('hash' or 'path'), only selectable at database initialization",
+		Category: flags.EthCategory,
+	}
+	StateHistoryFlag = &cli.Uint64Flag{
+		Name:     "state.history",
+		Usage:    "Number of recent blocks to retain state history for rollbacks with the path scheme (default = 90,000 blocks, 0 = entire chain)",
+		Value:    ethconfig.Defaults.StateHistory,
+		Category: flags.EthCategory,
+	}
+	StatePruneRateFlag = &cli.Uint64Flag{
+		Name:     "state.prune.rate",
+		Usage:    "Maximum number of trie nodes deleted per second by the online state pruning (0 = unlimited)",
+		Category: flags.EthCategory,
+	}
+	StateWorkersFlag = &cli.IntFlag{
+		Name:     "state.workers",
+		Usage:    "Number of storage tries hashed and committed concurrently during block import (0 = number of CPUs)",
+		Category: flags.EthCategory,
+	}
+	StateDiffArchiveFlag = &cli.BoolFlag{
+		Name:     "state.diffarchive",
+		Usage:    "Serve historical states from per-block state diffs on top of periodic full state checkpoints (hash scheme only)",
+		Category: flags.EthCategory,
+	}
+	StateCheckpointFlag = &cli.Uint64Flag{
+		Name:     "state.checkpoint",
+		Usage:    "Number of blocks between the full state checkpoints of the diff archive",
+		Value:    ethconfig.Defaults.StateCheckpoint,
+		Category: flags.EthCategory,
+	}
+	HistoryRetainFlag = &cli.Uint64Flag{
+		Name:     "history.retain",
+		Usage:    "Number of recent blocks to keep the bodies and receipts for, older ones are pruned (0 = entire chain)",
+		Category: flags.EthCategory,
+	}
+	HistoryCutoffFlag = &cli.Uint64Flag{
+		Name:     "history.cutoff",
+		Usage:    "Number of the first block to keep the body and receipts for, older ones are pruned (0 = entire chain)",
+		Category: flags.EthCategory,
+	}
 	LightKDFFlag = &cli.BoolFlag{
 		Name:     "lightkdf",
 		Usage:    "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -396,6 +449,11 @@ var (
 		Value:    txpool.DefaultConfig.Rejournal,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolSnapshotFlag = &cli.StringFlag{
+		Name:     "txpool.snapshot",
+		Usage:    "Disk snapshot of all pooled transactions (local and remote) to survive node restarts",
+		Category: flags.TxPoolCategory,
+	}
 	TxPoolPriceLimitFlag = &cli.Uint64Flag{
 		Name:     "txpool.pricelimit",
 		Usage:    "Minimum gas price limit to enforce for acceptance into the pool",
@@ -565,6 +623,24 @@ var (
 		Value:    ethconfig.Defaults.Miner.NewPayloadTimeout,
 		Category: flags.MinerCategory,
 	}
+	MinerTxOrderingFlag = &cli.StringFlag{
+		Name:     "miner.txordering",
+		Usage:    "Transaction ordering policy of the built blocks (tip, fifo, fairness)",
+		Value:    ethconfig.Defaults.Miner.TxOrdering,
+		Category: flags.MinerCategory,
+	}
+	MinerBundleScoringFlag = &cli.StringFlag{
+		Name:     "miner.bundlescoring",
+		Usage:    "Scoring used to rank transaction bundles for inclusion (profit, gasprice)",
+		Value:    ethconfig.Defaults.Miner.BundleScoring,
+		Category: flags.MinerCategory,
+	}
+	MinerMaxBundlesFlag = &cli.IntFlag{
+		Name:     "miner.maxbundles",
+		Usage:    "Maximum number of transaction bundles simulated for inclusion into a block",
+		Value:    ethconfig.Defaults.Miner.MaxBundlesPerBlock,
+		Category: flags.MinerCategory,
+	}
 
 	// Account settings
 	UnlockedAccountFlag = &cli.StringFlag{
@@ -617,6 +693,22 @@ var (
 		Value:    ethconfig.Defaults.RPCTxFeeCap,
 		Category: flags.APICategory,
 	}
+	TraceCacheFlag = &cli.StringFlag{
+		Name:     "trace.cache",
+		Usage:    "Comma separated list of tracers whose transaction traces are persisted (e.g. callTracer,prestateTracer)",
+		Category: flags.APICategory,
+	}
+	TraceCacheRetentionFlag = &cli.Uint64Flag{
+		Name:     "trace.cache.retention",
+		Usage:    "Number of recent blocks to keep indexed traces for, on-demand ones being kept forever (0 = keep forever)",
+		Value:    ethconfig.Defaults.TraceCacheRetention,
+		Category: flags.APICategory,
+	}
+	TraceCacheIndexFlag = &cli.BoolFlag{
+		Name:     "trace.cache.index",
+		Usage:    "Trace the transactions of newly imported blocks in the background to populate the trace cache",
+		Category: flags.APICategory,
+	}
 	// Authenticated RPC HTTP settings
 	AuthListenFlag = &cli.StringFlag{
 		Name:     "authrpc.addr",
@@ -678,6 +770,16 @@ var (
 		Usage:    "Filename for IPC socket/pipe within the datadir (explicit paths escape it)",
 		Category: flags.APICategory,
 	}
+	IPCAllowMethodsFlag = &cli.StringFlag{
+		Name:     "ipc.methods.allow",
+		Usage:    "Comma separated list of the only methods offered over IPC. Accepts '*' wildcards.",
+		Category: flags.APICategory,
+	}
+	IPCDenyMethodsFlag = &cli.StringFlag{
+		Name:     "ipc.methods.deny",
+		Usage:    "Comma separated list of methods never offered over IPC. Accepts '*' wildcards.",
+		Category: flags.APICategory,
+	}
 	HTTPEnabledFlag = &cli.BoolFlag{
 		Name:     "http",
 		Usage:    "Enable the HTTP-RPC server",
@@ -713,6 +815,16 @@ var (
 		Value:    "",
 		Category: flags.APICategory,
 	}
+	HTTPAllowMethodsFlag = &cli.StringFlag{
+		Name:     "http.methods.allow",
+		Usage:    "Comma separated list of the only methods offered over the HTTP-RPC interface. Accepts '*' wildcards.",
+		Category: flags.APICategory,
+	}
+	HTTPDenyMethodsFlag = &cli.StringFlag{
+		Name:     "http.methods.deny",
+		Usage:    "Comma separated list of methods never offered over the HTTP-RPC interface. Accepts '*' wildcards.",
+		Category: flags.APICategory,
+	}
 	HTTPPathPrefixFlag = &cli.StringFlag{
 		Name:     "http.rpcprefix",
 		Usage:    "HTTP path path prefix on which JSON-RPC is served. Use '/' to serve on all paths.",
@@ -759,6 +871,16 @@ var (
 		Value:    "",
 		Category: flags.APICategory,
 	}
+	WSAllowMethodsFlag = &cli.StringFlag{
+		Name:     "ws.methods.allow",
+		Usage:    "Comma separated list of the only methods offered over the WS-RPC interface. Accepts '*' wildcards.",
+		Category: flags.APICategory,
+	}
+	WSDenyMethodsFlag = &cli.StringFlag{
+		Name:     "ws.methods.deny",
+		Usage:    "Comma separated list of methods never offered over the WS-RPC interface. Accepts '*' wildcards.",
+		Category: flags.APICategory,
+	}
 	WSAllowedOriginsFlag = &cli.StringFlag{
 		Name:     "ws.origins",
 		Usage:    "Origins from which to accept websockets requests",
@@ -786,6 +908,39 @@ var (
 		Usage:    "Allow for unprotected (non EIP155 signed) transactions to be submitted via RPC",
 		Category: flags.APICategory,
 	}
+	BatchRequestLimit = &cli.IntFlag{
+		Name:     "rpc.batch-request-limit",
+		Usage:    "Maximum number of requests in a batch (0 = unlimited)",
+		Value:    node.DefaultConfig.BatchRequestLimit,
+		Category: flags.APICategory,
+	}
+	BatchResponseMaxSize = &cli.IntFlag{
+		Name:     "rpc.batch-response-max-size",
+		Usage:    "Maximum number of bytes returned from a batched call (0 = unlimited)",
+		Value:    node.DefaultConfig.BatchResponseMaxSize,
+		Category: flags.APICategory,
+	}
+	RPCRateLimitFlag = &cli.Float64Flag{
+		Name:     "rpc.ratelimit",
+		Usage:    "Tokens per second granted to every HTTP and WS client, expensive calls costing more (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCRateLimitBurstFlag = &cli.IntFlag{
+		Name:     "rpc.ratelimit.burst",
+		Usage:    "Maximum tokens an HTTP and WS client can accumulate (default = rate)",
+		Category: flags.APICategory,
+	}
+	RPCRateLimitKeyFlag = &cli.StringFlag{
+		Name:     "rpc.ratelimit.key",
+		Usage:    `Client identifier for rate limiting: "ip", "header:<name>" or "jwt:<claim>"`,
+		Value:    "ip",
+		Category: flags.APICategory,
+	}
+	RPCRateLimitKeysFlag = &cli.StringFlag{
+		Name:     "rpc.ratelimit.keys",
+		Usage:    "Comma separated header values or JWT claims identifying the rate limited clients, others being identified by IP",
+		Category: flags.APICategory,
+	}
 	EnablePersonal = &cli.BoolFlag{
 		Name:     "rpc.enabledeprecatedpersonal",
 		Usage:    "Enables the (deprecated) personal namespace",
@@ -1010,6 +1165,7 @@ var (
 	DatabasePathFlags = []cli.Flag{
 		DataDirFlag,
 		AncientFlag,
+		RemoteAncientFlag,
 		RemoteDBFlag,
 		HttpHeaderFlag,
 	}
@@ -1198,6 +1354,12 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(HTTPApiFlag.Name) {
 		cfg.HTTPModules = SplitAndTrim(ctx.String(HTTPApiFlag.Name))
 	}
+	if ctx.IsSet(HTTPAllowMethodsFlag.Name) {
+		cfg.HTTPAllowMethods = SplitAndTrim(ctx.String(HTTPAllowMethodsFlag.Name))
+	}
+	if ctx.IsSet(HTTPDenyMethodsFlag.Name) {
+		cfg.HTTPDenyMethods = SplitAndTrim(ctx.String(HTTPDenyMethodsFlag.Name))
+	}
 
 	if ctx.IsSet(HTTPVirtualHostsFlag.Name) {
 		cfg.HTTPVirtualHosts = SplitAndTrim(ctx.String(HTTPVirtualHostsFlag.Name))
@@ -1209,6 +1371,24 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.Bool(AllowUnprotectedTxs.Name)
 	}
+	if ctx.IsSet(BatchRequestLimit.Name) {
+		cfg.BatchRequestLimit = ctx.Int(BatchRequestLimit.Name)
+	}
+	if ctx.IsSet(BatchResponseMaxSize.Name) {
+		cfg.BatchResponseMaxSize = ctx.Int(BatchResponseMaxSize.Name)
+	}
+	if ctx.IsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCRateLimit.Rate = ctx.Float64(RPCRateLimitFlag.Name)
+	}
+	if ctx.IsSet(RPCRateLimitBurstFlag.Name) {
+		cfg.RPCRateLimit.Burst = ctx.Int(RPCRateLimitBurstFlag.Name)
+	}
+	if ctx.IsSet(RPCRateLimitKeyFlag.Name) {
+		cfg.RPCRateLimit.Key = ctx.String(RPCRateLimitKeyFlag.Name)
+	}
+	if ctx.IsSet(RPCRateLimitKeysFlag.Name) {
+		cfg.RPCRateLimit.Keys = SplitAndTrim(ctx.String(RPCRateLimitKeysFlag.Name))
+	}
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -1242,6 +1422,12 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(WSApiFlag.Name) {
 		cfg.WSModules = SplitAndTrim(ctx.String(WSApiFlag.Name))
 	}
+	if ctx.IsSet(WSAllowMethodsFlag.Name) {
+		cfg.WSAllowMethods = SplitAndTrim(ctx.String(WSAllowMethodsFlag.Name))
+	}
+	if ctx.IsSet(WSDenyMethodsFlag.Name) {
+		cfg.WSDenyMethods = SplitAndTrim(ctx.String(WSDenyMethodsFlag.Name))
+	}
 
 	if ctx.IsSet(WSPathPrefixFlag.Name) {
 		cfg.WSPathPrefix = ctx.String(WSPathPrefixFlag.Name)
@@ -1258,6 +1444,12 @@ func setIPC(ctx *cli.Context, cfg *node.Config) {
 	case ctx.IsSet(IPCPathFlag.Name):
 		cfg.IPCPath = ctx.String(IPCPathFlag.Name)
 	}
+	if ctx.IsSet(IPCAllowMethodsFlag.Name) {
+		cfg.IPCAllowMethods = SplitAndTrim(ctx.String(IPCAllowMethodsFlag.Name))
+	}
+	if ctx.IsSet(IPCDenyMethodsFlag.Name) {
+		cfg.IPCDenyMethods = SplitAndTrim(ctx.String(IPCDenyMethodsFlag.Name))
+	}
 }
 
 // setLes configures the les server and ultra light client settings from the command line flags.
@@ -1504,6 +1696,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 		log.Info(fmt.Sprintf("Using %s as db engine", dbEngine))
 		cfg.DBEngine = dbEngine
 	}
+	if ctx.IsSet(DBSecondaryFlag.Name) {
+		cfg.DBSecondary = ctx.Bool(DBSecondaryFlag.Name)
+	}
 }
 
 func setSmartCard(ctx *cli.Context, cfg *node.Config) {
@@ -1581,6 +1776,9 @@ func setTxPool(ctx *cli.Context, cfg *txpool.Config) {
 	if ctx.IsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.Duration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.IsSet(TxPoolSnapshotFlag.Name) {
+		cfg.Snapshot = ctx.String(TxPoolSnapshotFlag.Name)
+	}
 	if ctx.IsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.Uint64(TxPoolPriceLimitFlag.Name)
 	}
@@ -1654,6 +1852,21 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.IsSet(MinerNewPayloadTimeout.Name) {
 		cfg.NewPayloadTimeout = ctx.Duration(MinerNewPayloadTimeout.Name)
 	}
+	if ctx.IsSet(MinerTxOrderingFlag.Name) {
+		cfg.TxOrdering = ctx.String(MinerTxOrderingFlag.Name)
+		if _, err := miner.NewTxOrderingPolicy(cfg.TxOrdering); err != nil {
+			Fatalf("Invalid miner transaction ordering: %v", err)
+		}
+	}
+	if ctx.IsSet(MinerBundleScoringFlag.Name) {
+		cfg.BundleScoring = ctx.String(MinerBundleScoringFlag.Name)
+		if _, err := miner.NewBundleScorer(cfg.BundleScoring); err != nil {
+			Fatalf("Invalid miner bundle scoring: %v", err)
+		}
+	}
+	if ctx.IsSet(MinerMaxBundlesFlag.Name) {
+		cfg.MaxBundlesPerBlock = ctx.Int(MinerMaxBundlesFlag.Name)
+	}
 }
 
 func setRequiredBlocks(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1779,6 +1992,14 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.String(AncientFlag.Name)
 	}
+	if ctx.IsSet(RemoteAncientFlag.Name) {
+		CheckExclusive(ctx, AncientFlag, RemoteAncientFlag)
+		url := ctx.String(RemoteAncientFlag.Name)
+		if !rawdb.IsRemoteAncient(url) {
+			Fatalf("Invalid --%s URL %q, only http and https are supported", RemoteAncientFlag.Name, url)
+		}
+		cfg.DatabaseFreezer = url
+	}
 
 	if gcmode := ctx.String(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1798,6 +2019,36 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.Uint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.IsSet(StateSchemeFlag.Name) {
+		cfg.StateScheme = ctx.String(StateSchemeFlag.Name)
+	}
+	if ctx.IsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.Uint64(StateHistoryFlag.Name)
+	}
+	if ctx.IsSet(BloomFilterSizeFlag.Name) {
+		cfg.StatePruneBloom = ctx.Uint64(BloomFilterSizeFlag.Name)
+	}
+	if ctx.IsSet(StatePruneRateFlag.Name) {
+		cfg.StatePruneRate = ctx.Uint64(StatePruneRateFlag.Name)
+	}
+	if ctx.IsSet(StateWorkersFlag.Name) {
+		cfg.StateWorkers = ctx.Int(StateWorkersFlag.Name)
+	}
+	if ctx.IsSet(StateDiffArchiveFlag.Name) {
+		cfg.StateDiffArchive = ctx.Bool(StateDiffArchiveFlag.Name)
+		if cfg.StateDiffArchive && cfg.NoPruning {
+			Fatalf("--%s is not compatible with --%s=archive", StateDiffArchiveFlag.Name, GCModeFlag.Name)
+		}
+	}
+	if ctx.IsSet(StateCheckpointFlag.Name) {
+		cfg.StateCheckpoint = ctx.Uint64(StateCheckpointFlag.Name)
+	}
+	if ctx.IsSet(HistoryRetainFlag.Name) {
+		cfg.HistoryRetain = ctx.Uint64(HistoryRetainFlag.Name)
+	}
+	if ctx.IsSet(HistoryCutoffFlag.Name) {
+		cfg.HistoryCutoff = ctx.Uint64(HistoryCutoffFlag.Name)
+	}
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheTrieFlag.Name) / 100
 	}
@@ -1847,6 +2098,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.Float64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.IsSet(TraceCacheFlag.Name) {
+		cfg.TraceCache = SplitAndTrim(ctx.String(TraceCacheFlag.Name))
+	}
+	if ctx.IsSet(TraceCacheRetentionFlag.Name) {
+		cfg.TraceCacheRetention = ctx.Uint64(TraceCacheRetentionFlag.Name)
+	}
+	if ctx.IsSet(TraceCacheIndexFlag.Name) {
+		cfg.TraceCacheIndex = ctx.Bool(TraceCacheIndexFlag.Name)
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.IsSet(DNSDiscoveryFlag.Name) {
@@ -2150,6 +2410,9 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 		err     error
 		chainDb ethdb.Database
 	)
+	if stack.Config().DBSecondary && !readonly {
+		Fatalf("Database opened with --%s is read-only", DBSecondaryFlag.Name)
+	}
 	switch {
 	case ctx.IsSet(RemoteDBFlag.Name):
 		log.Info("Using remote db", "url", ctx.String(RemoteDBFlag.Name), "headers", len(ctx.StringSlice(HttpHeaderFlag.Name)))
@@ -2161,7 +2424,11 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 	case ctx.String(SyncModeFlag.Name) == "light":
 		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles, "", readonly)
 	default:
-		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ctx.String(AncientFlag.Name), "", readonly)
+		ancient := ctx.String(AncientFlag.Name)
+		if ctx.IsSet(RemoteAncientFlag.Name) {
+			ancient = ctx.String(RemoteAncientFlag.Name)
+		}
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ancient, "", readonly, false)
 	}
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
@@ -2169,6 +2436,25 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 	return chainDb
 }
 
+// MakeTrieDatabase constructs a trie database based on the configured state
+// scheme, validating it against the one the database was initialized with.
+func MakeTrieDatabase(ctx *cli.Context, disk ethdb.Database, preimage bool, readOnly bool) *trie.Database {
+	scheme, err := rawdb.ParseStateScheme(ctx.String(StateSchemeFlag.Name), disk)
+	if err != nil {
+		Fatalf("%v", err)
+	}
+	config := &trie.Config{
+		Preimages: preimage,
+	}
+	if scheme == rawdb.PathScheme {
+		config.PathDB = &trie.PathConfig{
+			StateHistory: ctx.Uint64(StateHistoryFlag.Name),
+			ReadOnly:     readOnly,
+		}
+	}
+	return trie.NewDatabaseWithConfig(disk, config)
+}
+
 func IsNetworkPreset(ctx *cli.Context) bool {
 	for _, flag := range NetworkFlags {
 		bFlag, _ := flag.(*cli.BoolFlag)
@@ -0,0 +1,132 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/era"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestHistoryImportAndExport(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{address: {Balance: big.NewInt(1000000000000000000)}},
+		}
+		signer = types.LatestSigner(genesis.Config)
+	)
+	// Generate a chain with transactions to have receipts
+	_, blocks, _ := core.GenerateChainWithGenesis(genesis, ethash.NewFaker(), 100, func(i int, g *core.BlockGen) {
+		if i%3 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(g.TxNonce(address), common.Address{0xaa}, big.NewInt(1), params.TxGas, g.BaseFee(), nil), signer, key)
+			g.AddTx(tx)
+		}
+	})
+	chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unable to initialize chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("error inserting chain: %v", err)
+	}
+	// Export the history in epochs of 16 blocks
+	dir := t.TempDir()
+	if err := ExportHistory(chain, dir, 0, 100, 16); err != nil {
+		t.Fatalf("error exporting history: %v", err)
+	}
+	names, err := era.ReadDir(dir, historyNetwork(chain.Config()))
+	if err != nil {
+		t.Fatalf("error reading era dir: %v", err)
+	}
+	if len(names) != 7 {
+		t.Fatalf("wrong number of era1 files: have %d, want 7", len(names))
+	}
+	// Random access into the last archive
+	e, err := era.Open(filepath.Join(dir, names[6]))
+	if err != nil {
+		t.Fatalf("error opening era1 file: %v", err)
+	}
+	if block, err := e.GetBlockByNumber(99); err != nil || block.Hash() != blocks[98].Hash() {
+		t.Fatalf("block #99 mismatch: %v", err)
+	}
+	e.Close()
+
+	// Import the history into a fresh database
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+	imported, err := core.NewBlockChain(db, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unable to initialize chain: %v", err)
+	}
+	defer imported.Stop()
+	if err := ImportHistory(imported, db, dir); err != nil {
+		t.Fatalf("failed to import history: %v", err)
+	}
+	if head := imported.CurrentSnapBlock().Number.Uint64(); head != 100 {
+		t.Fatalf("wrong snap head after import: have %d, want 100", head)
+	}
+	for _, want := range blocks {
+		have := imported.GetBlockByNumber(want.NumberU64())
+		if have == nil || have.Hash() != want.Hash() {
+			t.Fatalf("block #%d mismatch", want.NumberU64())
+		}
+		if td, want := imported.GetTd(have.Hash(), have.NumberU64()), chain.GetTd(want.Hash(), want.NumberU64()); td.Cmp(want) != 0 {
+			t.Fatalf("block #%d: td mismatch: have %v, want %v", have.NumberU64(), td, want)
+		}
+		if types.Receipts(imported.GetReceiptsByHash(have.Hash())).Len() != len(have.Transactions()) {
+			t.Fatalf("block #%d: receipts mismatch", have.NumberU64())
+		}
+	}
+	// Tampered accumulator lists are rejected
+	roots, _ := os.ReadFile(filepath.Join(dir, historyAccumulators))
+	lines := strings.Fields(string(roots))
+	lines[3] = common.Hash{}.Hex()
+	os.WriteFile(filepath.Join(dir, historyAccumulators), []byte(strings.Join(lines, "\n")), 0644)
+
+	db2, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db2.Close()
+	imported2, err := core.NewBlockChain(db2, nil, genesis, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("unable to initialize chain: %v", err)
+	}
+	defer imported2.Stop()
+	if err := ImportHistory(imported2, db2, dir); err == nil || !strings.Contains(err.Error(), "accumulator mismatch") {
+		t.Fatalf("tampered accumulator not detected: %v", err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -204,6 +205,60 @@ func benchInsertChain(b *testing.B, disk bool, gen func(int, *BlockGen)) {
 	}
 }
 
+func BenchmarkStateRoot_1worker(b *testing.B) {
+	benchStateRoot(b, 1)
+}
+func BenchmarkStateRoot_4workers(b *testing.B) {
+	benchStateRoot(b, 4)
+}
+func BenchmarkStateRoot_16workers(b *testing.B) {
+	benchStateRoot(b, 16)
+}
+
+// benchStateRoot measures the root computation and the commit of the state
+// after a block modifying the storage of many contracts, with the given number
+// of workers hashing and committing the storage tries.
+func benchStateRoot(b *testing.B, workers int) {
+	const (
+		contracts = 500 // Number of contracts modified by the block
+		slots     = 200 // Number of storage slots of each contract
+		modified  = 20  // Number of storage slots modified in each contract
+	)
+	db := state.NewDatabase(rawdb.NewMemoryDatabase())
+	statedb, _ := state.New(types.EmptyRootHash, db, nil)
+	for i := 0; i < contracts; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.SetNonce(addr, 1)
+		for j := 0; j < slots; j++ {
+			statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(j+1))))
+		}
+	}
+	root, err := statedb.Commit(true)
+	if err != nil {
+		b.Fatalf("failed to commit state: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		statedb, _ := state.New(root, db, nil)
+		statedb.SetWorkers(workers)
+		for j := 0; j < contracts; j++ {
+			addr := common.BigToAddress(big.NewInt(int64(j + 1)))
+			for k := 0; k < modified; k++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(k*slots/modified))), common.BigToHash(big.NewInt(int64(i+k+2))))
+			}
+		}
+		b.StartTimer()
+
+		statedb.IntermediateRoot(true)
+		if _, err := statedb.Commit(true); err != nil {
+			b.Fatalf("failed to commit state: %v", err)
+		}
+	}
+}
+
 func BenchmarkChainRead_header_10k(b *testing.B) {
 	benchReadChain(b, false, 10000)
 }
@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -94,6 +95,7 @@ const (
 	blockCacheLimit     = 256
 	receiptsCacheLimit  = 32
 	txLookupCacheLimit  = 1024
+	archiveCacheLimit   = 4
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
 	TriesInMemory       = 128
@@ -136,6 +138,15 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	StateScheme         string        // Scheme used to store the state trie nodes
+	StateHistory        uint64        // Number of recent blocks to keep the state history for (path scheme only), 0 keeps all
+	PruneBloomSize      uint64        // Memory allowance (MB) for the bloom filter of the online state pruning
+	PruneRate           uint64        // Maximum number of trie nodes deleted per second by the online state pruning, 0 is unlimited
+	StateWorkers        int           // Number of storage tries hashed and committed concurrently, 0 uses all CPUs
+	DiffArchive         bool          // Whether to record per-block state diffs and keep periodic state checkpoints (diff archive)
+	CheckpointInterval  uint64        // Number of blocks between the full state checkpoints of the diff archive
+	HistoryRetain       uint64        // Number of recent blocks to keep the bodies and receipts for, 0 keeps all
+	HistoryCutoff       uint64        // Number of the first block to keep the body and receipts for, 0 keeps all
 
 	SnapshotNoBuild bool // Whether the background generation is allowed
 	SnapshotWait    bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
@@ -171,6 +182,7 @@ type BlockChain struct {
 
 	db            ethdb.Database                   // Low level persistent database to store final content in
 	snaps         *snapshot.Tree                   // Snapshot tree for fast trie leaf access
+	pruner        *pruner.OnlinePruner             // Background pruner of the stale state, nil if unsupported
 	triegc        *prque.Prque[int64, common.Hash] // Priority queue mapping block numbers to tries to gc
 	gcproc        time.Duration                    // Accumulates canonical block processing for trie dumping
 	lastWrite     uint64                           // Last block when the state was flushed
@@ -213,6 +225,9 @@ type BlockChain struct {
 	// future blocks are blocks added for later processing
 	futureBlocks *lru.Cache[common.Hash, *types.Block]
 
+	// archiveCache holds the trie nodes of states reconstructed from diffs
+	archiveCache *lru.Cache[common.Hash, *trie.Database]
+
 	wg            sync.WaitGroup //
 	quit          chan struct{}  // shutdown signal, closed in Stop.
 	running       int32          // 0 if chain is running, 1 when stopped
@@ -235,11 +250,29 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 	}
 
 	// Open trie database with provided config
-	triedb := trie.NewDatabaseWithConfig(db, &trie.Config{
+	trieConfig := &trie.Config{
 		Cache:     cacheConfig.TrieCleanLimit,
 		Journal:   cacheConfig.TrieCleanJournal,
 		Preimages: cacheConfig.Preimages,
-	})
+	}
+	if cacheConfig.StateScheme == rawdb.PathScheme {
+		// The path-based scheme only stores a single version of the state,
+		// so it can't keep all historical states of an archive node.
+		if cacheConfig.TrieDirtyDisabled {
+			return nil, errors.New("archive mode is not supported by the path scheme")
+		}
+		trieConfig.PathDB = &trie.PathConfig{StateHistory: cacheConfig.StateHistory}
+	}
+	if cacheConfig.DiffArchive {
+		// The checkpoints rely on the hash-based scheme keeping several states
+		if cacheConfig.StateScheme == rawdb.PathScheme {
+			return nil, errors.New("diff archive mode is not supported by the path scheme")
+		}
+		if cacheConfig.CheckpointInterval == 0 {
+			return nil, errors.New("diff archive mode requires a positive checkpoint interval")
+		}
+	}
+	triedb := trie.NewDatabaseWithConfig(db, trieConfig)
 	// Setup the genesis block, commit the provided genesis specification
 	// to database if the genesis block is not present yet, or load the
 	// stored one from database.
@@ -270,6 +303,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		blockCache:    lru.NewCache[common.Hash, *types.Block](blockCacheLimit),
 		txLookupCache: lru.NewCache[common.Hash, *rawdb.LegacyTxLookupEntry](txLookupCacheLimit),
 		futureBlocks:  lru.NewCache[common.Hash, *types.Block](maxFutureBlocks),
+		archiveCache:  lru.NewCache[common.Hash, *trie.Database](archiveCacheLimit),
 		engine:        engine,
 		vmConfig:      vmConfig,
 	}
@@ -404,6 +438,22 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 			AsyncBuild: !bc.cacheConfig.SnapshotWait,
 		}
 		bc.snaps, _ = snapshot.New(snapconfig, bc.db, bc.triedb, head.Root)
+
+		// Online pruning relies on the snapshot and the reference counted
+		// hash-based trie database. Resume any unfinished run right away,
+		// it might have left partially deleted stale states behind. The
+		// checkpoints of the diff archive must be kept, so it's disabled there.
+		if bc.snaps != nil && bc.triedb.Scheme() == rawdb.HashScheme && !bc.cacheConfig.TrieDirtyDisabled && !bc.cacheConfig.DiffArchive {
+			bc.pruner = pruner.NewOnlinePruner(bc.db, bc.triedb, bc.snaps, pruner.OnlineConfig{
+				BloomSize: bc.cacheConfig.PruneBloomSize,
+				Rate:      bc.cacheConfig.PruneRate,
+			})
+			if bc.pruner.Interrupted() {
+				if err := bc.pruner.Start(head.Root); err != nil {
+					log.Warn("Failed to resume state pruning, restart it manually", "err", err)
+				}
+			}
+		}
 	}
 
 	// Start future block processor.
@@ -432,12 +482,16 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, genesis *Genesis
 		}
 		rawdb.WriteChainConfig(db, genesisHash, chainConfig)
 	}
-	// Start tx indexer/unindexer if required.
+	// Start tx indexer/unindexer if required. The history is pruned by the
+	// same routine as the transaction indices of the dropped blocks need to be
+	// deleted first.
 	if txLookupLimit != nil {
 		bc.txLookupLimit = *txLookupLimit
 
 		bc.wg.Add(1)
 		go bc.maintainTxIndex()
+	} else if bc.cacheConfig.HistoryRetain != 0 || bc.cacheConfig.HistoryCutoff != 0 {
+		log.Warn("History expiry requires the transaction indexer, disabled")
 	}
 	return bc, nil
 }
@@ -645,6 +699,13 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, time uint64, root common.Ha
 					if root != (common.Hash{}) && !beyondRoot && newHeadBlock.Root() == root {
 						beyondRoot, rootNumber = true, newHeadBlock.NumberU64()
 					}
+					// The path scheme can roll the persisted state back using the state history
+					if !bc.HasState(newHeadBlock.Root()) && bc.triedb.Recoverable(newHeadBlock.Root()) {
+						if err := bc.triedb.Recover(newHeadBlock.Root()); err != nil {
+							log.Crit("Failed to roll back state", "err", err)
+						}
+						log.Debug("Rolled back chain state", "number", newHeadBlock.NumberU64(), "hash", newHeadBlock.Hash(), "root", newHeadBlock.Root())
+					}
 					if !bc.HasState(newHeadBlock.Root()) {
 						log.Trace("Block state missing, rewinding further", "number", newHeadBlock.NumberU64(), "hash", newHeadBlock.Hash())
 						if pivot == nil || newHeadBlock.NumberU64() > *pivot {
@@ -942,9 +1003,21 @@ func (bc *BlockChain) stopWithoutSaving() {
 func (bc *BlockChain) Stop() {
 	bc.stopWithoutSaving()
 
+	// Interrupt the state pruning, it's resumed on the next startup
+	if bc.pruner != nil {
+		bc.pruner.Stop()
+	}
+
 	// Ensure that the entirety of the state snapshot is journalled to disk.
 	var snapBase common.Hash
 	if bc.snaps != nil {
+		// The path scheme only persists the head state, flatten the snapshot
+		// onto it so that an unfinished generation can resume after restart.
+		if bc.triedb.Scheme() == rawdb.PathScheme {
+			if err := bc.snaps.Cap(bc.CurrentBlock().Root, 0); err != nil {
+				log.Error("Failed to flatten state snapshot", "err", err)
+			}
+		}
 		var err error
 		if snapBase, err = bc.snaps.Journal(bc.CurrentBlock().Root); err != nil {
 			log.Error("Failed to journal state snapshot", "err", err)
@@ -956,7 +1029,17 @@ func (bc *BlockChain) Stop() {
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
 	//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
 	//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
-	if !bc.cacheConfig.TrieDirtyDisabled {
+	//
+	// With the path scheme only the HEAD state is persisted, the older ones are
+	// recovered from the state history if needed.
+	if bc.triedb.Scheme() == rawdb.PathScheme {
+		if head := bc.CurrentBlock(); bc.HasState(head.Root) {
+			log.Info("Writing cached state to disk", "block", head.Number, "hash", head.Hash(), "root", head.Root)
+			if err := bc.triedb.Commit(head.Root, true); err != nil {
+				log.Error("Failed to commit recent state trie", "err", err)
+			}
+		}
+	} else if !bc.cacheConfig.TrieDirtyDisabled {
 		triedb := bc.triedb
 
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
@@ -991,9 +1074,30 @@ func (bc *BlockChain) Stop() {
 	if bc.cacheConfig.TrieCleanJournal != "" {
 		bc.triedb.SaveCache(bc.cacheConfig.TrieCleanJournal)
 	}
+	if err := bc.triedb.Close(); err != nil {
+		log.Error("Failed to close trie database", "err", err)
+	}
 	log.Info("Blockchain stopped")
 }
 
+// PruneState starts deleting the stale state in the background, keeping the
+// states tracked by the snapshot. It's only supported with the hash-based scheme
+// and the snapshot enabled.
+func (bc *BlockChain) PruneState() error {
+	if bc.pruner == nil {
+		return errors.New("online state pruning requires the hash scheme with snapshots enabled and no diff archive")
+	}
+	if bc.pruner.Running() {
+		return pruner.ErrPruningRunning
+	}
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	return bc.pruner.Start(bc.CurrentBlock().Root)
+}
+
 // StopInsert interrupts all insertion methods, causing them to return
 // errInsertionInterrupted as soon as possible. Insertion is permanently disabled after
 // calling this method.
@@ -1359,10 +1463,20 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	if err != nil {
 		return err
 	}
+	if bc.cacheConfig.DiffArchive {
+		if err := bc.writeStateDiff(block, state); err != nil {
+			return err
+		}
+	}
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.TrieDirtyDisabled {
 		return bc.triedb.Commit(root, false)
 	}
+	// The path scheme keeps the recent states as diff layers and persists the
+	// older ones, there's no garbage to collect.
+	if bc.triedb.Scheme() == rawdb.PathScheme {
+		return bc.triedb.CapLayers(root, TriesInMemory)
+	}
 	// Full but not archive node, do proper garbage collection
 	bc.triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
 	bc.triegc.Push(root, -int64(block.NumberU64()))
@@ -1383,6 +1497,16 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Find the next state trie we need to commit
 	chosen := current - TriesInMemory
 	flushInterval := time.Duration(atomic.LoadInt64(&bc.flushInterval))
+	// The diff archive keeps the states at the checkpoints forever
+	if bc.cacheConfig.DiffArchive && chosen%bc.cacheConfig.CheckpointInterval == 0 {
+		if header := bc.GetHeaderByNumber(chosen); header == nil {
+			log.Warn("Reorg in progress, state checkpoint skipped", "number", chosen)
+		} else {
+			bc.triedb.Commit(header.Root, false)
+			bc.lastWrite = chosen
+			bc.gcproc = 0
+		}
+	}
 	// If we exceeded time allowance, flush an entire trie to disk
 	if bc.gcproc > flushInterval {
 		// If the header is missing (canonical chain behind), we're reorging a low
@@ -1728,6 +1852,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 		if err != nil {
 			return it.index, err
 		}
+		if bc.cacheConfig.DiffArchive {
+			statedb.RecordDiffs()
+		}
+		statedb.SetWorkers(bc.cacheConfig.StateWorkers)
 
 		// Enable prefetching to pull in trie node paths while processing transactions
 		statedb.StartPrefetcher("chain")
@@ -2352,11 +2480,15 @@ func (bc *BlockChain) skipBlock(err error, it *insertIterator) bool {
 func (bc *BlockChain) indexBlocks(tail *uint64, head uint64, done chan struct{}) {
 	defer func() { close(done) }()
 
+	// The blocks below the history tail have no bodies anymore, they can't
+	// be indexed.
+	pruned := bc.HistoryTail()
+
 	// The tail flag is not existent, it means the node is just initialized
 	// and all blocks(may from ancient store) are not indexed yet.
 	if tail == nil {
-		from := uint64(0)
-		if bc.txLookupLimit != 0 && head >= bc.txLookupLimit {
+		from := pruned
+		if bc.txLookupLimit != 0 && head >= bc.txLookupLimit && head-bc.txLookupLimit+1 > from {
 			from = head - bc.txLookupLimit + 1
 		}
 		rawdb.IndexTransactions(bc.db, from, head+1, bc.quit)
@@ -2364,7 +2496,7 @@ func (bc *BlockChain) indexBlocks(tail *uint64, head uint64, done chan struct{})
 	}
 	// The tail flag is existent, but the whole chain is required to be indexed.
 	if bc.txLookupLimit == 0 || head < bc.txLookupLimit {
-		if *tail > 0 {
+		if *tail > pruned {
 			// It can happen when chain is rewound to a historical point which
 			// is even lower than the indexes tail, recap the indexing target
 			// to new head to avoid reading non-existent block bodies.
@@ -2372,18 +2504,71 @@ func (bc *BlockChain) indexBlocks(tail *uint64, head uint64, done chan struct{})
 			if end > head+1 {
 				end = head + 1
 			}
-			rawdb.IndexTransactions(bc.db, 0, end, bc.quit)
+			rawdb.IndexTransactions(bc.db, pruned, end, bc.quit)
 		}
 		return
 	}
 	// Update the transaction index to the new chain state
-	if head-bc.txLookupLimit+1 < *tail {
+	from := head - bc.txLookupLimit + 1
+	if from < pruned {
+		from = pruned
+	}
+	if from < *tail {
 		// Reindex a part of missing indices and rewind index tail to HEAD-limit
-		rawdb.IndexTransactions(bc.db, head-bc.txLookupLimit+1, *tail, bc.quit)
+		rawdb.IndexTransactions(bc.db, from, *tail, bc.quit)
 	} else {
 		// Unindex a part of stale indices and forward index tail to HEAD-limit
-		rawdb.UnindexTransactions(bc.db, *tail, head-bc.txLookupLimit+1, bc.quit)
+		rawdb.UnindexTransactions(bc.db, *tail, from, bc.quit)
+	}
+}
+
+// pruneHistory drops the bodies and receipts of the frozen blocks which fall
+// out of the configured history range. The transaction indices of the dropped
+// blocks are deleted beforehand as they couldn't be resolved anymore.
+func (bc *BlockChain) pruneHistory(head uint64) {
+	target := bc.cacheConfig.HistoryCutoff
+	if limit := bc.cacheConfig.HistoryRetain; limit != 0 && head >= limit && head-limit+1 > target {
+		target = head - limit + 1
+	}
+	// Only the ancient store supports pruning, the recent blocks are kept
+	frozen, err := bc.db.Ancients()
+	if err != nil {
+		return
 	}
+	if target > frozen {
+		target = frozen
+	}
+	tail, err := bc.db.Tail()
+	if err != nil || target <= tail {
+		return
+	}
+	// A missing index tail means the indexer didn't run yet, it will start
+	// above the pruned blocks.
+	start := time.Now()
+	if txtail := rawdb.ReadTxIndexTail(bc.db); txtail != nil && *txtail < target {
+		rawdb.UnindexTransactions(bc.db, *txtail, target, bc.quit)
+
+		// Bail out if the unindexing was interrupted
+		if txtail := rawdb.ReadTxIndexTail(bc.db); txtail == nil || *txtail < target {
+			return
+		}
+	}
+	// The genesis block is never pruned, make sure it's retained by the
+	// key-value store before dropping it from the ancients.
+	genesis := bc.genesisBlock
+	rawdb.WriteBody(bc.db, genesis.Hash(), 0, genesis.Body())
+	rawdb.WriteReceipts(bc.db, genesis.Hash(), 0, nil)
+
+	if err := bc.db.TruncateTail(target); err != nil {
+		log.Error("Failed to prune chain history", "tail", target, "err", err)
+		return
+	}
+	bc.bodyCache.Purge()
+	bc.bodyRLPCache.Purge()
+	bc.receiptsCache.Purge()
+	bc.blockCache.Purge()
+
+	log.Info("Pruned chain history", "tail", target, "elapsed", common.PrettyDuration(time.Since(start)))
 }
 
 // maintainTxIndex is responsible for the construction and deletion of the
@@ -2395,7 +2580,8 @@ func (bc *BlockChain) indexBlocks(tail *uint64, head uint64, done chan struct{})
 //
 // The user can adjust the txlookuplimit value for each launch after sync,
 // Geth will automatically construct the missing indices or delete the extra
-// indices.
+// indices. If history expiry is configured, the old bodies and receipts are
+// pruned in the same pass before updating the indices.
 func (bc *BlockChain) maintainTxIndex() {
 	defer bc.wg.Done()
 
@@ -2415,7 +2601,10 @@ func (bc *BlockChain) maintainTxIndex() {
 		case head := <-headCh:
 			if done == nil {
 				done = make(chan struct{})
-				go bc.indexBlocks(rawdb.ReadTxIndexTail(bc.db), head.Block.NumberU64(), done)
+				go func(head uint64) {
+					bc.pruneHistory(head)
+					bc.indexBlocks(rawdb.ReadTxIndexTail(bc.db), head, done)
+				}(head.Block.NumberU64())
 			}
 		case <-done:
 			done = nil
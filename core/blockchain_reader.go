@@ -320,7 +320,15 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache, bc.snaps)
+	statedb, err := state.New(root, bc.stateCache, bc.snaps)
+	if err != nil {
+		return nil, err
+	}
+	// Blocks built on top of the state (e.g. mined ones) need their diffs too
+	if bc.cacheConfig.DiffArchive {
+		statedb.RecordDiffs()
+	}
+	return statedb, nil
 }
 
 // Config retrieves the chain's fork configuration.
@@ -376,6 +384,23 @@ func (bc *BlockChain) TxLookupLimit() uint64 {
 	return bc.txLookupLimit
 }
 
+// HistoryTail returns the number of the first block whose body and receipts
+// are retained, the older ones were dropped by history expiry. The genesis
+// block is always kept.
+func (bc *BlockChain) HistoryTail() uint64 {
+	tail, err := bc.db.Tail()
+	if err != nil {
+		return 0
+	}
+	return tail
+}
+
+// HistoryPruned reports whether the body and receipts of the given block were
+// dropped by history expiry.
+func (bc *BlockChain) HistoryPruned(number uint64) bool {
+	return number > 0 && number < bc.HistoryTail()
+}
+
 // TrieDB retrieves the low level trie database used for data storage.
 func (bc *BlockChain) TrieDB() *trie.Database {
 	return bc.triedb
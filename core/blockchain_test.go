@@ -17,6 +17,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -2623,6 +2624,85 @@ func TestTransactionIndices(t *testing.T) {
 	}
 }
 
+func TestHistoryExpiry(t *testing.T) {
+	// Configure and generate a sample block chain
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(100000000000000000)
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{address: {Balance: funds}}}
+		signer  = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, receipts := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 128, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, block.header.BaseFee, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	ancientDb, _ := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	defer ancientDb.Close()
+	rawdb.WriteAncientBlocks(ancientDb, append([]*types.Block{gspec.ToBlock()}, blocks...), append([]types.Receipts{{}}, receipts...), big.NewInt(0))
+
+	// Keep the history of the last 32 blocks, index all available transactions
+	var (
+		limit  uint64
+		config = *defaultCacheConfig
+	)
+	config.HistoryRetain = 32
+	chain, err := NewBlockChain(ancientDb, &config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, &limit)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	chain.indexBlocks(rawdb.ReadTxIndexTail(ancientDb), 128, make(chan struct{}))
+	chain.pruneHistory(128)
+	chain.indexBlocks(rawdb.ReadTxIndexTail(ancientDb), 128, make(chan struct{}))
+
+	if tail := chain.HistoryTail(); tail != 97 {
+		t.Fatalf("history tail mismatch: have %d, want 97", tail)
+	}
+	if tail := rawdb.ReadTxIndexTail(ancientDb); tail == nil || *tail != 97 {
+		t.Fatalf("tx index tail mismatch: have %v, want 97", tail)
+	}
+	for i, block := range blocks {
+		number := uint64(i + 1)
+		if chain.GetHeaderByNumber(number) == nil {
+			t.Fatalf("block #%d: header missing", number)
+		}
+		pruned := number < 97
+		if have := chain.HistoryPruned(number); have != pruned {
+			t.Fatalf("block #%d: pruned mismatch: have %v, want %v", number, have, pruned)
+		}
+		if have := chain.GetBlockByNumber(number) == nil; have != pruned {
+			t.Fatalf("block #%d: body presence mismatch", number)
+		}
+		if have := chain.GetReceiptsByHash(block.Hash()) == nil; have != pruned {
+			t.Fatalf("block #%d: receipts presence mismatch", number)
+		}
+		if have := rawdb.ReadTxLookupEntry(ancientDb, block.Transactions()[0].Hash()) == nil; have != pruned {
+			t.Fatalf("block #%d: tx index presence mismatch", number)
+		}
+	}
+	if chain.GetBlockByNumber(0) == nil {
+		t.Fatal("genesis block pruned")
+	}
+	chain.Stop()
+
+	// Reopen without history expiry, the pruned blocks must not be reindexed
+	chain, err = NewBlockChain(ancientDb, nil, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, &limit)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	chain.indexBlocks(rawdb.ReadTxIndexTail(ancientDb), 128, make(chan struct{}))
+	if tail := rawdb.ReadTxIndexTail(ancientDb); tail == nil || *tail != 97 {
+		t.Fatalf("tx index tail mismatch after reopen: have %v, want 97", tail)
+	}
+	if tail := chain.HistoryTail(); tail != 97 {
+		t.Fatalf("history tail mismatch after reopen: have %d, want 97", tail)
+	}
+}
+
 func TestSkipStaleTxIndicesInSnapSync(t *testing.T) {
 	// Configure and generate a sample block chain
 	var (
@@ -4340,3 +4420,301 @@ func TestEIP3651(t *testing.T) {
 		t.Fatalf("sender balance incorrect: expected %d, got %d", expected, actual)
 	}
 }
+
+// Tests that a chain running on the path-based state scheme keeps the recent
+// states in memory, can roll the persisted state back with the state history
+// and picks up the persisted state after a restart.
+func TestPathSchemeRollback(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   GenesisAlloc{address: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 2*TriesInMemory, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{byte(i)}, big.NewInt(1000), params.TxGas, b.header.BaseFee, nil), signer, key)
+		b.AddTx(tx)
+	})
+	db, err := rawdb.NewDatabaseWithFreezer(rawdb.NewMemoryDatabase(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	config := &CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+		StateScheme:    rawdb.PathScheme,
+		StateHistory:   TriesInMemory,
+	}
+	chain, err := NewBlockChain(db, config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	// The last TriesInMemory states are held in diff layers, older ones only
+	// in the state history.
+	for _, block := range blocks {
+		have := chain.HasState(block.Root())
+		if want := block.NumberU64() >= TriesInMemory; have != want {
+			t.Fatalf("block %d: state availability mismatch: have %v, want %v", block.NumberU64(), have, want)
+		}
+	}
+	if err := chain.SetHead(TriesInMemory / 2); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	head := chain.CurrentBlock()
+	if head.Number.Uint64() != TriesInMemory/2 {
+		t.Fatalf("head mismatch: have %d, want %d", head.Number, TriesInMemory/2)
+	}
+	if !chain.HasState(head.Root) {
+		t.Fatal("rewound head state missing")
+	}
+	chain.Stop()
+
+	// Reopen the chain, the rewound head state must be available
+	chain, err = NewBlockChain(db, config, nil, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if have := chain.CurrentBlock().Number.Uint64(); have != TriesInMemory/2 {
+		t.Fatalf("head mismatch after restart: have %d, want %d", have, TriesInMemory/2)
+	}
+	if _, err := chain.State(); err != nil {
+		t.Fatalf("head state missing after restart: %v", err)
+	}
+}
+
+// Tests that the online state pruning deletes the stale states while keeping
+// the persisted and all the recent ones intact, even if blocks are imported
+// during the pruning.
+func TestOnlineStatePruning(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(params.Ether)},
+				// Stores the block number in the slot of the block number
+				contract: {Code: []byte{byte(vm.NUMBER), byte(vm.NUMBER), byte(vm.SSTORE), byte(vm.STOP)}, Balance: common.Big0},
+			},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 230, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), contract, big.NewInt(1), 100000, b.header.BaseFee, nil), signer, key)
+		b.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+
+	// Import the first part of the chain in archive mode to have all the stale
+	// states persisted.
+	archive, err := NewBlockChain(db, &CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true}, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create archive chain: %v", err)
+	}
+	if n, err := archive.InsertChain(blocks[:200]); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	archive.Stop()
+
+	config := &CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+		SnapshotLimit:  256,
+		SnapshotWait:   true,
+		PruneBloomSize: 256,
+	}
+	chain, err := NewBlockChain(db, config, nil, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks[200:220]); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", 200+n, err)
+	}
+	if err := chain.PruneState(); err != nil {
+		t.Fatalf("failed to start pruning: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks[220:]); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", 220+n, err)
+	}
+	for chain.pruner.Running() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if chain.pruner.Interrupted() {
+		t.Fatal("pruning not finished")
+	}
+	// The states older than the snapshot persistent layer are stale, except
+	// the genesis one.
+	for _, block := range blocks[:199] {
+		if chain.HasState(block.Root()) {
+			t.Fatalf("block %d: stale state not pruned", block.NumberU64())
+		}
+	}
+	check := func(root common.Hash) error {
+		statedb, err := state.New(root, chain.StateCache(), nil)
+		if err != nil {
+			return err
+		}
+		it := state.NewNodeIterator(statedb)
+		for it.Next() {
+		}
+		return it.Error
+	}
+	if err := check(chain.Genesis().Root()); err != nil {
+		t.Fatalf("genesis state damaged: %v", err)
+	}
+	for _, block := range blocks[199:] {
+		if err := check(block.Root()); err != nil {
+			t.Fatalf("block %d: state damaged: %v", block.NumberU64(), err)
+		}
+	}
+}
+
+func TestDiffArchive(t *testing.T) {
+	for _, snapshots := range []bool{false, true} {
+		testDiffArchive(t, snapshots)
+	}
+}
+
+func testDiffArchive(t *testing.T, snapshots bool) {
+	var (
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address   = crypto.PubkeyToAddress(key.PublicKey)
+		counter   = common.HexToAddress("0xc0de")
+		toggle    = common.HexToAddress("0xc0df")
+		destroyer = common.HexToAddress("0xdead")
+		gspec     = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(params.Ether)},
+				// Stores the block number in the slot of the block number
+				counter: {Code: []byte{byte(vm.NUMBER), byte(vm.NUMBER), byte(vm.SSTORE), byte(vm.STOP)}, Balance: common.Big0},
+				// Sets and clears the first slot in turns
+				toggle: {Code: []byte{byte(vm.NUMBER), byte(vm.PUSH1), 0x1, byte(vm.AND), byte(vm.PUSH1), 0x0, byte(vm.SSTORE), byte(vm.STOP)}, Balance: common.Big0},
+				// Self destructs on the first call, wiping its storage
+				destroyer: {
+					Code:    []byte{byte(vm.PUSH1), 0x0, byte(vm.SELFDESTRUCT)},
+					Storage: map[common.Hash]common.Hash{{0x1}: {0x1}},
+					Balance: common.Big1,
+				},
+			},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	_, blocks, _ := GenerateChainWithGenesis(gspec, ethash.NewFaker(), 300, func(i int, b *BlockGen) {
+		for _, to := range []common.Address{counter, toggle, common.BigToAddress(big.NewInt(int64(0x1000 + i)))} {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), to, big.NewInt(1), 100000, b.header.BaseFee, nil), signer, key)
+			b.AddTx(tx)
+		}
+		// Destroy the contract and resurrect it as a plain account later on
+		if i == 40 || i == 80 {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), destroyer, big.NewInt(1), 100000, b.header.BaseFee, nil), signer, key)
+			b.AddTx(tx)
+		}
+	})
+	archive, err := NewBlockChain(rawdb.NewMemoryDatabase(), &CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true}, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create archive chain: %v", err)
+	}
+	defer archive.Stop()
+	if n, err := archive.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into archive: %v", n, err)
+	}
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		config = &CacheConfig{
+			TrieCleanLimit:     256,
+			TrieDirtyLimit:     256,
+			TrieTimeLimit:      5 * time.Minute,
+			SnapshotWait:       true,
+			DiffArchive:        true,
+			CheckpointInterval: 32,
+		}
+	)
+	if snapshots {
+		config.SnapshotLimit = 256
+	}
+	chain, err := NewBlockChain(db, config, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	// Only the checkpoints of the old states are kept
+	if !chain.HasState(blocks[63].Root()) {
+		t.Fatalf("checkpoint state missing")
+	}
+	if chain.HasState(blocks[64].Root()) {
+		t.Fatalf("non-checkpoint state not garbage collected")
+	}
+	check := func(chain *BlockChain, block *types.Block) error {
+		have, err := chain.HistoricState(context.Background(), block.Header())
+		if err != nil {
+			return err
+		}
+		want, err := archive.StateAt(block.Root())
+		if err != nil {
+			return err
+		}
+		for _, addr := range []common.Address{address, counter, toggle, destroyer, common.BigToAddress(big.NewInt(int64(0x1000 + block.NumberU64() - 1)))} {
+			if have, want := have.GetBalance(addr), want.GetBalance(addr); have.Cmp(want) != 0 {
+				return fmt.Errorf("balance mismatch for %x: have %v, want %v", addr, have, want)
+			}
+		}
+		for _, slot := range []common.Hash{{}, {0x1}, common.BigToHash(block.Number())} {
+			for _, addr := range []common.Address{counter, toggle, destroyer} {
+				if have, want := have.GetState(addr, slot), want.GetState(addr, slot); have != want {
+					return fmt.Errorf("storage mismatch for %x at %x: have %x, want %x", addr, slot, have, want)
+				}
+			}
+		}
+		return nil
+	}
+	for _, block := range blocks {
+		if err := check(chain, block); err != nil {
+			t.Fatalf("block %d (snapshots %v): %v", block.NumberU64(), snapshots, err)
+		}
+	}
+	// The diffs survive a restart
+	chain.Stop()
+	chain, err = NewBlockChain(db, config, nil, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen chain: %v", err)
+	}
+	defer chain.Stop()
+	if err := check(chain, blocks[100]); err != nil {
+		t.Fatalf("block %d after restart: %v", blocks[100].NumberU64(), err)
+	}
+	// Reconstruction is aborted with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := chain.HistoricState(ctx, blocks[110].Header()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled reconstruction: have %v, want %v", err, context.Canceled)
+	}
+	// States further than a checkpoint interval from a stored one are missing
+	if !chain.HasState(blocks[95].Root()) {
+		t.Fatalf("checkpoint state missing")
+	}
+	rawdb.DeleteLegacyTrieNode(db, blocks[95].Root())
+	if _, err := chain.HistoricState(context.Background(), blocks[110].Header()); err == nil {
+		t.Fatalf("reconstructed state beyond the checkpoint interval")
+	}
+}
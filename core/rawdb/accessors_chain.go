@@ -444,7 +444,10 @@ func ReadBodyRLP(db ethdb.Reader, hash common.Hash, number uint64) rlp.RawValue
 		// Check if the data is in ancients
 		if isCanon(reader, number, hash) {
 			data, _ = reader.Ancient(ChainFreezerBodiesTable, number)
-			return nil
+			if len(data) > 0 {
+				return nil
+			}
+			// Pruned by history expiry, only the genesis is kept in leveldb
 		}
 		// If not, try reading from leveldb
 		data, _ = db.Get(blockBodyKey(number, hash))
@@ -587,7 +590,10 @@ func ReadReceiptsRLP(db ethdb.Reader, hash common.Hash, number uint64) rlp.RawVa
 		// Check if the data is in ancients
 		if isCanon(reader, number, hash) {
 			data, _ = reader.Ancient(ChainFreezerReceiptTable, number)
-			return nil
+			if len(data) > 0 {
+				return nil
+			}
+			// Pruned by history expiry, only the genesis is kept in leveldb
 		}
 		// If not, try reading from leveldb
 		data, _ = db.Get(blockReceiptsKey(number, hash))
@@ -674,6 +680,45 @@ func DeleteReceipts(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	}
 }
 
+// ReadStateDiffRLP retrieves the state diff of a block in RLP encoding.
+func ReadStateDiffRLP(db ethdb.Reader, hash common.Hash, number uint64) rlp.RawValue {
+	var data []byte
+	db.ReadAncients(func(reader ethdb.AncientReaderOp) error {
+		// Check if the data is in ancients
+		if isCanon(reader, number, hash) {
+			data, _ = reader.Ancient(ChainFreezerStateDiffTable, number)
+			return nil
+		}
+		// If not, try reading from leveldb
+		data, _ = db.Get(stateDiffKey(number, hash))
+		return nil
+	})
+	return data
+}
+
+// WriteStateDiffRLP stores the RLP encoded state diff of a block into the database.
+func WriteStateDiffRLP(db ethdb.KeyValueWriter, hash common.Hash, number uint64, diff rlp.RawValue) {
+	if err := db.Put(stateDiffKey(number, hash), diff); err != nil {
+		log.Crit("Failed to store block state diff", "err", err)
+	}
+}
+
+// DeleteStateDiff removes the state diff of a block.
+func DeleteStateDiff(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(stateDiffKey(number, hash)); err != nil {
+		log.Crit("Failed to delete block state diff", "err", err)
+	}
+}
+
+// appendStateDiff appends the state diff of a block to the chain freezer, unless
+// the freezer has no state diff table as the diff archive was never enabled.
+func appendStateDiff(op ethdb.AncientWriteOp, number uint64, diff rlp.RawValue) error {
+	if err := op.AppendRaw(ChainFreezerStateDiffTable, number, diff); err != nil && !errors.Is(err, errUnknownTable) {
+		return err
+	}
+	return nil
+}
+
 // storedReceiptRLP is the storage encoding of a receipt.
 // Re-definition in core/types/receipt.go.
 // TODO: Re-use the existing definition.
@@ -809,12 +854,17 @@ func writeAncientBlock(op ethdb.AncientWriteOp, block *types.Block, header *type
 	if err := op.Append(ChainFreezerDifficultyTable, num, td); err != nil {
 		return fmt.Errorf("can't append block %d total difficulty: %v", num, err)
 	}
+	// Blocks imported without execution have no state diff
+	if err := appendStateDiff(op, num, nil); err != nil {
+		return fmt.Errorf("can't append block %d state diff: %v", num, err)
+	}
 	return nil
 }
 
 // DeleteBlock removes all block data associated with a hash.
 func DeleteBlock(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	DeleteReceipts(db, hash, number)
+	DeleteStateDiff(db, hash, number)
 	DeleteHeader(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
@@ -824,6 +874,7 @@ func DeleteBlock(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 // the hash to number mapping.
 func DeleteBlockWithoutNumber(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
 	DeleteReceipts(db, hash, number)
+	DeleteStateDiff(db, hash, number)
 	deleteHeaderWithoutNumber(db, hash, number)
 	DeleteBody(db, hash, number)
 	DeleteTd(db, hash, number)
@@ -17,6 +17,8 @@
 package rawdb
 
 import (
+	"encoding/binary"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
@@ -92,3 +94,116 @@ func DeleteCode(db ethdb.KeyValueWriter, hash common.Hash) {
 		log.Crit("Failed to delete contract code", "err", err)
 	}
 }
+
+// ReadStateID retrieves the id of the persisted state with the given root, or
+// nil if the state is unknown.
+func ReadStateID(db ethdb.KeyValueReader, root common.Hash) *uint64 {
+	data, err := db.Get(stateIDKey(root))
+	if err != nil || len(data) != 8 {
+		return nil
+	}
+	id := binary.BigEndian.Uint64(data)
+	return &id
+}
+
+// WriteStateID writes the id of the persisted state with the given root.
+func WriteStateID(db ethdb.KeyValueWriter, root common.Hash, id uint64) {
+	if err := db.Put(stateIDKey(root), encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store state id", "err", err)
+	}
+}
+
+// DeleteStateID deletes the id of the persisted state with the given root.
+func DeleteStateID(db ethdb.KeyValueWriter, root common.Hash) {
+	if err := db.Delete(stateIDKey(root)); err != nil {
+		log.Crit("Failed to delete state id", "err", err)
+	}
+}
+
+// ReadPersistentStateID retrieves the id of the latest persisted state.
+func ReadPersistentStateID(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(persistentStateIDKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WritePersistentStateID stores the id of the latest persisted state.
+func WritePersistentStateID(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Put(persistentStateIDKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store the persistent state id", "err", err)
+	}
+}
+
+// ReadStateHistory retrieves the reverse diff of the state transition with the
+// given id from the state freezer. The transition with id N reverts the state
+// with id N to the one with id N-1.
+func ReadStateHistory(db ethdb.AncientReaderOp, id uint64) []byte {
+	if id == 0 {
+		return nil
+	}
+	blob, err := db.Ancient(StateFreezerHistoryTable, id-1)
+	if err != nil {
+		return nil
+	}
+	return blob
+}
+
+// WriteStateHistory appends the reverse diff of the state transition with the
+// given id to the state freezer.
+func WriteStateHistory(db ethdb.AncientWriter, id uint64, blob []byte) error {
+	_, err := db.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		return op.AppendRaw(StateFreezerHistoryTable, id-1, blob)
+	})
+	return err
+}
+
+// DeleteStateIDs removes all the state id mappings, used when the state history
+// is reset.
+func DeleteStateIDs(db ethdb.KeyValueStore) {
+	var (
+		it    = db.NewIterator(stateIDPrefix, nil)
+		batch = db.NewBatch()
+	)
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) != len(stateIDPrefix)+common.HashLength {
+			continue
+		}
+		batch.Delete(it.Key())
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete state ids", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete state ids", "err", err)
+	}
+}
+
+// ReadOnlinePruningCursor retrieves the database key an interrupted online state
+// pruning should resume from, nil if no pruning is in progress.
+func ReadOnlinePruningCursor(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(onlinePruningKey)
+	return data
+}
+
+// WriteOnlinePruningCursor stores the database key the online state pruning has
+// advanced to. The cursor must not be empty.
+func WriteOnlinePruningCursor(db ethdb.KeyValueWriter, cursor []byte) {
+	if err := db.Put(onlinePruningKey, cursor); err != nil {
+		log.Crit("Failed to store online pruning cursor", "err", err)
+	}
+}
+
+// DeleteOnlinePruningCursor deletes the online state pruning cursor, marking
+// the pruning as finished.
+func DeleteOnlinePruningCursor(db ethdb.KeyValueWriter) {
+	if err := db.Delete(onlinePruningKey); err != nil {
+		log.Crit("Failed to remove online pruning cursor", "err", err)
+	}
+}
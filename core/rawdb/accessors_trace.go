@@ -0,0 +1,83 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReadTraceResult retrieves the cached result of a tracer for the transaction
+// at the given index of a block, pinned or not. The tracer is identified by the
+// hash of its name and configuration.
+func ReadTraceResult(db ethdb.KeyValueReader, number uint64, hash common.Hash, index uint64, tracer common.Hash) []byte {
+	if data, _ := db.Get(traceResultKey(number, hash, index, tracer)); len(data) > 0 {
+		return data
+	}
+	data, _ := db.Get(pinnedTraceResultKey(number, hash, index, tracer))
+	return data
+}
+
+// WriteTraceResult stores the result of a tracer for the transaction at the
+// given index of a block. Pinned results are never removed by DeleteTraceResults.
+func WriteTraceResult(db ethdb.KeyValueWriter, number uint64, hash common.Hash, index uint64, tracer common.Hash, result []byte, pinned bool) {
+	key := traceResultKey(number, hash, index, tracer)
+	if pinned {
+		key = pinnedTraceResultKey(number, hash, index, tracer)
+	}
+	if err := db.Put(key, result); err != nil {
+		log.Crit("Failed to store trace result", "err", err)
+	}
+}
+
+// DeleteTraceResults removes the unpinned cached trace results of all blocks
+// below the given number, returning the number of deleted results.
+func DeleteTraceResults(db ethdb.KeyValueStore, limit uint64) int {
+	it := db.NewIterator(traceResultPrefix, nil)
+	defer it.Release()
+
+	var (
+		batch   = db.NewBatch()
+		deleted int
+	)
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(traceResultPrefix)+16+2*common.HashLength {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(traceResultPrefix):]) >= limit {
+			break
+		}
+		if err := batch.Delete(key); err != nil {
+			log.Crit("Failed to delete trace result", "err", err)
+		}
+		deleted++
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete trace results", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete trace results", "err", err)
+	}
+	return deleted
+}
@@ -36,7 +36,7 @@ import (
 //
 // Now this scheme is still kept for backward compatibility, and it will be used
 // for archive node and some other tries(e.g. light trie).
-const HashScheme = "hashScheme"
+const HashScheme = "hash"
 
 // PathScheme is the new path-based state scheme with which trie nodes are stored
 // in the disk with node path as the database key. This scheme will only store one
@@ -44,7 +44,7 @@ const HashScheme = "hashScheme"
 // is native. At the same time, this scheme will put adjacent trie nodes in the same
 // area of the disk with good data locality property. But this scheme needs to rely
 // on extra state diffs to survive deep reorg.
-const PathScheme = "pathScheme"
+const PathScheme = "path"
 
 // nodeHasher used to derive the hash of trie node.
 type nodeHasher struct{ sha crypto.KeccakState }
@@ -169,6 +169,19 @@ func DeleteLegacyTrieNode(db ethdb.KeyValueWriter, hash common.Hash) {
 	}
 }
 
+// ReadVerkleNode retrieves the verkle tree node with the given commitment.
+func ReadVerkleNode(db ethdb.KeyValueReader, commitment []byte) []byte {
+	data, _ := db.Get(verkleNodeKey(commitment))
+	return data
+}
+
+// WriteVerkleNode writes the provided verkle tree node to database.
+func WriteVerkleNode(db ethdb.KeyValueWriter, commitment []byte, node []byte) {
+	if err := db.Put(verkleNodeKey(commitment), node); err != nil {
+		log.Crit("Failed to store verkle node", "err", err)
+	}
+}
+
 // HasTrieNode checks the trie node presence with the provided node info and
 // the associated node hash.
 func HasTrieNode(db ethdb.KeyValueReader, owner common.Hash, path []byte, hash common.Hash, scheme string) bool {
@@ -261,3 +274,43 @@ func DeleteTrieNode(db ethdb.KeyValueWriter, owner common.Hash, path []byte, has
 		panic(fmt.Sprintf("Unknown scheme %v", scheme))
 	}
 }
+
+// ReadStateScheme retrieves the state scheme the database was initialized with,
+// or an empty string if none was recorded.
+func ReadStateScheme(db ethdb.KeyValueReader) string {
+	data, _ := db.Get(stateSchemeKey)
+	return string(data)
+}
+
+// WriteStateScheme stores the state scheme the database is initialized with.
+func WriteStateScheme(db ethdb.KeyValueWriter, scheme string) {
+	if err := db.Put(stateSchemeKey, []byte(scheme)); err != nil {
+		log.Crit("Failed to store state scheme", "err", err)
+	}
+}
+
+// ParseStateScheme checks the state scheme requested by the user against the
+// one the database was initialized with and returns the scheme to use. An
+// empty provided scheme selects the stored one, or the hash scheme for a new
+// database.
+func ParseStateScheme(provided string, disk ethdb.KeyValueReader) (string, error) {
+	if provided != "" && provided != HashScheme && provided != PathScheme {
+		return "", fmt.Errorf("unknown state scheme %q", provided)
+	}
+	stored := ReadStateScheme(disk)
+	if stored == "" && ReadHeadHeaderHash(disk) != (common.Hash{}) {
+		// Databases holding a chain without a scheme marker predate the
+		// path-based scheme and are hash based.
+		stored = HashScheme
+	}
+	switch {
+	case stored == "" && provided == "":
+		return HashScheme, nil
+	case stored == "":
+		return provided, nil
+	case provided == "" || provided == stored:
+		return stored, nil
+	default:
+		return "", fmt.Errorf("incompatible state scheme, stored: %s, provided: %s", stored, provided)
+	}
+}
@@ -16,6 +16,12 @@
 
 package rawdb
 
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
 // The list of table names of chain freezer.
 const (
 	// ChainFreezerHeaderTable indicates the name of the freezer header table.
@@ -32,6 +38,9 @@ const (
 
 	// ChainFreezerDifficultyTable indicates the name of the freezer total difficulty table.
 	ChainFreezerDifficultyTable = "diffs"
+
+	// ChainFreezerStateDiffTable indicates the name of the freezer state diff table.
+	ChainFreezerStateDiffTable = "statediffs"
 )
 
 // chainFreezerNoSnappy configures whether compression is disabled for the ancient-tables.
@@ -44,10 +53,64 @@ var chainFreezerNoSnappy = map[string]bool{
 	ChainFreezerDifficultyTable: true,
 }
 
+// chainFreezerTables returns the tables of the chain freezer in the given
+// directory. The state diff table is only part of it if the diff archive is
+// enabled, or the table exists already since it was enabled before. The hash
+// keyed state diffs don't compress well either.
+func chainFreezerTables(datadir string, stateDiffs bool) map[string]bool {
+	tables := make(map[string]bool, len(chainFreezerNoSnappy)+1)
+	for name, noSnappy := range chainFreezerNoSnappy {
+		tables[name] = noSnappy
+	}
+	if !stateDiffs {
+		_, err := os.Stat(filepath.Join(datadir, fmt.Sprintf("%s.ridx", ChainFreezerStateDiffTable)))
+		stateDiffs = err == nil
+	}
+	if stateDiffs {
+		tables[ChainFreezerStateDiffTable] = true
+	}
+	return tables
+}
+
+// freezerLateTables is the set of tables added to existing freezers later on.
+// They are filled up with empty items when missing from an existing freezer
+// instead of truncating all the other tables.
+var freezerLateTables = map[string]bool{
+	ChainFreezerStateDiffTable: true,
+}
+
+// freezerPrunableTables is the set of tables whose tail can be truncated while
+// the other tables of the same freezer keep all their items. The chain freezer
+// drops the old bodies and receipts this way but retains the headers.
+var freezerPrunableTables = map[string]bool{
+	ChainFreezerBodiesTable:  true,
+	ChainFreezerReceiptTable: true,
+}
+
+// The list of table names of state freezer.
+const (
+	// StateFreezerHistoryTable indicates the name of the freezer table holding
+	// the reverse diffs of the persisted state transitions.
+	StateFreezerHistoryTable = "history"
+)
+
+// stateFreezerNoSnappy configures whether compression is disabled for the state
+// freezer tables.
+var stateFreezerNoSnappy = map[string]bool{
+	StateFreezerHistoryTable: false,
+}
+
 // The list of identifiers of ancient stores.
 var (
 	chainFreezerName = "chain" // the folder name of chain segment ancient store.
+	stateFreezerName = "state" // the folder name of state history ancient store.
 )
 
 // freezers the collections of all builtin freezers.
-var freezers = []string{chainFreezerName}
+var freezers = []string{chainFreezerName, stateFreezerName}
+
+// NewStateFreezer initializes the freezer for the state history, located in
+// the given root ancient directory.
+func NewStateFreezer(ancientDir string, readonly bool) (*ResettableFreezer, error) {
+	return NewResettableFreezer(filepath.Join(ancientDir, stateFreezerName), "eth/db/state", readonly, freezerTableSize, stateFreezerNoSnappy)
+}
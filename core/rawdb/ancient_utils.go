@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"fmt"
+	"path/filepath"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -67,6 +68,10 @@ func inspectFreezers(db ethdb.Database) ([]freezerInfo, error) {
 				}
 				info.sizes = append(info.sizes, tableSize{name: table, size: common.StorageSize(size)})
 			}
+			// The state diffs are only stored in diff archive mode
+			if size, err := db.AncientSize(ChainFreezerStateDiffTable); err == nil {
+				info.sizes = append(info.sizes, tableSize{name: ChainFreezerStateDiffTable, size: common.StorageSize(size)})
+			}
 			// Retrieve the number of last stored item
 			ancients, err := db.Ancients()
 			if err != nil {
@@ -82,6 +87,46 @@ func inspectFreezers(db ethdb.Database) ([]freezerInfo, error) {
 			info.tail = tail
 			infos = append(infos, info)
 
+		case stateFreezerName:
+			// The state history is only present for the path-based scheme
+			// and lives in a standalone freezer.
+			datadir, err := db.AncientDatadir()
+			if err != nil {
+				return nil, err
+			}
+			if !common.FileExist(filepath.Join(datadir, stateFreezerName)) {
+				continue
+			}
+			f, err := NewStateFreezer(datadir, true)
+			if err != nil {
+				return nil, err
+			}
+			info := freezerInfo{name: freezer}
+			for table := range stateFreezerNoSnappy {
+				size, err := f.AncientSize(table)
+				if err != nil {
+					f.Close()
+					return nil, err
+				}
+				info.sizes = append(info.sizes, tableSize{name: table, size: common.StorageSize(size)})
+			}
+			ancients, err := f.Ancients()
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			tail, err := f.Tail()
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			f.Close()
+			if ancients == 0 {
+				continue
+			}
+			info.head, info.tail = ancients-1, tail
+			infos = append(infos, info)
+
 		default:
 			return nil, fmt.Errorf("unknown freezer, supported ones: %v", freezers)
 		}
@@ -100,7 +145,10 @@ func InspectFreezerTable(ancient string, freezerName string, tableName string, s
 	)
 	switch freezerName {
 	case chainFreezerName:
-		path, tables = resolveChainFreezerDir(ancient), chainFreezerNoSnappy
+		path = resolveChainFreezerDir(ancient)
+		tables = chainFreezerTables(path, false)
+	case stateFreezerName:
+		path, tables = filepath.Join(ancient, stateFreezerName), stateFreezerNoSnappy
 	default:
 		return fmt.Errorf("unknown freezer, supported ones: %v", freezers)
 	}
@@ -51,9 +51,10 @@ type chainFreezer struct {
 	trigger chan chan struct{} // Manual blocking freeze trigger, test determinism
 }
 
-// newChainFreezer initializes the freezer for ancient chain data.
-func newChainFreezer(datadir string, namespace string, readonly bool) (*chainFreezer, error) {
-	freezer, err := NewChainFreezer(datadir, namespace, readonly)
+// newChainFreezer initializes the freezer for ancient chain data, including the
+// state diffs if requested.
+func newChainFreezer(datadir string, namespace string, readonly bool, stateDiffs bool) (*chainFreezer, error) {
+	freezer, err := NewFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerTables(datadir, stateDiffs))
 	if err != nil {
 		return nil, err
 	}
@@ -276,6 +277,9 @@ func (f *chainFreezer) freezeRange(nfdb *nofreezedb, number, limit uint64) (hash
 			if len(td) == 0 {
 				return fmt.Errorf("total difficulty missing, can't freeze block %d", number)
 			}
+			// State diffs are only recorded in diff archive mode, freeze an
+			// empty item if there's none but the table exists.
+			diff := ReadStateDiffRLP(nfdb, hash, number)
 
 			// Write to the batch.
 			if err := op.AppendRaw(ChainFreezerHashTable, number, hash[:]); err != nil {
@@ -293,6 +297,9 @@ func (f *chainFreezer) freezeRange(nfdb *nofreezedb, number, limit uint64) (hash
 			if err := op.AppendRaw(ChainFreezerDifficultyTable, number, td); err != nil {
 				return fmt.Errorf("can't write td to Freezer: %v", err)
 			}
+			if err := appendStateDiff(op, number, diff); err != nil {
+				return fmt.Errorf("can't write state diff to Freezer: %v", err)
+			}
 
 			hashes = append(hashes, hash)
 		}
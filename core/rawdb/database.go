@@ -34,6 +34,10 @@ import (
 	"github.com/olekukonko/tablewriter"
 )
 
+// errNotSecondary is returned if a database not opened in secondary mode is
+// asked to catch up with its primary.
+var errNotSecondary = errors.New("not a secondary database")
+
 // freezerdb is a database wrapper that enabled freezer data retrievals.
 type freezerdb struct {
 	ancientRoot string
@@ -66,22 +70,41 @@ func (frdb *freezerdb) Close() error {
 // a freeze cycle completes, without having to sleep for a minute to trigger the
 // automatic background run.
 func (frdb *freezerdb) Freeze(threshold uint64) error {
-	if frdb.AncientStore.(*chainFreezer).readonly {
+	freezer, ok := frdb.AncientStore.(*chainFreezer)
+	if !ok || freezer.readonly {
 		return errReadOnly
 	}
 	// Set the freezer threshold to a temporary value
 	defer func(old uint64) {
-		frdb.AncientStore.(*chainFreezer).threshold.Store(old)
-	}(frdb.AncientStore.(*chainFreezer).threshold.Load())
-	frdb.AncientStore.(*chainFreezer).threshold.Store(threshold)
+		freezer.threshold.Store(old)
+	}(freezer.threshold.Load())
+	freezer.threshold.Store(threshold)
 
 	// Trigger a freeze cycle and block until it's done
 	trigger := make(chan struct{}, 1)
-	frdb.AncientStore.(*chainFreezer).trigger <- trigger
+	freezer.trigger <- trigger
 	<-trigger
 	return nil
 }
 
+// TryCatchUpWithPrimary makes the changes of the primary instance visible to a
+// database opened in secondary mode. The key-value store is refreshed first, so
+// the chain segments frozen in between are found in either of the two stores.
+func (frdb *freezerdb) TryCatchUpWithPrimary() error {
+	kvdb, ok := frdb.KeyValueStore.(secondary)
+	if !ok {
+		return errNotSecondary
+	}
+	if err := kvdb.TryCatchUpWithPrimary(); err != nil {
+		return err
+	}
+	// Remote ancient stores are immutable, nothing to catch up with
+	if frdb, ok := frdb.AncientStore.(secondary); ok {
+		return frdb.TryCatchUpWithPrimary()
+	}
+	return nil
+}
+
 // nofreezedb is a database wrapper that disables freezer data retrievals.
 type nofreezedb struct {
 	ethdb.KeyValueStore
@@ -164,6 +187,15 @@ func (db *nofreezedb) AncientDatadir() (string, error) {
 	return "", errNotSupported
 }
 
+// TryCatchUpWithPrimary makes the changes of the primary instance visible to a
+// database opened in secondary mode.
+func (db *nofreezedb) TryCatchUpWithPrimary() error {
+	if kvdb, ok := db.KeyValueStore.(secondary); ok {
+		return kvdb.TryCatchUpWithPrimary()
+	}
+	return errNotSecondary
+}
+
 // NewDatabase creates a high level database on top of a given key-value data
 // store without a freezer moving immutable chain segments into cold storage.
 func NewDatabase(db ethdb.KeyValueStore) ethdb.Database {
@@ -197,9 +229,30 @@ func resolveChainFreezerDir(ancient string) string {
 // value data store with a freezer moving immutable chain segments into cold
 // storage. The passed ancient indicates the path of root ancient directory
 // where the chain freezer can be opened.
+//
+// If the ancient is an HTTP URL, the chain freezer tables are read from there
+// by a RemoteFreezer. The remote dataset is immutable, nothing is frozen into
+// it and the newer blocks are retained by the key-value store.
 func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace string, readonly bool) (ethdb.Database, error) {
+	return newDatabaseWithFreezer(db, ancient, namespace, readonly, false)
+}
+
+// newDatabaseWithFreezer creates a high level database on top of a key-value
+// store with a freezer, creating the state diff table of the chain freezer if
+// requested.
+func newDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace string, readonly bool, stateDiffs bool) (ethdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly)
+	var (
+		frdb    ethdb.AncientStore
+		freezer *chainFreezer
+		err     error
+	)
+	if IsRemoteAncient(ancient) {
+		frdb, err = NewRemoteChainFreezer(ancient, namespace)
+	} else {
+		freezer, err = newChainFreezer(resolveChainFreezerDir(ancient), namespace, readonly, stateDiffs)
+		frdb = freezer
+	}
 	if err != nil {
 		printChainMetadata(db)
 		return nil, err
@@ -283,11 +336,11 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace st
 		}
 	}
 	// Freezer is consistent with the key-value database, permit combining the two
-	if !frdb.readonly {
-		frdb.wg.Add(1)
+	if freezer != nil && !freezer.readonly {
+		freezer.wg.Add(1)
 		go func() {
-			frdb.freeze(db)
-			frdb.wg.Done()
+			freezer.freeze(db)
+			freezer.wg.Done()
 		}()
 	}
 	return &freezerdb{
@@ -297,6 +350,45 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace st
 	}, nil
 }
 
+// NewSecondaryDatabaseWithFreezer creates a read-only high level database on top
+// of a key-value data store and a chain freezer which are both written by another,
+// primary instance. The stores are opened without locking them and aren't cross
+// validated, their consistency is maintained by the primary.
+func NewSecondaryDatabaseWithFreezer(db ethdb.KeyValueStore, ancient string, namespace string) (ethdb.Database, error) {
+	var (
+		frdb ethdb.AncientStore
+		err  error
+	)
+	if IsRemoteAncient(ancient) {
+		frdb, err = NewRemoteChainFreezer(ancient, namespace)
+	} else {
+		frdb, err = NewSecondaryFreezer(resolveChainFreezerDir(ancient), namespace, freezerTableSize, chainFreezerNoSnappy)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &freezerdb{
+		ancientRoot:   ancient,
+		KeyValueStore: db,
+		AncientStore:  frdb,
+	}, nil
+}
+
+// secondary is implemented by the stores opened in secondary mode.
+type secondary interface {
+	TryCatchUpWithPrimary() error
+}
+
+// TryCatchUpWithPrimary makes the changes of the primary instance visible to a
+// database opened in secondary mode, see OpenOptions.Secondary. Iterators and
+// snapshots created before keep on reading the previous state.
+func TryCatchUpWithPrimary(db ethdb.Database) error {
+	if db, ok := db.(secondary); ok {
+		return db.TryCatchUpWithPrimary()
+	}
+	return errNotSecondary
+}
+
 // NewMemoryDatabase creates an ephemeral in-memory key-value database without a
 // freezer moving immutable chain segments into cold storage.
 func NewMemoryDatabase() ethdb.Database {
@@ -321,6 +413,17 @@ func NewLevelDBDatabase(file string, cache int, handles int, namespace string, r
 	return NewDatabase(db), nil
 }
 
+// NewSecondaryLevelDBDatabase opens a persistent key-value database written by
+// another, primary instance in read-only secondary mode, without a freezer.
+func NewSecondaryLevelDBDatabase(file string, cache int, handles int) (ethdb.Database, error) {
+	db, err := leveldb.NewSecondary(file, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Using LevelDB as the backing database", "secondary", true)
+	return NewDatabase(db), nil
+}
+
 const (
 	dbPebble  = "pebble"
 	dbLeveldb = "leveldb"
@@ -352,6 +455,16 @@ type OpenOptions struct {
 	Cache             int    // the capacity(in megabytes) of the data caching
 	Handles           int    // number of files to be open simultaneously
 	ReadOnly          bool
+
+	// Secondary opens the databases read-only next to a primary instance which
+	// keeps on writing into them, without locking them. The databases are a
+	// point-in-time view, the changes made by the primary only become visible
+	// after calling TryCatchUpWithPrimary.
+	Secondary bool
+
+	// StateDiffs creates the freezer table of the state diffs recorded in diff
+	// archive mode. An existing table is opened regardless.
+	StateDiffs bool
 }
 
 // openKeyValueDatabase opens a disk-based key-value database, e.g. leveldb or pebble.
@@ -368,6 +481,9 @@ func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
 	if o.Type == dbPebble || existingDb == dbPebble {
 		if PebbleEnabled {
 			log.Info("Using pebble as the backing database")
+			if o.Secondary {
+				return NewSecondaryPebbleDBDatabase(o.Directory, o.Cache, o.Handles)
+			}
 			return NewPebbleDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
 		} else {
 			return nil, errors.New("db.engine 'pebble' not supported on this platform")
@@ -378,6 +494,9 @@ func openKeyValueDatabase(o OpenOptions) (ethdb.Database, error) {
 	}
 	log.Info("Using leveldb as the backing database")
 	// Use leveldb, either as default (no explicit choice), or pre-existing, or chosen explicitly
+	if o.Secondary {
+		return NewSecondaryLevelDBDatabase(o.Directory, o.Cache, o.Handles)
+	}
 	return NewLevelDBDatabase(o.Directory, o.Cache, o.Handles, o.Namespace, o.ReadOnly)
 }
 
@@ -394,7 +513,12 @@ func Open(o OpenOptions) (ethdb.Database, error) {
 	if len(o.AncientsDirectory) == 0 {
 		return kvdb, nil
 	}
-	frdb, err := NewDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly)
+	var frdb ethdb.Database
+	if o.Secondary {
+		frdb, err = NewSecondaryDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace)
+	} else {
+		frdb, err = newDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly, o.StateDiffs)
+	}
 	if err != nil {
 		kvdb.Close()
 		return nil, err
@@ -447,10 +571,15 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		headers         stat
 		bodies          stat
 		receipts        stat
+		stateDiffs      stat
 		tds             stat
 		numHashPairings stat
 		hashNumPairings stat
 		tries           stat
+		accountTries    stat
+		storageTries    stat
+		verkleNodes     stat
+		stateIDs        stat
 		codes           stat
 		txLookups       stat
 		accountSnaps    stat
@@ -459,6 +588,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		bloomBits       stat
 		beaconHeaders   stat
 		cliqueSnaps     stat
+		traceResults    stat
 
 		// Les statistic
 		chtTrieNodes   stat
@@ -485,6 +615,8 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			bodies.Add(size)
 		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == (len(blockReceiptsPrefix)+8+common.HashLength):
 			receipts.Add(size)
+		case bytes.HasPrefix(key, stateDiffPrefix) && len(key) == (len(stateDiffPrefix)+8+common.HashLength):
+			stateDiffs.Add(size)
 		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerTDSuffix):
 			tds.Add(size)
 		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerHashSuffix):
@@ -493,6 +625,14 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			hashNumPairings.Add(size)
 		case len(key) == common.HashLength:
 			tries.Add(size)
+		case bytes.HasPrefix(key, trieNodeAccountPrefix) && len(key) <= len(trieNodeAccountPrefix)+2*common.HashLength:
+			accountTries.Add(size)
+		case bytes.HasPrefix(key, trieNodeStoragePrefix) && len(key) >= len(trieNodeStoragePrefix)+common.HashLength:
+			storageTries.Add(size)
+		case bytes.HasPrefix(key, verkleNodePrefix) && len(key) == len(verkleNodePrefix)+common.HashLength:
+			verkleNodes.Add(size)
+		case bytes.HasPrefix(key, stateIDPrefix) && len(key) == len(stateIDPrefix)+common.HashLength:
+			stateIDs.Add(size)
 		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
 			codes.Add(size)
 		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
@@ -515,6 +655,10 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			beaconHeaders.Add(size)
 		case bytes.HasPrefix(key, CliqueSnapshotPrefix) && len(key) == 7+common.HashLength:
 			cliqueSnaps.Add(size)
+		case bytes.HasPrefix(key, traceResultPrefix) && len(key) == (len(traceResultPrefix)+16+2*common.HashLength):
+			traceResults.Add(size)
+		case bytes.HasPrefix(key, pinnedTraceResultPrefix) && len(key) == (len(pinnedTraceResultPrefix)+16+2*common.HashLength):
+			traceResults.Add(size)
 		case bytes.HasPrefix(key, ChtTablePrefix) ||
 			bytes.HasPrefix(key, ChtIndexTablePrefix) ||
 			bytes.HasPrefix(key, ChtPrefix): // Canonical hash trie
@@ -530,6 +674,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 				lastPivotKey, fastTrieProgressKey, snapshotDisabledKey, SnapshotRootKey, snapshotJournalKey,
 				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
+				stateSchemeKey, persistentStateIDKey, onlinePruningKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -552,6 +697,7 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Headers", headers.Size(), headers.Count()},
 		{"Key-Value store", "Bodies", bodies.Size(), bodies.Count()},
 		{"Key-Value store", "Receipt lists", receipts.Size(), receipts.Count()},
+		{"Key-Value store", "State diffs", stateDiffs.Size(), stateDiffs.Count()},
 		{"Key-Value store", "Difficulties", tds.Size(), tds.Count()},
 		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
@@ -559,11 +705,16 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
 		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
+		{"Key-Value store", "Path trie account nodes", accountTries.Size(), accountTries.Count()},
+		{"Key-Value store", "Path trie storage nodes", storageTries.Size(), storageTries.Count()},
+		{"Key-Value store", "Verkle tree nodes", verkleNodes.Size(), verkleNodes.Count()},
+		{"Key-Value store", "Path state ids", stateIDs.Size(), stateIDs.Count()},
 		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
 		{"Key-Value store", "Beacon sync headers", beaconHeaders.Size(), beaconHeaders.Count()},
 		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
+		{"Key-Value store", "Trace results", traceResults.Size(), traceResults.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
 		{"Light client", "CHT trie nodes", chtTrieNodes.Size(), chtTrieNodes.Count()},
 		{"Light client", "Bloom trie nodes", bloomTrieNodes.Size(), bloomTrieNodes.Count()},
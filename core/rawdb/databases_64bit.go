@@ -35,3 +35,13 @@ func NewPebbleDBDatabase(file string, cache int, handles int, namespace string,
 	}
 	return NewDatabase(db), nil
 }
+
+// NewSecondaryPebbleDBDatabase opens a persistent key-value database written by
+// another, primary instance in read-only secondary mode, without a freezer.
+func NewSecondaryPebbleDBDatabase(file string, cache int, handles int) (ethdb.Database, error) {
+	db, err := pebble.NewSecondary(file, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	return NewDatabase(db), nil
+}
@@ -32,3 +32,9 @@ const PebbleEnabled = false
 func NewPebbleDBDatabase(file string, cache int, handles int, namespace string, readonly bool) (ethdb.Database, error) {
 	return nil, errors.New("pebble is not supported on this platform")
 }
+
+// NewSecondaryPebbleDBDatabase opens a persistent key-value database written by
+// another, primary instance in read-only secondary mode, without a freezer.
+func NewSecondaryPebbleDBDatabase(file string, cache int, handles int) (ethdb.Database, error) {
+	return nil, errors.New("pebble is not supported on this platform")
+}
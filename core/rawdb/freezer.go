@@ -72,6 +72,7 @@ type Freezer struct {
 
 	readonly     bool
 	tables       map[string]*freezerTable // Data tables for storing everything
+	prunable     map[string]bool          // Tables truncated by TruncateTail, all of them if empty
 	instanceLock *flock.Flock             // File-system lock to prevent double opens
 	closeOnce    sync.Once
 }
@@ -79,7 +80,7 @@ type Freezer struct {
 // NewChainFreezer is a small utility method around NewFreezer that sets the
 // default parameters for the chain storage.
 func NewChainFreezer(datadir string, namespace string, readonly bool) (*Freezer, error) {
-	return NewFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerNoSnappy)
+	return NewFreezer(datadir, namespace, readonly, freezerTableSize, chainFreezerTables(datadir, false))
 }
 
 // NewFreezer creates a freezer instance for maintaining immutable ordered
@@ -88,6 +89,13 @@ func NewChainFreezer(datadir string, namespace string, readonly bool) (*Freezer,
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, readonly, false, maxTableSize, tables)
+}
+
+// newFreezer creates a freezer instance. In secondary mode the freezer is opened
+// read-only next to a primary instance writing into it, so the directory isn't
+// locked and the tables may be ahead of each other.
+func newFreezer(datadir string, namespace string, readonly bool, secondary bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
 		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
@@ -101,39 +109,56 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 			return nil, errSymlinkDatadir
 		}
 	}
-	flockFile := filepath.Join(datadir, "FLOCK")
-	if err := os.MkdirAll(filepath.Dir(flockFile), 0755); err != nil {
-		return nil, err
-	}
-	// Leveldb uses LOCK as the filelock filename. To prevent the
-	// name collision, we use FLOCK as the lock name.
-	lock := flock.New(flockFile)
-	if locked, err := lock.TryLock(); err != nil {
-		return nil, err
-	} else if !locked {
-		return nil, errors.New("locking failed")
+	var lock *flock.Flock
+	if secondary {
+		readonly = true
+	} else {
+		flockFile := filepath.Join(datadir, "FLOCK")
+		if err := os.MkdirAll(filepath.Dir(flockFile), 0755); err != nil {
+			return nil, err
+		}
+		// Leveldb uses LOCK as the filelock filename. To prevent the
+		// name collision, we use FLOCK as the lock name.
+		lock = flock.New(flockFile)
+		if locked, err := lock.TryLock(); err != nil {
+			return nil, err
+		} else if !locked {
+			return nil, errors.New("locking failed")
+		}
 	}
 	// Open all the supported data tables
 	freezer := &Freezer{
 		readonly:     readonly,
 		tables:       make(map[string]*freezerTable),
+		prunable:     make(map[string]bool),
 		instanceLock: lock,
 	}
+	for name := range tables {
+		if freezerPrunableTables[name] {
+			freezer.prunable[name] = true
+		}
+	}
 
 	// Create the tables.
 	for name, disableSnappy := range tables {
-		table, err := newTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly)
+		table, err := openTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly, secondary)
 		if err != nil {
 			for _, table := range freezer.tables {
 				table.Close()
 			}
-			lock.Unlock()
+			if lock != nil {
+				lock.Unlock()
+			}
 			return nil, err
 		}
 		freezer.tables[name] = table
 	}
 	var err error
-	if freezer.readonly {
+	if secondary {
+		// In secondary mode only read up to the boundaries the
+		// primary has already written into all the tables.
+		err = freezer.validateSecondary()
+	} else if freezer.readonly {
 		// In readonly mode only validate, don't truncate.
 		// validate also sets `freezer.frozen`.
 		err = freezer.validate()
@@ -145,14 +170,20 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 		for _, table := range freezer.tables {
 			table.Close()
 		}
-		lock.Unlock()
+		if lock != nil {
+			lock.Unlock()
+		}
 		return nil, err
 	}
 
 	// Create the write batch.
 	freezer.writeBatch = newFreezerBatch(freezer)
 
-	log.Info("Opened ancient database", "database", datadir, "readonly", readonly)
+	if secondary {
+		log.Debug("Opened ancient database", "database", datadir, "secondary", true)
+	} else {
+		log.Info("Opened ancient database", "database", datadir, "readonly", readonly)
+	}
 	return freezer, nil
 }
 
@@ -168,8 +199,10 @@ func (f *Freezer) Close() error {
 				errs = append(errs, err)
 			}
 		}
-		if err := f.instanceLock.Unlock(); err != nil {
-			errs = append(errs, err)
+		if f.instanceLock != nil {
+			if err := f.instanceLock.Unlock(); err != nil {
+				errs = append(errs, err)
+			}
 		}
 	})
 	if errs != nil {
@@ -212,7 +245,9 @@ func (f *Freezer) Ancients() (uint64, error) {
 	return f.frozen.Load(), nil
 }
 
-// Tail returns the number of first stored item in the freezer.
+// Tail returns the number of first stored item in the freezer. If the freezer
+// has prunable tables, it's the first item stored in those, the other tables
+// still hold all the items.
 func (f *Freezer) Tail() (uint64, error) {
 	return f.tail.Load(), nil
 }
@@ -294,6 +329,7 @@ func (f *Freezer) TruncateHead(items uint64) error {
 }
 
 // TruncateTail discards any recent data below the provided threshold number.
+// Only the prunable tables are truncated if the freezer has any.
 func (f *Freezer) TruncateTail(tail uint64) error {
 	if f.readonly {
 		return errReadOnly
@@ -304,7 +340,10 @@ func (f *Freezer) TruncateTail(tail uint64) error {
 	if f.tail.Load() >= tail {
 		return nil
 	}
-	for _, table := range f.tables {
+	for name, table := range f.tables {
+		if !f.truncatable(name) {
+			continue
+		}
 		if err := table.truncateTail(tail); err != nil {
 			return err
 		}
@@ -327,6 +366,12 @@ func (f *Freezer) Sync() error {
 	return nil
 }
 
+// truncatable reports whether the tail of the given table is truncated along
+// with the freezer tail.
+func (f *Freezer) truncatable(name string) bool {
+	return len(f.prunable) == 0 || f.prunable[name]
+}
+
 // validate checks that every table has the same boundary.
 // Used instead of `repair` in readonly mode.
 func (f *Freezer) validate() error {
@@ -340,6 +385,9 @@ func (f *Freezer) validate() error {
 	)
 	// Hack to get boundary of any table
 	for kind, table := range f.tables {
+		if !f.truncatable(kind) {
+			continue
+		}
 		head = table.items.Load()
 		tail = table.itemHidden.Load()
 		name = kind
@@ -350,7 +398,7 @@ func (f *Freezer) validate() error {
 		if head != table.items.Load() {
 			return fmt.Errorf("freezer tables %s and %s have differing head: %d != %d", kind, name, table.items.Load(), head)
 		}
-		if tail != table.itemHidden.Load() {
+		if f.truncatable(kind) && tail != table.itemHidden.Load() {
 			return fmt.Errorf("freezer tables %s and %s have differing tail: %d != %d", kind, name, table.itemHidden.Load(), tail)
 		}
 	}
@@ -359,26 +407,80 @@ func (f *Freezer) validate() error {
 	return nil
 }
 
+// validateSecondary sets the boundaries of a freezer opened in secondary mode
+// to the items stored in all of its tables. The primary appends to the tables
+// and truncates them one after the other, the items it's in the middle of
+// writing or deleting are hidden.
+func (f *Freezer) validateSecondary() error {
+	var (
+		head = uint64(math.MaxUint64)
+		tail = uint64(0)
+	)
+	for name, table := range f.tables {
+		items := table.items.Load()
+		if items == 0 && freezerLateTables[name] {
+			continue // not filled up by the primary yet
+		}
+		if head > items {
+			head = items
+		}
+		if f.truncatable(name) {
+			if hidden := table.itemHidden.Load(); hidden > tail {
+				tail = hidden
+			}
+		}
+	}
+	if head == math.MaxUint64 {
+		head = 0
+	}
+	if tail > head {
+		tail = head
+	}
+	f.frozen.Store(head)
+	f.tail.Store(tail)
+	return nil
+}
+
 // repair truncates all data tables to the same length.
 func (f *Freezer) repair() error {
 	var (
 		head = uint64(math.MaxUint64)
 		tail = uint64(0)
 	)
-	for _, table := range f.tables {
+	for name, table := range f.tables {
 		items := table.items.Load()
+		if items == 0 && freezerLateTables[name] {
+			continue // filled up below
+		}
 		if head > items {
 			head = items
 		}
+		if !f.truncatable(name) {
+			continue
+		}
 		hidden := table.itemHidden.Load()
 		if hidden > tail {
 			tail = hidden
 		}
 	}
-	for _, table := range f.tables {
+	if head == math.MaxUint64 {
+		head = 0
+	}
+	for name, table := range f.tables {
+		if table.items.Load() == 0 && head > 0 && freezerLateTables[name] {
+			log.Info("Filling up new freezer table", "table", name, "items", head)
+			if err := table.fill(head); err != nil {
+				return err
+			}
+		}
+	}
+	for name, table := range f.tables {
 		if err := table.truncateHead(head); err != nil {
 			return err
 		}
+		if !f.truncatable(name) {
+			continue
+		}
 		if err := table.truncateTail(tail); err != nil {
 			return err
 		}
@@ -43,12 +43,20 @@ func newFreezerBatch(f *Freezer) *freezerBatch {
 
 // Append adds an RLP-encoded item of the given kind.
 func (batch *freezerBatch) Append(kind string, num uint64, item interface{}) error {
-	return batch.tables[kind].Append(num, item)
+	table, ok := batch.tables[kind]
+	if !ok {
+		return errUnknownTable
+	}
+	return table.Append(num, item)
 }
 
 // AppendRaw adds an item of the given kind.
 func (batch *freezerBatch) AppendRaw(kind string, num uint64, item []byte) error {
-	return batch.tables[kind].AppendRaw(num, item)
+	table, ok := batch.tables[kind]
+	if !ok {
+		return errUnknownTable
+	}
+	return table.AppendRaw(num, item)
 }
 
 // reset initializes the batch.
@@ -172,6 +180,26 @@ func (batch *freezerTableBatch) appendItem(data []byte) error {
 	return batch.maybeCommit()
 }
 
+// fill appends empty items to the table until it reaches the given number of
+// items.
+func (t *freezerTable) fill(items uint64) error {
+	batch := t.newBatch()
+	for item := batch.curItem; item < items; item++ {
+		if err := batch.appendItem(nil); err != nil {
+			return err
+		}
+		if len(batch.indexBuffer) > freezerBatchBufferLimit {
+			if err := batch.commit(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := batch.commit(); err != nil {
+		return err
+	}
+	return t.Sync()
+}
+
 // maybeCommit writes the buffered data if the buffer is full enough.
 func (batch *freezerTableBatch) maybeCommit() error {
 	if len(batch.dataBuffer) > freezerBatchBufferLimit {
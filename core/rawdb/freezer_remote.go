@@ -0,0 +1,512 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+// remoteFreezerTimeout is the timeout of the HTTP requests of the remote freezer.
+const remoteFreezerTimeout = 30 * time.Second
+
+// IsRemoteAncient reports whether the given ancient location refers to a remote
+// ancient store served over HTTP instead of a local directory.
+func IsRemoteAncient(ancient string) bool {
+	return strings.HasPrefix(ancient, "http://") || strings.HasPrefix(ancient, "https://")
+}
+
+// RemoteFreezer is a read-only ancient store reading the freezer tables from
+// a static HTTP server, e.g. an object storage bucket, holding a copy of the
+// directory of a local freezer. The files are never downloaded as a whole, the
+// index entries and items are fetched lazily with range requests.
+//
+// The dataset is expected to be immutable, the boundaries of the tables are
+// only loaded when the store is opened. This permits several nodes to share one
+// ancient dataset, but none of them can freeze more blocks into it.
+type RemoteFreezer struct {
+	frozen uint64 // Number of blocks in the dataset
+	tail   uint64 // Number of the first item stored in the prunable tables
+
+	tables map[string]*remoteTable
+}
+
+// NewRemoteChainFreezer opens the chain freezer tables served at the given URL.
+func NewRemoteChainFreezer(url string, namespace string) (*RemoteFreezer, error) {
+	return NewRemoteFreezer(url, namespace, chainFreezerNoSnappy)
+}
+
+// NewRemoteFreezer opens the freezer tables served at the given URL.
+//
+// The 'tables' argument defines the data tables. If the value of a map
+// entry is true, snappy compression is disabled for the table.
+func NewRemoteFreezer(url string, namespace string, tables map[string]bool) (*RemoteFreezer, error) {
+	var (
+		client    = &http.Client{Timeout: remoteFreezerTimeout}
+		readMeter = metrics.NewRegisteredMeter(namespace+"ancient/remote/read", nil)
+		freezer   = &RemoteFreezer{tables: make(map[string]*remoteTable)}
+		prunable  = make(map[string]bool)
+	)
+	url = strings.TrimSuffix(url, "/")
+	for name, disableSnappy := range tables {
+		table, err := newRemoteTable(client, url, name, disableSnappy, readMeter)
+		if err != nil {
+			return nil, err
+		}
+		freezer.tables[name] = table
+		if freezerPrunableTables[name] {
+			prunable[name] = true
+		}
+	}
+	// The tables are aligned the same way as a local freezer is repaired,
+	// but nothing is deleted, the excess items are just not served.
+	head := uint64(math.MaxUint64)
+	for name, table := range freezer.tables {
+		if table.missing {
+			continue
+		}
+		if table.items < head {
+			head = table.items
+		}
+		if (len(prunable) == 0 || prunable[name]) && table.hidden > freezer.tail {
+			freezer.tail = table.hidden
+		}
+	}
+	if head == math.MaxUint64 {
+		head = 0
+	}
+	for name, table := range freezer.tables {
+		if table.missing {
+			table.items = head
+			continue
+		}
+		if table.items > head {
+			log.Warn("Ignoring excess items of remote freezer table", "table", name, "items", table.items, "limit", head)
+			table.items = head
+		}
+		if len(prunable) == 0 || prunable[name] {
+			table.hidden = freezer.tail
+		}
+		if table.hidden > head {
+			return nil, fmt.Errorf("remote freezer table %s has tail %d above head %d", name, table.hidden, head)
+		}
+	}
+	freezer.frozen = head
+
+	log.Info("Opened remote ancient database", "url", url, "items", head, "tail", freezer.tail)
+	return freezer, nil
+}
+
+// Close terminates the remote freezer. There are no resources held between the
+// requests, so it's a noop.
+func (f *RemoteFreezer) Close() error {
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the remote freezer.
+func (f *RemoteFreezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return number >= table.hidden && number < table.items, nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an ancient binary blob from the remote freezer.
+func (f *RemoteFreezer) Ancient(kind string, number uint64) ([]byte, error) {
+	items, err := f.AncientRange(kind, number, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index 'start'.
+// It will return
+//   - at most 'max' items,
+//   - at least 1 item (even if exceeding the maxByteSize), but will otherwise
+//     return as many items as fit into maxByteSize.
+func (f *RemoteFreezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.retrieveItems(start, count, maxBytes)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the length of the frozen items.
+func (f *RemoteFreezer) Ancients() (uint64, error) {
+	return f.frozen, nil
+}
+
+// Tail returns the number of first stored item in the freezer. If the freezer
+// has prunable tables, it's the first item stored in those, the other tables
+// still hold all the items.
+func (f *RemoteFreezer) Tail() (uint64, error) {
+	return f.tail, nil
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (f *RemoteFreezer) AncientSize(kind string) (uint64, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.size()
+	}
+	return 0, errUnknownTable
+}
+
+// ReadAncients runs the given read operation. The remote dataset is immutable,
+// so the reads are consistent without any locking.
+func (f *RemoteFreezer) ReadAncients(fn func(ethdb.AncientReaderOp) error) (err error) {
+	return fn(f)
+}
+
+// ModifyAncients is not supported, the remote freezer is read-only.
+func (f *RemoteFreezer) ModifyAncients(func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errReadOnly
+}
+
+// TruncateHead is not supported, the remote freezer is read-only.
+func (f *RemoteFreezer) TruncateHead(items uint64) error {
+	return errReadOnly
+}
+
+// TruncateTail is not supported, the remote freezer is read-only.
+func (f *RemoteFreezer) TruncateTail(tail uint64) error {
+	return errReadOnly
+}
+
+// Sync is a noop, there is nothing written to the remote freezer.
+func (f *RemoteFreezer) Sync() error {
+	return nil
+}
+
+// MigrateTable is not supported, the remote freezer is read-only.
+func (f *RemoteFreezer) MigrateTable(kind string, convert convertLegacyFn) error {
+	return errReadOnly
+}
+
+// remoteTable is a single freezer table served over HTTP. It mirrors the file
+// layout of the local freezerTable.
+type remoteTable struct {
+	items      uint64 // Number of items served by the table (including items removed from tail)
+	itemOffset uint64 // Number of items removed from the table
+	hidden     uint64 // Number of items marked as deleted
+
+	name          string
+	url           string // Base URL of the freezer directory
+	noCompression bool
+	missing       bool // Table added later on, not present in the dataset
+
+	client    *http.Client
+	index     *httpFile            // Index file of the table
+	files     map[uint32]*httpFile // Data files opened so far
+	lock      sync.Mutex           // Protects the data file cache
+	readMeter metrics.Meter
+}
+
+// newRemoteTable opens the remote freezer table with the given name, loading
+// its boundaries.
+func newRemoteTable(client *http.Client, url string, name string, noCompression bool, readMeter metrics.Meter) (*remoteTable, error) {
+	t := &remoteTable{
+		name:          name,
+		url:           url,
+		noCompression: noCompression,
+		client:        client,
+		files:         make(map[uint32]*httpFile),
+		readMeter:     readMeter,
+	}
+	idxName := fmt.Sprintf("%s.cidx", name)
+	if noCompression {
+		idxName = fmt.Sprintf("%s.ridx", name)
+	}
+	index, err := openHTTPFile(client, url+"/"+idxName)
+	if errors.Is(err, os.ErrNotExist) && freezerLateTables[name] {
+		// The table is filled up with empty items by the local freezer,
+		// serve them the same way.
+		t.missing = true
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if index.size < indexEntrySize || index.size%indexEntrySize != 0 {
+		return nil, fmt.Errorf("corrupted index file of remote freezer table %s: size %d", name, index.size)
+	}
+	t.index = index
+
+	// The first index entry carries the number of items removed from the tail
+	buffer := make([]byte, indexEntrySize)
+	if _, err := index.ReadAt(buffer, 0); err != nil {
+		return nil, err
+	}
+	var first indexEntry
+	first.unmarshalBinary(buffer)
+	t.itemOffset = uint64(first.offset)
+	t.items = t.itemOffset + uint64(index.size/indexEntrySize) - 1
+	t.hidden = t.itemOffset
+
+	// The metadata holds the number of hidden items, it's missing from
+	// legacy tables.
+	meta, err := openHTTPFile(client, url+"/"+fmt.Sprintf("%s.meta", name))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		blob := make([]byte, meta.size)
+		if _, err := meta.ReadAt(blob, 0); err != nil {
+			return nil, err
+		}
+		var m freezerTableMeta
+		if err := rlp.DecodeBytes(blob, &m); err != nil {
+			return nil, fmt.Errorf("invalid metadata of remote freezer table %s: %v", name, err)
+		}
+		if m.VirtualTail > t.hidden {
+			t.hidden = m.VirtualTail
+		}
+	}
+	return t, nil
+}
+
+// dataFile returns the data file with the given number, opening it if needed.
+func (t *remoteTable) dataFile(num uint32) (*httpFile, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if f, ok := t.files[num]; ok {
+		return f, nil
+	}
+	name := fmt.Sprintf("%s.%04d.cdat", t.name, num)
+	if t.noCompression {
+		name = fmt.Sprintf("%s.%04d.rdat", t.name, num)
+	}
+	f, err := openHTTPFile(t.client, t.url+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	t.files[num] = f
+	return f, nil
+}
+
+// getIndices returns the index entries for the given from-item, covering 'count'
+// items. The range must be checked by the caller.
+func (t *remoteTable) getIndices(from, count uint64) ([]*indexEntry, error) {
+	from = from - t.itemOffset
+	buffer := make([]byte, (count+1)*indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, int64(from*indexEntrySize)); err != nil {
+		return nil, err
+	}
+	indices := make([]*indexEntry, count+1)
+	for i := range indices {
+		indices[i] = new(indexEntry)
+		indices[i].unmarshalBinary(buffer[i*indexEntrySize:])
+	}
+	if from == 0 {
+		// The first entry carries the tail information instead of an offset,
+		// see freezerTable.getIndices.
+		indices[0].offset = 0
+		indices[0].filenum = indices[1].filenum
+	}
+	return indices, nil
+}
+
+// retrieveItems returns multiple items in sequence, starting from the index
+// 'start'. It returns at least one item, but otherwise avoids returning more
+// than maxBytes bytes.
+func (t *remoteTable) retrieveItems(start, count, maxBytes uint64) ([][]byte, error) {
+	if t.items <= start || t.hidden > start || count == 0 {
+		return nil, errOutOfBounds
+	}
+	if start+count > t.items {
+		count = t.items - start
+	}
+	if t.missing {
+		return make([][]byte, count), nil
+	}
+	indices, err := t.getIndices(start, count)
+	if err != nil {
+		return nil, err
+	}
+	// Group the items into contiguous spans of the data files, limiting the
+	// amount of data fetched by the size of the compressed items.
+	type span struct {
+		file       uint32
+		start, end uint32
+		sizes      []int
+	}
+	var (
+		spans []*span
+		total uint64
+	)
+	for i := 0; i < len(indices)-1; i++ {
+		startOffset, endOffset, file := indices[i].bounds(indices[i+1])
+		size := uint64(endOffset - startOffset)
+		if i > 0 && total+size > maxBytes {
+			break
+		}
+		total += size
+		if n := len(spans); n > 0 && spans[n-1].file == file && spans[n-1].end == startOffset {
+			spans[n-1].end = endOffset
+			spans[n-1].sizes = append(spans[n-1].sizes, int(size))
+		} else {
+			spans = append(spans, &span{file: file, start: startOffset, end: endOffset, sizes: []int{int(size)}})
+		}
+	}
+	var (
+		output     [][]byte
+		outputSize int
+	)
+	for _, s := range spans {
+		f, err := t.dataFile(s.file)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, s.end-s.start)
+		if _, err := f.ReadAt(data, int64(s.start)); err != nil {
+			return nil, err
+		}
+		t.readMeter.Mark(int64(len(data)))
+
+		for _, size := range s.sizes {
+			item := data[:size]
+			data = data[size:]
+
+			if !t.noCompression {
+				decompressedSize, _ := snappy.DecodedLen(item)
+				if len(output) > 0 && uint64(outputSize+decompressedSize) > maxBytes {
+					return output, nil
+				}
+				if item, err = snappy.Decode(nil, item); err != nil {
+					return nil, err
+				}
+			}
+			output = append(output, item)
+			outputSize += len(item)
+		}
+	}
+	return output, nil
+}
+
+// size returns the total data size of the table, fetching the size of all the
+// data files.
+func (t *remoteTable) size() (uint64, error) {
+	if t.missing {
+		return 0, nil
+	}
+	buffer := make([]byte, indexEntrySize)
+	if _, err := t.index.ReadAt(buffer, t.index.size-indexEntrySize); err != nil {
+		return 0, err
+	}
+	var (
+		first indexEntry
+		last  indexEntry
+	)
+	last.unmarshalBinary(buffer)
+	if _, err := t.index.ReadAt(buffer, 0); err != nil {
+		return 0, err
+	}
+	first.unmarshalBinary(buffer)
+
+	total := uint64(t.index.size)
+	for num := first.filenum; num <= last.filenum; num++ {
+		f, err := t.dataFile(num)
+		if err != nil {
+			return 0, err
+		}
+		total += uint64(f.size)
+	}
+	return total, nil
+}
+
+// httpFile is a read-only file served over HTTP, which is read through range
+// requests.
+type httpFile struct {
+	client *http.Client
+	url    string
+	size   int64
+}
+
+// openHTTPFile retrieves the size of the file served at the given URL. If the
+// file doesn't exist, an error wrapping os.ErrNotExist is returned.
+func openHTTPFile(client *http.Client, url string) (*httpFile, error) {
+	res, err := client.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", os.ErrNotExist, url)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to open %s: %s", url, res.Status)
+	case res.ContentLength < 0:
+		return nil, fmt.Errorf("failed to open %s: unknown size", url)
+	}
+	return &httpFile{client: client, url: url, size: res.ContentLength}, nil
+}
+
+// ReadAt implements io.ReaderAt, fetching the requested range of the file.
+func (f *httpFile) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off < 0 || off >= f.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > f.size {
+		end = f.size
+	}
+	req, err := http.NewRequest(http.MethodGet, f.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, end-1))
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Servers ignoring the range deliver the whole file, which is only
+	// usable when reading from the start.
+	if res.StatusCode != http.StatusPartialContent && (res.StatusCode != http.StatusOK || off != 0) {
+		return 0, fmt.Errorf("failed to read %s: %s", f.url, res.Status)
+	}
+	n, err := io.ReadFull(res.Body, p[:end-off])
+	if err != nil {
+		return n, err
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteFreezer(t *testing.T) {
+	// Fill up a local freezer with a small file size limit, so the items are
+	// spread over multiple data files.
+	tables := map[string]bool{ChainFreezerHeaderTable: false, ChainFreezerBodiesTable: false, ChainFreezerHashTable: true}
+	f, dir := newFreezerForTesting(t, tables)
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 100; i++ {
+			for table := range tables {
+				if err := op.AppendRaw(table, i, getChunk(100+int(i), int(i))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.TruncateTail(30))
+	require.NoError(t, f.Close())
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	remote, err := NewRemoteFreezer(srv.URL, "", tables)
+	if err != nil {
+		t.Fatalf("failed to open remote freezer: %v", err)
+	}
+	defer remote.Close()
+
+	if frozen, _ := remote.Ancients(); frozen != 100 {
+		t.Fatalf("wrong number of items: have %d, want 100", frozen)
+	}
+	if tail, _ := remote.Tail(); tail != 30 {
+		t.Fatalf("wrong tail: have %d, want 30", tail)
+	}
+	// Single items, the headers are retained below the tail
+	for i := uint64(0); i < 100; i++ {
+		want := getChunk(100+int(i), int(i))
+		for table := range tables {
+			blob, err := remote.Ancient(table, i)
+			if table == ChainFreezerBodiesTable && i < 30 {
+				if err == nil {
+					t.Fatalf("pruned item %d of %s retrieved", i, table)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("failed to retrieve item %d of %s: %v", i, table, err)
+			}
+			if !bytes.Equal(blob, want) {
+				t.Fatalf("item %d of %s mismatch: have %x, want %x", i, table, blob, want)
+			}
+		}
+	}
+	if _, err := remote.Ancient(ChainFreezerHeaderTable, 100); err == nil {
+		t.Fatal("out of bounds item retrieved")
+	}
+	// Ranges across multiple data files, limited by the item count and size
+	items, err := remote.AncientRange(ChainFreezerHashTable, 40, 20, 10000)
+	require.NoError(t, err)
+	if len(items) != 20 {
+		t.Fatalf("wrong number of items: have %d, want 20", len(items))
+	}
+	for i, item := range items {
+		if want := getChunk(140+i, 40+i); !bytes.Equal(item, want) {
+			t.Fatalf("range item %d mismatch", 40+i)
+		}
+	}
+	items, err = remote.AncientRange(ChainFreezerHeaderTable, 40, 20, 300)
+	require.NoError(t, err)
+	if len(items) != 2 {
+		t.Fatalf("wrong number of size limited items: have %d, want 2", len(items))
+	}
+	// The sizes match the files of the table
+	for table := range tables {
+		have, err := remote.AncientSize(table)
+		require.NoError(t, err)
+
+		files, _ := filepath.Glob(filepath.Join(dir, table+".*"))
+		var want uint64
+		for _, file := range files {
+			if filepath.Ext(file) == ".meta" {
+				continue
+			}
+			stat, err := os.Stat(file)
+			require.NoError(t, err)
+			want += uint64(stat.Size())
+		}
+		if have != want {
+			t.Fatalf("size mismatch of %s: have %d, want %d", table, have, want)
+		}
+	}
+	// Writes are rejected
+	if _, err := remote.ModifyAncients(func(op ethdb.AncientWriteOp) error { return nil }); err != errReadOnly {
+		t.Fatalf("write not rejected: %v", err)
+	}
+}
+
+func TestRemoteChainFreezerDatabase(t *testing.T) {
+	// Freeze a chain segment into a local ancient store
+	blocks := makeTestBlocks(10, 2)
+	receipts := make([]types.Receipts, len(blocks))
+
+	ancient := filepath.Join(t.TempDir(), "ancient")
+	db, err := NewDatabaseWithFreezer(NewMemoryDatabase(), ancient, "", false)
+	require.NoError(t, err)
+	if _, err := WriteAncientBlocks(db, blocks, receipts, big.NewInt(100)); err != nil {
+		t.Fatalf("failed to write ancient blocks: %v", err)
+	}
+	require.NoError(t, db.Close())
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(filepath.Join(ancient, chainFreezerName))))
+	defer srv.Close()
+
+	// Open the dataset remotely in place of the local freezer
+	db, err = NewDatabaseWithFreezer(memorydb.New(), srv.URL, "", false)
+	if err != nil {
+		t.Fatalf("failed to open remote ancient database: %v", err)
+	}
+	defer db.Close()
+
+	for _, block := range blocks {
+		number := block.NumberU64()
+		if hash := ReadCanonicalHash(db, number); hash != block.Hash() {
+			t.Fatalf("block #%d: canonical hash mismatch", number)
+		}
+		have := ReadBlock(db, block.Hash(), number)
+		if have == nil || have.Hash() != block.Hash() {
+			t.Fatalf("block #%d: block mismatch", number)
+		}
+		if blob := ReadStateDiffRLP(db, block.Hash(), number); len(blob) != 0 {
+			t.Fatalf("block #%d: unexpected state diff", number)
+		}
+	}
+	if err := db.(*freezerdb).Freeze(0); err != errReadOnly {
+		t.Fatalf("freezing into remote store not rejected: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+const (
+	// secondaryOpenRetries is the number of attempts made to open the freezer
+	// in secondary mode. Opening fails if the primary is in the middle of
+	// writing the data and index files of a table.
+	secondaryOpenRetries = 5
+
+	// secondaryOpenDelay is the time waited before reattempting to open the
+	// freezer in secondary mode.
+	secondaryOpenDelay = 100 * time.Millisecond
+)
+
+// SecondaryFreezer is a read-only wrapper of a freezer which is written by
+// another, primary instance. It doesn't lock the freezer directory and only
+// sees the items frozen by the primary up to the moment it was opened or last
+// caught up with it.
+type SecondaryFreezer struct {
+	freezer *Freezer
+	opener  freezerOpenFunc
+	lock    sync.RWMutex
+}
+
+// NewSecondaryFreezer opens the freezer in the given directory in secondary
+// mode, without locking it against the primary instance.
+func NewSecondaryFreezer(datadir string, namespace string, maxTableSize uint32, tables map[string]bool) (*SecondaryFreezer, error) {
+	opener := func() (*Freezer, error) {
+		var (
+			freezer *Freezer
+			err     error
+		)
+		for i := 0; i < secondaryOpenRetries; i++ {
+			if i > 0 {
+				time.Sleep(secondaryOpenDelay)
+			}
+			if freezer, err = newFreezer(datadir, namespace, true, true, maxTableSize, tables); err == nil {
+				return freezer, nil
+			}
+		}
+		return nil, err
+	}
+	freezer, err := opener()
+	if err != nil {
+		return nil, err
+	}
+	return &SecondaryFreezer{
+		freezer: freezer,
+		opener:  opener,
+	}, nil
+}
+
+// TryCatchUpWithPrimary reopens the freezer tables, making the items frozen
+// and the tail truncations done by the primary since visible.
+func (f *SecondaryFreezer) TryCatchUpWithPrimary() error {
+	freezer, err := f.opener()
+	if err != nil {
+		return err
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	old := f.freezer
+	f.freezer = freezer
+	return old.Close()
+}
+
+// Close terminates the freezer, unmapping all the data files.
+func (f *SecondaryFreezer) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.freezer.Close()
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer
+func (f *SecondaryFreezer) HasAncient(kind string, number uint64) (bool, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *SecondaryFreezer) Ancient(kind string, number uint64) ([]byte, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.Ancient(kind, number)
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index 'start'.
+// It will return
+//   - at most 'max' items,
+//   - at least 1 item (even if exceeding the maxByteSize), but will otherwise
+//     return as many items as fit into maxByteSize
+func (f *SecondaryFreezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.AncientRange(kind, start, count, maxBytes)
+}
+
+// Ancients returns the length of the frozen items.
+func (f *SecondaryFreezer) Ancients() (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.Ancients()
+}
+
+// Tail returns the number of first stored item in the freezer.
+func (f *SecondaryFreezer) Tail() (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.Tail()
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (f *SecondaryFreezer) AncientSize(kind string) (uint64, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.AncientSize(kind)
+}
+
+// ReadAncients runs the given read operation on the current view of the freezer.
+func (f *SecondaryFreezer) ReadAncients(fn func(ethdb.AncientReaderOp) error) (err error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return f.freezer.ReadAncients(fn)
+}
+
+// ModifyAncients is not supported by a secondary freezer.
+func (f *SecondaryFreezer) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (writeSize int64, err error) {
+	return 0, errReadOnly
+}
+
+// TruncateHead is not supported by a secondary freezer.
+func (f *SecondaryFreezer) TruncateHead(items uint64) error {
+	return errReadOnly
+}
+
+// TruncateTail is not supported by a secondary freezer.
+func (f *SecondaryFreezer) TruncateTail(tail uint64) error {
+	return errReadOnly
+}
+
+// Sync is a noop, a secondary freezer has nothing to flush.
+func (f *SecondaryFreezer) Sync() error {
+	return nil
+}
+
+// MigrateTable is not supported by a secondary freezer.
+func (f *SecondaryFreezer) MigrateTable(kind string, convert convertLegacyFn) error {
+	return errReadOnly
+}
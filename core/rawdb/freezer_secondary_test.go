@@ -0,0 +1,165 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecondaryFreezer(t *testing.T) {
+	tables := map[string]bool{"a": true, "b": false}
+	f, dir := newFreezerForTesting(t, tables)
+	defer f.Close()
+
+	appendItems := func(from, to uint64) {
+		_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+			for i := from; i < to; i++ {
+				for table := range tables {
+					if err := op.AppendRaw(table, i, getChunk(100, int(i))); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	appendItems(0, 10)
+
+	// Open the freezer alongside the running primary
+	secondary, err := NewSecondaryFreezer(dir, "", 2049, tables)
+	if err != nil {
+		t.Fatalf("failed to open secondary freezer: %v", err)
+	}
+	defer secondary.Close()
+
+	if frozen, _ := secondary.Ancients(); frozen != 10 {
+		t.Fatalf("wrong number of items: have %d, want 10", frozen)
+	}
+	// Items frozen by the primary are only visible after catching up
+	appendItems(10, 20)
+	if frozen, _ := secondary.Ancients(); frozen != 10 {
+		t.Fatalf("items visible before catching up: have %d, want 10", frozen)
+	}
+	require.NoError(t, secondary.TryCatchUpWithPrimary())
+	if frozen, _ := secondary.Ancients(); frozen != 20 {
+		t.Fatalf("wrong number of items after catching up: have %d, want 20", frozen)
+	}
+	for table := range tables {
+		blob, err := secondary.Ancient(table, 15)
+		require.NoError(t, err)
+		if want := getChunk(100, 15); !bytes.Equal(blob, want) {
+			t.Fatalf("item 15 of %s mismatch: have %x, want %x", table, blob, want)
+		}
+	}
+	// So are the items pruned by the primary
+	require.NoError(t, f.TruncateTail(5))
+	require.NoError(t, secondary.TryCatchUpWithPrimary())
+	if tail, _ := secondary.Tail(); tail != 5 {
+		t.Fatalf("wrong tail after catching up: have %d, want 5", tail)
+	}
+	// Items the primary is in the middle of writing are hidden, without touching
+	// the files of the primary
+	batch := f.tables["a"].newBatch()
+	require.NoError(t, batch.AppendRaw(20, getChunk(100, 20)))
+	require.NoError(t, batch.commit())
+	if _, err := f.tables["b"].head.Write([]byte{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	size, err := f.tables["b"].sizeNolock()
+	require.NoError(t, err)
+
+	require.NoError(t, secondary.TryCatchUpWithPrimary())
+	if frozen, _ := secondary.Ancients(); frozen != 20 {
+		t.Fatalf("partially written item visible: have %d items, want 20", frozen)
+	}
+	if have, _ := f.tables["b"].sizeNolock(); have != size {
+		t.Fatalf("primary table modified: have size %d, want %d", have, size)
+	}
+	// Writes are rejected
+	if _, err := secondary.ModifyAncients(func(op ethdb.AncientWriteOp) error { return nil }); err != errReadOnly {
+		t.Fatalf("write not rejected: %v", err)
+	}
+	if err := secondary.TruncateTail(10); err != errReadOnly {
+		t.Fatalf("truncation not rejected: %v", err)
+	}
+}
+
+func TestSecondaryDatabase(t *testing.T) {
+	var (
+		dir     = t.TempDir()
+		ancient = filepath.Join(dir, "ancient")
+		blocks  = makeTestBlocks(10, 2)
+	)
+	primary, err := Open(OpenOptions{Directory: dir, AncientsDirectory: ancient})
+	require.NoError(t, err)
+	defer primary.Close()
+
+	// Freeze the first blocks and keep the rest in the key-value store
+	if _, err := WriteAncientBlocks(primary, blocks[:5], make([]types.Receipts, 5), big.NewInt(100)); err != nil {
+		t.Fatalf("failed to write ancient blocks: %v", err)
+	}
+	writeBlocks := func(blocks []*types.Block) {
+		for _, block := range blocks {
+			WriteBlock(primary, block)
+			WriteCanonicalHash(primary, block.Hash(), block.NumberU64())
+		}
+	}
+	writeBlocks(blocks[5:8])
+
+	// Open the database alongside the running primary
+	db, err := Open(OpenOptions{Directory: dir, AncientsDirectory: ancient, Secondary: true})
+	if err != nil {
+		t.Fatalf("failed to open secondary database: %v", err)
+	}
+	defer db.Close()
+
+	checkBlocks := func(n int) {
+		t.Helper()
+		for i, block := range blocks {
+			have := ReadBlock(db, ReadCanonicalHash(db, block.NumberU64()), block.NumberU64())
+			if i < n && (have == nil || have.Hash() != block.Hash()) {
+				t.Fatalf("block #%d: block mismatch", block.NumberU64())
+			}
+			if i >= n && have != nil {
+				t.Fatalf("block #%d: unexpected block", block.NumberU64())
+			}
+		}
+	}
+	checkBlocks(8)
+	if err := db.Put([]byte("key"), []byte("value")); err == nil {
+		t.Fatal("write into secondary database succeeded")
+	}
+	// Blocks written by the primary are only visible after catching up
+	writeBlocks(blocks[8:])
+	checkBlocks(8)
+
+	require.NoError(t, TryCatchUpWithPrimary(db))
+	checkBlocks(10)
+
+	// Databases not opened in secondary mode can't catch up
+	if err := TryCatchUpWithPrimary(primary); err != errNotSecondary {
+		t.Fatalf("catching up primary not rejected: %v", err)
+	}
+}
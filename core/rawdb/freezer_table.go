@@ -100,6 +100,7 @@ type freezerTable struct {
 
 	noCompression bool // if true, disables snappy compression. Note: does not work retroactively
 	readonly      bool
+	secondary     bool   // if true, the table is written by another process and never repaired
 	maxFileSize   uint32 // Max file size for data-files
 	name          string
 	path          string
@@ -129,6 +130,16 @@ func newFreezerTable(path, name string, disableSnappy, readonly bool) (*freezerT
 // non-existent. Both files are truncated to the shortest common length to ensure
 // they don't go out of sync.
 func newTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly bool) (*freezerTable, error) {
+	return openTable(path, name, readMeter, writeMeter, sizeGauge, maxFilesize, noCompression, readonly, false)
+}
+
+// openTable opens a freezer table. In secondary mode the table is opened
+// read-only while another process appends to and truncates it: the items
+// it's in the middle of writing are hidden instead of truncated.
+func openTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly, secondary bool) (*freezerTable, error) {
+	if secondary {
+		readonly = true
+	}
 	// Ensure the containing directory exists and open the indexEntry file
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, err
@@ -177,6 +188,7 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 		logger:        log.New("database", path, "table", name),
 		noCompression: noCompression,
 		readonly:      readonly,
+		secondary:     secondary,
 		maxFileSize:   maxFilesize,
 	}
 	if err := tab.repair(); err != nil {
@@ -211,7 +223,7 @@ func (t *freezerTable) repair() error {
 		}
 	}
 	// Ensure the index is a multiple of indexEntrySize bytes
-	if overflow := stat.Size() % indexEntrySize; overflow != 0 {
+	if overflow := stat.Size() % indexEntrySize; overflow != 0 && !t.secondary {
 		truncateFreezerFile(t.index, stat.Size()-overflow) // New file can't trigger this path
 	}
 	// Retrieve the file sizes and prepare for truncation
@@ -219,6 +231,13 @@ func (t *freezerTable) repair() error {
 		return err
 	}
 	offsetsSize := stat.Size()
+	if t.secondary {
+		// Skip the index entry partially written by the primary
+		offsetsSize -= stat.Size() % indexEntrySize
+		if offsetsSize == 0 {
+			return errors.New("table index is not initialized yet")
+		}
+	}
 
 	// Open the head file
 	var (
@@ -270,7 +289,12 @@ func (t *freezerTable) repair() error {
 	// Keep truncating both files until they come in sync
 	contentExp = int64(lastIndex.offset)
 	for contentExp != contentSize {
-		verbose = true
+		// Ignore the data appended by the primary, which is not indexed yet
+		if t.secondary && contentExp < contentSize {
+			contentSize = contentExp
+			break
+		}
+		verbose = !t.secondary
 		// Truncate the head file to the last offset pointer
 		if contentExp < contentSize {
 			t.logger.Warn("Truncating dangling head", "indexed", contentExp, "stored", contentSize)
@@ -281,9 +305,11 @@ func (t *freezerTable) repair() error {
 		}
 		// Truncate the index to point within the head file
 		if contentExp > contentSize {
-			t.logger.Warn("Truncating dangling indexes", "indexes", offsetsSize/indexEntrySize, "indexed", contentExp, "stored", contentSize)
-			if err := truncateFreezerFile(t.index, offsetsSize-indexEntrySize); err != nil {
-				return err
+			if !t.secondary {
+				t.logger.Warn("Truncating dangling indexes", "indexes", offsetsSize/indexEntrySize, "indexed", contentExp, "stored", contentSize)
+				if err := truncateFreezerFile(t.index, offsetsSize-indexEntrySize); err != nil {
+					return err
+				}
 			}
 			offsetsSize -= indexEntrySize
 
@@ -300,7 +326,12 @@ func (t *freezerTable) repair() error {
 			if newLastIndex.filenum != lastIndex.filenum {
 				// Release earlier opened file
 				t.releaseFile(lastIndex.filenum)
-				if t.head, err = t.openFile(newLastIndex.filenum, openFreezerFileForAppend); err != nil {
+				if t.readonly {
+					t.head, err = t.openFile(newLastIndex.filenum, openFreezerFileForReadOnly)
+				} else {
+					t.head, err = t.openFile(newLastIndex.filenum, openFreezerFileForAppend)
+				}
+				if err != nil {
 					return err
 				}
 				if stat, err = t.head.Stat(); err != nil {
@@ -332,11 +363,12 @@ func (t *freezerTable) repair() error {
 	t.headBytes = contentSize
 	t.headId = lastIndex.filenum
 
-	// Delete the leftover files because of head deletion
-	t.releaseFilesAfter(t.headId, true)
+	// Delete the leftover files because of head deletion. The files of a
+	// secondary table are owned by the primary, they're only closed.
+	t.releaseFilesAfter(t.headId, !t.secondary)
 
 	// Delete the leftover files because of tail deletion
-	t.releaseFilesBefore(t.tailId, true)
+	t.releaseFilesBefore(t.tailId, !t.secondary)
 
 	// Close opened files and preopen all files
 	if err := t.preopen(); err != nil {
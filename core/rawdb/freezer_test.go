@@ -283,6 +283,134 @@ func TestFreezerReadonlyValidate(t *testing.T) {
 	}
 }
 
+func TestChainFreezerStateDiffTable(t *testing.T) {
+	dir := t.TempDir()
+
+	// The state diff table is only created on request
+	f, err := newChainFreezer(dir, "", false, false)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	if _, err := f.AncientSize(ChainFreezerStateDiffTable); !errors.Is(err, errUnknownTable) {
+		t.Fatalf("state diff table created without request: %v", err)
+	}
+	require.NoError(t, f.Close())
+
+	f, err = newChainFreezer(dir, "", false, true)
+	if err != nil {
+		t.Fatal("can't reopen freezer", err)
+	}
+	require.NoError(t, f.Close())
+
+	// An existing table is opened regardless
+	f, err = newChainFreezer(dir, "", false, false)
+	if err != nil {
+		t.Fatal("can't reopen freezer", err)
+	}
+	defer f.Close()
+	if _, err := f.AncientSize(ChainFreezerStateDiffTable); err != nil {
+		t.Fatalf("existing state diff table not opened: %v", err)
+	}
+}
+
+func TestFreezerFillLateTable(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFreezer(dir, "", false, 2049, map[string]bool{"a": true})
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	var item = make([]byte, 1024)
+	batch := f.tables["a"].newBatch()
+	for i := uint64(0); i < 5; i++ {
+		require.NoError(t, batch.AppendRaw(i, item))
+	}
+	require.NoError(t, batch.commit())
+	require.NoError(t, f.Close())
+
+	// Reopen with a table added later on, the existing data must be kept
+	tables := map[string]bool{"a": true, ChainFreezerStateDiffTable: true}
+	f, err = NewFreezer(dir, "", false, 2049, tables)
+	if err != nil {
+		t.Fatal("can't reopen freezer", err)
+	}
+	checkAncientCount(t, f, "a", 5)
+	checkAncientCount(t, f, ChainFreezerStateDiffTable, 5)
+	if blob, err := f.Ancient(ChainFreezerStateDiffTable, 2); err != nil || len(blob) != 0 {
+		t.Fatalf("unexpected filled item: %x, %v", blob, err)
+	}
+	// Both tables can be extended together
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		if err := op.AppendRaw("a", 5, item); err != nil {
+			return err
+		}
+		return op.AppendRaw(ChainFreezerStateDiffTable, 5, []byte{0x1})
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// The filled table is validated like any other in readonly mode
+	f, err = NewFreezer(dir, "", true, 2049, tables)
+	if err != nil {
+		t.Fatal("can't open readonly freezer", err)
+	}
+	defer f.Close()
+	checkAncientCount(t, f, ChainFreezerStateDiffTable, 6)
+}
+
+func TestFreezerTruncatePrunableTail(t *testing.T) {
+	dir := t.TempDir()
+	tables := map[string]bool{ChainFreezerHeaderTable: true, ChainFreezerBodiesTable: true}
+	f, err := NewFreezer(dir, "", false, 2049, tables)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	var item = make([]byte, 1024)
+	_, err = f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := uint64(0); i < 10; i++ {
+			if err := op.AppendRaw(ChainFreezerHeaderTable, i, item); err != nil {
+				return err
+			}
+			if err := op.AppendRaw(ChainFreezerBodiesTable, i, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Only the bodies are dropped, the headers are kept
+	require.NoError(t, f.TruncateTail(6))
+	if tail, _ := f.Tail(); tail != 6 {
+		t.Fatalf("wrong tail: have %d, want 6", tail)
+	}
+	checkAncientCount(t, f, ChainFreezerHeaderTable, 10)
+	if _, err := f.Ancient(ChainFreezerHeaderTable, 0); err != nil {
+		t.Fatalf("header dropped: %v", err)
+	}
+	if _, err := f.Ancient(ChainFreezerBodiesTable, 5); err == nil {
+		t.Fatal("body not dropped")
+	}
+	if _, err := f.Ancient(ChainFreezerBodiesTable, 6); err != nil {
+		t.Fatalf("body retained dropped: %v", err)
+	}
+	require.NoError(t, f.Close())
+
+	// The differing tails are accepted when reopening
+	for _, readonly := range []bool{false, true} {
+		f, err = NewFreezer(dir, "", readonly, 2049, tables)
+		if err != nil {
+			t.Fatalf("can't reopen freezer (readonly %v): %v", readonly, err)
+		}
+		if tail, _ := f.Tail(); tail != 6 {
+			t.Fatalf("wrong tail after reopen: have %d, want 6", tail)
+		}
+		if _, err := f.Ancient(ChainFreezerHeaderTable, 0); err != nil {
+			t.Fatalf("header dropped after reopen: %v", err)
+		}
+		require.NoError(t, f.Close())
+	}
+}
+
 func newFreezerForTesting(t *testing.T, tables map[string]bool) (*Freezer, string) {
 	t.Helper()
 
@@ -84,6 +84,17 @@ var (
 	// transitionStatusKey tracks the eth2 transition status.
 	transitionStatusKey = []byte("eth2-transition")
 
+	// stateSchemeKey tracks the scheme the state trie nodes are stored with.
+	stateSchemeKey = []byte("StateScheme")
+
+	// persistentStateIDKey tracks the id of the latest state persisted in the
+	// path-based node storage.
+	persistentStateIDKey = []byte("LastStateID")
+
+	// onlinePruningKey tracks the iteration position of an unfinished online
+	// state pruning.
+	onlinePruningKey = []byte("OnlinePruning")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -92,17 +103,24 @@ var (
 
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
+	stateDiffPrefix     = []byte("d") // stateDiffPrefix + num (uint64 big endian) + hash -> block state diff
 
-	txLookupPrefix        = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
-	bloomBitsPrefix       = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
-	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
-	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
-	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
-	skeletonHeaderPrefix  = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
+	txLookupPrefix          = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
+	bloomBitsPrefix         = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	SnapshotAccountPrefix   = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix   = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+	CodePrefix              = []byte("c") // CodePrefix + code hash -> account code
+	skeletonHeaderPrefix    = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
+	traceResultPrefix       = []byte("T") // traceResultPrefix + num (uint64 big endian) + hash + tx index (uint64 big endian) + tracer hash -> trace result
+	pinnedTraceResultPrefix = []byte("P") // pinnedTraceResultPrefix + num (uint64 big endian) + hash + tx index (uint64 big endian) + tracer hash -> trace result
 
 	// Path-based trie node scheme.
 	trieNodeAccountPrefix = []byte("A") // trieNodeAccountPrefix + hexPath -> trie node
 	trieNodeStoragePrefix = []byte("O") // trieNodeStoragePrefix + accountHash + hexPath -> trie node
+	stateIDPrefix         = []byte("L") // stateIDPrefix + state root -> state id
+
+	// Verkle tree node scheme.
+	verkleNodePrefix = []byte("V") // verkleNodePrefix + commitment -> verkle node
 
 	PreimagePrefix = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-")  // config prefix for the db
@@ -175,6 +193,11 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian) + hash
+func stateDiffKey(number uint64, hash common.Hash) []byte {
+	return append(append(stateDiffPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
@@ -210,6 +233,23 @@ func skeletonHeaderKey(number uint64) []byte {
 	return append(skeletonHeaderPrefix, encodeBlockNumber(number)...)
 }
 
+// traceResultKey = traceResultPrefix + num (uint64 big endian) + hash + tx index (uint64 big endian) + tracer hash
+func traceResultKey(number uint64, hash common.Hash, index uint64, tracer common.Hash) []byte {
+	key := append(append(traceResultPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+	return append(append(key, encodeBlockNumber(index)...), tracer.Bytes()...)
+}
+
+// pinnedTraceResultKey = pinnedTraceResultPrefix + num (uint64 big endian) + hash + tx index (uint64 big endian) + tracer hash
+func pinnedTraceResultKey(number uint64, hash common.Hash, index uint64, tracer common.Hash) []byte {
+	key := append(append(pinnedTraceResultPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+	return append(append(key, encodeBlockNumber(index)...), tracer.Bytes()...)
+}
+
+// stateIDKey = stateIDPrefix + root (32 bytes)
+func stateIDKey(root common.Hash) []byte {
+	return append(stateIDPrefix, root.Bytes()...)
+}
+
 // preimageKey = PreimagePrefix + hash
 func preimageKey(hash common.Hash) []byte {
 	return append(PreimagePrefix, hash.Bytes()...)
@@ -248,3 +288,8 @@ func accountTrieNodeKey(path []byte) []byte {
 func storageTrieNodeKey(accountHash common.Hash, path []byte) []byte {
 	return append(append(trieNodeStoragePrefix, accountHash.Bytes()...), path...)
 }
+
+// verkleNodeKey = verkleNodePrefix + commitment.
+func verkleNodeKey(commitment []byte) []byte {
+	return append(verkleNodePrefix, commitment...)
+}
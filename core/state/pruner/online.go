@@ -0,0 +1,437 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// onlinePruneBatch is the maximum number of trie nodes deleted by the online
+	// pruner in a single database batch.
+	onlinePruneBatch = 4096
+
+	// onlineHoldLimit is the maximum memory used by the diffs accumulated while
+	// the persistent snapshot layer is held for generating the live set. The
+	// pruning is aborted if it's exceeded.
+	onlineHoldLimit = 1024 * 1024 * 1024
+)
+
+var (
+	// ErrPruningRunning is returned if an online pruning is requested while
+	// another one is still in progress.
+	ErrPruningRunning = errors.New("state pruning already in progress")
+
+	// errPruningInterrupted is returned if the online pruning is stopped
+	// before finishing.
+	errPruningInterrupted = errors.New("state pruning interrupted")
+
+	// errPruningHoldBroken is returned if the chain progressed too much while
+	// the live set of the persistent state was generated.
+	errPruningHoldBroken = errors.New("snapshot hold exceeded memory allowance")
+)
+
+// OnlineConfig includes all the configurations for online pruning.
+type OnlineConfig struct {
+	BloomSize uint64 // The Megabytes of memory allocated to the bloom-filter of live nodes
+	Rate      uint64 // The maximum number of trie nodes deleted per second, 0 means unlimited
+}
+
+// OnlinePruner deletes the stale state in the background, while the chain keeps
+// importing blocks on top. Contrary to Pruner, it doesn't rewind the state to
+// the pruning target but keeps all the states tracked by the snapshot tree:
+//
+//   - the state of the persistent snapshot layer is regenerated from the flat
+//     snapshot data into a bloom filter, the layer is held in place meanwhile
+//   - the nodes along the paths modified by the diff layers are added on top,
+//     covering all the states tracked in memory
+//   - the nodes flushed by the trie database during the pruning are added too
+//   - the database is iterated and all trie nodes missing from the live set are
+//     deleted in rate limited batches
+//
+// The iteration position is tracked in the database. An interrupted pruning
+// has to be restarted, which collects a fresh live set and resumes deleting
+// from the stored position.
+type OnlinePruner struct {
+	config   OnlineConfig
+	db       ethdb.Database
+	triedb   *trie.Database
+	snaptree *snapshot.Tree
+
+	running atomic.Bool // Flag whether a pruning is in progress
+	live    *stateBloom // Live trie nodes of the pruning in progress
+	lock    sync.Mutex  // Lock serializing live set extensions with deletions
+
+	quit chan struct{} // Quit channel of the pruning in progress
+	wg   sync.WaitGroup
+}
+
+// NewOnlinePruner creates an online pruner for a hash-based trie database and
+// registers it for the node flushes of the database.
+func NewOnlinePruner(db ethdb.Database, triedb *trie.Database, snaptree *snapshot.Tree, config OnlineConfig) *OnlinePruner {
+	p := &OnlinePruner{
+		config:   config,
+		db:       db,
+		triedb:   triedb,
+		snaptree: snaptree,
+	}
+	triedb.SetFlushHook(p.protect)
+	return p
+}
+
+// Interrupted reports whether a previous pruning was stopped before finishing,
+// in which case it has to be started again.
+func (p *OnlinePruner) Interrupted() bool {
+	return rawdb.ReadOnlinePruningCursor(p.db) != nil
+}
+
+// Running reports whether a pruning is in progress.
+func (p *OnlinePruner) Running() bool {
+	return p.running.Load()
+}
+
+// Start collects the live trie nodes of all states tracked by the snapshot tree
+// up to the given head and launches the deletion of everything else in the
+// background.
+//
+// The recent states are collected synchronously, so this method must be called
+// while the chain is not mutating the state. As a side effect, the oldest fully
+// available recent state is persisted, to be used in case of a crash.
+func (p *OnlinePruner) Start(head common.Hash) error {
+	if p.running.Load() {
+		return ErrPruningRunning
+	}
+	// The persistent snapshot layer must be complete, it's the base of the live set
+	root := p.snaptree.DiskRoot()
+	it, err := p.snaptree.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	it.Release()
+
+	if p.snaptree.Snapshot(head) == nil {
+		return fmt.Errorf("snapshot [%#x] missing", head)
+	}
+	// Sanitize the bloom filter size if it's too small.
+	if p.config.BloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", p.config.BloomSize, "updated(MB)", 256)
+		p.config.BloomSize = 256
+	}
+	live, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	bottom, err := p.protectLayers(live, p.snaptree.Snapshots(head, -1, true), root)
+	if err != nil {
+		return err
+	}
+	if err := p.triedb.Commit(bottom, false); err != nil {
+		return err
+	}
+	if !p.Interrupted() {
+		rawdb.WriteOnlinePruningCursor(p.db, []byte{0})
+	} else {
+		log.Info("Resuming interrupted state pruning")
+	}
+	p.live, p.quit = live, make(chan struct{})
+	p.running.Store(true)
+
+	log.Info("Started online state pruning", "persisted", root, "recovery", bottom, "head", head)
+
+	release, broken := p.snaptree.Hold(onlineHoldLimit)
+
+	p.wg.Add(1)
+	go p.run(root, release, broken, p.quit)
+	return nil
+}
+
+// Stop interrupts the pruning in progress and waits until it exits. The
+// deletion is resumed by the next Start.
+func (p *OnlinePruner) Stop() {
+	if p.quit != nil {
+		close(p.quit)
+		p.quit = nil
+	}
+	p.wg.Wait()
+}
+
+// protect adds a node being flushed into the disk to the live set of the
+// pruning in progress.
+func (p *OnlinePruner) protect(hash common.Hash) {
+	if !p.running.Load() {
+		return
+	}
+	p.lock.Lock()
+	p.live.Put(hash.Bytes(), nil)
+	p.lock.Unlock()
+}
+
+// protectLayers adds the trie nodes of the states belonging to the given diff
+// layers into the live set, assuming the state of the persistent layer is added
+// separately. The layers are ordered top-down and the root of the bottom-most
+// state with all of its trie nodes available is returned.
+//
+// Every node of a state is either part of its parent state, or lies on the path
+// of a modified account or storage slot. The paths of the layers without all the
+// nodes available are added to the next available state on top.
+func (p *OnlinePruner) protectLayers(live ethdb.KeyValueWriter, layers []snapshot.Snapshot, root common.Hash) (common.Hash, error) {
+	var (
+		bottom   common.Hash
+		accounts = make(map[common.Hash]struct{})
+		storage  = make(map[common.Hash]map[common.Hash]struct{})
+	)
+	for i := len(layers) - 1; i >= 0; i-- {
+		root := layers[i].Root()
+		hashes, slots, err := p.snaptree.DiffKeys(root)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		for _, hash := range hashes {
+			accounts[hash] = struct{}{}
+		}
+		for owner, hashes := range slots {
+			if storage[owner] == nil {
+				storage[owner] = make(map[common.Hash]struct{})
+			}
+			for _, hash := range hashes {
+				storage[owner][hash] = struct{}{}
+			}
+		}
+		if err := proveState(p.triedb, root, accounts, storage, live); err != nil {
+			log.Debug("Skipping unavailable state for pruning", "root", root, "err", err)
+			continue
+		}
+		if bottom == (common.Hash{}) {
+			bottom = root
+		}
+		accounts = make(map[common.Hash]struct{})
+		storage = make(map[common.Hash]map[common.Hash]struct{})
+	}
+	// Without diff layers, the persistent state itself must be available
+	if len(layers) == 0 {
+		if _, err := trie.New(trie.StateTrieID(root), p.triedb); err != nil {
+			return common.Hash{}, err
+		}
+		bottom = root
+	}
+	if bottom == (common.Hash{}) {
+		return common.Hash{}, errors.New("no recent state available")
+	}
+	return bottom, nil
+}
+
+// proveState adds the trie nodes along the paths of the given accounts and
+// storage slots in the state with the given root into the live set.
+func proveState(triedb *trie.Database, root common.Hash, accounts map[common.Hash]struct{}, storage map[common.Hash]map[common.Hash]struct{}, live ethdb.KeyValueWriter) error {
+	tr, err := trie.New(trie.StateTrieID(root), triedb)
+	if err != nil {
+		return err
+	}
+	live.Put(root.Bytes(), nil)
+	for hash := range accounts {
+		if err := tr.Prove(hash.Bytes(), 0, live); err != nil {
+			return err
+		}
+	}
+	for owner, slots := range storage {
+		blob, err := tr.TryGet(owner.Bytes())
+		if err != nil {
+			return err
+		}
+		if len(blob) == 0 {
+			continue // Destructed account, the storage is gone
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(blob, &acc); err != nil {
+			return err
+		}
+		if acc.Root == types.EmptyRootHash {
+			continue
+		}
+		st, err := trie.New(trie.StorageTrieID(root, owner, acc.Root), triedb)
+		if err != nil {
+			return err
+		}
+		for hash := range slots {
+			if err := st.Prove(hash.Bytes(), 0, live); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// run generates the persistent state into the live set and deletes all the
+// trie nodes missing from it. The generation is aborted if the hold of the
+// persistent state is broken.
+func (p *OnlinePruner) run(root common.Hash, release func(), broken <-chan struct{}, quit chan struct{}) {
+	defer p.wg.Done()
+	defer p.running.Store(false)
+
+	abort, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		select {
+		case <-broken:
+		case <-quit:
+		case <-done:
+			return
+		}
+		close(abort)
+	}()
+	start := time.Now()
+	err := snapshot.GenerateTrieWithAbort(p.snaptree, root, p.db, p.live, abort)
+	close(done)
+	release()
+
+	select {
+	case <-broken:
+		err = errPruningHoldBroken
+	default:
+	}
+
+	if err == nil {
+		err = extractGenesis(p.db, p.live)
+	}
+	if err == nil {
+		err = p.prune(start, quit)
+	}
+	select {
+	case <-quit:
+		log.Warn("Online state pruning interrupted, restart required", "elapsed", common.PrettyDuration(time.Since(start)))
+	default:
+		if err != nil {
+			log.Error("Online state pruning failed", "err", err)
+		}
+	}
+}
+
+// prune iterates the database from the stored position and deletes the trie
+// nodes missing from the live set.
+func (p *OnlinePruner) prune(start time.Time, quit chan struct{}) error {
+	var (
+		count  int
+		items  int
+		size   common.StorageSize
+		keys   [][]byte
+		pstart = time.Now()
+		logged = time.Now()
+		iter   = p.db.NewIterator(nil, rawdb.ReadOnlinePruningCursor(p.db))
+	)
+	defer func() { iter.Release() }()
+
+	for iter.Next() {
+		if items++; items%100000 == 0 {
+			select {
+			case <-quit:
+				return errPruningInterrupted
+			default:
+			}
+		}
+		key := iter.Key()
+		if len(key) != common.HashLength {
+			continue
+		}
+		if ok, _ := p.live.Contain(key); ok {
+			continue
+		}
+		keys = append(keys, common.CopyBytes(key))
+		size += common.StorageSize(len(key) + len(iter.Value()))
+
+		if len(keys) < onlinePruneBatch {
+			continue
+		}
+		deleted, err := p.delete(keys, key)
+		if err != nil {
+			return err
+		}
+		count, keys = count+deleted, keys[:0]
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(pstart)))
+			logged = time.Now()
+		}
+		// Throttle the deletions to the configured rate
+		var wait time.Duration
+		if p.config.Rate > 0 {
+			wait = time.Duration(count)*time.Second/time.Duration(p.config.Rate) - time.Since(pstart)
+		}
+		select {
+		case <-quit:
+			return errPruningInterrupted
+		case <-time.After(wait):
+		}
+		// Recreate the iterator after every batch commit in order
+		// to allow the underlying compactor to delete the entries.
+		iter.Release()
+		iter = p.db.NewIterator(nil, key)
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	deleted, err := p.delete(keys, nil)
+	if err != nil {
+		return err
+	}
+	count += deleted
+
+	log.Info("Online state pruning successful", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// delete removes the given trie nodes unless they were added to the live set in
+// the meantime, and moves the stored position to the given key. The position is
+// dropped if the key is nil, marking the pruning as finished.
+func (p *OnlinePruner) delete(keys [][]byte, cursor []byte) (int, error) {
+	var (
+		batch  = p.db.NewBatch()
+		hashes = make([]common.Hash, 0, len(keys))
+	)
+	p.lock.Lock()
+	for _, key := range keys {
+		if ok, _ := p.live.Contain(key); ok {
+			continue // Flushed after the iteration
+		}
+		batch.Delete(key)
+		hashes = append(hashes, common.BytesToHash(key))
+	}
+	if cursor != nil {
+		rawdb.WriteOnlinePruningCursor(batch, cursor)
+	} else {
+		rawdb.DeleteOnlinePruningCursor(batch)
+	}
+	err := batch.Write()
+	p.lock.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	p.triedb.Evict(hashes)
+	return len(hashes), nil
+}
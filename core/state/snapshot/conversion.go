@@ -35,6 +35,9 @@ import (
 	"github.com/ethereum/go-ethereum/trie"
 )
 
+// errGenerateAborted is returned if the trie generation is interrupted.
+var errGenerateAborted = errors.New("trie generation aborted")
+
 // trieKV represents a trie key-value pair
 type trieKV struct {
 	key   common.Hash
@@ -65,6 +68,12 @@ func GenerateStorageTrieRoot(account common.Hash, it StorageIterator) (common.Ha
 // accounts as well as the corresponding storages and regenerate the whole state
 // (account trie + all storage tries).
 func GenerateTrie(snaptree *Tree, root common.Hash, src ethdb.Database, dst ethdb.KeyValueWriter) error {
+	return GenerateTrieWithAbort(snaptree, root, src, dst, nil)
+}
+
+// GenerateTrieWithAbort is the interruptible version of GenerateTrie, giving
+// up with errGenerateAborted once the abort channel is closed.
+func GenerateTrieWithAbort(snaptree *Tree, root common.Hash, src ethdb.Database, dst ethdb.KeyValueWriter, abort chan struct{}) error {
 	// Traverse all state by snapshot, re-generate the whole state trie
 	acctIt, err := snaptree.AccountIterator(root, common.Hash{})
 	if err != nil {
@@ -74,6 +83,11 @@ func GenerateTrie(snaptree *Tree, root common.Hash, src ethdb.Database, dst ethd
 
 	scheme := snaptree.triedb.Scheme()
 	got, err := generateTrieRoot(dst, scheme, acctIt, common.Hash{}, stackTrieGenerate, func(dst ethdb.KeyValueWriter, accountHash, codeHash common.Hash, stat *generateStats) (common.Hash, error) {
+		select {
+		case <-abort:
+			return common.Hash{}, errGenerateAborted
+		default:
+		}
 		// Migrate the code first, commit the contract code into the tmp db.
 		if codeHash != types.EmptyCodeHash {
 			code := rawdb.ReadCode(src, codeHash)
@@ -169,6 +169,7 @@ type Tree struct {
 	diskdb ethdb.KeyValueStore      // Persistent database to store the snapshot
 	triedb *trie.Database           // In-memory cache to access the trie through
 	layers map[common.Hash]snapshot // Collection of all known layers
+	holds  map[*hold]struct{}       // Holders preventing diffs from being persisted
 	lock   sync.RWMutex
 
 	// Test hooks
@@ -503,8 +504,12 @@ func (t *Tree) cap(diff *diffLayer, layers int) *diskLayer {
 	default:
 		panic(fmt.Sprintf("unknown data layer: %T", parent))
 	}
-	// If the bottom-most layer is larger than our memory cap, persist to disk
+	// If the bottom-most layer is larger than our memory cap, persist to disk,
+	// unless the disk layer is held and the holders' limits are not yet reached
 	bottom := diff.parent.(*diffLayer)
+	if t.keepHeld(bottom) {
+		return nil
+	}
 
 	bottom.lock.RLock()
 	base := diffToDisk(bottom)
@@ -655,6 +660,90 @@ func diffToDisk(bottom *diffLayer) *diskLayer {
 	return res
 }
 
+// hold is a request to keep the disk layer in place, see Tree.Hold.
+type hold struct {
+	limit  uint64        // Memory allowance of the bottom-most diff layer
+	broken chan struct{} // Closed if the allowance was exceeded
+}
+
+// Hold prevents the diff layers from being persisted into the disk layer until
+// the returned release function is called, keeping the persistent snapshot
+// data stable for long running iterations. The diffs keep accumulating in the
+// bottom-most diff layer meanwhile, until its memory usage exceeds the given
+// limit. The hold is broken at that point, the layer gets persisted and the
+// returned channel is closed to notify the holder.
+//
+// Note, a full flattening via Cap with zero layers still persists everything.
+func (t *Tree) Hold(limit uint64) (func(), <-chan struct{}) {
+	h := &hold{limit: limit, broken: make(chan struct{})}
+
+	t.lock.Lock()
+	if t.holds == nil {
+		t.holds = make(map[*hold]struct{})
+	}
+	t.holds[h] = struct{}{}
+	t.lock.Unlock()
+
+	return func() {
+		t.lock.Lock()
+		delete(t.holds, h)
+		t.lock.Unlock()
+	}, h.broken
+}
+
+// keepHeld breaks the holds whose memory allowance is exceeded by the given
+// bottom-most diff layer and reports whether any holds remain, in which case the
+// layer must not be persisted. The caller must hold the tree lock.
+func (t *Tree) keepHeld(bottom *diffLayer) bool {
+	bottom.lock.RLock()
+	memory := bottom.memory
+	bottom.lock.RUnlock()
+
+	for h := range t.holds {
+		if memory > h.limit {
+			log.Warn("Snapshot hold exceeded memory allowance", "limit", common.StorageSize(h.limit))
+			close(h.broken)
+			delete(t.holds, h)
+		}
+	}
+	return len(t.holds) > 0
+}
+
+// DiffKeys returns the hashes of the accounts and storage slots modified by the
+// diff layer belonging to the given block root, relative to its parent layer.
+// Destructed accounts are included in the account list.
+func (t *Tree) DiffKeys(root common.Hash) ([]common.Hash, map[common.Hash][]common.Hash, error) {
+	t.lock.RLock()
+	layer := t.layers[root]
+	t.lock.RUnlock()
+
+	diff, ok := layer.(*diffLayer)
+	if !ok {
+		return nil, nil, fmt.Errorf("snapshot [%#x] is not a diff layer", root)
+	}
+	diff.lock.RLock()
+	defer diff.lock.RUnlock()
+
+	accounts := make([]common.Hash, 0, len(diff.accountData)+len(diff.destructSet))
+	for hash := range diff.destructSet {
+		accounts = append(accounts, hash)
+	}
+	for hash := range diff.accountData {
+		if _, ok := diff.destructSet[hash]; !ok {
+			accounts = append(accounts, hash)
+		}
+	}
+	storage := make(map[common.Hash][]common.Hash, len(diff.storageData))
+	for account, slots := range diff.storageData {
+		hashes := make([]common.Hash, 0, len(slots))
+		for hash := range slots {
+			hashes = append(hashes, hash)
+		}
+		storage[account] = hashes
+	}
+	return accounts, storage, nil
+}
+
 // Journal commits an entire diff hierarchy to disk into a single journal entry.
 // This is meant to be used during shutdown to persist the snapshot without
 // flattening everything down (bad for reorgs).
@@ -486,3 +486,67 @@ func TestReadStateDuringFlattening(t *testing.T) {
 		t.Fatal("Unexpected blocker")
 	}
 }
+
+// Tests that held disk layers are not persisted into until the memory allowance
+// of the hold is exceeded.
+func TestHoldLimit(t *testing.T) {
+	// Create an empty base layer and a snapshot tree out of it
+	base := &diskLayer{
+		diskdb: rawdb.NewMemoryDatabase(),
+		root:   common.HexToHash("0x01"),
+		cache:  fastcache.New(1024 * 500),
+	}
+	snaps := &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+	// Create a bottom diff layer exceeding the aggregator memory limit and a
+	// small one on top of it
+	accounts := make(map[common.Hash][]byte)
+	for i := 0; i < 1024; i++ {
+		accounts[randomHash()] = make([]byte, 8192)
+	}
+	if err := snaps.Update(common.HexToHash("0x02"), common.HexToHash("0x01"), nil, accounts, nil); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, randomAccountSet("0xa1"), nil); err != nil {
+		t.Fatalf("failed to create a diff layer: %v", err)
+	}
+	// The bottom layer must be retained while it fits into the allowance
+	release, broken := snaps.Hold(16 * 1024 * 1024)
+	defer release()
+
+	if err := snaps.Cap(common.HexToHash("0x03"), 1); err != nil {
+		t.Fatalf("failed to cap snapshot tree: %v", err)
+	}
+	if n := len(snaps.layers); n != 3 {
+		t.Fatalf("held layer count mismatch: have %d, want %d", n, 3)
+	}
+	select {
+	case <-broken:
+		t.Fatal("hold broken within allowance")
+	default:
+	}
+	// Exceeding the allowance must only break the affected hold
+	_, broken = snaps.Hold(1024 * 1024)
+	if err := snaps.Cap(common.HexToHash("0x03"), 1); err != nil {
+		t.Fatalf("failed to cap snapshot tree: %v", err)
+	}
+	if n := len(snaps.layers); n != 3 {
+		t.Fatalf("held layer count mismatch: have %d, want %d", n, 3)
+	}
+	select {
+	case <-broken:
+	default:
+		t.Fatal("hold not broken beyond allowance")
+	}
+	// The layer must be persisted once no holds remain
+	release()
+	if err := snaps.Cap(common.HexToHash("0x03"), 1); err != nil {
+		t.Fatalf("failed to cap snapshot tree: %v", err)
+	}
+	if n := len(snaps.layers); n != 2 {
+		t.Fatalf("released layer count mismatch: have %d, want %d", n, 2)
+	}
+}
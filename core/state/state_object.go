@@ -134,6 +134,11 @@ func (s *stateObject) touch() {
 // be loaded.
 func (s *stateObject) getTrie(db Database) (Trie, error) {
 	if s.trie == nil {
+		// The storage slots of verkle trees live in the account trie
+		if s.db.trie.IsVerkle() {
+			s.trie = s.db.trie
+			return s.trie, nil
+		}
 		// Try fetching from prefetcher first
 		// We don't prefetch empty tries
 		if s.data.Root != types.EmptyRootHash && s.db.prefetcher != nil {
@@ -186,7 +191,7 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 		enc []byte
 		err error
 	)
-	if s.db.snap != nil {
+	if s.db.snap != nil && s.db.witness == nil {
 		start := time.Now()
 		enc, err = s.db.snap.Storage(s.addrHash, crypto.Keccak256Hash(key.Bytes()))
 		if metrics.EnabledExpensive {
@@ -309,7 +314,7 @@ func (s *stateObject) updateTrie(db Database) (Trie, error) {
 			s.db.StorageUpdated += 1
 		}
 		// If state snapshotting is active, cache the data til commit
-		if s.db.snap != nil {
+		if s.db.snapStorage != nil {
 			if storage == nil {
 				// Retrieve the old storage map, if available, create a new one otherwise
 				if storage = s.db.snapStorage[s.addrHash]; storage == nil {
@@ -330,42 +335,20 @@ func (s *stateObject) updateTrie(db Database) (Trie, error) {
 	return tr, nil
 }
 
-// UpdateRoot sets the trie root to the current root hash of. An error
-// will be returned if trie root hash is not computed correctly.
-func (s *stateObject) updateRoot(db Database) {
-	tr, err := s.updateTrie(db)
-	if err != nil {
-		return
-	}
-	// If nothing changed, don't bother with hashing anything
-	if tr == nil {
-		return
-	}
-	// Track the amount of time wasted on hashing the storage trie
-	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageHashes += time.Since(start) }(time.Now())
-	}
-	s.data.Root = tr.Hash()
+// updateRoot sets the trie root to the current root hash of the storage trie,
+// which must have been updated by updateTrie already. It's safe to be called
+// concurrently for different objects.
+func (s *stateObject) updateRoot() {
+	s.data.Root = s.trie.Hash()
 }
 
-// commitTrie submits the storage changes into the storage trie and re-computes
-// the root. Besides, all trie changes will be collected in a nodeset and returned.
-func (s *stateObject) commitTrie(db Database) (*trie.NodeSet, error) {
-	tr, err := s.updateTrie(db)
-	if err != nil {
-		return nil, err
-	}
-	// If nothing changed, don't bother with committing anything
-	if tr == nil {
-		return nil, nil
-	}
-	// Track the amount of time wasted on committing the storage trie
-	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageCommits += time.Since(start) }(time.Now())
-	}
-	root, nodes := tr.Commit(false)
+// commitTrie commits the storage trie, which must have been updated by updateTrie
+// already, and re-computes the root. All trie changes will be collected in a
+// nodeset and returned. It's safe to be called concurrently for different objects.
+func (s *stateObject) commitTrie() *trie.NodeSet {
+	root, nodes := s.trie.Commit(false)
 	s.data.Root = root
-	return nodes, nil
+	return nodes
 }
 
 // AddBalance adds amount to s's balance.
@@ -406,7 +389,11 @@ func (s *stateObject) setBalance(amount *big.Int) {
 func (s *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject := newObject(db, s.address, s.data)
 	if s.trie != nil {
-		stateObject.trie = db.db.CopyTrie(s.trie)
+		if s.trie.IsVerkle() {
+			stateObject.trie = db.trie
+		} else {
+			stateObject.trie = db.db.CopyTrie(s.trie)
+		}
 	}
 	stateObject.code = s.code
 	stateObject.dirtyStorage = s.dirtyStorage.Copy()
@@ -439,6 +426,9 @@ func (s *stateObject) Code(db Database) []byte {
 	if err != nil {
 		s.db.setError(fmt.Errorf("can't load code hash %x: %v", s.CodeHash(), err))
 	}
+	if s.db.witness != nil {
+		s.db.witness.AddCode(code)
+	}
 	s.code = code
 	return code
 }
@@ -453,6 +443,10 @@ func (s *stateObject) CodeSize(db Database) int {
 	if bytes.Equal(s.CodeHash(), types.EmptyCodeHash.Bytes()) {
 		return 0
 	}
+	// The code itself is needed by the witness, not only its size
+	if s.db.witness != nil {
+		return len(s.Code(db))
+	}
 	size, err := db.ContractCodeSize(s.addrHash, common.BytesToHash(s.CodeHash()))
 	if err != nil {
 		s.db.setError(fmt.Errorf("can't load code size %x: %v", s.CodeHash(), err))
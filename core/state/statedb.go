@@ -21,12 +21,14 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
 	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
@@ -34,8 +36,14 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/utils"
+	"golang.org/x/sync/errgroup"
 )
 
+// errVerkleSelfDestruct is returned if an account self-destructs in a verkle
+// tree, whose storage slots can't be enumerated for deletion.
+var errVerkleSelfDestruct = errors.New("self-destruct is not supported by verkle trees")
+
 type revision struct {
 	id           int
 	journalIndex int
@@ -72,6 +80,18 @@ type StateDB struct {
 	snapAccounts map[common.Hash][]byte
 	snapStorage  map[common.Hash]map[common.Hash][]byte
 
+	// If state diffs are recorded, the account and storage changes are tracked
+	// in the snapshot maps even without a snapshot, and handed out on commit.
+	diffs bool
+	diff  *StateDiff
+
+	// If a witness is recorded, all the state read is retrieved from the tries
+	// and the codes, and collected into it.
+	witness *stateless.Witness
+
+	// Maximum number of storage tries hashed and committed concurrently
+	workers int
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects         map[common.Address]*stateObject
 	stateObjectsPending  map[common.Address]struct{} // State objects finalized but not yet written to the trie
@@ -150,6 +170,7 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 		accessList:           newAccessList(),
 		transientStorage:     newTransientStorage(),
 		hasher:               crypto.NewKeccakState(),
+		workers:              runtime.NumCPU(),
 	}
 	if sdb.snaps != nil {
 		if sdb.snap = sdb.snaps.Snapshot(root); sdb.snap != nil {
@@ -160,6 +181,45 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 	return sdb, nil
 }
 
+// RecordDiffs makes the state gather the account and storage changes of the
+// subsequent commits, retrievable via StateDiff. It must be called before the
+// state is modified.
+func (s *StateDB) RecordDiffs() {
+	s.diffs = true
+	if s.snapAccounts == nil {
+		s.snapAccounts = make(map[common.Hash][]byte)
+		s.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
+}
+
+// StateDiff returns the changes made by the last commit, or nil if the state
+// diffs are not recorded.
+func (s *StateDB) StateDiff() *StateDiff {
+	return s.diff
+}
+
+// SetWitness starts recording the state accessed into the given witness. The
+// snapshot is bypassed from then on, since the trie nodes along the paths of
+// all the state read are needed.
+func (s *StateDB) SetWitness(witness *stateless.Witness) {
+	s.witness = witness
+}
+
+// Witness returns the witness the accessed state is recorded into, or nil if
+// the state is not recorded.
+func (s *StateDB) Witness() *stateless.Witness {
+	return s.witness
+}
+
+// SetWorkers sets the maximum number of storage tries hashed and committed
+// concurrently. Non-positive values default to the number of CPUs.
+func (s *StateDB) SetWorkers(workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	s.workers = workers
+}
+
 // StartPrefetcher initializes a new trie prefetcher to pull in nodes from the
 // state trie concurrently while the state is mutated so that when we reach the
 // commit phase, most of the needed data is already hot.
@@ -168,7 +228,10 @@ func (s *StateDB) StartPrefetcher(namespace string) {
 		s.prefetcher.close()
 		s.prefetcher = nil
 	}
-	if s.snap != nil {
+	// The storage tries of verkle trees are the account trie itself, which can't
+	// be swapped for a prefetched copy. The prefetched tries don't record the
+	// nodes accessed by the statedb either.
+	if s.snap != nil && !s.trie.IsVerkle() && s.witness == nil {
 		s.prefetcher = newTriePrefetcher(s.db, s.originalRoot, namespace)
 	}
 }
@@ -323,8 +386,16 @@ func (s *StateDB) GetState(addr common.Address, hash common.Hash) common.Hash {
 	return common.Hash{}
 }
 
-// GetProof returns the Merkle proof for a given account.
+// GetProof returns the Merkle proof for a given account. On verkle trees it's
+// a single multiproof of all the account header leaves instead.
 func (s *StateDB) GetProof(addr common.Address) ([][]byte, error) {
+	if tr, ok := s.trie.(*trie.VerkleTrie); ok {
+		proof, err := tr.ProveAccount(addr)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{proof}, nil
+	}
 	return s.GetProofByHash(crypto.Keccak256Hash(addr.Bytes()))
 }
 
@@ -344,8 +415,13 @@ func (s *StateDB) GetStorageProof(a common.Address, key common.Hash) ([][]byte,
 	if trie == nil {
 		return nil, errors.New("storage trie for requested address does not exist")
 	}
+	// Verkle proofs are keyed by the tree key of the slot instead of its hash
+	proofKey := crypto.Keccak256(key.Bytes())
+	if trie.IsVerkle() {
+		proofKey = utils.GetTreeKeyStorageSlot(a[:], key[:])
+	}
 	var proof proofList
-	err = trie.Prove(crypto.Keccak256(key.Bytes()), 0, &proof)
+	err = trie.Prove(proofKey, 0, &proof)
 	if err != nil {
 		return nil, err
 	}
@@ -515,12 +591,17 @@ func (s *StateDB) updateStateObject(obj *stateObject) {
 	if err := s.trie.UpdateAccount(addr, &obj.data); err != nil {
 		s.setError(fmt.Errorf("updateStateObject (%x) error: %v", addr[:], err))
 	}
+	if obj.code != nil && obj.dirtyCode {
+		if err := s.trie.UpdateContractCode(addr, common.BytesToHash(obj.CodeHash()), obj.code); err != nil {
+			s.setError(fmt.Errorf("updateStateObject (%x) error: %v", addr[:], err))
+		}
+	}
 
 	// If state snapshotting is active, cache the data til commit. Note, this
 	// update mechanism is not symmetric to the deletion, because whereas it is
 	// enough to track account updates at commit time, deletions need tracking
 	// at transaction boundary level to ensure we capture state clearing.
-	if s.snap != nil {
+	if s.snapAccounts != nil {
 		s.snapAccounts[obj.addrHash] = snapshot.SlimAccountRLP(obj.data.Nonce, obj.data.Balance, obj.data.Root, obj.data.CodeHash)
 	}
 }
@@ -531,8 +612,13 @@ func (s *StateDB) deleteStateObject(obj *stateObject) {
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.AccountUpdates += time.Since(start) }(time.Now())
 	}
-	// Delete the account from the trie
+	// Delete the account from the trie. The storage of verkle accounts can't be
+	// wiped, so they must not self-destruct.
 	addr := obj.Address()
+	if obj.suicided && s.trie.IsVerkle() {
+		s.setError(fmt.Errorf("deleteStateObject (%x) error: %w", addr[:], errVerkleSelfDestruct))
+		return
+	}
 	if err := s.trie.DeleteAccount(addr); err != nil {
 		s.setError(fmt.Errorf("deleteStateObject (%x) error: %v", addr[:], err))
 	}
@@ -559,7 +645,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	}
 	// If no live objects are available, attempt to use snapshots
 	var data *types.StateAccount
-	if s.snap != nil {
+	if s.snap != nil && s.witness == nil {
 		start := time.Now()
 		acc, err := s.snap.Account(crypto.HashData(s.hasher, addr.Bytes()))
 		if metrics.EnabledExpensive {
@@ -637,6 +723,12 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 		s.journal.append(resetObjectChange{prev: prev, prevdestruct: prevdestruct})
 	}
 	s.setStateObject(newobj)
+
+	// The storage trie of the overwritten account is dropped, retain the nodes
+	// resolved from it until now
+	if s.witness != nil && prev != nil && prev.trie != nil {
+		s.witness.AddState(prev.trie.Witness())
+	}
 	if prev != nil && !prev.deleted {
 		return newobj, prev
 	}
@@ -701,6 +793,7 @@ func (s *StateDB) Copy() *StateDB {
 		db:                   s.db,
 		trie:                 s.db.CopyTrie(s.trie),
 		originalRoot:         s.originalRoot,
+		workers:              s.workers,
 		stateObjects:         make(map[common.Address]*stateObject, len(s.journal.dirties)),
 		stateObjectsPending:  make(map[common.Address]struct{}, len(s.stateObjectsPending)),
 		stateObjectsDirty:    make(map[common.Address]struct{}, len(s.journal.dirties)),
@@ -781,7 +874,9 @@ func (s *StateDB) Copy() *StateDB {
 		// and force the miner to operate trie-backed only
 		state.snaps = s.snaps
 		state.snap = s.snap
-
+	}
+	state.diffs = s.diffs
+	if s.snapAccounts != nil {
 		// deep copy needed
 		state.snapAccounts = make(map[common.Hash][]byte)
 		for k, v := range s.snapAccounts {
@@ -855,7 +950,7 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 			// Note, we can't do this only at the end of a block because multiple
 			// transactions within the same block might self destruct and then
 			// resurrect an account; but the snapshotter needs both events.
-			if s.snap != nil {
+			if s.snapAccounts != nil {
 				delete(s.snapAccounts, obj.addrHash) // Clear out any previously updated account data (may be recreated via a resurrect)
 				delete(s.snapStorage, obj.addrHash)  // Clear out any previously updated storage data (may be recreated via a resurrect)
 			}
@@ -903,11 +998,18 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	// the account prefetcher. Instead, let's process all the storage updates
 	// first, giving the account prefetches just a few more milliseconds of time
 	// to pull useful data from disk.
+	//
+	// The storage tries are independent of each other, so after inserting the
+	// changes they are hashed concurrently.
+	var updated []*stateObject
 	for addr := range s.stateObjectsPending {
 		if obj := s.stateObjects[addr]; !obj.deleted {
-			obj.updateRoot(s.db)
+			if tr, err := obj.updateTrie(s.db); err == nil && tr != nil && !tr.IsVerkle() {
+				updated = append(updated, obj)
+			}
 		}
 	}
+	s.hashStorageTries(updated)
 	// Now we're about to start to write changes to the trie. The trie is so far
 	// _untouched_. We can check with the prefetcher, if it can give us a trie
 	// which has the same root, but also has some content loaded into it.
@@ -937,7 +1039,62 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	if metrics.EnabledExpensive {
 		defer func(start time.Time) { s.AccountHashes += time.Since(start) }(time.Now())
 	}
-	return s.trie.Hash()
+	root := s.trie.Hash()
+
+	// Collect the nodes resolved by all the tries, including the ones needed
+	// for hashing the updated tries, into the witness
+	if s.witness != nil {
+		s.witness.AddState(s.trie.Witness())
+		for _, obj := range s.stateObjects {
+			if obj.trie != nil {
+				s.witness.AddState(obj.trie.Witness())
+			}
+		}
+	}
+	return root
+}
+
+// hashStorageTries recomputes the storage roots of the given objects, whose
+// tries are already updated, on at most s.workers goroutines.
+func (s *StateDB) hashStorageTries(objs []*stateObject) {
+	// Track the amount of time wasted on hashing the storage tries
+	if metrics.EnabledExpensive {
+		defer func(start time.Time) { s.StorageHashes += time.Since(start) }(time.Now())
+	}
+	var workers errgroup.Group
+	workers.SetLimit(s.workers)
+	for _, obj := range objs {
+		obj := obj
+		workers.Go(func() error {
+			obj.updateRoot()
+			return nil
+		})
+	}
+	workers.Wait()
+}
+
+// commitStorageTries commits the storage tries of the given objects, which are
+// already updated, on at most s.workers goroutines. The dirty nodes of the tries
+// are returned in the order of the objects.
+func (s *StateDB) commitStorageTries(objs []*stateObject) []*trie.NodeSet {
+	// Track the amount of time wasted on committing the storage tries
+	if metrics.EnabledExpensive {
+		defer func(start time.Time) { s.StorageCommits += time.Since(start) }(time.Now())
+	}
+	var (
+		sets    = make([]*trie.NodeSet, len(objs))
+		workers errgroup.Group
+	)
+	workers.SetLimit(s.workers)
+	for i, obj := range objs {
+		i, obj := i, obj
+		workers.Go(func() error {
+			sets[i] = obj.commitTrie()
+			return nil
+		})
+	}
+	workers.Wait()
+	return sets
 }
 
 // SetTxContext sets the current transaction hash and index which are
@@ -964,7 +1121,9 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	}
 	// Finalize any pending changes and merge everything into the tries
 	s.IntermediateRoot(deleteEmptyObjects)
-
+	if s.dbErr != nil {
+		return common.Hash{}, fmt.Errorf("commit aborted due to database error: %w", s.dbErr)
+	}
 	// Commit objects to the trie, measuring the elapsed time
 	var (
 		accountTrieNodesUpdated int
@@ -974,6 +1133,7 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		nodes                   = trie.NewMergedNodeSet()
 		codeWriter              = s.db.DiskDB().NewBatch()
 	)
+	var committed []*stateObject
 	for addr := range s.stateObjectsDirty {
 		if obj := s.stateObjects[addr]; !obj.deleted {
 			// Write any contract code associated with the state object
@@ -982,18 +1142,12 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 				obj.dirtyCode = false
 			}
 			// Write any storage changes in the state object to its storage trie
-			set, err := obj.commitTrie(s.db)
+			tr, err := obj.updateTrie(s.db)
 			if err != nil {
 				return common.Hash{}, err
 			}
-			// Merge the dirty nodes of storage trie into global set
-			if set != nil {
-				if err := nodes.Merge(set); err != nil {
-					return common.Hash{}, err
-				}
-				updates, deleted := set.Size()
-				storageTrieNodesUpdated += updates
-				storageTrieNodesDeleted += deleted
+			if tr != nil && !tr.IsVerkle() {
+				committed = append(committed, obj)
 			}
 		}
 		// If the contract is destructed, the storage is still left in the
@@ -1006,6 +1160,19 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	if len(s.stateObjectsDirty) > 0 {
 		s.stateObjectsDirty = make(map[common.Address]struct{})
 	}
+	// Commit the storage tries concurrently and merge the dirty nodes of them
+	// into the global set
+	for _, set := range s.commitStorageTries(committed) {
+		if set == nil {
+			continue
+		}
+		if err := nodes.Merge(set); err != nil {
+			return common.Hash{}, err
+		}
+		updates, deleted := set.Size()
+		storageTrieNodesUpdated += updates
+		storageTrieNodesDeleted += deleted
+	}
 	if codeWriter.ValueSize() > 0 {
 		if err := codeWriter.Write(); err != nil {
 			log.Crit("Failed to commit dirty codes", "error", err)
@@ -1038,6 +1205,11 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		s.AccountUpdated, s.AccountDeleted = 0, 0
 		s.StorageUpdated, s.StorageDeleted = 0, 0
 	}
+	// If state diffs are recorded, gather the changes before the snapshot tree
+	// takes ownership of them
+	if s.diffs {
+		s.diff = newStateDiff(s.convertAccountSet(s.stateObjectsDestruct), s.snapAccounts, s.snapStorage)
+	}
 	// If snapshotting is enabled, update the snapshot tree with this new version
 	if s.snap != nil {
 		start := time.Now()
@@ -1059,6 +1231,10 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		}
 		s.snap, s.snapAccounts, s.snapStorage = nil, nil, nil
 	}
+	if s.diffs {
+		s.snapAccounts = make(map[common.Hash][]byte)
+		s.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 	if len(s.stateObjectsDestruct) > 0 {
 		s.stateObjectsDestruct = make(map[common.Address]struct{})
 	}
@@ -1071,7 +1247,7 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	}
 	if root != origin {
 		start := time.Now()
-		if err := s.db.TrieDB().Update(nodes); err != nil {
+		if err := s.db.TrieDB().UpdateState(root, origin, nodes); err != nil {
 			return common.Hash{}, err
 		}
 		s.originalRoot = root
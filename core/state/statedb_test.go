@@ -19,6 +19,7 @@ package state
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -32,6 +33,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/utils"
 )
 
 // Tests that updating a state trie does not leak any database writes prior to
@@ -998,3 +1001,127 @@ func TestStateDBTransientStorage(t *testing.T) {
 		t.Fatalf("transient storage mismatch: have %x, want %x", got, value)
 	}
 }
+
+func TestVerkleStateDB(t *testing.T) {
+	db := NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &trie.Config{Verkle: true})
+	state, _ := New(types.EmptyRootHash, db, nil)
+
+	var (
+		addr = common.HexToAddress("0xaaaa")
+		code = []byte{0x60, 0x01, 0x60, 0x00, 0x55}
+		slot = common.HexToHash("0x01")
+	)
+	state.SetBalance(addr, big.NewInt(42))
+	state.SetNonce(addr, 3)
+	state.SetCode(addr, code)
+	state.SetState(addr, slot, common.HexToHash("0xff"))
+
+	root, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	// Update the storage on top of the committed state
+	state, _ = New(root, db, nil)
+	if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("balance mismatch: have %v, want 42", balance)
+	}
+	if nonce := state.GetNonce(addr); nonce != 3 {
+		t.Fatalf("nonce mismatch: have %d, want 3", nonce)
+	}
+	if have := state.GetCode(addr); !bytes.Equal(have, code) {
+		t.Fatalf("code mismatch: have %x, want %x", have, code)
+	}
+	if value := state.GetState(addr, slot); value != common.HexToHash("0xff") {
+		t.Fatalf("slot mismatch: have %x, want 0xff", value)
+	}
+	state.SetState(addr, slot, common.HexToHash("0xfe"))
+	next, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if next == root {
+		t.Fatal("storage update didn't change the root")
+	}
+	// Both states are retrievable, the storage proofs are verkle proofs
+	for stateRoot, want := range map[common.Hash]common.Hash{root: common.HexToHash("0xff"), next: common.HexToHash("0xfe")} {
+		state, _ = New(stateRoot, db, nil)
+		if value := state.GetState(addr, slot); value != want {
+			t.Fatalf("state %x: slot mismatch: have %x, want %x", stateRoot, value, want)
+		}
+		proof, err := state.GetStorageProof(addr, slot)
+		if err != nil {
+			t.Fatalf("state %x: failed to prove slot: %v", stateRoot, err)
+		}
+		key := utils.GetTreeKeyStorageSlot(addr[:], slot[:])
+		if err := trie.VerifyVerkleProof(stateRoot, proof[0], [][]byte{key}, [][]byte{want[:]}); err != nil {
+			t.Fatalf("state %x: failed to verify proof: %v", stateRoot, err)
+		}
+		if _, err := state.GetProof(addr); err != nil {
+			t.Fatalf("state %x: failed to prove account: %v", stateRoot, err)
+		}
+	}
+	// Self-destructs are rejected, the storage of the account can't be wiped
+	state, _ = New(next, db, nil)
+	state.Suicide(addr)
+	if _, err := state.Commit(true); !errors.Is(err, errVerkleSelfDestruct) {
+		t.Fatalf("self-destruct error mismatch: have %v, want %v", err, errVerkleSelfDestruct)
+	}
+}
+
+// Tests that the state written is the same regardless of the number of workers
+// hashing and committing the storage tries.
+func TestConcurrentStorageCommit(t *testing.T) {
+	commit := func(workers int) (common.Hash, map[string]string) {
+		diskdb := rawdb.NewMemoryDatabase()
+		db := NewDatabase(diskdb)
+		state, _ := New(types.EmptyRootHash, db, nil)
+		state.SetWorkers(workers)
+
+		for i := byte(0); i < 64; i++ {
+			addr := common.BytesToAddress([]byte{i})
+			state.SetBalance(addr, big.NewInt(int64(i)))
+			for j := 0; j < int(i)*4; j++ {
+				state.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(j)+1)))
+			}
+		}
+		root, _ := state.Commit(false)
+
+		// Modify the storage again, deleting some slots too
+		state, _ = New(root, db, nil)
+		state.SetWorkers(workers)
+		for i := byte(0); i < 64; i += 2 {
+			addr := common.BytesToAddress([]byte{i})
+			for j := 0; j < int(i)*4; j += 3 {
+				state.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.Hash{})
+			}
+			state.SetState(addr, common.HexToHash("0xffff"), common.HexToHash("0x01"))
+		}
+		if root = state.IntermediateRoot(false); root != state.IntermediateRoot(false) {
+			t.Fatalf("intermediate root not stable")
+		}
+		root, err := state.Commit(false)
+		if err != nil {
+			t.Fatalf("failed to commit state: %v", err)
+		}
+		if err := db.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("failed to flush state: %v", err)
+		}
+		written := make(map[string]string)
+		it := diskdb.NewIterator(nil, nil)
+		for it.Next() {
+			written[string(it.Key())] = string(it.Value())
+		}
+		it.Release()
+		return root, written
+	}
+	root, written := commit(1)
+	for _, workers := range []int{2, 16} {
+		have, haveWritten := commit(workers)
+		if have != root {
+			t.Fatalf("workers %d: root mismatch: have %x, want %x", workers, have, root)
+		}
+		if !reflect.DeepEqual(haveWritten, written) {
+			t.Fatalf("workers %d: database content mismatch", workers)
+		}
+	}
+}
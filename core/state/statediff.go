@@ -0,0 +1,213 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// StateDiff is the set of state changes made by a single block. All entries
+// are keyed by the hash of the account address or storage slot and sorted.
+type StateDiff struct {
+	Destructs []common.Hash     // Accounts deleted, their storage is wiped
+	Accounts  []DiffAccount     // Accounts created or updated, applied after the destructs
+	Storages  []DiffStorageSlot // Storage slots created, updated or deleted
+}
+
+// DiffAccount is the new value of an account in slim snapshot encoding.
+type DiffAccount struct {
+	Hash common.Hash
+	Blob []byte
+}
+
+// DiffStorageSlot is the new value of a storage slot in its trie encoding, an
+// empty blob means the slot was deleted.
+type DiffStorageSlot struct {
+	Account common.Hash
+	Hash    common.Hash
+	Blob    []byte
+}
+
+// newStateDiff assembles a state diff from the change sets maintained for the
+// snapshot tree.
+func newStateDiff(destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *StateDiff {
+	diff := &StateDiff{
+		Destructs: make([]common.Hash, 0, len(destructs)),
+		Accounts:  make([]DiffAccount, 0, len(accounts)),
+	}
+	for hash := range destructs {
+		diff.Destructs = append(diff.Destructs, hash)
+	}
+	sort.Slice(diff.Destructs, func(i, j int) bool {
+		return bytes.Compare(diff.Destructs[i][:], diff.Destructs[j][:]) < 0
+	})
+	for hash, blob := range accounts {
+		diff.Accounts = append(diff.Accounts, DiffAccount{Hash: hash, Blob: blob})
+	}
+	sort.Slice(diff.Accounts, func(i, j int) bool {
+		return bytes.Compare(diff.Accounts[i].Hash[:], diff.Accounts[j].Hash[:]) < 0
+	})
+	for account, slots := range storage {
+		for hash, blob := range slots {
+			diff.Storages = append(diff.Storages, DiffStorageSlot{Account: account, Hash: hash, Blob: blob})
+		}
+	}
+	sort.Slice(diff.Storages, func(i, j int) bool {
+		if c := bytes.Compare(diff.Storages[i].Account[:], diff.Storages[j].Account[:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(diff.Storages[i].Hash[:], diff.Storages[j].Hash[:]) < 0
+	})
+	return diff
+}
+
+// diffApplier replays state diffs on the raw tries of a base state.
+type diffApplier struct {
+	triedb   *trie.Database
+	root     common.Hash                // Root of the base state
+	accounts *trie.Trie                 // Account trie, keyed by address hash
+	storages map[common.Hash]*trie.Trie // Opened storage tries, nil for wiped ones
+}
+
+// storage returns the storage trie of the given account, opening it from the
+// current account data if needed.
+func (a *diffApplier) storage(account common.Hash) (*trie.Trie, error) {
+	if tr, ok := a.storages[account]; ok && tr != nil {
+		return tr, nil
+	}
+	root := types.EmptyRootHash
+	if _, wiped := a.storages[account]; !wiped {
+		blob, err := a.accounts.TryGet(account[:])
+		if err != nil {
+			return nil, err
+		}
+		if len(blob) > 0 {
+			var data types.StateAccount
+			if err := rlp.DecodeBytes(blob, &data); err != nil {
+				return nil, err
+			}
+			root = data.Root
+		}
+	}
+	tr, err := trie.New(trie.StorageTrieID(a.root, account, root), a.triedb)
+	if err != nil {
+		return nil, err
+	}
+	a.storages[account] = tr
+	return tr, nil
+}
+
+// apply replays a single state diff.
+func (a *diffApplier) apply(diff *StateDiff) error {
+	for _, hash := range diff.Destructs {
+		if err := a.accounts.TryDelete(hash[:]); err != nil {
+			return err
+		}
+		a.storages[hash] = nil
+	}
+	for _, slot := range diff.Storages {
+		tr, err := a.storage(slot.Account)
+		if err != nil {
+			return err
+		}
+		if len(slot.Blob) == 0 {
+			err = tr.TryDelete(slot.Hash[:])
+		} else {
+			err = tr.TryUpdate(slot.Hash[:], slot.Blob)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, account := range diff.Accounts {
+		data, err := snapshot.FullAccount(account.Blob)
+		if err != nil {
+			return err
+		}
+		// Storage tries touched by the diffs must end up at the recorded root
+		if tr := a.storages[account.Hash]; tr != nil {
+			if have := tr.Hash(); have != common.BytesToHash(data.Root) {
+				return fmt.Errorf("storage root mismatch for %x: have %x, want %x", account.Hash, have, data.Root)
+			}
+		} else if _, wiped := a.storages[account.Hash]; wiped && common.BytesToHash(data.Root) != types.EmptyRootHash {
+			return fmt.Errorf("storage root mismatch for %x: have %x, want %x", account.Hash, types.EmptyRootHash, data.Root)
+		}
+		blob, err := rlp.EncodeToBytes(data)
+		if err != nil {
+			return err
+		}
+		if err := a.accounts.TryUpdate(account.Hash[:], blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyStateDiffs replays the given diffs in order on top of the state with the
+// given root, and inserts the resulting trie nodes into the trie database. The
+// root of the resulting state is returned. The replay is aborted if the context
+// is cancelled.
+func ApplyStateDiffs(ctx context.Context, triedb *trie.Database, root common.Hash, diffs []*StateDiff) (common.Hash, error) {
+	accounts, err := trie.New(trie.StateTrieID(root), triedb)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	applier := &diffApplier{
+		triedb:   triedb,
+		root:     root,
+		accounts: accounts,
+		storages: make(map[common.Hash]*trie.Trie),
+	}
+	for i, diff := range diffs {
+		if err := ctx.Err(); err != nil {
+			return common.Hash{}, err
+		}
+		if err := applier.apply(diff); err != nil {
+			return common.Hash{}, fmt.Errorf("state diff %d: %w", i, err)
+		}
+	}
+	nodes := trie.NewMergedNodeSet()
+	for _, tr := range applier.storages {
+		if tr == nil {
+			continue
+		}
+		if _, set := tr.Commit(false); set != nil {
+			if err := nodes.Merge(set); err != nil {
+				return common.Hash{}, err
+			}
+		}
+	}
+	newRoot, set := accounts.Commit(false)
+	if set != nil {
+		if err := nodes.Merge(set); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	if err := triedb.UpdateState(newRoot, root, nodes); err != nil {
+		return common.Hash{}, err
+	}
+	return newRoot, nil
+}
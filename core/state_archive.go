@@ -0,0 +1,114 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// writeStateDiff stores the state changes made by the given block, they are
+// moved into the ancient store together with the block later on.
+func (bc *BlockChain) writeStateDiff(block *types.Block, statedb *state.StateDB) error {
+	diff := statedb.StateDiff()
+	if diff == nil {
+		log.Warn("State diff not recorded", "number", block.Number(), "hash", block.Hash())
+		return nil
+	}
+	blob, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteStateDiffRLP(bc.db, block.Hash(), block.NumberU64(), blob)
+	return nil
+}
+
+// readStateDiff retrieves the state changes made by the given block.
+func (bc *BlockChain) readStateDiff(hash common.Hash, number uint64) (*state.StateDiff, error) {
+	blob := rawdb.ReadStateDiffRLP(bc.db, hash, number)
+	if len(blob) == 0 {
+		return nil, fmt.Errorf("state diff of block #%d [%x..] not available", number, hash[:4])
+	}
+	diff := new(state.StateDiff)
+	if err := rlp.DecodeBytes(blob, diff); err != nil {
+		return nil, fmt.Errorf("invalid state diff of block #%d [%x..]: %v", number, hash[:4], err)
+	}
+	return diff, nil
+}
+
+// HistoricState returns a mutable state based on the given header. If the state
+// is not stored and the diff archive is enabled, it's reconstructed by applying
+// the state diffs of the blocks following the nearest stored state, typically a
+// checkpoint. At most a checkpoint interval worth of diffs are applied, states
+// further away from a stored one are treated as missing.
+func (bc *BlockChain) HistoricState(ctx context.Context, header *types.Header) (*state.StateDB, error) {
+	statedb, err := bc.StateAt(header.Root)
+	if err == nil || !bc.cacheConfig.DiffArchive {
+		return statedb, err
+	}
+	if triedb, ok := bc.archiveCache.Get(header.Root); ok {
+		return state.New(header.Root, state.NewDatabaseWithNodeDB(bc.db, triedb), nil)
+	}
+	// Gather the state diffs back to the nearest state persisted on disk, the
+	// recent in-memory ones are not considered as they might get dereferenced
+	// while reconstructing.
+	var (
+		base  = header
+		diffs []*state.StateDiff
+	)
+	for base.Root != types.EmptyRootHash && !rawdb.HasLegacyTrieNode(bc.db, base.Root) {
+		if uint64(len(diffs)) >= bc.cacheConfig.CheckpointInterval {
+			return nil, fmt.Errorf("no stored state within %d blocks of block #%d", bc.cacheConfig.CheckpointInterval, header.Number)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		diff, err := bc.readStateDiff(base.Hash(), base.Number.Uint64())
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+
+		number := base.Number.Uint64()
+		if base = bc.GetHeader(base.ParentHash, number-1); base == nil {
+			return nil, fmt.Errorf("missing parent of block #%d", number)
+		}
+	}
+	for i, j := 0, len(diffs)-1; i < j; i, j = i+1, j-1 {
+		diffs[i], diffs[j] = diffs[j], diffs[i]
+	}
+	triedb := trie.NewDatabase(bc.db)
+	root, err := state.ApplyStateDiffs(ctx, triedb, base.Root, diffs)
+	if err != nil {
+		return nil, err
+	}
+	if root != header.Root {
+		return nil, fmt.Errorf("reconstructed state root mismatch for block #%d: have %x, want %x", header.Number, root, header.Root)
+	}
+	log.Debug("Reconstructed historic state", "number", header.Number, "base", base.Number, "diffs", len(diffs))
+
+	bc.archiveCache.Add(header.Root, triedb)
+	return state.New(header.Root, state.NewDatabaseWithNodeDB(bc.db, triedb), nil)
+}
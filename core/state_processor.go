@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -36,10 +37,33 @@ import (
 // StateProcessor implements Processor.
 type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
+	bc     processorChain      // Canonical block chain, or the headers of a witness
 	engine consensus.Engine    // Consensus engine used for block rewards
 }
 
+// processorChain is the subset of the chain methods needed for processing the
+// blocks, implemented by both the blockchain and the stateless executor.
+type processorChain interface {
+	ChainContext
+	consensus.ChainHeaderReader
+}
+
+// witnessChain wraps a chain, recording all the headers retrieved through it
+// into the execution witness of the block being processed.
+type witnessChain struct {
+	processorChain
+	witness *stateless.Witness
+}
+
+// GetHeader retrieves a block header from the chain and adds it to the witness.
+func (c *witnessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	header := c.processorChain.GetHeader(hash, number)
+	if header != nil {
+		c.witness.AddHeader(header)
+	}
+	return header
+}
+
 // NewStateProcessor initialises a new StateProcessor.
 func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *StateProcessor {
 	return &StateProcessor{
@@ -65,12 +89,17 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		blockNumber = block.Number()
 		allLogs     []*types.Log
 		gp          = new(GasPool).AddGas(block.GasLimit())
+		chain       = p.bc
 	)
+	// If the state accessed is recorded, record the ancestors accessed too
+	if witness := statedb.Witness(); witness != nil {
+		chain = &witnessChain{processorChain: p.bc, witness: witness}
+	}
 	// Mutate the block and state according to any hard-fork specs
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
-	blockContext := NewEVMBlockContext(header, p.bc, nil)
+	blockContext := NewEVMBlockContext(header, chain, nil)
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
@@ -92,7 +121,7 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		return nil, nil, 0, fmt.Errorf("withdrawals before shanghai")
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
-	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), withdrawals)
+	p.engine.Finalize(chain, header, statedb, block.Transactions(), block.Uncles(), withdrawals)
 
 	return receipts, allLogs, *usedGas, nil
 }
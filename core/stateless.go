@@ -0,0 +1,141 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ExecuteStateless runs a block on top of the state contained in its execution
+// witness, without access to any database. It returns the state root and the
+// receipt root computed, and an error if the block is invalid or the witness is
+// missing some of the state needed to execute it.
+func ExecuteStateless(config *params.ChainConfig, engine consensus.Engine, block *types.Block, witness *stateless.Witness) (common.Hash, common.Hash, error) {
+	parent := witness.Parent()
+	if parent.Hash() != block.ParentHash() {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("witness parent mismatch: have %x, want %x", parent.Hash(), block.ParentHash())
+	}
+	statedb, err := state.New(parent.Root, state.NewDatabase(witness.MakeHashDB()), nil)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	chain := newWitnessHeaderChain(config, engine, witness)
+	processor := &StateProcessor{config: config, bc: chain, engine: engine}
+
+	receipts, _, usedGas, err := processor.Process(block, statedb, vm.Config{})
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	// Missing trie nodes and codes are only reported through the statedb
+	if err := statedb.Error(); err != nil {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("incomplete witness: %w", err)
+	}
+	var (
+		header      = block.Header()
+		stateRoot   = statedb.IntermediateRoot(config.IsEIP158(header.Number))
+		receiptRoot = types.DeriveSha(receipts, trie.NewStackTrie(nil))
+	)
+	if err := statedb.Error(); err != nil {
+		return common.Hash{}, common.Hash{}, fmt.Errorf("incomplete witness: %w", err)
+	}
+	if header.GasUsed != usedGas {
+		return stateRoot, receiptRoot, fmt.Errorf("invalid gas used (remote: %d local: %d)", header.GasUsed, usedGas)
+	}
+	if rbloom := types.CreateBloom(receipts); rbloom != header.Bloom {
+		return stateRoot, receiptRoot, fmt.Errorf("invalid bloom (remote: %x  local: %x)", header.Bloom, rbloom)
+	}
+	if receiptRoot != header.ReceiptHash {
+		return stateRoot, receiptRoot, fmt.Errorf("invalid receipt root hash (remote: %x local: %x)", header.ReceiptHash, receiptRoot)
+	}
+	if stateRoot != header.Root {
+		return stateRoot, receiptRoot, fmt.Errorf("invalid merkle root (remote: %x local: %x)", header.Root, stateRoot)
+	}
+	return stateRoot, receiptRoot, nil
+}
+
+// witnessHeaderChain is a chain made of the ancestor headers of an execution
+// witness, enough for processing the block the witness is for.
+type witnessHeaderChain struct {
+	config  *params.ChainConfig
+	engine  consensus.Engine
+	parent  *types.Header
+	headers map[common.Hash]*types.Header
+}
+
+// newWitnessHeaderChain creates a chain from the headers of a witness.
+func newWitnessHeaderChain(config *params.ChainConfig, engine consensus.Engine, witness *stateless.Witness) *witnessHeaderChain {
+	chain := &witnessHeaderChain{
+		config:  config,
+		engine:  engine,
+		parent:  witness.Parent(),
+		headers: make(map[common.Hash]*types.Header),
+	}
+	for _, header := range witness.Headers() {
+		chain.headers[header.Hash()] = header
+	}
+	return chain
+}
+
+// Config retrieves the chain configuration.
+func (c *witnessHeaderChain) Config() *params.ChainConfig { return c.config }
+
+// Engine retrieves the consensus engine.
+func (c *witnessHeaderChain) Engine() consensus.Engine { return c.engine }
+
+// CurrentHeader returns the parent of the block being executed.
+func (c *witnessHeaderChain) CurrentHeader() *types.Header { return c.parent }
+
+// GetHeader retrieves a header of the witness by hash and number.
+func (c *witnessHeaderChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.headers[hash]; header != nil && header.Number.Uint64() == number {
+		return header
+	}
+	return nil
+}
+
+// GetHeaderByHash retrieves a header of the witness by hash.
+func (c *witnessHeaderChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	return c.headers[hash]
+}
+
+// GetHeaderByNumber retrieves a header of the witness by number, following the
+// parent links from the parent of the executed block.
+func (c *witnessHeaderChain) GetHeaderByNumber(number uint64) *types.Header {
+	for header := c.parent; header != nil; header = c.headers[header.ParentHash] {
+		if header.Number.Uint64() == number {
+			return header
+		}
+		if header.Number.Uint64() < number {
+			break
+		}
+	}
+	return nil
+}
+
+// GetTd is not available without the database, total difficulties aren't part
+// of the witness.
+func (c *witnessHeaderChain) GetTd(hash common.Hash, number uint64) *big.Int { return nil }
@@ -0,0 +1,184 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package stateless implements the execution witnesses of blocks, the state
+// a block touches during its execution, which is enough to re-execute it
+// without access to the database.
+package stateless
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Witness encompasses the state required to execute a block on top of its
+// parent: the trie nodes resolved along the paths of all the accounts and
+// storage slots touched, the codes of the contracts loaded and the ancestor
+// headers the block hashes of which were requested.
+type Witness struct {
+	headers []*types.Header          // Ancestors of the block, the parent first
+	codes   map[string]struct{}      // Codes of the contracts loaded
+	state   map[string]struct{}      // RLP encoded trie nodes resolved
+	hashes  map[common.Hash]struct{} // Hashes of the headers, to deduplicate them
+
+	lock sync.Mutex
+}
+
+// NewWitness creates an empty witness for executing a block on top of the
+// given parent.
+func NewWitness(parent *types.Header) *Witness {
+	return &Witness{
+		headers: []*types.Header{parent},
+		codes:   make(map[string]struct{}),
+		state:   make(map[string]struct{}),
+		hashes:  map[common.Hash]struct{}{parent.Hash(): {}},
+	}
+}
+
+// Parent returns the header of the parent of the block the witness is for.
+func (w *Witness) Parent() *types.Header {
+	return w.headers[0]
+}
+
+// Headers returns the ancestor headers contained in the witness, the parent
+// first.
+func (w *Witness) Headers() []*types.Header {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return append([]*types.Header{}, w.headers...)
+}
+
+// AddHeader adds an ancestor header to the witness.
+func (w *Witness) AddHeader(header *types.Header) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	hash := header.Hash()
+	if _, ok := w.hashes[hash]; ok {
+		return
+	}
+	w.hashes[hash] = struct{}{}
+	w.headers = append(w.headers, header)
+}
+
+// AddCode adds a contract code to the witness.
+func (w *Witness) AddCode(code []byte) {
+	if len(code) == 0 {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.codes[string(code)] = struct{}{}
+}
+
+// AddState adds a set of RLP encoded trie nodes to the witness.
+func (w *Witness) AddState(nodes [][]byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, node := range nodes {
+		w.state[string(node)] = struct{}{}
+	}
+}
+
+// MakeHashDB creates an in-memory database holding the contents of the witness,
+// the trie nodes and the codes being keyed by their hashes.
+func (w *Witness) MakeHashDB() ethdb.Database {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	db := rawdb.NewMemoryDatabase()
+	for code := range w.codes {
+		blob := []byte(code)
+		rawdb.WriteCode(db, crypto.Keccak256Hash(blob), blob)
+	}
+	for node := range w.state {
+		blob := []byte(node)
+		rawdb.WriteLegacyTrieNode(db, crypto.Keccak256Hash(blob), blob)
+	}
+	return db
+}
+
+// extWitness is the external representation of a witness, with the codes and
+// the trie nodes in a deterministic order.
+type extWitness struct {
+	Headers []*types.Header `json:"headers"`
+	Codes   []hexutil.Bytes `json:"codes"`
+	State   []hexutil.Bytes `json:"state"`
+}
+
+// sortedBlobs returns the blobs of the given set in ascending order.
+func sortedBlobs(set map[string]struct{}) []hexutil.Bytes {
+	blobs := make([]hexutil.Bytes, 0, len(set))
+	for blob := range set {
+		blobs = append(blobs, hexutil.Bytes(blob))
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return bytes.Compare(blobs[i], blobs[j]) < 0
+	})
+	return blobs
+}
+
+// MarshalJSON implements json.Marshaler.
+func (w *Witness) MarshalJSON() ([]byte, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return json.Marshal(&extWitness{
+		Headers: w.headers,
+		Codes:   sortedBlobs(w.codes),
+		State:   sortedBlobs(w.state),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (w *Witness) UnmarshalJSON(input []byte) error {
+	var ext extWitness
+	if err := json.Unmarshal(input, &ext); err != nil {
+		return err
+	}
+	if len(ext.Headers) == 0 {
+		return errors.New("witness without parent header")
+	}
+	*w = Witness{
+		headers: ext.Headers,
+		codes:   make(map[string]struct{}, len(ext.Codes)),
+		state:   make(map[string]struct{}, len(ext.State)),
+		hashes:  make(map[common.Hash]struct{}, len(ext.Headers)),
+	}
+	for _, header := range ext.Headers {
+		w.hashes[header.Hash()] = struct{}{}
+	}
+	for _, code := range ext.Codes {
+		w.codes[string(code)] = struct{}{}
+	}
+	for _, node := range ext.State {
+		w.state[string(node)] = struct{}{}
+	}
+	return nil
+}
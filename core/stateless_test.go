@@ -0,0 +1,125 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the witnesses recorded while processing blocks are enough for
+// executing them without the database.
+func TestStatelessExecution(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xaaaa")
+		config   = params.TestChainConfig
+		signer   = types.LatestSigner(config)
+
+		// Stores the hash of the third ancestor block at the slot of the block
+		// number, clears the slot of the parent and reads slot zero
+		code = common.FromHex("6003430340435560006001430355600054500000")
+	)
+	gspec := &Genesis{
+		Config: config,
+		Alloc: GenesisAlloc{
+			sender: {Balance: big.NewInt(params.Ether)},
+			contract: {
+				Code:    code,
+				Balance: common.Big0,
+				Storage: map[common.Hash]common.Hash{
+					common.HexToHash("0x00"): common.HexToHash("0x01"),
+					common.HexToHash("0x01"): common.HexToHash("0x02"),
+					common.HexToHash("0x02"): common.HexToHash("0x03"),
+				},
+			},
+		},
+	}
+	// Build the chain block by block, BLOCKHASH needing the previous ones
+	db := rawdb.NewMemoryDatabase()
+	chain, err := NewBlockChain(db, &CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true}, gspec, nil, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	var blocks []*types.Block
+	for i := 0; i < 6; i++ {
+		parent := chain.GetBlockByHash(chain.CurrentBlock().Hash())
+		generated, _ := GenerateChain(config, parent, ethash.NewFaker(), db, 1, func(_ int, b *BlockGen) {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), contract, common.Big0, 100000, b.header.BaseFee, nil), signer, key)
+			b.AddTxWithChain(chain, tx)
+			tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(sender), common.Address{byte(i + 1)}, big.NewInt(1), params.TxGas, b.header.BaseFee, nil), signer, key)
+			b.AddTxWithChain(chain, tx)
+		})
+		if _, err := chain.InsertChain(generated); err != nil {
+			t.Fatalf("failed to insert block %d: %v", i+1, err)
+		}
+		blocks = append(blocks, generated...)
+	}
+	for _, block := range blocks {
+		// Record the witness of the block by processing it on top of its parent
+		parent := chain.GetHeaderByHash(block.ParentHash())
+		statedb, err := state.New(parent.Root, chain.StateCache(), nil)
+		if err != nil {
+			t.Fatalf("block %d: failed to open parent state: %v", block.NumberU64(), err)
+		}
+		witness := stateless.NewWitness(parent)
+		statedb.SetWitness(witness)
+		if _, _, _, err := chain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+			t.Fatalf("block %d: failed to process: %v", block.NumberU64(), err)
+		}
+		statedb.IntermediateRoot(true)
+
+		// The third ancestor is reached through the grandparent header
+		if have := len(witness.Headers()); block.NumberU64() > 2 && have != 2 {
+			t.Fatalf("block %d: header count mismatch: have %d, want 2", block.NumberU64(), have)
+		}
+		// Pass it through the external format and execute the block from it
+		blob, err := json.Marshal(witness)
+		if err != nil {
+			t.Fatalf("block %d: failed to encode witness: %v", block.NumberU64(), err)
+		}
+		decoded := new(stateless.Witness)
+		if err := json.Unmarshal(blob, decoded); err != nil {
+			t.Fatalf("block %d: failed to decode witness: %v", block.NumberU64(), err)
+		}
+		stateRoot, receiptRoot, err := ExecuteStateless(config, ethash.NewFaker(), block, decoded)
+		if err != nil {
+			t.Fatalf("block %d: failed to execute statelessly: %v", block.NumberU64(), err)
+		}
+		if stateRoot != block.Root() || receiptRoot != block.ReceiptHash() {
+			t.Fatalf("block %d: root mismatch", block.NumberU64())
+		}
+		// A witness without the state must be rejected
+		if _, _, err := ExecuteStateless(config, ethash.NewFaker(), block, stateless.NewWitness(parent)); err == nil {
+			t.Fatalf("block %d: executed without state", block.NumberU64())
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrRateLimited is returned if the sender of a transaction submitted more
+	// transactions than its priority class permits within the rate window.
+	ErrRateLimited = errors.New("sender rate limited")
+
+	// ErrClassFull is returned if the priority class of a transaction already
+	// holds the maximum number of transactions permitted.
+	ErrClassFull = errors.New("priority class is full")
+)
+
+// rateWindow is the length of the window the class rate limits are enforced in.
+const rateWindow = time.Minute
+
+// ClassConfig is the configuration of a transaction priority class. Transactions
+// are assigned to a class based on their sender, or - for senders not listed in
+// any class - based on whether they create a contract. Transactions matching no
+// class are subject to the pool's default rules only.
+type ClassConfig struct {
+	Name      string           `json:"name"`
+	Senders   []common.Address `json:"senders,omitempty"`   // Senders whose transactions belong to the class
+	Creations bool             `json:"creations,omitempty"` // Whether contract creations of unlisted senders belong to the class
+
+	Guaranteed   bool   `json:"guaranteed,omitempty"`   // Whether the class is exempt from price and fairness based eviction
+	AccountSlots uint64 `json:"accountSlots,omitempty"` // Executable transaction slots guaranteed per account (0 = pool default)
+	AccountQueue uint64 `json:"accountQueue,omitempty"` // Non-executable transaction slots permitted per account (0 = pool default)
+	MaxTxs       uint64 `json:"maxTxs,omitempty"`       // Maximum number of transactions of the class in the pool (0 = unlimited)
+	RateLimit    uint64 `json:"rateLimit,omitempty"`    // Maximum number of transactions accepted per sender and minute (0 = unlimited)
+}
+
+// ClassStatus is the public view of a priority class: its limits along with the
+// number of transactions currently pooled in it. The sender lists are reduced
+// to their size, so the configuration doesn't leak through the public API.
+type ClassStatus struct {
+	Name      string `json:"name"`
+	Senders   int    `json:"senders"`             // Number of senders listed in the class
+	Creations bool   `json:"creations,omitempty"` // Whether contract creations of unlisted senders belong to the class
+
+	Guaranteed   bool   `json:"guaranteed,omitempty"`   // Whether the class is exempt from price and fairness based eviction
+	AccountSlots uint64 `json:"accountSlots,omitempty"` // Executable transaction slots guaranteed per account (0 = pool default)
+	AccountQueue uint64 `json:"accountQueue,omitempty"` // Non-executable transaction slots permitted per account (0 = pool default)
+	MaxTxs       uint64 `json:"maxTxs,omitempty"`       // Maximum number of transactions of the class in the pool (0 = unlimited)
+	RateLimit    uint64 `json:"rateLimit,omitempty"`    // Maximum number of transactions accepted per sender and minute (0 = unlimited)
+
+	Transactions int `json:"transactions"` // Number of transactions of the class in the pool
+}
+
+// classifier assigns transactions to their priority classes. It is immutable
+// once created, a configuration change replaces the entire classifier.
+type classifier struct {
+	classes  []*ClassConfig
+	senders  map[common.Address]*ClassConfig
+	creation *ClassConfig
+}
+
+// newClassifier validates the given class configurations and creates a
+// classifier out of them.
+func newClassifier(configs []ClassConfig) (*classifier, error) {
+	c := &classifier{
+		senders: make(map[common.Address]*ClassConfig),
+	}
+	names := make(map[string]struct{})
+	for i := range configs {
+		class := configs[i]
+		if class.Name == "" {
+			return nil, fmt.Errorf("priority class %d has no name", i)
+		}
+		if _, ok := names[class.Name]; ok {
+			return nil, fmt.Errorf("duplicate priority class %q", class.Name)
+		}
+		names[class.Name] = struct{}{}
+
+		class.Senders = append([]common.Address(nil), class.Senders...)
+		for _, addr := range class.Senders {
+			if other, ok := c.senders[addr]; ok {
+				return nil, fmt.Errorf("sender %v assigned to both %q and %q", addr, other.Name, class.Name)
+			}
+			c.senders[addr] = &class
+		}
+		if class.Creations {
+			if c.creation != nil {
+				return nil, fmt.Errorf("contract creations assigned to both %q and %q", c.creation.Name, class.Name)
+			}
+			c.creation = &class
+		}
+		c.classes = append(c.classes, &class)
+	}
+	return c, nil
+}
+
+// classify returns the priority class of a transaction, or nil if it doesn't
+// belong to any.
+func (c *classifier) classify(from common.Address, tx *types.Transaction) *ClassConfig {
+	if class := c.senders[from]; class != nil {
+		return class
+	}
+	if tx.To() == nil {
+		return c.creation
+	}
+	return nil
+}
+
+// guaranteed returns the addresses of all senders in guaranteed classes.
+func (c *classifier) guaranteed() []common.Address {
+	var addrs []common.Address
+	for addr, class := range c.senders {
+		if class.Guaranteed {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// configs returns a copy of the class configurations.
+func (c *classifier) configs() []ClassConfig {
+	configs := make([]ClassConfig, len(c.classes))
+	for i, class := range c.classes {
+		configs[i] = *class
+		configs[i].Senders = append([]common.Address(nil), class.Senders...)
+	}
+	return configs
+}
+
+// rateLimiter tracks the number of transactions submitted by individual
+// senders within fixed time windows.
+type rateLimiter struct {
+	windows map[common.Address]*senderWindow
+}
+
+type senderWindow struct {
+	start time.Time
+	count uint64
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{windows: make(map[common.Address]*senderWindow)}
+}
+
+// allow reports whether the sender may submit one more transaction in the
+// current window.
+func (r *rateLimiter) allow(addr common.Address, limit uint64, now time.Time) bool {
+	window := r.windows[addr]
+	if window == nil || now.Sub(window.start) >= rateWindow {
+		return limit > 0
+	}
+	return window.count < limit
+}
+
+// charge counts an accepted transaction of the sender in the current window.
+func (r *rateLimiter) charge(addr common.Address, now time.Time) {
+	window := r.windows[addr]
+	if window == nil || now.Sub(window.start) >= rateWindow {
+		window = &senderWindow{start: now}
+		r.windows[addr] = window
+	}
+	window.count++
+}
+
+// expire drops all windows that ended before the given time.
+func (r *rateLimiter) expire(now time.Time) {
+	for addr, window := range r.windows {
+		if now.Sub(window.start) >= rateWindow {
+			delete(r.windows, addr)
+		}
+	}
+}
+
+// exempt reports whether the transactions of the given account are protected
+// from the fairness based eviction rules, either because the account is local
+// or because it belongs to a guaranteed priority class.
+func (pool *TxPool) exempt(addr common.Address) bool {
+	if pool.locals.contains(addr) {
+		return true
+	}
+	class := pool.classes.senders[addr]
+	return class != nil && class.Guaranteed
+}
+
+// accountSlots returns the number of executable slots guaranteed to the account.
+func (pool *TxPool) accountSlots(addr common.Address) uint64 {
+	if class := pool.classes.senders[addr]; class != nil && class.AccountSlots > 0 {
+		return class.AccountSlots
+	}
+	return pool.config.AccountSlots
+}
+
+// accountQueue returns the number of non-executable slots permitted to the account.
+func (pool *TxPool) accountQueue(addr common.Address) uint64 {
+	if class := pool.classes.senders[addr]; class != nil && class.AccountQueue > 0 {
+		return class.AccountQueue
+	}
+	return pool.config.AccountQueue
+}
+
+// checkClass enforces the admission rules of the priority class a new
+// transaction belongs to.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) checkClass(class *ClassConfig, from common.Address, tx *types.Transaction) error {
+	if class.RateLimit > 0 && !pool.limiter.allow(from, class.RateLimit, time.Now()) {
+		return ErrRateLimited
+	}
+	if class.MaxTxs > 0 && uint64(pool.all.ClassCount(class.Name)) >= class.MaxTxs {
+		// Replacements don't grow the class, let them through
+		if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
+			return nil
+		}
+		if list := pool.queue[from]; list != nil && list.Overlaps(tx) {
+			return nil
+		}
+		return ErrClassFull
+	}
+	return nil
+}
+
+// setClassifier installs a new classifier into the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) setClassifier(classes *classifier) {
+	pool.classes = classes
+	pool.all.setClassifier(func(tx *types.Transaction) string {
+		from, _ := types.Sender(pool.signer, tx) // already validated
+		if class := classes.classify(from, tx); class != nil {
+			return class.Name
+		}
+		return ""
+	})
+	// Pooled transactions of guaranteed senders are exempt from price eviction,
+	// those of senders no longer guaranteed are subject to it again
+	guaranteed := newAccountSet(pool.signer, classes.guaranteed()...)
+	exempt := newAccountSet(pool.signer)
+	exempt.merge(pool.locals)
+	exempt.merge(guaranteed)
+	for _, tx := range pool.all.LocalsToRemotes(exempt) {
+		pool.priced.Put(tx, false)
+	}
+	pool.priced.Removed(pool.all.RemoteToLocals(guaranteed))
+}
+
+// SetClasses replaces the priority classes of the pool. Already pooled
+// transactions are reassigned to the new classes, but the new limits are
+// only enforced for subsequent transactions.
+func (pool *TxPool) SetClasses(configs []ClassConfig) error {
+	classes, err := newClassifier(configs)
+	if err != nil {
+		return err
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.setClassifier(classes)
+	pool.config.Classes = classes.configs()
+	return nil
+}
+
+// Classes returns the status of the currently configured priority classes,
+// including the number of pooled transactions in each.
+func (pool *TxPool) Classes() []ClassStatus {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	configs := pool.classes.configs()
+	stats := make([]ClassStatus, len(configs))
+	for i, config := range configs {
+		stats[i] = ClassStatus{
+			Name:         config.Name,
+			Senders:      len(config.Senders),
+			Creations:    config.Creations,
+			Guaranteed:   config.Guaranteed,
+			AccountSlots: config.AccountSlots,
+			AccountQueue: config.AccountQueue,
+			MaxTxs:       config.MaxTxs,
+			RateLimit:    config.RateLimit,
+			Transactions: pool.all.ClassCount(config.Name),
+		}
+	}
+	return stats
+}
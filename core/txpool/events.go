@@ -0,0 +1,167 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// TxEventType is the kind of change a transaction went through in the pool.
+type TxEventType string
+
+const (
+	TxEventAdded    TxEventType = "added"    // Transaction accepted into the pool
+	TxEventPromoted TxEventType = "promoted" // Transaction moved from the queue to the pending set
+	TxEventDemoted  TxEventType = "demoted"  // Transaction moved from the pending set back to the queue
+	TxEventReplaced TxEventType = "replaced" // Transaction superseded by one with the same nonce
+	TxEventDropped  TxEventType = "dropped"  // Transaction rejected or invalidated by the chain state
+	TxEventEvicted  TxEventType = "evicted"  // Transaction removed to enforce the pool limits
+)
+
+// TxEventReason is the reason code attached to a lifecycle event, detailing
+// why a transaction was moved or removed.
+type TxEventReason string
+
+const (
+	ReasonInvalid            TxEventReason = "invalid"            // Transaction failed validation
+	ReasonUnderpriced        TxEventReason = "underpriced"        // Transaction priced out of a full pool
+	ReasonReplaceUnderpriced TxEventReason = "replaceUnderpriced" // Transaction didn't bump the price of the one it tried to replace
+	ReasonPriceBump          TxEventReason = "priceBump"          // Transaction replaced by one with a higher fee
+	ReasonClassLimit         TxEventReason = "classLimit"         // Transaction exceeded the limits of its priority class
+	ReasonNonceTooLow        TxEventReason = "nonceTooLow"        // Nonce already used on chain, usually by this very transaction
+	ReasonUnpayable          TxEventReason = "unpayable"          // Balance too low or gas above the block gas limit
+	ReasonNonceGap           TxEventReason = "nonceGap"           // A preceding transaction of the account was removed
+	ReasonAccountLimit       TxEventReason = "accountLimit"       // Account exceeded its non-executable slots
+	ReasonPoolLimit          TxEventReason = "poolLimit"          // Pool exceeded its global slots
+	ReasonLifetime           TxEventReason = "lifetime"           // Transaction queued for longer than the configured lifetime
+	ReasonMinTip             TxEventReason = "minTip"             // Tip below the raised minimum price of the pool
+)
+
+// TxEvent is a single transaction lifecycle event of the pool.
+type TxEvent struct {
+	Type       TxEventType    `json:"type"`
+	Reason     TxEventReason  `json:"reason,omitempty"`
+	Hash       common.Hash    `json:"hash"`
+	From       common.Address `json:"from"`
+	Nonce      hexutil.Uint64 `json:"nonce"`
+	ReplacedBy *common.Hash   `json:"replacedBy,omitempty"` // Hash of the superseding transaction for replacements
+	Error      string         `json:"error,omitempty"`      // Validation error for rejected transactions
+}
+
+// SubscribeTxEvents registers a subscription for the lifecycle events of the
+// pooled transactions. Events are delivered in batches, in the order they
+// happened within a batch. Delivery is asynchronous, so batches are dropped if
+// the subscribers fall too far behind.
+func (pool *TxPool) SubscribeTxEvents(ch chan<- []TxEvent) event.Subscription {
+	return pool.scope.Track(pool.txEventFeed.Subscribe(ch))
+}
+
+// emitTxEvent queues a lifecycle event of the given transaction for delivery.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) emitTxEvent(typ TxEventType, reason TxEventReason, tx *types.Transaction) {
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	pool.txEvents = append(pool.txEvents, TxEvent{
+		Type:   typ,
+		Reason: reason,
+		Hash:   tx.Hash(),
+		From:   from,
+		Nonce:  hexutil.Uint64(tx.Nonce()),
+	})
+}
+
+// emitTxEvents queues the same lifecycle event for a batch of transactions.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) emitTxEvents(typ TxEventType, reason TxEventReason, txs []*types.Transaction) {
+	for _, tx := range txs {
+		pool.emitTxEvent(typ, reason, tx)
+	}
+}
+
+// emitReplaceEvent queues the replacement event of an old transaction.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) emitReplaceEvent(old, tx *types.Transaction) {
+	pool.emitTxEvent(TxEventReplaced, ReasonPriceBump, old)
+	hash := tx.Hash()
+	pool.txEvents[len(pool.txEvents)-1].ReplacedBy = &hash
+}
+
+// emitRejectEvent queues the drop event of a transaction refused by the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) emitRejectEvent(tx *types.Transaction, err error) {
+	reason := ReasonInvalid
+	switch {
+	case errors.Is(err, ErrUnderpriced), errors.Is(err, ErrFutureReplacePending):
+		reason = ReasonUnderpriced
+	case errors.Is(err, ErrTxPoolOverflow):
+		reason = ReasonPoolLimit
+	case errors.Is(err, ErrReplaceUnderpriced):
+		reason = ReasonReplaceUnderpriced
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrClassFull):
+		reason = ReasonClassLimit
+	}
+	pool.emitTxEvent(TxEventDropped, reason, tx)
+	pool.txEvents[len(pool.txEvents)-1].Error = err.Error()
+}
+
+// takeTxEvents returns the queued lifecycle events and resets the queue.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) takeTxEvents() []TxEvent {
+	events := pool.txEvents
+	pool.txEvents = nil
+	return events
+}
+
+// sendTxEvents queues a batch of lifecycle events for delivery to the subscribers.
+// It never blocks: if the subscribers fall too far behind, the batch is dropped
+// instead of stalling the pool.
+func (pool *TxPool) sendTxEvents(events []TxEvent) {
+	if len(events) == 0 {
+		return
+	}
+	select {
+	case pool.txEventCh <- events:
+	default:
+		droppedTxEventMeter.Mark(int64(len(events)))
+		log.Debug("Dropped transaction lifecycle events", "count", len(events))
+	}
+}
+
+// txEventLoop delivers the queued lifecycle event batches to the subscribers,
+// decoupling the pool from how fast they consume them.
+func (pool *TxPool) txEventLoop() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case events := <-pool.txEventCh:
+			pool.txEventFeed.Send(events)
+		case <-pool.reorgShutdownCh:
+			return
+		}
+	}
+}
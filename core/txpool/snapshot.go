@@ -0,0 +1,186 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txpool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// snapshotVersion is the version number of the pool snapshot encoding. It is
+// bumped whenever the format changes in an incompatible way.
+const snapshotVersion = 1
+
+// errSnapshotVersion is returned if a pool snapshot of an unknown format is
+// attempted to be imported.
+var errSnapshotVersion = errors.New("unsupported txpool snapshot version")
+
+// snapshotHeader is the first item of a pool snapshot stream.
+type snapshotHeader struct {
+	Version uint64
+	Pending uint64 // Number of executable transactions following the header
+	Queued  uint64 // Number of non-executable transactions following the pending ones
+}
+
+// snapshotEntry is a single pooled transaction in a pool snapshot.
+type snapshotEntry struct {
+	Tx    *types.Transaction
+	Local bool
+}
+
+// ImportStats contains the outcome of importing a pool snapshot.
+type ImportStats struct {
+	Pending int `json:"pending"` // Number of executable transactions found in the snapshot
+	Queued  int `json:"queued"`  // Number of non-executable transactions found in the snapshot
+	Dropped int `json:"dropped"` // Number of transactions rejected by the pool on import
+}
+
+// Export writes the entire content of the pool - both executable and
+// non-executable transactions - into the given writer as an RLP stream. The
+// transactions are ordered by account and nonce, so a later import can replay
+// them without causing nonce gaps.
+func (pool *TxPool) Export(w io.Writer) (int, error) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	var pending, queued []snapshotEntry
+	for addr, list := range pool.pending {
+		local := pool.locals.contains(addr)
+		for _, tx := range list.Flatten() {
+			pending = append(pending, snapshotEntry{Tx: tx, Local: local})
+		}
+	}
+	for addr, list := range pool.queue {
+		local := pool.locals.contains(addr)
+		for _, tx := range list.Flatten() {
+			queued = append(queued, snapshotEntry{Tx: tx, Local: local})
+		}
+	}
+	header := &snapshotHeader{
+		Version: snapshotVersion,
+		Pending: uint64(len(pending)),
+		Queued:  uint64(len(queued)),
+	}
+	if err := rlp.Encode(w, header); err != nil {
+		return 0, err
+	}
+	for _, entry := range append(pending, queued...) {
+		if err := rlp.Encode(w, &entry); err != nil {
+			return 0, err
+		}
+	}
+	return len(pending) + len(queued), nil
+}
+
+// Import reads a pool snapshot created by Export and injects the contained
+// transactions into the pool. Every transaction is revalidated against the
+// current head, so stale ones are silently dropped.
+//
+// The local flags recorded in the snapshot are only honoured if trustLocals is
+// set, otherwise all transactions are imported as remote ones.
+func (pool *TxPool) Import(r io.Reader, trustLocals bool) (*ImportStats, error) {
+	stream := rlp.NewStream(r, 0)
+
+	var header snapshotHeader
+	if err := stream.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", errSnapshotVersion, header.Version)
+	}
+	var (
+		stats   = &ImportStats{Pending: int(header.Pending), Queued: int(header.Queued)}
+		locals  types.Transactions
+		remotes types.Transactions
+	)
+	for i := uint64(0); i < header.Pending+header.Queued; i++ {
+		var entry snapshotEntry
+		if err := stream.Decode(&entry); err != nil {
+			return nil, err
+		}
+		if entry.Local && trustLocals && !pool.config.NoLocals {
+			locals = append(locals, entry.Tx)
+		} else {
+			remotes = append(remotes, entry.Tx)
+		}
+	}
+	// Local transactions are journaled on insertion, remote ones are added
+	// synchronously so they are visible to the caller when returning.
+	for _, err := range pool.AddLocals(locals) {
+		if err != nil && !errors.Is(err, ErrAlreadyKnown) {
+			log.Debug("Failed to import local transaction", "err", err)
+			stats.Dropped++
+		}
+	}
+	for _, err := range pool.AddRemotesSync(remotes) {
+		if err != nil && !errors.Is(err, ErrAlreadyKnown) {
+			log.Debug("Failed to import remote transaction", "err", err)
+			stats.Dropped++
+		}
+	}
+	return stats, nil
+}
+
+// loadSnapshot imports the pool snapshot from the configured path, if any.
+func (pool *TxPool) loadSnapshot() error {
+	input, err := os.Open(pool.config.Snapshot)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Skip the import if the snapshot doesn't exist at all
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	stats, err := pool.Import(input, true)
+	if err != nil {
+		return err
+	}
+	log.Info("Loaded transaction pool snapshot", "pending", stats.Pending, "queued", stats.Queued, "dropped", stats.Dropped)
+	return nil
+}
+
+// storeSnapshot exports the entire pool content into the configured path. The
+// snapshot is written into a temporary file first and moved into place only
+// once complete, so a crash never leaves a half-written snapshot behind.
+func (pool *TxPool) storeSnapshot() error {
+	output, err := os.OpenFile(pool.config.Snapshot+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	count, err := pool.Export(output)
+	if err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(pool.config.Snapshot+".new", pool.config.Snapshot); err != nil {
+		return err
+	}
+	log.Info("Stored transaction pool snapshot", "transactions", count)
+	return nil
+}
@@ -41,6 +41,10 @@ const (
 	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
 	chainHeadChanSize = 10
 
+	// txEventQueueSize is the number of lifecycle event batches waiting for
+	// delivery to the subscribers, beyond which new batches are dropped.
+	txEventQueueSize = 1024
+
 	// txSlotSize is used to calculate how many data slots a single transaction
 	// takes up based on its size. The slots are used as DoS protection, ensuring
 	// that validating a new transaction remains a constant operation (in reality
@@ -125,6 +129,10 @@ var (
 	// throttleTxMeter counts how many transactions are rejected due to too-many-changes between
 	// txpool reorgs.
 	throttleTxMeter = metrics.NewRegisteredMeter("txpool/throttle", nil)
+
+	// classLimitTxMeter counts how many transactions are rejected due to the
+	// rate or size limits of their priority class.
+	classLimitTxMeter = metrics.NewRegisteredMeter("txpool/classlimit", nil)
 	// reorgDurationTimer measures how long time a txpool reorg takes.
 	reorgDurationTimer = metrics.NewRegisteredTimer("txpool/reorgtime", nil)
 	// dropBetweenReorgHistogram counts how many drops we experience between two reorg runs. It is expected
@@ -137,6 +145,10 @@ var (
 	slotsGauge   = metrics.NewRegisteredGauge("txpool/slots", nil)
 
 	reheapTimer = metrics.NewRegisteredTimer("txpool/reheap", nil)
+
+	// droppedTxEventMeter counts the lifecycle events dropped because the
+	// subscribers didn't keep up with the pool.
+	droppedTxEventMeter = metrics.NewRegisteredMeter("txpool/events/dropped", nil)
 )
 
 // TxStatus is the current status of a transaction as seen by the pool.
@@ -165,6 +177,7 @@ type Config struct {
 	NoLocals  bool             // Whether local transaction handling should be disabled
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
+	Snapshot  string           // Snapshot of all pooled transactions to survive node restarts (empty = disabled)
 
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
@@ -175,6 +188,8 @@ type Config struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	Classes []ClassConfig // Priority classes with their own admission and eviction rules
 }
 
 // DefaultConfig contains the default configurations for the transaction
@@ -246,6 +261,7 @@ type TxPool struct {
 	chain       blockChain
 	gasPrice    *big.Int
 	txFeed      event.Feed
+	txEventFeed event.Feed
 	scope       event.SubscriptionScope
 	signer      types.Signer
 	mu          sync.RWMutex
@@ -262,6 +278,9 @@ type TxPool struct {
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *journal    // Journal of local transaction to back up to disk
 
+	classes *classifier  // Priority classes transactions are assigned to
+	limiter *rateLimiter // Per-sender submission counters of rate limited classes
+
 	pending map[common.Address]*list     // All currently processable transactions
 	queue   map[common.Address]*list     // Queued but non-processable transactions
 	beats   map[common.Address]time.Time // Last heartbeat from each known account
@@ -274,11 +293,14 @@ type TxPool struct {
 	reqPromoteCh    chan *accountSet
 	queueTxEventCh  chan *types.Transaction
 	reorgDoneCh     chan chan struct{}
-	reorgShutdownCh chan struct{}  // requests shutdown of scheduleReorgLoop
-	wg              sync.WaitGroup // tracks loop, scheduleReorgLoop
+	reorgShutdownCh chan struct{}  // requests shutdown of scheduleReorgLoop and txEventLoop
+	wg              sync.WaitGroup // tracks loop, scheduleReorgLoop, txEventLoop
 	initDoneCh      chan struct{}  // is closed once the pool is initialized (for tests)
 
 	changesSinceReorg int // A counter for how many drops we've performed in-between reorg.
+
+	txEvents  []TxEvent      // Lifecycle events waiting to be delivered outside of the lock
+	txEventCh chan []TxEvent // Lifecycle event batches waiting for delivery to the subscribers
 }
 
 type txpoolResetRequest struct {
@@ -308,6 +330,7 @@ func NewTxPool(config Config, chainconfig *params.ChainConfig, chain blockChain)
 		reorgDoneCh:     make(chan chan struct{}),
 		reorgShutdownCh: make(chan struct{}),
 		initDoneCh:      make(chan struct{}),
+		txEventCh:       make(chan []TxEvent, txEventQueueSize),
 		gasPrice:        new(big.Int).SetUint64(config.PriceLimit),
 	}
 	pool.locals = newAccountSet(pool.signer)
@@ -316,12 +339,25 @@ func NewTxPool(config Config, chainconfig *params.ChainConfig, chain blockChain)
 		pool.locals.add(addr)
 	}
 	pool.priced = newPricedList(pool.all)
+
+	classes, err := newClassifier(config.Classes)
+	if err != nil {
+		log.Error("Invalid txpool priority classes, disabling", "err", err)
+		classes, _ = newClassifier(nil)
+		pool.config.Classes = nil
+	}
+	pool.limiter = newRateLimiter()
+	pool.setClassifier(classes)
 	pool.reset(nil, chain.CurrentBlock())
 
 	// Start the reorg loop early so it can handle requests generated during journal loading.
 	pool.wg.Add(1)
 	go pool.scheduleReorgLoop()
 
+	// Start delivering the lifecycle events before any can be generated
+	pool.wg.Add(1)
+	go pool.txEventLoop()
+
 	// If local transactions and journaling is enabled, load from disk
 	if !config.NoLocals && config.Journal != "" {
 		pool.journal = newTxJournal(config.Journal)
@@ -333,6 +369,12 @@ func NewTxPool(config Config, chainconfig *params.ChainConfig, chain blockChain)
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If full pool persistence is enabled, restore the remote transactions too
+	if config.Snapshot != "" {
+		if err := pool.loadSnapshot(); err != nil {
+			log.Warn("Failed to load transaction pool snapshot", "err", err)
+		}
+	}
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -392,21 +434,25 @@ func (pool *TxPool) loop() {
 		// Handle inactive account transaction eviction
 		case <-evict.C:
 			pool.mu.Lock()
+			pool.limiter.expire(time.Now())
 			for addr := range pool.queue {
-				// Skip local transactions from the eviction mechanism
-				if pool.locals.contains(addr) {
+				// Skip local and guaranteed transactions from the eviction mechanism
+				if pool.exempt(addr) {
 					continue
 				}
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
 					list := pool.queue[addr].Flatten()
+					pool.emitTxEvents(TxEventEvicted, ReasonLifetime, list)
 					for _, tx := range list {
 						pool.removeTx(tx.Hash(), true)
 					}
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
 			}
+			events := pool.takeTxEvents()
 			pool.mu.Unlock()
+			pool.sendTxEvents(events)
 
 		// Handle local transaction journal rotation
 		case <-journal.C:
@@ -433,6 +479,11 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.config.Snapshot != "" {
+		if err := pool.storeSnapshot(); err != nil {
+			log.Warn("Failed to store transaction pool snapshot", "err", err)
+		}
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -454,7 +505,6 @@ func (pool *TxPool) GasPrice() *big.Int {
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetGasPrice(price *big.Int) {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
 
 	old := pool.gasPrice
 	pool.gasPrice = price
@@ -462,11 +512,15 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	if price.Cmp(old) > 0 {
 		// pool.priced is sorted by GasFeeCap, so we have to iterate through pool.all instead
 		drop := pool.all.RemotesBelowTip(price)
+		pool.emitTxEvents(TxEventEvicted, ReasonMinTip, drop)
 		for _, tx := range drop {
 			pool.removeTx(tx.Hash(), false)
 		}
 		pool.priced.Removed(len(drop))
 	}
+	events := pool.takeTxEvents()
+	pool.mu.Unlock()
+	pool.sendTxEvents(events)
 
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
@@ -705,10 +759,30 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	// already validated by this point
 	from, _ := types.Sender(pool.signer, tx)
 
+	// Enforce the limits of the transaction's priority class, if any. Transactions
+	// of guaranteed classes are exempt from price based eviction, same as locals.
+	class := pool.classes.classify(from, tx)
+	if !isLocal && class != nil {
+		if err := pool.checkClass(class, from, tx); err != nil {
+			log.Trace("Discarding transaction exceeding class limits", "hash", hash, "class", class.Name, "err", err)
+			classLimitTxMeter.Mark(1)
+			return false, err
+		}
+		// Only accepted transactions count towards the sender's rate limit
+		if class.RateLimit > 0 {
+			defer func() {
+				if err == nil {
+					pool.limiter.charge(from, time.Now())
+				}
+			}()
+		}
+	}
+	exempt := isLocal || (class != nil && class.Guaranteed)
+
 	// If the transaction pool is full, discard underpriced transactions
 	if uint64(pool.all.Slots()+numSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
 		// If the new transaction is underpriced, don't accept it
-		if !isLocal && pool.priced.Underpriced(tx) {
+		if !exempt && pool.priced.Underpriced(tx) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
 			underpricedTxMeter.Mark(1)
 			return false, ErrUnderpriced
@@ -759,6 +833,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
 			underpricedTxMeter.Mark(1)
+			pool.emitTxEvent(TxEventEvicted, ReasonUnderpriced, tx)
 			dropped := pool.removeTx(tx.Hash(), false)
 			pool.changesSinceReorg += dropped
 		}
@@ -777,11 +852,13 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
+			pool.emitReplaceEvent(old, tx)
 		}
-		pool.all.Add(tx, isLocal)
-		pool.priced.Put(tx, isLocal)
+		pool.all.Add(tx, exempt)
+		pool.priced.Put(tx, exempt)
 		pool.journalTx(from, tx)
 		pool.queueTxEvent(tx)
+		pool.emitTxEvent(TxEventAdded, "", tx)
 		log.Trace("Pooled new executable transaction", "hash", hash, "from", from, "to", tx.To())
 
 		// Successful promotion, bump the heartbeat
@@ -789,7 +866,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		return old != nil, nil
 	}
 	// New transaction isn't replacing a pending one, push into queue
-	replaced, err = pool.enqueueTx(hash, tx, isLocal, true)
+	replaced, err = pool.enqueueTx(hash, tx, exempt, true)
 	if err != nil {
 		return false, err
 	}
@@ -803,6 +880,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		localGauge.Inc(1)
 	}
 	pool.journalTx(from, tx)
+	pool.emitTxEvent(TxEventAdded, "", tx)
 
 	log.Trace("Pooled new future transaction", "hash", hash, "from", from, "to", tx.To())
 	return replaced, nil
@@ -842,6 +920,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
+		pool.emitReplaceEvent(old, tx)
 	} else {
 		// Nothing was replaced, bump the queued counter
 		queuedGauge.Inc(1)
@@ -891,6 +970,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
 		pendingDiscardMeter.Mark(1)
+		pool.emitReplaceEvent(tx, list.txs.Get(tx.Nonce()))
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -898,12 +978,14 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		pendingReplaceMeter.Mark(1)
+		pool.emitReplaceEvent(old, tx)
 	} else {
 		// Nothing was replaced, bump the pending counter
 		pendingGauge.Inc(1)
 	}
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.pendingNonces.set(addr, tx.Nonce()+1)
+	pool.emitTxEvent(TxEventPromoted, "", tx)
 
 	// Successful promotion, bump the heartbeat
 	pool.beats[addr] = time.Now()
@@ -988,7 +1070,9 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
 	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	events := pool.takeTxEvents()
 	pool.mu.Unlock()
+	pool.sendTxEvents(events)
 
 	var nilSlot = 0
 	for _, err := range newErrs {
@@ -1017,6 +1101,9 @@ func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error,
 		if err == nil && !replaced {
 			dirty.addTx(tx)
 		}
+		if err != nil && !errors.Is(err, ErrAlreadyKnown) {
+			pool.emitRejectEvent(tx, err)
+		}
 	}
 	validTxMeter.Mark(int64(len(dirty.accounts)))
 	return errs, dirty
@@ -1087,6 +1174,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) int {
 				// Internal shuffle shouldn't touch the lookup set.
 				pool.enqueueTx(tx.Hash(), tx, false, false)
 			}
+			pool.emitTxEvents(TxEventDemoted, ReasonNonceGap, invalids)
 			// Update the account nonce if needed
 			pool.pendingNonces.setIfLower(addr, tx.Nonce())
 			// Reduce the pending counter
@@ -1268,8 +1356,12 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 
 	dropBetweenReorgHistogram.Update(int64(pool.changesSinceReorg))
 	pool.changesSinceReorg = 0 // Reset change counter
+	txEvents := pool.takeTxEvents()
 	pool.mu.Unlock()
 
+	// Notify subsystems of the lifecycle changes made during the reorg
+	pool.sendTxEvents(txEvents)
+
 	// Notify subsystems for newly added transactions
 	for _, tx := range promoted {
 		addr, _ := types.Sender(pool.signer, tx)
@@ -1398,6 +1490,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.emitTxEvents(TxEventDropped, ReasonNonceTooLow, forwards)
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
@@ -1405,6 +1498,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 			hash := tx.Hash()
 			pool.all.Remove(hash)
 		}
+		pool.emitTxEvents(TxEventDropped, ReasonUnpayable, drops)
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
 		queuedNofundsMeter.Mark(int64(len(drops)))
 
@@ -1422,12 +1516,13 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		// Drop all transactions over the allowed limit
 		var caps types.Transactions
 		if !pool.locals.contains(addr) {
-			caps = list.Cap(int(pool.config.AccountQueue))
+			caps = list.Cap(int(pool.accountQueue(addr)))
 			for _, tx := range caps {
 				hash := tx.Hash()
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
+			pool.emitTxEvents(TxEventEvicted, ReasonAccountLimit, caps)
 			queuedRateLimitMeter.Mark(int64(len(caps)))
 		}
 		// Mark all the items dropped as removed
@@ -1462,7 +1557,7 @@ func (pool *TxPool) truncatePending() {
 	spammers := prque.New[int64, common.Address](nil)
 	for addr, list := range pool.pending {
 		// Only evict transactions from high rollers
-		if !pool.locals.contains(addr) && uint64(list.Len()) > pool.config.AccountSlots {
+		if !pool.exempt(addr) && uint64(list.Len()) > pool.accountSlots(addr) {
 			spammers.Push(addr, int64(list.Len()))
 		}
 	}
@@ -1493,6 +1588,7 @@ func (pool *TxPool) truncatePending() {
 						pool.pendingNonces.setIfLower(offenders[i], tx.Nonce())
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
+					pool.emitTxEvents(TxEventEvicted, ReasonPoolLimit, caps)
 					pool.priced.Removed(len(caps))
 					pendingGauge.Dec(int64(len(caps)))
 					if pool.locals.contains(offenders[i]) {
@@ -1506,7 +1602,8 @@ func (pool *TxPool) truncatePending() {
 
 	// If still above threshold, reduce to limit or min allowance
 	if pending > pool.config.GlobalSlots && len(offenders) > 0 {
-		for pending > pool.config.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.config.AccountSlots {
+		last := offenders[len(offenders)-1]
+		for pending > pool.config.GlobalSlots && uint64(pool.pending[last].Len()) > pool.accountSlots(last) {
 			for _, addr := range offenders {
 				list := pool.pending[addr]
 
@@ -1520,6 +1617,7 @@ func (pool *TxPool) truncatePending() {
 					pool.pendingNonces.setIfLower(addr, tx.Nonce())
 					log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 				}
+				pool.emitTxEvents(TxEventEvicted, ReasonPoolLimit, caps)
 				pool.priced.Removed(len(caps))
 				pendingGauge.Dec(int64(len(caps)))
 				if pool.locals.contains(addr) {
@@ -1545,7 +1643,7 @@ func (pool *TxPool) truncateQueue() {
 	// Sort all accounts with queued transactions by heartbeat
 	addresses := make(addressesByHeartbeat, 0, len(pool.queue))
 	for addr := range pool.queue {
-		if !pool.locals.contains(addr) { // don't drop locals
+		if !pool.exempt(addr) { // don't drop locals or guaranteed classes
 			addresses = append(addresses, addressByHeartbeat{addr, pool.beats[addr]})
 		}
 	}
@@ -1560,7 +1658,9 @@ func (pool *TxPool) truncateQueue() {
 
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
-			for _, tx := range list.Flatten() {
+			txs := list.Flatten()
+			pool.emitTxEvents(TxEventEvicted, ReasonPoolLimit, txs)
+			for _, tx := range txs {
 				pool.removeTx(tx.Hash(), true)
 			}
 			drop -= size
@@ -1570,6 +1670,7 @@ func (pool *TxPool) truncateQueue() {
 		// Otherwise drop only last few transactions
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+			pool.emitTxEvent(TxEventEvicted, ReasonPoolLimit, txs[i])
 			pool.removeTx(txs[i].Hash(), true)
 			drop--
 			queuedRateLimitMeter.Mark(1)
@@ -1596,6 +1697,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
+		pool.emitTxEvents(TxEventDropped, ReasonNonceTooLow, olds)
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
@@ -1603,6 +1705,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
 			pool.all.Remove(hash)
 		}
+		pool.emitTxEvents(TxEventDropped, ReasonUnpayable, drops)
 		pendingNofundsMeter.Mark(int64(len(drops)))
 
 		for _, tx := range invalids {
@@ -1612,6 +1715,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			// Internal shuffle shouldn't touch the lookup set.
 			pool.enqueueTx(hash, tx, false, false)
 		}
+		pool.emitTxEvents(TxEventDemoted, ReasonNonceGap, invalids)
 		pendingGauge.Dec(int64(len(olds) + len(drops) + len(invalids)))
 		if pool.locals.contains(addr) {
 			localGauge.Dec(int64(len(olds) + len(drops) + len(invalids)))
@@ -1626,6 +1730,7 @@ func (pool *TxPool) demoteUnexecutables() {
 				// Internal shuffle shouldn't touch the lookup set.
 				pool.enqueueTx(hash, tx, false, false)
 			}
+			pool.emitTxEvents(TxEventDemoted, ReasonNonceGap, gapped)
 			pendingGauge.Dec(int64(len(gapped)))
 		}
 		// Delete the entire pending entry if it became empty.
@@ -1734,6 +1839,9 @@ type lookup struct {
 	lock    sync.RWMutex
 	locals  map[common.Hash]*types.Transaction
 	remotes map[common.Hash]*types.Transaction
+
+	classify func(tx *types.Transaction) string // Resolver of a transaction's priority class
+	classes  map[string]int                     // Number of transactions in each priority class
 }
 
 // newLookup returns a new lookup structure.
@@ -1741,9 +1849,47 @@ func newLookup() *lookup {
 	return &lookup{
 		locals:  make(map[common.Hash]*types.Transaction),
 		remotes: make(map[common.Hash]*types.Transaction),
+		classes: make(map[string]int),
 	}
 }
 
+// setClassifier replaces the priority class resolver and recounts the
+// transactions in each class.
+func (t *lookup) setClassifier(classify func(tx *types.Transaction) string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.classify = classify
+	t.classes = make(map[string]int)
+	for _, tx := range t.locals {
+		t.addClass(tx, 1)
+	}
+	for _, tx := range t.remotes {
+		t.addClass(tx, 1)
+	}
+}
+
+// addClass adjusts the counter of the priority class the transaction belongs to.
+func (t *lookup) addClass(tx *types.Transaction, delta int) {
+	if t.classify == nil {
+		return
+	}
+	if class := t.classify(tx); class != "" {
+		t.classes[class] += delta
+		if t.classes[class] == 0 {
+			delete(t.classes, class)
+		}
+	}
+}
+
+// ClassCount returns the current number of transactions in a priority class.
+func (t *lookup) ClassCount(class string) int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.classes[class]
+}
+
 // Range calls f on each key and value present in the map. The callback passed
 // should return the indicator whether the iteration needs to be continued.
 // Callers need to specify which set (or both) to be iterated.
@@ -1833,6 +1979,7 @@ func (t *lookup) Add(tx *types.Transaction, local bool) {
 
 	t.slots += numSlots(tx)
 	slotsGauge.Update(int64(t.slots))
+	t.addClass(tx, 1)
 
 	if local {
 		t.locals[tx.Hash()] = tx
@@ -1856,6 +2003,7 @@ func (t *lookup) Remove(hash common.Hash) {
 	}
 	t.slots -= numSlots(tx)
 	slotsGauge.Update(int64(t.slots))
+	t.addClass(tx, -1)
 
 	delete(t.locals, hash)
 	delete(t.remotes, hash)
@@ -1878,6 +2026,23 @@ func (t *lookup) RemoteToLocals(locals *accountSet) int {
 	return migrated
 }
 
+// LocalsToRemotes migrates the local transactions of accounts not contained in
+// the given set into the remote set, returning the migrated transactions.
+func (t *lookup) LocalsToRemotes(locals *accountSet) types.Transactions {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var migrated types.Transactions
+	for hash, tx := range t.locals {
+		if !locals.containsTx(tx) {
+			t.remotes[hash] = tx
+			delete(t.locals, hash)
+			migrated = append(migrated, tx)
+		}
+	}
+	return migrated
+}
+
 // RemotesBelowTip finds all remote transactions below the given tip threshold.
 func (t *lookup) RemotesBelowTip(threshold *big.Int) types.Transactions {
 	found := make(types.Transactions, 0, 128)
@@ -17,6 +17,7 @@
 package txpool
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	crand "crypto/rand"
 	"errors"
@@ -24,6 +25,8 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -2365,6 +2368,357 @@ func testJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that with full pool persistence enabled, remote transactions - both
+// pending and queued - survive restarts, but get revalidated against the head.
+func TestSnapshotting(t *testing.T) {
+	t.Parallel()
+
+	snapshot := filepath.Join(t.TempDir(), "txpool.rlp")
+
+	// Create the original pool and fill it with remote transactions
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := newTestBlockChain(1000000, statedb, new(event.Feed))
+
+	config := testTxPoolConfig
+	config.Snapshot = snapshot
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	remote, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), remote),
+		pricedTransaction(1, 100000, big.NewInt(1), remote),
+		pricedTransaction(3, 100000, big.NewInt(1), remote),
+	}
+	for _, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add remote transaction: %v", err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 2, 1)
+	}
+	// Terminate the pool, include the first transaction and ensure the rest survive
+	pool.Stop()
+	statedb.SetNonce(crypto.PubkeyToAddress(remote.PublicKey), 1)
+	blockchain = newTestBlockChain(1000000, statedb, new(event.Feed))
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 1, 1)
+	}
+	if pool.Get(txs[0].Hash()) != nil {
+		t.Errorf("stale transaction was restored")
+	}
+	if pool.Get(txs[1].Hash()) == nil || pool.Get(txs[2].Hash()) == nil {
+		t.Errorf("live transactions were not restored")
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that a pool dump can be loaded into another pool.
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Stop()
+
+	local, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+
+	pool.addRemoteSync(transaction(0, 100000, key))
+	pool.addRemoteSync(transaction(2, 100000, key))
+	pool.AddLocal(transaction(0, 100000, local))
+
+	var buf bytes.Buffer
+	count, err := pool.Export(&buf)
+	if err != nil {
+		t.Fatalf("failed to export pool: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("exported transaction count mismatch: have %d, want %d", count, 3)
+	}
+	// Import the dump into a fresh pool and ensure everything's restored
+	other, _ := setupPool()
+	defer other.Stop()
+
+	testAddBalance(other, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	testAddBalance(other, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+
+	stats, err := other.Import(bytes.NewReader(buf.Bytes()), true)
+	if err != nil {
+		t.Fatalf("failed to import pool: %v", err)
+	}
+	if stats.Pending != 2 || stats.Queued != 1 || stats.Dropped != 0 {
+		t.Fatalf("import stats mismatch: have %+v", stats)
+	}
+	if pending, queued := other.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 2, 1)
+	}
+	if locals := other.Locals(); len(locals) != 1 || locals[0] != crypto.PubkeyToAddress(local.PublicKey) {
+		t.Fatalf("local accounts mismatch: have %v", locals)
+	}
+	// Untrusted dumps are imported as remote transactions
+	untrusted, _ := setupPool()
+	defer untrusted.Stop()
+
+	testAddBalance(untrusted, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	testAddBalance(untrusted, crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+
+	if _, err := untrusted.Import(bytes.NewReader(buf.Bytes()), false); err != nil {
+		t.Fatalf("failed to import pool: %v", err)
+	}
+	if pending, queued := untrusted.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d/%d, want %d/%d", pending, queued, 2, 1)
+	}
+	if locals := untrusted.Locals(); len(locals) != 0 {
+		t.Fatalf("untrusted import created local accounts: %v", locals)
+	}
+	// Importing garbage must fail
+	if _, err := other.Import(bytes.NewReader([]byte{0xde, 0xad}), true); err == nil {
+		t.Fatalf("imported invalid dump")
+	}
+}
+
+// Tests that the admission limits of priority classes are enforced: rate limited
+// senders are throttled, full classes reject new transactions but accept
+// replacements, and contract creations are assigned to their own class.
+func TestClassLimits(t *testing.T) {
+	t.Parallel()
+
+	var (
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		blockchain = newTestBlockChain(1000000, statedb, new(event.Feed))
+
+		spammerKey, _ = crypto.GenerateKey()
+		spammerAddr   = crypto.PubkeyToAddress(spammerKey.PublicKey)
+		creator, _    = crypto.GenerateKey()
+	)
+	config := testTxPoolConfig
+	config.Classes = []ClassConfig{
+		{Name: "spam", Senders: []common.Address{spammerAddr}, RateLimit: 2},
+		{Name: "create", Creations: true, MaxTxs: 1},
+	}
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	testAddBalance(pool, spammerAddr, big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(creator.PublicKey), big.NewInt(1000000000))
+
+	// The spammer may only submit two transactions within the window, rejected
+	// transactions don't count
+	for i := uint64(0); i < 3; i++ {
+		err := pool.addRemoteSync(transaction(i, 100000, spammerKey))
+		if i < 2 && err != nil {
+			t.Fatalf("transaction %d: failed to add: %v", i, err)
+		}
+		if i == 0 {
+			if err := pool.addRemoteSync(transaction(0, 100001, spammerKey)); !errors.Is(err, ErrReplaceUnderpriced) {
+				t.Fatalf("error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+			}
+		}
+		if i == 2 && !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("transaction %d: error mismatch: have %v, want %v", i, err, ErrRateLimited)
+		}
+	}
+	// Contract creations beyond the class capacity are rejected, replacements aren't
+	create := func(nonce uint64, price int64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewContractCreation(nonce, big.NewInt(0), 100000, big.NewInt(price), nil), types.HomesteadSigner{}, creator)
+		return tx
+	}
+	if err := pool.addRemoteSync(create(0, 1)); err != nil {
+		t.Fatalf("failed to add contract creation: %v", err)
+	}
+	if err := pool.addRemoteSync(create(1, 1)); !errors.Is(err, ErrClassFull) {
+		t.Fatalf("error mismatch: have %v, want %v", err, ErrClassFull)
+	}
+	if err := pool.addRemoteSync(create(0, 2)); err != nil {
+		t.Fatalf("failed to replace contract creation: %v", err)
+	}
+	// Local transactions are exempt from class limits
+	if err := pool.AddLocal(transaction(2, 100000, spammerKey)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	classes := pool.Classes()
+	if classes[0].Transactions != 3 || classes[1].Transactions != 1 {
+		t.Fatalf("class counts mismatch: have %d/%d, want %d/%d", classes[0].Transactions, classes[1].Transactions, 3, 1)
+	}
+	if classes[0].Senders != 1 || classes[1].Senders != 0 {
+		t.Fatalf("class sender counts mismatch: have %d/%d, want %d/%d", classes[0].Senders, classes[1].Senders, 1, 0)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that transactions of guaranteed priority classes are exempt from the
+// fairness based eviction of pending transactions, and that classes can be
+// reconfigured at runtime.
+func TestClassGuaranteedEviction(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := newTestBlockChain(1000000, statedb, new(event.Feed))
+
+	config := testTxPoolConfig
+	config.GlobalSlots = config.AccountSlots * 2
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	whitelisted := crypto.PubkeyToAddress(keys[0].PublicKey)
+
+	// Invalid configurations must be rejected
+	if err := pool.SetClasses([]ClassConfig{{Name: "a"}, {Name: "a"}}); err == nil {
+		t.Fatalf("duplicate class names accepted")
+	}
+	if err := pool.SetClasses([]ClassConfig{{Name: "vip", Senders: []common.Address{whitelisted}, Guaranteed: true}}); err != nil {
+		t.Fatalf("failed to set classes: %v", err)
+	}
+	// Overflow the pending pool from all accounts, only the whitelisted one
+	// should retain all of its transactions
+	txs := types.Transactions{}
+	for _, key := range keys {
+		for j := uint64(0); j < config.GlobalSlots; j++ {
+			txs = append(txs, transaction(j, 100000, key))
+		}
+	}
+	pool.AddRemotesSync(txs)
+
+	if have := pool.pending[whitelisted].Len(); have != int(config.GlobalSlots) {
+		t.Fatalf("guaranteed transactions evicted: have %d, want %d", have, config.GlobalSlots)
+	}
+	for _, key := range keys[1:] {
+		if list := pool.pending[crypto.PubkeyToAddress(key.PublicKey)]; list != nil && list.Len() > int(config.AccountSlots) {
+			t.Fatalf("unprotected account above its allowance: have %d, want <= %d", list.Len(), config.AccountSlots)
+		}
+	}
+	if classes := pool.Classes(); len(classes) != 1 || classes[0].Transactions != int(config.GlobalSlots) {
+		t.Fatalf("class status mismatch: have %+v", classes)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Dropping the classes resets the counters and subjects the formerly
+	// guaranteed transactions to eviction again
+	if err := pool.SetClasses(nil); err != nil {
+		t.Fatalf("failed to reset classes: %v", err)
+	}
+	if classes := pool.Classes(); len(classes) != 0 {
+		t.Fatalf("classes not reset: have %+v", classes)
+	}
+	if locals := pool.all.LocalCount(); locals != 0 {
+		t.Fatalf("demoted transactions still local: have %d", locals)
+	}
+	if err := validatePoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the lifecycle events of transactions are emitted with the correct
+// types and reasons.
+func TestTxEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Stop()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	events := make(chan []TxEvent, 16)
+	sub := pool.SubscribeTxEvents(events)
+	defer sub.Unsubscribe()
+
+	var (
+		first    = pricedTransaction(0, 100000, big.NewInt(1), key)
+		replace  = pricedTransaction(0, 100000, big.NewInt(2), key)
+		rejected = pricedTransaction(0, 100001, big.NewInt(2), key)
+		cheap    = pricedTransaction(1, 100000, big.NewInt(1), key)
+	)
+	pool.addRemoteSync(first)
+	pool.addRemoteSync(replace)
+	pool.addRemoteSync(rejected)
+	pool.addRemoteSync(cheap)
+	pool.SetGasPrice(big.NewInt(2))
+
+	replaced := replace.Hash()
+	want := []TxEvent{
+		{Type: TxEventAdded, Hash: first.Hash()},
+		{Type: TxEventPromoted, Hash: first.Hash()},
+		{Type: TxEventReplaced, Reason: ReasonPriceBump, Hash: first.Hash(), ReplacedBy: &replaced},
+		{Type: TxEventAdded, Hash: replace.Hash()},
+		{Type: TxEventDropped, Reason: ReasonReplaceUnderpriced, Hash: rejected.Hash(), Error: ErrReplaceUnderpriced.Error()},
+		{Type: TxEventAdded, Hash: cheap.Hash()},
+		{Type: TxEventPromoted, Hash: cheap.Hash()},
+		{Type: TxEventEvicted, Reason: ReasonMinTip, Hash: cheap.Hash()},
+	}
+	var have []TxEvent
+	for len(have) < len(want) {
+		select {
+		case batch := <-events:
+			have = append(have, batch...)
+		case <-time.After(time.Second):
+			t.Fatalf("missing events: have %d, want %d", len(have), len(want))
+		}
+	}
+	for i := range want {
+		want[i].From = crypto.PubkeyToAddress(key.PublicKey)
+		want[i].Nonce = have[i].Nonce
+		if !reflect.DeepEqual(have[i], want[i]) {
+			t.Errorf("event %d mismatch: have %+v, want %+v", i, have[i], want[i])
+		}
+	}
+}
+
+// Tests that a subscriber not consuming the lifecycle events doesn't block the
+// pool, its events being dropped instead.
+func TestTxEventsStalledSubscriber(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupPool()
+	defer pool.Stop()
+
+	events := make(chan []TxEvent)
+	sub := pool.SubscribeTxEvents(events)
+	defer sub.Unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		batch := []TxEvent{{Type: TxEventAdded}}
+		for i := 0; i < 2*txEventQueueSize; i++ {
+			pool.sendTxEvents(batch)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool blocked on a stalled subscriber")
+	}
+	// The pool must keep working and deliver events once the subscriber catches up
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	if err := pool.addRemoteSync(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("no events delivered after stall")
+	}
+}
+
 // TestStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestStatusCheck(t *testing.T) {
@@ -17,6 +17,7 @@
 package eth
 
 import (
+	"bytes"
 	"compress/gzip"
 	"context"
 	"errors"
@@ -33,9 +34,13 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
@@ -107,6 +112,16 @@ func (api *MinerAPI) SetExtra(extra string) (bool, error) {
 	return true, nil
 }
 
+// SetTxOrdering sets the transaction ordering policy of the built blocks.
+func (api *MinerAPI) SetTxOrdering(name string) (bool, error) {
+	policy, err := miner.NewTxOrderingPolicy(name)
+	if err != nil {
+		return false, err
+	}
+	api.e.Miner().SetTxOrdering(policy)
+	return true, nil
+}
+
 // SetGasPrice sets the minimum accepted gas price for the miner.
 func (api *MinerAPI) SetGasPrice(gasPrice hexutil.Big) bool {
 	api.e.lock.Lock()
@@ -134,6 +149,74 @@ func (api *MinerAPI) SetRecommitInterval(interval int) {
 	api.e.Miner().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
 }
 
+// TxPoolAPI is the collection of Ethereum full node related transaction pool
+// APIs that are not available on light clients.
+type TxPoolAPI struct {
+	eth *Ethereum
+}
+
+// NewTxPoolAPI creates a new TxPoolAPI instance.
+func NewTxPoolAPI(eth *Ethereum) *TxPoolAPI {
+	return &TxPoolAPI{eth: eth}
+}
+
+// Export dumps all pending and queued transactions of the pool as an RLP
+// stream, which can be loaded into another node via admin_importTxPool.
+func (api *TxPoolAPI) Export() (hexutil.Bytes, error) {
+	var buf bytes.Buffer
+	if _, err := api.eth.TxPool().Export(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Classes returns the priority classes of the transaction pool with their limits,
+// the number of senders listed and the number of transactions pooled in each.
+// The listed senders themselves are not exposed.
+func (api *TxPoolAPI) Classes() []txpool.ClassStatus {
+	return api.eth.TxPool().Classes()
+}
+
+// BundleAPI provides an API to submit atomic transaction bundles to the miner.
+// It is served in the miner namespace, restricting it to trusted callers.
+type BundleAPI struct {
+	e *Ethereum
+}
+
+// NewBundleAPI creates a new BundleAPI instance.
+func NewBundleAPI(e *Ethereum) *BundleAPI {
+	return &BundleAPI{e}
+}
+
+// SendBundleArgs represents the arguments of miner_sendBundle.
+type SendBundleArgs struct {
+	Txs            []hexutil.Bytes `json:"txs"`            // Signed transactions, in order
+	BlockNumber    hexutil.Uint64  `json:"blockNumber"`    // First block the bundle may be included in
+	MaxBlockNumber *hexutil.Uint64 `json:"maxBlockNumber"` // Last block the bundle may be included in (defaults to blockNumber)
+}
+
+// SendBundle submits a bundle of signed transactions which are included into
+// a block within the given range either all together and in order, or not at
+// all. It returns the identifier of the bundle.
+func (api *BundleAPI) SendBundle(args SendBundleArgs) (common.Hash, error) {
+	bundle := &miner.Bundle{
+		Txs:      make(types.Transactions, len(args.Txs)),
+		MinBlock: uint64(args.BlockNumber),
+		MaxBlock: uint64(args.BlockNumber),
+	}
+	if args.MaxBlockNumber != nil {
+		bundle.MaxBlock = uint64(*args.MaxBlockNumber)
+	}
+	for i, blob := range args.Txs {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(blob); err != nil {
+			return common.Hash{}, fmt.Errorf("invalid bundle transaction %d: %v", i, err)
+		}
+		bundle.Txs[i] = tx
+	}
+	return api.e.Miner().SendBundle(bundle)
+}
+
 // AdminAPI is the collection of Ethereum full node related APIs for node
 // administration.
 type AdminAPI struct {
@@ -194,6 +277,32 @@ func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	return true
 }
 
+// PruneState starts deleting the stale state in the background, while the node
+// keeps importing blocks. The progress is reported in the logs.
+func (api *AdminAPI) PruneState() (bool, error) {
+	if err := api.eth.BlockChain().PruneState(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImportTxPool loads a transaction pool dump created by txpool_export into the
+// pool. The transactions are revalidated against the current head, invalid ones
+// are dropped. Transactions are imported as remote ones, unless trustLocals is
+// set to keep the local flags recorded in the dump.
+func (api *AdminAPI) ImportTxPool(blob hexutil.Bytes, trustLocals *bool) (*txpool.ImportStats, error) {
+	return api.eth.TxPool().Import(bytes.NewReader(blob), trustLocals != nil && *trustLocals)
+}
+
+// SetTxPoolClasses replaces the priority classes of the transaction pool. The
+// new admission limits apply to subsequently arriving transactions.
+func (api *AdminAPI) SetTxPoolClasses(classes []txpool.ClassConfig) (bool, error) {
+	if err := api.eth.TxPool().SetClasses(classes); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // ImportChain imports a blockchain from a local file.
 func (api *AdminAPI) ImportChain(file string) (bool, error) {
 	// Make sure the can access the file to import
@@ -612,3 +721,41 @@ func (api *DebugAPI) SetTrieFlushInterval(interval string) error {
 	api.eth.blockchain.SetTrieFlushInterval(t)
 	return nil
 }
+
+// ExecutionWitness re-executes the given block on top of its parent state and
+// returns the execution witness of it: all the trie nodes, contract codes and
+// ancestor headers needed to execute the block without a database.
+func (api *DebugAPI) ExecutionWitness(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*stateless.Witness, error) {
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not executable")
+	}
+	bc := api.eth.blockchain
+	parent := bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %x not found", block.ParentHash())
+	}
+	// Open the parent state without the snapshot, all the state needs to be
+	// resolved from the tries for recording the nodes accessed
+	statedb, err := state.New(parent.Root, bc.StateCache(), nil)
+	if err != nil {
+		return nil, err
+	}
+	witness := stateless.NewWitness(parent)
+	statedb.SetWitness(witness)
+
+	if _, _, _, err := bc.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		return nil, err
+	}
+	statedb.IntermediateRoot(bc.Config().IsEIP158(block.Number()))
+	if err := statedb.Error(); err != nil {
+		return nil, err
+	}
+	return witness, nil
+}
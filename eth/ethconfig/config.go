@@ -74,6 +74,9 @@ var Defaults = Config{
 	},
 	NetworkId:               1,
 	TxLookupLimit:           2350000,
+	StateHistory:            90000,
+	StatePruneBloom:         2048,
+	StateCheckpoint:         1024,
 	LightPeers:              100,
 	UltraLightFraction:      75,
 	DatabaseCache:           512,
@@ -89,7 +92,8 @@ var Defaults = Config{
 	RPCGasCap:               50000000,
 	RPCEVMTimeout:           5 * time.Second,
 	GPO:                     FullNodeGPO,
-	RPCTxFeeCap:             1, // 1 ether
+	RPCTxFeeCap:             1,     // 1 ether
+	TraceCacheRetention:     90000, // ~2 weeks of blocks
 }
 
 func init() {
@@ -135,6 +139,27 @@ type Config struct {
 
 	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
 
+	// StateScheme is the scheme used to store the state trie nodes, "hash" or
+	// "path". Empty selects the scheme the database was initialized with.
+	StateScheme  string `toml:",omitempty"`
+	StateHistory uint64 `toml:",omitempty"` // The maximum number of blocks from head whose state histories are reserved (path scheme only).
+
+	// Online state pruning options, see admin_pruneState (hash scheme only).
+	StatePruneBloom uint64 `toml:",omitempty"` // Megabytes of memory allocated to the bloom filter of live trie nodes
+	StatePruneRate  uint64 `toml:",omitempty"` // Maximum number of trie nodes deleted per second, 0 is unlimited
+
+	StateWorkers int `toml:",omitempty"` // Number of storage tries hashed and committed concurrently, 0 uses all CPUs
+
+	// Diff archive options, historical states are reconstructed from the state
+	// diffs of the blocks following the nearest full state checkpoint.
+	StateDiffArchive bool   `toml:",omitempty"` // Whether to record state diffs and keep periodic checkpoints
+	StateCheckpoint  uint64 `toml:",omitempty"` // Number of blocks between the full state checkpoints
+
+	// History expiry options, the bodies and receipts of the older blocks are
+	// dropped from the ancient store while the headers are kept.
+	HistoryRetain uint64 `toml:",omitempty"` // Number of recent blocks to keep the bodies and receipts for, 0 keeps all
+	HistoryCutoff uint64 `toml:",omitempty"` // Number of the first block to keep the body and receipts for, 0 keeps all
+
 	// RequiredBlocks is a set of block number -> hash mappings which must be in the
 	// canonical chain of all remote peers. Setting the option makes geth verify the
 	// presence of these blocks for every new peer connection.
@@ -199,6 +224,18 @@ type Config struct {
 	// send-transaction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// TraceCache lists the tracers whose results are persisted per transaction.
+	TraceCache []string `toml:",omitempty"`
+
+	// TraceCacheRetention is the number of recent blocks to keep the trace
+	// results of the indexer for, 0 keeps them forever. Results traced on
+	// demand are always kept.
+	TraceCacheRetention uint64 `toml:",omitempty"`
+
+	// TraceCacheIndex enables tracing the transactions of newly imported blocks
+	// in the background to populate the trace cache.
+	TraceCacheIndex bool `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
@@ -26,6 +26,15 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NoPruning               bool
 		NoPrefetch              bool
 		TxLookupLimit           uint64                 `toml:",omitempty"`
+		StateScheme             string                 `toml:",omitempty"`
+		StateHistory            uint64                 `toml:",omitempty"`
+		StatePruneBloom         uint64                 `toml:",omitempty"`
+		StatePruneRate          uint64                 `toml:",omitempty"`
+		StateWorkers            int                    `toml:",omitempty"`
+		StateDiffArchive        bool                   `toml:",omitempty"`
+		StateCheckpoint         uint64                 `toml:",omitempty"`
+		HistoryRetain           uint64                 `toml:",omitempty"`
+		HistoryCutoff           uint64                 `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               int                    `toml:",omitempty"`
 		LightIngress            int                    `toml:",omitempty"`
@@ -58,6 +67,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		RPCGasCap               uint64
 		RPCEVMTimeout           time.Duration
 		RPCTxFeeCap             float64
+		TraceCache              []string                       `toml:",omitempty"`
+		TraceCacheRetention     uint64                         `toml:",omitempty"`
+		TraceCacheIndex         bool                           `toml:",omitempty"`
 		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideShanghai        *uint64                        `toml:",omitempty"`
@@ -71,6 +83,15 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.StateScheme = c.StateScheme
+	enc.StateHistory = c.StateHistory
+	enc.StatePruneBloom = c.StatePruneBloom
+	enc.StatePruneRate = c.StatePruneRate
+	enc.StateWorkers = c.StateWorkers
+	enc.StateDiffArchive = c.StateDiffArchive
+	enc.StateCheckpoint = c.StateCheckpoint
+	enc.HistoryRetain = c.HistoryRetain
+	enc.HistoryCutoff = c.HistoryCutoff
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -103,6 +124,9 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.TraceCache = c.TraceCache
+	enc.TraceCacheRetention = c.TraceCacheRetention
+	enc.TraceCacheIndex = c.TraceCacheIndex
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
 	enc.OverrideShanghai = c.OverrideShanghai
@@ -120,6 +144,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NoPruning               *bool
 		NoPrefetch              *bool
 		TxLookupLimit           *uint64                `toml:",omitempty"`
+		StateScheme             *string                `toml:",omitempty"`
+		StateHistory            *uint64                `toml:",omitempty"`
+		StatePruneBloom         *uint64                `toml:",omitempty"`
+		StatePruneRate          *uint64                `toml:",omitempty"`
+		StateWorkers            *int                   `toml:",omitempty"`
+		StateDiffArchive        *bool                  `toml:",omitempty"`
+		StateCheckpoint         *uint64                `toml:",omitempty"`
+		HistoryRetain           *uint64                `toml:",omitempty"`
+		HistoryCutoff           *uint64                `toml:",omitempty"`
 		RequiredBlocks          map[uint64]common.Hash `toml:"-"`
 		LightServ               *int                   `toml:",omitempty"`
 		LightIngress            *int                   `toml:",omitempty"`
@@ -152,6 +185,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		RPCGasCap               *uint64
 		RPCEVMTimeout           *time.Duration
 		RPCTxFeeCap             *float64
+		TraceCache              []string                       `toml:",omitempty"`
+		TraceCacheRetention     *uint64                        `toml:",omitempty"`
+		TraceCacheIndex         *bool                          `toml:",omitempty"`
 		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideShanghai        *uint64                        `toml:",omitempty"`
@@ -184,6 +220,33 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.StateScheme != nil {
+		c.StateScheme = *dec.StateScheme
+	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
+	if dec.StatePruneBloom != nil {
+		c.StatePruneBloom = *dec.StatePruneBloom
+	}
+	if dec.StatePruneRate != nil {
+		c.StatePruneRate = *dec.StatePruneRate
+	}
+	if dec.StateWorkers != nil {
+		c.StateWorkers = *dec.StateWorkers
+	}
+	if dec.StateDiffArchive != nil {
+		c.StateDiffArchive = *dec.StateDiffArchive
+	}
+	if dec.StateCheckpoint != nil {
+		c.StateCheckpoint = *dec.StateCheckpoint
+	}
+	if dec.HistoryRetain != nil {
+		c.HistoryRetain = *dec.HistoryRetain
+	}
+	if dec.HistoryCutoff != nil {
+		c.HistoryCutoff = *dec.HistoryCutoff
+	}
 	if dec.RequiredBlocks != nil {
 		c.RequiredBlocks = dec.RequiredBlocks
 	}
@@ -280,6 +343,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.TraceCache != nil {
+		c.TraceCache = dec.TraceCache
+	}
+	if dec.TraceCacheRetention != nil {
+		c.TraceCacheRetention = *dec.TraceCacheRetention
+	}
+	if dec.TraceCacheIndex != nil {
+		c.TraceCacheIndex = *dec.TraceCacheIndex
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
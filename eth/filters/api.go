@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/txpool"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -178,6 +179,51 @@ func (api *FilterAPI) NewPendingTransactions(ctx context.Context, fullTx *bool)
 	return rpcSub, nil
 }
 
+// TxpoolEvents creates a subscription that is triggered each time a transaction
+// changes state in the transaction pool: when it is added, promoted, demoted,
+// replaced, dropped or evicted. If senders are given, only the events of the
+// transactions sent by those accounts are delivered.
+func (api *FilterAPI) TxpoolEvents(ctx context.Context, senders *[]common.Address) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	var filter map[common.Address]struct{}
+	if senders != nil {
+		filter = make(map[common.Address]struct{}, len(*senders))
+		for _, addr := range *senders {
+			filter[addr] = struct{}{}
+		}
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan []txpool.TxEvent, 128)
+		eventsSub := api.sys.backend.SubscribeTxPoolEvents(events)
+		defer eventsSub.Unsubscribe()
+
+		for {
+			select {
+			case batch := <-events:
+				for _, ev := range batch {
+					if filter != nil {
+						if _, ok := filter[ev.From]; !ok {
+							continue
+						}
+					}
+					notifier.Notify(rpcSub.ID, ev)
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 func (api *FilterAPI) NewBlockFilter() rpc.ID {
@@ -218,6 +218,7 @@ func ServiceGetBlockBodiesQuery(chain *core.BlockChain, query GetBlockBodiesPack
 	// Gather blocks until the fetch or network limits is reached
 	var (
 		bytes  int
+		pruned int
 		bodies []rlp.RawValue
 	)
 	for lookups, hash := range query {
@@ -228,8 +229,15 @@ func ServiceGetBlockBodiesQuery(chain *core.BlockChain, query GetBlockBodiesPack
 		if data := chain.GetBodyRLP(hash); len(data) != 0 {
 			bodies = append(bodies, data)
 			bytes += len(data)
+		} else if historyPruned(chain, hash) {
+			pruned++
 		}
 	}
+	// The protocol has no way to signal the pruned history, the bodies are
+	// omitted just like the unknown ones.
+	if pruned > 0 {
+		log.Debug("Skipped pruned block bodies", "requested", len(query), "pruned", pruned, "err", core.ErrHistoryPruned)
+	}
 	return bodies
 }
 
@@ -286,6 +294,7 @@ func ServiceGetReceiptsQuery(chain *core.BlockChain, query GetReceiptsPacket) []
 	// Gather state data until the fetch or network limits is reached
 	var (
 		bytes    int
+		pruned   int
 		receipts []rlp.RawValue
 	)
 	for lookups, hash := range query {
@@ -296,7 +305,11 @@ func ServiceGetReceiptsQuery(chain *core.BlockChain, query GetReceiptsPacket) []
 		// Retrieve the requested block's receipts
 		results := chain.GetReceiptsByHash(hash)
 		if results == nil {
-			if header := chain.GetHeaderByHash(hash); header == nil || header.ReceiptHash != types.EmptyRootHash {
+			header := chain.GetHeaderByHash(hash)
+			if header == nil || header.ReceiptHash != types.EmptyRootHash {
+				if header != nil && chain.HistoryPruned(header.Number.Uint64()) {
+					pruned++
+				}
 				continue
 			}
 		}
@@ -308,9 +321,19 @@ func ServiceGetReceiptsQuery(chain *core.BlockChain, query GetReceiptsPacket) []
 			bytes += len(encoded)
 		}
 	}
+	if pruned > 0 {
+		log.Debug("Skipped pruned receipts", "requested", len(query), "pruned", pruned, "err", core.ErrHistoryPruned)
+	}
 	return receipts
 }
 
+// historyPruned reports whether the given block is known but its body and
+// receipts were dropped by history expiry.
+func historyPruned(chain *core.BlockChain, hash common.Hash) bool {
+	header := chain.GetHeaderByHash(hash)
+	return header != nil && chain.HistoryPruned(header.Number.Uint64())
+}
+
 func handleNewBlockhashes(backend Backend, msg Decoder, peer *Peer) error {
 	// A batch of new block announcements just arrived
 	ann := new(NewBlockHashesPacket)
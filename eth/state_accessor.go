@@ -24,6 +24,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -77,6 +78,15 @@ func (eth *Ethereum) StateAtBlock(ctx context.Context, block *types.Block, reexe
 			}, nil
 		}
 	}
+	// The path scheme stores a single version of the state by path, which can't
+	// be shared with an ephemeral trie.Database. Only the recent states kept by
+	// the live database are available.
+	if base == nil && eth.blockchain.TrieDB().Scheme() == rawdb.PathScheme {
+		if statedb, err = eth.blockchain.StateAt(block.Root()); err != nil {
+			return nil, nil, fmt.Errorf("historical state %x is not available in path scheme", block.Root())
+		}
+		return statedb, noopReleaser, nil
+	}
 	// The state is both for reading and writing, or it's unavailable in disk,
 	// try to construct/recover the state over an ephemeral trie.Database for
 	// isolating the live one.
@@ -66,6 +66,10 @@ const (
 	// for tracing. The creation of trace state will be paused if the unused
 	// trace states exceed this limit.
 	maximumPendingTraceStates = 128
+
+	// maxTraceFilterBlocks is the maximum number of blocks a single trace_filter
+	// call is allowed to span.
+	maxTraceFilterBlocks = 1000
 )
 
 var errTxNotFound = errors.New("transaction not found")
@@ -88,16 +92,18 @@ type Backend interface {
 	ChainDb() ethdb.Database
 	StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, readOnly bool, preferDisk bool) (*state.StateDB, StateReleaseFunc, error)
 	StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (*core.Message, vm.BlockContext, *state.StateDB, StateReleaseFunc, error)
+	TraceCache() *TraceCache // Persistent cache of trace results, nil if disabled
 }
 
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
 type API struct {
 	backend Backend
+	tracers *directory // Tracers available by name, the default directory unless overridden in tests
 }
 
 // NewAPI creates a new API definition for the tracing methods of the Ethereum service.
 func NewAPI(backend Backend) *API {
-	return &API{backend: backend}
+	return &API{backend: backend, tracers: &DefaultDirectory}
 }
 
 type chainContext struct {
@@ -613,7 +619,7 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 	// process that generates states in one thread and traces txes
 	// in separate worker threads.
 	if config != nil && config.Tracer != nil && *config.Tracer != "" {
-		if isJS := DefaultDirectory.IsJS(*config.Tracer); isJS {
+		if isJS := api.tracers.IsJS(*config.Tracer); isJS {
 			return api.traceBlockParallel(ctx, block, statedb, config)
 		}
 	}
@@ -870,6 +876,11 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if err != nil {
 		return nil, err
 	}
+	// Serve the result from the trace cache if the tracer's results are persisted
+	cache := api.backend.TraceCache()
+	if res, ok := cache.get(block, int(index), config); ok {
+		return res, nil
+	}
 	msg, vmctx, statedb, release, err := api.backend.StateAtTransaction(ctx, block, int(index), reexec)
 	if err != nil {
 		return nil, err
@@ -882,7 +893,12 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 		TxIndex:     int(index),
 		TxHash:      hash,
 	}
-	return api.traceTx(ctx, msg, txctx, vmctx, statedb, config)
+	res, err := api.traceTx(ctx, msg, txctx, vmctx, statedb, config)
+	if err != nil {
+		return nil, err
+	}
+	cache.put(block, int(index), config, res, false)
+	return res, nil
 }
 
 // TraceCall lets you trace a given eth_call. It collects the structured logs
@@ -931,8 +947,8 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 		}
 		config.BlockOverrides.Apply(&vmctx)
 	}
-	// Execute the trace
-	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee())
+	// Execute the trace, honouring a possibly overridden base fee
+	msg, err := args.ToMessage(api.backend.RPCGasCap(), vmctx.BaseFee)
 	if err != nil {
 		return nil, err
 	}
@@ -949,10 +965,9 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 // be tracer dependent.
 func (api *API) traceTx(ctx context.Context, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
 	var (
-		tracer    Tracer
-		err       error
-		timeout   = defaultTraceTimeout
-		txContext = core.NewEVMTxContext(message)
+		tracer  Tracer
+		err     error
+		timeout = defaultTraceTimeout
 	)
 	if config == nil {
 		config = &TraceConfig{}
@@ -960,19 +975,29 @@ func (api *API) traceTx(ctx context.Context, message *core.Message, txctx *Conte
 	// Default tracer is the struct logger
 	tracer = logger.NewStructLogger(config.Config)
 	if config.Tracer != nil {
-		tracer, err = DefaultDirectory.New(*config.Tracer, txctx, config.TracerConfig)
+		tracer, err = api.tracers.New(*config.Tracer, txctx, config.TracerConfig)
 		if err != nil {
 			return nil, err
 		}
 	}
-	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer, NoBaseFee: true})
-
 	// Define a meaningful timeout of a single transaction trace
 	if config.Timeout != nil {
 		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
 			return nil, err
 		}
 	}
+	if err := api.applyTraced(ctx, message, txctx, vmctx, statedb, tracer, timeout); err != nil {
+		return nil, err
+	}
+	return tracer.GetResult()
+}
+
+// applyTraced executes the given message in the provided environment with the
+// tracer attached, aborting the execution if it exceeds the timeout.
+func (api *API) applyTraced(ctx context.Context, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, tracer Tracer, timeout time.Duration) error {
+	txContext := core.NewEVMTxContext(message)
+	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer, NoBaseFee: true})
+
 	deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 	go func() {
 		<-deadlineCtx.Done()
@@ -986,10 +1011,10 @@ func (api *API) traceTx(ctx context.Context, message *core.Message, txctx *Conte
 
 	// Call Prepare to clear out the statedb access list
 	statedb.SetTxContext(txctx.TxHash, txctx.TxIndex)
-	if _, err = core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.GasLimit)); err != nil {
-		return nil, fmt.Errorf("tracing failed: %w", err)
+	if _, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.GasLimit)); err != nil {
+		return fmt.Errorf("tracing failed: %w", err)
 	}
-	return tracer.GetResult()
+	return nil
 }
 
 // APIs return the collection of RPC services the tracer package offers.
@@ -1000,6 +1025,10 @@ func APIs(backend Backend) []rpc.API {
 			Namespace: "debug",
 			Service:   NewAPI(backend),
 		},
+		{
+			Namespace: "trace",
+			Service:   NewTraceAPI(backend),
+		},
 	}
 }
 
@@ -57,6 +57,7 @@ type testBackend struct {
 	engine      consensus.Engine
 	chaindb     ethdb.Database
 	chain       *core.BlockChain
+	cache       *TraceCache
 
 	refHook func() // Hook is invoked when the requested state is referenced
 	relHook func() // Hook is invoked when the requested state is released
@@ -186,6 +187,10 @@ func (b *testBackend) StateAtTransaction(ctx context.Context, block *types.Block
 	return nil, vm.BlockContext{}, nil, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, block.Hash())
 }
 
+func (b *testBackend) TraceCache() *TraceCache {
+	return b.cache
+}
+
 func TestTraceCall(t *testing.T) {
 	t.Parallel()
 
@@ -893,3 +898,203 @@ func TestTraceChain(t *testing.T) {
 		}
 	}
 }
+
+func TestTraceReplayTransaction(t *testing.T) {
+	t.Parallel()
+
+	// Initialize a contract storing 42 in its first slot
+	accounts := newAccounts(1)
+	contract := common.Address{0xc0, 0xde}
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+			contract: {
+				Balance: new(big.Int),
+				Code:    []byte{byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP)},
+			},
+		},
+	}
+	var target common.Hash
+	backend := newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(0, contract, big.NewInt(0), 50000, b.BaseFee(), nil), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+		target = tx.Hash()
+	})
+	defer backend.chain.Stop()
+	api := NewTraceAPI(backend)
+
+	res, err := api.ReplayTransaction(context.Background(), target, []string{traceTypeVMTrace})
+	if err != nil {
+		t.Fatalf("failed to replay transaction: %v", err)
+	}
+	if res.Trace != nil || res.StateDiff != nil {
+		t.Errorf("unrequested output kinds returned")
+	}
+	have, _ := json.Marshal(res.VMTrace)
+	want := `{"code":"0x602a60005500","ops":[` +
+		`{"cost":3,"ex":{"used":28997,"push":["0x2a"],"mem":null,"store":null},"pc":0,"sub":null,"op":"PUSH1"},` +
+		`{"cost":3,"ex":{"used":28994,"push":["0x0"],"mem":null,"store":null},"pc":2,"sub":null,"op":"PUSH1"},` +
+		`{"cost":22100,"ex":{"used":6894,"push":[],"mem":null,"store":{"key":"0x0","val":"0x2a"}},"pc":4,"sub":null,"op":"SSTORE"},` +
+		`{"cost":0,"ex":{"used":6894,"push":[],"mem":null,"store":null},"pc":5,"sub":null,"op":"STOP"}]}`
+	if string(have) != want {
+		t.Errorf("vmTrace mismatch:\nhave %s\nwant %s", have, want)
+	}
+	// Unknown output kinds must be rejected
+	if _, err := api.ReplayTransaction(context.Background(), target, []string{"bogus"}); err == nil {
+		t.Errorf("unknown trace type accepted")
+	}
+}
+
+func TestStateDiff(t *testing.T) {
+	t.Parallel()
+
+	prestate := `{
+		"pre": {
+			"0x000000000000000000000000000000000000000a": {"balance": "0x10", "nonce": 1},
+			"0x000000000000000000000000000000000000000b": {"balance": "0x5", "code": "0x60", "storage": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x0000000000000000000000000000000000000000000000000000000000000002"}},
+			"0x000000000000000000000000000000000000000c": {"balance": "0x1"}
+		},
+		"post": {
+			"0x000000000000000000000000000000000000000a": {"balance": "0x8", "nonce": 2},
+			"0x000000000000000000000000000000000000000b": {"storage": {}},
+			"0x000000000000000000000000000000000000000d": {"balance": "0x3"}
+		}
+	}`
+	diff, err := newStateDiff(json.RawMessage(prestate))
+	if err != nil {
+		t.Fatalf("failed to convert state diff: %v", err)
+	}
+	have, _ := json.Marshal(diff)
+	want := `{` +
+		`"0x000000000000000000000000000000000000000a":{"balance":{"*":{"from":"0x10","to":"0x8"}},"nonce":{"*":{"from":"0x1","to":"0x2"}},"code":"=","storage":{}},` +
+		`"0x000000000000000000000000000000000000000b":{"balance":"=","nonce":"=","code":"=","storage":{"0x0000000000000000000000000000000000000000000000000000000000000001":{"*":{"from":"0x0000000000000000000000000000000000000000000000000000000000000002","to":"0x0000000000000000000000000000000000000000000000000000000000000000"}}}},` +
+		`"0x000000000000000000000000000000000000000c":{"balance":{"-":"0x1"},"nonce":{"-":"0x0"},"code":{"-":"0x"},"storage":{}},` +
+		`"0x000000000000000000000000000000000000000d":{"balance":{"+":"0x3"},"nonce":{"+":"0x0"},"code":{"+":"0x"},"storage":{}}}`
+	if string(have) != want {
+		t.Errorf("state diff mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+func TestTraceFilterMatch(t *testing.T) {
+	t.Parallel()
+
+	var (
+		call     = `{"action":{"callType":"call","from":"0x000000000000000000000000000000000000000a","to":"0x000000000000000000000000000000000000000b"},"type":"call"}`
+		create   = `{"action":{"from":"0x000000000000000000000000000000000000000a","init":"0x"},"result":{"address":"0x000000000000000000000000000000000000000c"},"type":"create"}`
+		suicide  = `{"action":{"address":"0x000000000000000000000000000000000000000c","refundAddress":"0x000000000000000000000000000000000000000a"},"type":"suicide"}`
+		addrA    = common.HexToAddress("0x0a")
+		addrB    = common.HexToAddress("0x0b")
+		addrC    = common.HexToAddress("0x0c")
+		anything []common.Address
+	)
+	tests := []struct {
+		from, to []common.Address
+		frame    string
+		want     bool
+	}{
+		{anything, anything, call, true},
+		{[]common.Address{addrA}, anything, call, true},
+		{[]common.Address{addrB}, anything, call, false},
+		{[]common.Address{addrA}, []common.Address{addrB}, call, true},
+		{[]common.Address{addrA}, []common.Address{addrC}, call, false},
+		{anything, []common.Address{addrC}, create, true},
+		{[]common.Address{addrC}, []common.Address{addrA}, suicide, true},
+		{[]common.Address{addrA}, anything, suicide, false},
+	}
+	for i, tt := range tests {
+		have, err := newTraceFilter(tt.from, tt.to).match(json.RawMessage(tt.frame))
+		if err != nil {
+			t.Fatalf("test %d: failed to match frame: %v", i, err)
+		}
+		if have != tt.want {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, have, tt.want)
+		}
+	}
+}
+
+func TestTraceFilterRange(t *testing.T) {
+	genesis := &core.Genesis{Config: params.TestChainConfig}
+	backend := newTestBackend(t, maxTraceFilterBlocks+1, genesis, func(i int, b *core.BlockGen) {})
+	defer backend.teardown()
+	api := NewTraceAPI(backend)
+
+	// Ranges beyond the maximum span are rejected up front
+	from, to := rpc.BlockNumber(0), rpc.BlockNumber(maxTraceFilterBlocks)
+	if _, err := api.Filter(context.Background(), TraceFilterArgs{FromBlock: &from, ToBlock: &to}); err == nil {
+		t.Fatal("oversized block range accepted")
+	}
+	from = 1
+	traces, err := api.Filter(context.Background(), TraceFilterArgs{FromBlock: &from, ToBlock: &to})
+	if err != nil {
+		t.Fatalf("failed to filter traces: %v", err)
+	}
+	if len(traces) != 0 {
+		t.Fatalf("unexpected traces in empty blocks: %d", len(traces))
+	}
+}
+
+func TestTraceCache(t *testing.T) {
+	// Register a tracer counting its instantiations, i.e. the executions
+	var (
+		created int32
+		tracers = &directory{elems: make(map[string]elem)}
+	)
+	tracers.Register("cacheTestTracer", func(ctx *Context, cfg json.RawMessage) (Tracer, error) {
+		atomic.AddInt32(&created, 1)
+		return logger.NewStructLogger(nil), nil
+	}, false)
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		},
+	}
+	var txs []common.Hash
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+		txs = append(txs, tx.Hash())
+	})
+	defer backend.chain.Stop()
+	backend.cache = NewTraceCache(backend.chaindb, []string{"cacheTestTracer"}, 1, false)
+	api := NewAPI(backend)
+	api.tracers = tracers
+
+	trace := func(hash common.Hash, config *TraceConfig, want int32) {
+		t.Helper()
+		if _, err := api.TraceTransaction(context.Background(), hash, config); err != nil {
+			t.Fatalf("failed to trace transaction: %v", err)
+		}
+		if have := atomic.LoadInt32(&created); have != want {
+			t.Fatalf("executions mismatch: have %d, want %d", have, want)
+		}
+	}
+	var (
+		tracer = "cacheTestTracer"
+		config = &TraceConfig{Tracer: &tracer}
+		custom = &TraceConfig{Tracer: &tracer, TracerConfig: json.RawMessage(`{"custom": true}`)}
+	)
+	// Repeated traces must be served from the cache, custom configs are never cached
+	trace(txs[0], config, 1)
+	trace(txs[0], config, 1)
+	trace(txs[0], &TraceConfig{Tracer: &tracer, TracerConfig: json.RawMessage(`{ }`)}, 1)
+	trace(txs[0], custom, 2)
+	trace(txs[0], custom, 3)
+
+	// Indexed blocks must not be executed again
+	if err := backend.cache.indexBlock(context.Background(), api, 2); err != nil {
+		t.Fatalf("failed to index block: %v", err)
+	}
+	trace(txs[1], config, 4)
+
+	// Expired indexed results must be pruned, and hence re-executed, while the
+	// ones traced on demand are kept regardless of their age
+	backend.cache.prune(4)
+	trace(txs[0], config, 4)
+	trace(txs[1], config, 5)
+	backend.cache.prune(4)
+	trace(txs[1], config, 5)
+}
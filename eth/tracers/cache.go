@@ -0,0 +1,272 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// traceIndexLimit is the maximum number of blocks the background indexer
+	// goes back on a new chain head, older ones are not indexed.
+	traceIndexLimit = 64
+
+	// traceCachePruneInterval is the number of blocks between two rounds of
+	// removing the expired trace results.
+	traceCachePruneInterval = 128
+)
+
+var (
+	traceCacheHitMeter  = metrics.NewRegisteredMeter("eth/tracers/cache/hit", nil)
+	traceCacheMissMeter = metrics.NewRegisteredMeter("eth/tracers/cache/miss", nil)
+)
+
+// IndexerBackend is the backend needed to keep the trace cache up to date with
+// the chain.
+type IndexerBackend interface {
+	Backend
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// TraceCache persists the results of selected tracers per transaction, so that
+// repeatedly tracing historical transactions doesn't re-execute their blocks.
+// Results are stored in a separate namespace of the chain database, keyed by
+// block.
+//
+// The cache is either populated on demand by debug_traceTransaction, or by a
+// background indexer tracing all transactions of newly imported blocks. The
+// results of the indexer expire after the configured number of blocks, while
+// the ones requested on demand are kept, so that historical transactions only
+// ever get executed once. Only the results of the configured tracers with their
+// default configuration are cached, other configurations are always executed.
+type TraceCache struct {
+	db        ethdb.Database
+	tracers   map[string]struct{} // Names of the tracers whose results are cached
+	retention uint64              // Number of recent blocks to keep indexed results for, 0 keeps all
+	index     bool                // Whether to trace newly imported blocks in the background
+
+	head   atomic.Uint64 // Latest chain head announced to the indexer
+	wake   chan struct{} // Notifies the indexer of a new chain head, coalescing pending ones
+	sub    event.Subscription
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewTraceCache creates a trace cache for the given tracers on top of the
+// chain database.
+func NewTraceCache(db ethdb.Database, tracers []string, retention uint64, index bool) *TraceCache {
+	c := &TraceCache{
+		db:        db,
+		tracers:   make(map[string]struct{}),
+		retention: retention,
+		index:     index,
+		wake:      make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+	}
+	for _, tracer := range tracers {
+		c.tracers[tracer] = struct{}{}
+	}
+	return c
+}
+
+// traceCacheID returns the identifier of a tracer along with its configuration.
+func traceCacheID(tracer string, config json.RawMessage) common.Hash {
+	var buf bytes.Buffer
+	if len(config) > 0 && json.Compact(&buf, config) == nil {
+		if cfg := buf.String(); cfg == "null" || cfg == "{}" {
+			buf.Reset()
+		}
+	}
+	return crypto.Keccak256Hash([]byte(tracer), []byte{0}, buf.Bytes())
+}
+
+// cacheable returns the tracer whose results may be cached for the given trace
+// configuration, or false if the results are not cached. Caller supplied tracer
+// configurations are not cached, as they would allow growing the cache without
+// bounds.
+func (c *TraceCache) cacheable(config *TraceConfig) (string, bool) {
+	if c == nil || config == nil || config.Tracer == nil {
+		return "", false
+	}
+	if traceCacheID(*config.Tracer, config.TracerConfig) != traceCacheID(*config.Tracer, nil) {
+		return "", false
+	}
+	_, ok := c.tracers[*config.Tracer]
+	return *config.Tracer, ok
+}
+
+// get retrieves the cached result of a transaction trace, if available.
+func (c *TraceCache) get(block *types.Block, index int, config *TraceConfig) (json.RawMessage, bool) {
+	tracer, ok := c.cacheable(config)
+	if !ok {
+		return nil, false
+	}
+	res := rawdb.ReadTraceResult(c.db, block.NumberU64(), block.Hash(), uint64(index), traceCacheID(tracer, config.TracerConfig))
+	if res == nil {
+		traceCacheMissMeter.Mark(1)
+		return nil, false
+	}
+	traceCacheHitMeter.Mark(1)
+	return res, true
+}
+
+// put stores the result of a transaction trace if the tracer is cached. Results
+// traced on demand are pinned, only the indexed ones expiring.
+func (c *TraceCache) put(block *types.Block, index int, config *TraceConfig, result interface{}, indexed bool) {
+	tracer, ok := c.cacheable(config)
+	if !ok {
+		return
+	}
+	if res, ok := result.(json.RawMessage); ok {
+		rawdb.WriteTraceResult(c.db, block.NumberU64(), block.Hash(), uint64(index), traceCacheID(tracer, config.TracerConfig), res, !indexed)
+	}
+}
+
+// prune removes the indexed results which expired by the given chain head.
+func (c *TraceCache) prune(head uint64) {
+	if c.retention == 0 || head <= c.retention {
+		return
+	}
+	if deleted := rawdb.DeleteTraceResults(c.db, head-c.retention); deleted > 0 {
+		log.Debug("Pruned expired trace results", "head", head, "deleted", deleted)
+	}
+}
+
+// Start launches the background maintenance of the cache, pruning the expired
+// results and tracing newly imported blocks if indexing is enabled.
+func (c *TraceCache) Start(backend IndexerBackend) {
+	headCh := make(chan core.ChainHeadEvent, 10)
+	c.sub = backend.SubscribeChainHeadEvent(headCh)
+
+	c.wg.Add(2)
+	go c.loop(headCh)
+	go c.indexLoop(NewAPI(backend))
+}
+
+// Stop terminates the background maintenance of the cache.
+func (c *TraceCache) Stop() {
+	if c.sub != nil {
+		c.sub.Unsubscribe()
+	}
+	close(c.closed)
+	c.wg.Wait()
+}
+
+// loop processes the chain head events until the cache is stopped. It only
+// hands the new head over to the indexer, so that the event feed is never held
+// up by tracing.
+func (c *TraceCache) loop(headCh chan core.ChainHeadEvent) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case ev := <-headCh:
+			c.head.Store(ev.Block.NumberU64())
+			select {
+			case c.wake <- struct{}{}:
+			default:
+			}
+		case <-c.sub.Err():
+			return
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// indexLoop traces the blocks up to the latest announced chain head and prunes
+// the expired results, until the cache is stopped.
+func (c *TraceCache) indexLoop(api *API) {
+	defer c.wg.Done()
+
+	// Release the indexer if the cache is stopped in the middle of a block
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.closed
+		cancel()
+	}()
+	var indexed, pruned uint64
+	for {
+		select {
+		case <-c.wake:
+			head := c.head.Load()
+			if c.index {
+				from := indexed + 1
+				if head > traceIndexLimit && from < head-traceIndexLimit {
+					from = head - traceIndexLimit
+				}
+				for number := from; number <= head; number++ {
+					if err := c.indexBlock(ctx, api, number); err != nil {
+						if ctx.Err() == nil {
+							log.Warn("Failed to index block traces", "number", number, "err", err)
+						}
+						break
+					}
+					indexed = number
+				}
+			}
+			if head >= pruned+traceCachePruneInterval {
+				c.prune(head)
+				pruned = head
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// indexBlock traces all transactions of a canonical block with every cached
+// tracer and stores the results.
+func (c *TraceCache) indexBlock(ctx context.Context, api *API, number uint64) error {
+	block, err := api.blockByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return err
+	}
+	if number == 0 || len(block.Transactions()) == 0 {
+		return nil
+	}
+	for name := range c.tracers {
+		tracer := name
+		config := &TraceConfig{Tracer: &tracer}
+		results, err := api.traceBlock(ctx, block, config)
+		if err != nil {
+			return err
+		}
+		for i, res := range results {
+			if res.Error == "" {
+				c.put(block, i, config, res.Result, true)
+			}
+		}
+	}
+	return nil
+}
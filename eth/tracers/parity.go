@@ -0,0 +1,513 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+)
+
+// Output kinds supported by the trace_ namespace.
+const (
+	traceTypeTrace     = "trace"     // Flat list of the call frames
+	traceTypeVMTrace   = "vmTrace"   // Executed operations, nested by call frame
+	traceTypeStateDiff = "stateDiff" // State modifications of the transaction
+)
+
+// parityTraceConfig is the configuration of the flat call tracer producing the
+// trace output, reporting errors the way Parity/OpenEthereum did.
+var parityTraceConfig = json.RawMessage(`{"convertParityErrors":true}`)
+
+// TraceResults is the result of replaying a transaction in the trace_ namespace.
+// Output kinds which were not requested are left empty.
+type TraceResults struct {
+	Output          hexutil.Bytes   `json:"output"`
+	StateDiff       StateDiff       `json:"stateDiff"`
+	Trace           json.RawMessage `json:"trace"`
+	VMTrace         *VMTrace        `json:"vmTrace"`
+	TransactionHash *common.Hash    `json:"transactionHash,omitempty"`
+}
+
+// VMTrace is the list of operations executed within a single call frame.
+type VMTrace struct {
+	Code hexutil.Bytes  `json:"code"`
+	Ops  []*VMOperation `json:"ops"`
+}
+
+// VMOperation is a single executed operation of a vmTrace. The sub trace is set
+// for operations which entered a new call frame executing code.
+type VMOperation struct {
+	Cost uint64               `json:"cost"`
+	Ex   *VMExecutedOperation `json:"ex"`
+	Pc   uint64               `json:"pc"`
+	Sub  *VMTrace             `json:"sub"`
+	Op   string               `json:"op"`
+}
+
+// VMExecutedOperation holds the effects of an executed operation.
+type VMExecutedOperation struct {
+	Used  uint64         `json:"used"`  // Gas remaining after the operation
+	Push  []string       `json:"push"`  // Stack items pushed by the operation
+	Mem   *VMMemoryDiff  `json:"mem"`   // Memory written by the operation
+	Store *VMStorageDiff `json:"store"` // Storage slot written by the operation
+}
+
+// VMMemoryDiff is a memory range written by an operation.
+type VMMemoryDiff struct {
+	Off  int           `json:"off"`
+	Data hexutil.Bytes `json:"data"`
+}
+
+// VMStorageDiff is a storage slot written by an operation.
+type VMStorageDiff struct {
+	Key string `json:"key"`
+	Val string `json:"val"`
+}
+
+// StateDiff is the set of accounts modified by a transaction.
+type StateDiff map[common.Address]*AccountDiff
+
+// AccountDiff describes the modification of every field of an account. Each
+// field is either "=" if unchanged, {"+": value} if the account was created,
+// {"-": value} if it was destroyed, or {"*": {"from": old, "to": new}}.
+type AccountDiff struct {
+	Balance interface{}                 `json:"balance"`
+	Nonce   interface{}                 `json:"nonce"`
+	Code    interface{}                 `json:"code"`
+	Storage map[common.Hash]interface{} `json:"storage"`
+}
+
+// diffSame marks an unchanged field of an account diff.
+const diffSame = "="
+
+func diffBorn(v interface{}) interface{} {
+	return map[string]interface{}{"+": v}
+}
+
+func diffDied(v interface{}) interface{} {
+	return map[string]interface{}{"-": v}
+}
+
+func diffChanged(from, to interface{}) interface{} {
+	return map[string]interface{}{"*": map[string]interface{}{"from": from, "to": to}}
+}
+
+// prestateAccount is an account as reported by the prestate tracer in diff mode,
+// where all fields of the post state are optional.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Nonce   *uint64                     `json:"nonce"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+func (a *prestateAccount) balance() *hexutil.Big {
+	if a.Balance == nil {
+		return (*hexutil.Big)(new(big.Int))
+	}
+	return a.Balance
+}
+
+func (a *prestateAccount) nonce() hexutil.Uint64 {
+	if a.Nonce == nil {
+		return 0
+	}
+	return hexutil.Uint64(*a.Nonce)
+}
+
+func (a *prestateAccount) code() hexutil.Bytes {
+	if a.Code == nil {
+		return hexutil.Bytes{}
+	}
+	return *a.Code
+}
+
+// newStateDiff converts the diff mode output of the prestate tracer into a
+// state diff. Accounts only present in the pre state were destroyed, the ones
+// only present in the post state were created. For accounts present in both,
+// the post state only holds the modified fields.
+func newStateDiff(result json.RawMessage) (StateDiff, error) {
+	var prestate struct {
+		Pre  map[common.Address]*prestateAccount `json:"pre"`
+		Post map[common.Address]*prestateAccount `json:"post"`
+	}
+	if err := json.Unmarshal(result, &prestate); err != nil {
+		return nil, err
+	}
+	diff := make(StateDiff)
+	for addr, pre := range prestate.Pre {
+		post, ok := prestate.Post[addr]
+		if !ok {
+			acc := &AccountDiff{
+				Balance: diffDied(pre.balance()),
+				Nonce:   diffDied(pre.nonce()),
+				Code:    diffDied(pre.code()),
+				Storage: make(map[common.Hash]interface{}),
+			}
+			for key, val := range pre.Storage {
+				acc.Storage[key] = diffDied(val)
+			}
+			diff[addr] = acc
+			continue
+		}
+		acc := &AccountDiff{Balance: diffSame, Nonce: diffSame, Code: diffSame, Storage: make(map[common.Hash]interface{})}
+		if post.Balance != nil {
+			acc.Balance = diffChanged(pre.balance(), post.Balance)
+		}
+		if post.Nonce != nil {
+			acc.Nonce = diffChanged(pre.nonce(), post.nonce())
+		}
+		if post.Code != nil {
+			acc.Code = diffChanged(pre.code(), post.code())
+		}
+		// Changed slots are kept in the pre state unless they were empty, and
+		// are only present in the post state if they are not cleared.
+		for key, val := range pre.Storage {
+			acc.Storage[key] = diffChanged(val, post.Storage[key])
+		}
+		for key, val := range post.Storage {
+			if _, ok := pre.Storage[key]; !ok {
+				acc.Storage[key] = diffChanged(common.Hash{}, val)
+			}
+		}
+		diff[addr] = acc
+	}
+	for addr, post := range prestate.Post {
+		if _, ok := prestate.Pre[addr]; ok {
+			continue
+		}
+		acc := &AccountDiff{
+			Balance: diffBorn(post.balance()),
+			Nonce:   diffBorn(post.nonce()),
+			Code:    diffBorn(post.code()),
+			Storage: make(map[common.Hash]interface{}),
+		}
+		for key, val := range post.Storage {
+			acc.Storage[key] = diffBorn(val)
+		}
+		diff[addr] = acc
+	}
+	return diff, nil
+}
+
+// vmTraceLogger extends the struct logger with the code executed by every call
+// frame, so the logs can be assembled into a vmTrace.
+type vmTraceLogger struct {
+	*logger.StructLogger
+	env  *vm.EVM
+	code map[int][]byte // Code of the call frames, keyed by the index of their first log
+}
+
+func newVMTraceLogger() *vmTraceLogger {
+	return &vmTraceLogger{
+		StructLogger: logger.NewStructLogger(&logger.Config{EnableMemory: true}),
+		code:         make(map[int][]byte),
+	}
+}
+
+func (l *vmTraceLogger) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	l.StructLogger.CaptureStart(env, from, to, create, input, gas, value)
+	l.env = env
+	if create {
+		l.code[0] = input
+	} else {
+		l.code[0] = env.StateDB.GetCode(to)
+	}
+}
+
+func (l *vmTraceLogger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	// Frames entered without executing code (precompiles, plain transfers and
+	// self-destructs) have no logs, a later frame will overwrite their entry.
+	index := len(l.StructLogs())
+	switch typ {
+	case vm.CREATE, vm.CREATE2:
+		l.code[index] = input
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		l.code[index] = l.env.StateDB.GetCode(to)
+	}
+}
+
+// vmTrace assembles the collected logs into a vmTrace. The effects of every
+// operation are derived from the log of the next operation in the same frame.
+func (l *vmTraceLogger) vmTrace() *VMTrace {
+	type frame struct {
+		trace   *VMTrace
+		pending *VMOperation      // Last operation, waiting for its effects
+		log     *logger.StructLog // Log of the pending operation
+	}
+	var (
+		logs   = l.StructLogs()
+		root   = &VMTrace{Code: l.code[0], Ops: []*VMOperation{}}
+		frames = []*frame{{trace: root}}
+	)
+	for i := range logs {
+		log := &logs[i]
+
+		// Leave the returned frames and enter the new one if a call was made
+		for len(frames) > log.Depth {
+			top := frames[len(frames)-1]
+			if top.pending != nil {
+				top.pending.Ex = vmExecuted(top.log, nil)
+			}
+			frames = frames[:len(frames)-1]
+		}
+		if len(frames) < log.Depth {
+			sub := &VMTrace{Code: l.code[i], Ops: []*VMOperation{}}
+			if parent := frames[len(frames)-1]; parent.pending != nil {
+				parent.pending.Sub = sub
+			}
+			frames = append(frames, &frame{trace: sub})
+		}
+		top := frames[len(frames)-1]
+		if top.pending != nil {
+			top.pending.Ex = vmExecuted(top.log, log)
+		}
+		top.pending = &VMOperation{Cost: log.GasCost, Pc: log.Pc, Op: log.Op.String()}
+		top.log = log
+		top.trace.Ops = append(top.trace.Ops, top.pending)
+	}
+	for _, f := range frames {
+		if f.pending != nil {
+			f.pending.Ex = vmExecuted(f.log, nil)
+		}
+	}
+	return root
+}
+
+// vmExecuted derives the effects of an operation from the log of the operation
+// executed after it in the same frame. If there's none, the frame ended with the
+// operation and only the gas usage is known.
+func vmExecuted(log, next *logger.StructLog) *VMExecutedOperation {
+	ex := &VMExecutedOperation{Push: []string{}}
+	if next == nil {
+		if log.Gas > log.GasCost {
+			ex.Used = log.Gas - log.GasCost
+		}
+		return ex
+	}
+	ex.Used = next.Gas
+
+	if n := pushCount(log.Op); n > 0 && n <= len(next.Stack) {
+		for _, item := range next.Stack[len(next.Stack)-n:] {
+			ex.Push = append(ex.Push, item.Hex())
+		}
+	}
+	stack := func(n int) uint64 {
+		if n >= len(log.Stack) {
+			return 0
+		}
+		return log.Stack[len(log.Stack)-1-n].Uint64()
+	}
+	var off, size uint64
+	switch log.Op {
+	case vm.MSTORE:
+		off, size = stack(0), 32
+	case vm.MSTORE8:
+		off, size = stack(0), 1
+	case vm.CALLDATACOPY, vm.CODECOPY, vm.RETURNDATACOPY:
+		off, size = stack(0), stack(2)
+	case vm.EXTCODECOPY:
+		off, size = stack(1), stack(3)
+	case vm.CALL, vm.CALLCODE:
+		off, size = stack(5), stack(6)
+	case vm.DELEGATECALL, vm.STATICCALL:
+		off, size = stack(4), stack(5)
+	case vm.SSTORE:
+		if len(log.Stack) >= 2 {
+			ex.Store = &VMStorageDiff{
+				Key: log.Stack[len(log.Stack)-1].Hex(),
+				Val: log.Stack[len(log.Stack)-2].Hex(),
+			}
+		}
+	}
+	if size > 0 && off+size >= off && off+size <= uint64(len(next.Memory)) {
+		ex.Mem = &VMMemoryDiff{Off: int(off), Data: common.CopyBytes(next.Memory[off : off+size])}
+	}
+	return ex
+}
+
+// pushCount returns the number of stack items reported as pushed by an operation.
+// Swaps report all the items they moved.
+func pushCount(op vm.OpCode) int {
+	switch {
+	case op.IsPush(), op >= vm.DUP1 && op <= vm.DUP16:
+		return 1
+	case op >= vm.SWAP1 && op <= vm.SWAP16:
+		return int(op-vm.SWAP1) + 2
+	case op >= vm.LOG0 && op <= vm.LOG4:
+		return 0
+	}
+	switch op {
+	case vm.STOP, vm.POP, vm.MSTORE, vm.MSTORE8, vm.SSTORE, vm.JUMP, vm.JUMPI, vm.JUMPDEST,
+		vm.CALLDATACOPY, vm.CODECOPY, vm.EXTCODECOPY, vm.RETURNDATACOPY,
+		vm.RETURN, vm.REVERT, vm.SELFDESTRUCT, vm.INVALID:
+		return 0
+	}
+	return 1
+}
+
+// parityTracer runs the tracers needed for the requested output kinds of the
+// trace_ namespace side by side.
+type parityTracer struct {
+	tracers   []Tracer
+	trace     Tracer         // Flat call tracer, if the trace output was requested
+	stateDiff Tracer         // Prestate tracer in diff mode, if the state diff was requested
+	vmTrace   *vmTraceLogger // Struct logger, if the vmTrace output was requested
+	output    []byte         // Return data of the top call frame
+	reason    error          // Textual reason for the interruption
+}
+
+// newParityTracer creates a tracer producing the requested output kinds.
+func newParityTracer(txctx *Context, traceTypes []string) (*parityTracer, error) {
+	t := new(parityTracer)
+	for _, typ := range traceTypes {
+		switch typ {
+		case traceTypeTrace:
+			if t.trace != nil {
+				continue
+			}
+			tracer, err := DefaultDirectory.New("flatCallTracer", txctx, parityTraceConfig)
+			if err != nil {
+				return nil, err
+			}
+			t.trace = tracer
+			t.tracers = append(t.tracers, tracer)
+		case traceTypeStateDiff:
+			if t.stateDiff != nil {
+				continue
+			}
+			tracer, err := DefaultDirectory.New("prestateTracer", txctx, json.RawMessage(`{"diffMode":true}`))
+			if err != nil {
+				return nil, err
+			}
+			t.stateDiff = tracer
+			t.tracers = append(t.tracers, tracer)
+		case traceTypeVMTrace:
+			if t.vmTrace != nil {
+				continue
+			}
+			t.vmTrace = newVMTraceLogger()
+			t.tracers = append(t.tracers, t.vmTrace)
+		default:
+			return nil, fmt.Errorf("unknown trace type %q", typ)
+		}
+	}
+	return t, nil
+}
+
+func (t *parityTracer) CaptureTxStart(gasLimit uint64) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureTxStart(gasLimit)
+	}
+}
+
+func (t *parityTracer) CaptureTxEnd(restGas uint64) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureTxEnd(restGas)
+	}
+}
+
+func (t *parityTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+func (t *parityTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	t.output = common.CopyBytes(output)
+	for _, tracer := range t.tracers {
+		tracer.CaptureEnd(output, gasUsed, err)
+	}
+}
+
+func (t *parityTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+func (t *parityTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureExit(output, gasUsed, err)
+	}
+}
+
+func (t *parityTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+func (t *parityTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	for _, tracer := range t.tracers {
+		tracer.CaptureFault(pc, op, gas, cost, scope, depth, err)
+	}
+}
+
+// results assembles the requested output kinds after the execution.
+func (t *parityTracer) results() (*TraceResults, error) {
+	res := &TraceResults{Output: t.output}
+	if t.output == nil {
+		res.Output = hexutil.Bytes{}
+	}
+	if t.trace != nil {
+		trace, err := t.trace.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		res.Trace = trace
+	}
+	if t.stateDiff != nil {
+		prestate, err := t.stateDiff.GetResult()
+		if err != nil {
+			return nil, err
+		}
+		if res.StateDiff, err = newStateDiff(prestate); err != nil {
+			return nil, err
+		}
+	}
+	if t.vmTrace != nil {
+		if t.reason != nil {
+			return nil, t.reason
+		}
+		res.VMTrace = t.vmTrace.vmTrace()
+	}
+	return res, nil
+}
+
+// GetResult returns the JSON encoded results of all requested output kinds.
+func (t *parityTracer) GetResult() (json.RawMessage, error) {
+	res, err := t.results()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// Stop terminates execution of all the tracers.
+func (t *parityTracer) Stop(err error) {
+	t.reason = err
+	for _, tracer := range t.tracers {
+		tracer.Stop(err)
+	}
+}
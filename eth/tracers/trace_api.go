@@ -0,0 +1,389 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// TraceAPI offers the Parity/OpenEthereum compatible trace_ namespace, built on
+// the flat call tracer, the prestate tracer in diff mode and the struct logger.
+type TraceAPI struct {
+	api *API
+}
+
+// NewTraceAPI creates a new API definition for the trace_ namespace.
+func NewTraceAPI(backend Backend) *TraceAPI {
+	return &TraceAPI{api: NewAPI(backend)}
+}
+
+// TraceFilterArgs are the criteria of the trace_filter method. A trace matches
+// if its sender is in the from list and its recipient is in the to list, empty
+// lists matching any address.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"` // Number of matching traces to skip
+	Count       *uint64          `json:"count"` // Maximum number of traces to return
+}
+
+// ReplayTransaction re-executes a mined transaction, returning the requested
+// output kinds out of trace, vmTrace and stateDiff.
+func (api *TraceAPI) ReplayTransaction(ctx context.Context, hash common.Hash, traceTypes []string) (*TraceResults, error) {
+	tx, blockHash, blockNumber, index, err := api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	// Only mined txes are supported
+	if tx == nil {
+		return nil, errTxNotFound
+	}
+	if blockNumber == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	block, err := api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
+	if err != nil {
+		return nil, err
+	}
+	msg, vmctx, statedb, release, err := api.api.backend.StateAtTransaction(ctx, block, int(index), defaultTraceReexec)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	txctx := &Context{
+		BlockHash:   blockHash,
+		BlockNumber: block.Number(),
+		TxIndex:     int(index),
+		TxHash:      hash,
+	}
+	return api.replayTx(ctx, msg, txctx, vmctx, statedb, traceTypes)
+}
+
+// ReplayBlockTransactions re-executes all transactions of a block, returning
+// the requested output kinds for each of them.
+func (api *TraceAPI) ReplayBlockTransactions(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, traceTypes []string) ([]*TraceResults, error) {
+	block, err := api.blockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent, err := api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		return nil, err
+	}
+	statedb, release, err := api.api.backend.StateAtBlock(ctx, parent, defaultTraceReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var (
+		txs      = block.Transactions()
+		is158    = api.api.backend.ChainConfig().IsEIP158(block.Number())
+		blockCtx = core.NewEVMBlockContext(block.Header(), api.api.chainContext(ctx), nil)
+		signer   = types.MakeSigner(api.api.backend.ChainConfig(), block.Number())
+		results  = make([]*TraceResults, len(txs))
+	)
+	for i, tx := range txs {
+		msg, _ := core.TransactionToMessage(tx, signer, block.BaseFee())
+		txctx := &Context{
+			BlockHash:   block.Hash(),
+			BlockNumber: block.Number(),
+			TxIndex:     i,
+			TxHash:      tx.Hash(),
+		}
+		res, err := api.replayTx(ctx, msg, txctx, blockCtx, statedb, traceTypes)
+		if err != nil {
+			return nil, err
+		}
+		res.TransactionHash = &txctx.TxHash
+		results[i] = res
+
+		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+		statedb.Finalise(is158)
+	}
+	return results, nil
+}
+
+// Block returns the flat call traces of all transactions in a block.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]json.RawMessage, error) {
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.blockTraces(ctx, block)
+}
+
+// Filter returns the flat call traces matching the given criteria within a
+// range of blocks, both ends included. The range defaults to the latest block
+// and may span at most maxTraceFilterBlocks blocks. Blocks are traced
+// concurrently, but the traces are returned in chain order.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]json.RawMessage, error) {
+	from, to := rpc.LatestBlockNumber, rpc.LatestBlockNumber
+	if args.FromBlock != nil {
+		from = *args.FromBlock
+	}
+	if args.ToBlock != nil {
+		to = *args.ToBlock
+	}
+	start, err := api.api.blockByNumber(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := api.api.blockByNumber(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if start.NumberU64() > end.NumberU64() {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", end.NumberU64(), start.NumberU64())
+	}
+	if span := end.NumberU64() - start.NumberU64() + 1; span > maxTraceFilterBlocks {
+		return nil, fmt.Errorf("block range of %d blocks exceeds the maximum of %d", span, maxTraceFilterBlocks)
+	}
+	traces := []json.RawMessage{}
+	if end.NumberU64() == 0 || (args.Count != nil && *args.Count == 0) {
+		return traces, nil // genesis has no transactions to trace
+	}
+	// The chain tracer excludes the first block of the range, start at its parent
+	parent := start
+	if start.NumberU64() > 0 {
+		if parent, err = api.api.blockByNumberAndHash(ctx, rpc.BlockNumber(start.NumberU64()-1), start.ParentHash()); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		filter = newTraceFilter(args.FromAddress, args.ToAddress)
+		tracer = "flatCallTracer"
+		config = &TraceConfig{Tracer: &tracer, TracerConfig: parityTraceConfig}
+		closed = make(chan interface{})
+		resCh  = api.api.traceChain(parent, end, config, closed)
+		skip   uint64
+	)
+	if args.After != nil {
+		skip = *args.After
+	}
+	// Abort the chain tracer on return, draining the already produced results
+	defer func() {
+		close(closed)
+		go func() {
+			for range resCh {
+			}
+		}()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+
+		case res, ok := <-resCh:
+			if !ok {
+				// The chain tracer always delivers the last block, unless it failed
+				return nil, errors.New("chain tracing aborted")
+			}
+			for i, tx := range res.Traces {
+				if tx == nil {
+					return nil, fmt.Errorf("transaction %d of block #%d not traced", i, res.Block)
+				}
+				if tx.Error != "" {
+					return nil, fmt.Errorf("tracing transaction %d of block #%d failed: %v", i, res.Block, tx.Error)
+				}
+				var frames []json.RawMessage
+				if err := json.Unmarshal(tx.Result.(json.RawMessage), &frames); err != nil {
+					return nil, err
+				}
+				for _, frame := range frames {
+					match, err := filter.match(frame)
+					if err != nil {
+						return nil, err
+					}
+					if !match {
+						continue
+					}
+					if skip > 0 {
+						skip--
+						continue
+					}
+					traces = append(traces, frame)
+					if args.Count != nil && uint64(len(traces)) >= *args.Count {
+						return traces, nil
+					}
+				}
+			}
+			if uint64(res.Block) == end.NumberU64() {
+				return traces, nil
+			}
+		}
+	}
+}
+
+// Call executes a call on top of the given block, by default the latest one,
+// returning the requested output kinds out of trace, vmTrace and stateDiff.
+func (api *TraceAPI) Call(ctx context.Context, args ethapi.TransactionArgs, traceTypes []string, blockNrOrHash *rpc.BlockNumberOrHash) (*TraceResults, error) {
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	block, err := api.blockByNumberOrHash(ctx, *blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	statedb, release, err := api.api.backend.StateAtBlock(ctx, block, defaultTraceReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	vmctx := core.NewEVMBlockContext(block.Header(), api.api.chainContext(ctx), nil)
+	msg, err := args.ToMessage(api.api.backend.RPCGasCap(), vmctx.BaseFee)
+	if err != nil {
+		return nil, err
+	}
+	return api.replayTx(ctx, msg, new(Context), vmctx, statedb, traceTypes)
+}
+
+// replayTx executes the given message in the provided environment, producing
+// the requested output kinds.
+func (api *TraceAPI) replayTx(ctx context.Context, message *core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, traceTypes []string) (*TraceResults, error) {
+	tracer, err := newParityTracer(txctx, traceTypes)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.api.applyTraced(ctx, message, txctx, vmctx, statedb, tracer, defaultTraceTimeout); err != nil {
+		return nil, err
+	}
+	return tracer.results()
+}
+
+// blockTraces traces all transactions of a block with the flat call tracer and
+// concatenates their call frames.
+func (api *TraceAPI) blockTraces(ctx context.Context, block *types.Block) ([]json.RawMessage, error) {
+	tracer := "flatCallTracer"
+	results, err := api.api.traceBlock(ctx, block, &TraceConfig{Tracer: &tracer, TracerConfig: parityTraceConfig})
+	if err != nil {
+		return nil, err
+	}
+	traces := []json.RawMessage{}
+	for i, res := range results {
+		if res.Error != "" {
+			return nil, fmt.Errorf("tracing transaction %d failed: %s", i, res.Error)
+		}
+		var frames []json.RawMessage
+		if err := json.Unmarshal(res.Result.(json.RawMessage), &frames); err != nil {
+			return nil, err
+		}
+		traces = append(traces, frames...)
+	}
+	return traces, nil
+}
+
+// blockByNumberOrHash retrieves the block referenced by either its number or hash.
+// Tracing on top of the pending block is not supported.
+func (api *TraceAPI) blockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		return api.api.blockByHash(ctx, hash)
+	}
+	if number, ok := blockNrOrHash.Number(); ok {
+		if number == rpc.PendingBlockNumber {
+			return nil, errors.New("tracing on top of pending is not supported")
+		}
+		return api.api.blockByNumber(ctx, number)
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+// traceFilter matches flat call frames by their sender and recipient.
+type traceFilter struct {
+	from map[common.Address]struct{}
+	to   map[common.Address]struct{}
+}
+
+func newTraceFilter(from, to []common.Address) *traceFilter {
+	f := &traceFilter{
+		from: make(map[common.Address]struct{}),
+		to:   make(map[common.Address]struct{}),
+	}
+	for _, addr := range from {
+		f.from[addr] = struct{}{}
+	}
+	for _, addr := range to {
+		f.to[addr] = struct{}{}
+	}
+	return f
+}
+
+// match reports whether a flat call frame passes the filter. The recipient of a
+// contract creation is the created contract, the sender of a self-destruct is the
+// destroyed contract and its recipient the beneficiary.
+func (f *traceFilter) match(frame json.RawMessage) (bool, error) {
+	if len(f.from) == 0 && len(f.to) == 0 {
+		return true, nil
+	}
+	var call struct {
+		Action struct {
+			From          *common.Address `json:"from"`
+			To            *common.Address `json:"to"`
+			Address       *common.Address `json:"address"`
+			RefundAddress *common.Address `json:"refundAddress"`
+		} `json:"action"`
+		Result *struct {
+			Address *common.Address `json:"address"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(frame, &call); err != nil {
+		return false, err
+	}
+	from, to := call.Action.From, call.Action.To
+	if from == nil {
+		from = call.Action.Address
+	}
+	if to == nil {
+		to = call.Action.RefundAddress
+	}
+	if to == nil && call.Result != nil {
+		to = call.Result.Address
+	}
+	return matchAddress(f.from, from) && matchAddress(f.to, to), nil
+}
+
+// matchAddress reports whether the address is in the set, an empty set matching
+// any address.
+func matchAddress(set map[common.Address]struct{}, addr *common.Address) bool {
+	if len(set) == 0 {
+		return true
+	}
+	if addr == nil {
+		return false
+	}
+	_, ok := set[*addr]
+	return ok
+}
@@ -49,6 +49,17 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 	return NewClient(c), nil
 }
 
+// DialFailover connects a client to several endpoints of the same chain, given in order
+// of preference. Requests and subscriptions move to the next endpoint when the current
+// one fails, see rpc.DialFailover for details.
+func DialFailover(ctx context.Context, rawurls []string, options ...rpc.ClientOption) (*Client, error) {
+	c, err := rpc.DialFailover(ctx, rawurls, options...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
 // NewClient creates a client that uses the given RPC client.
 func NewClient(c *rpc.Client) *Client {
 	return &Client{c}
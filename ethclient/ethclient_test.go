@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 	"time"
@@ -710,3 +711,30 @@ func sendTransaction(ec *Client) error {
 	}
 	return ec.SendTransaction(context.Background(), tx)
 }
+
+func TestDialFailover(t *testing.T) {
+	backend, chain := newTestBackend(t)
+	defer backend.Close()
+	handler, err := backend.RPCHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := httptest.NewServer(handler)
+	defer live.Close()
+	dead := httptest.NewServer(handler)
+	dead.Close()
+
+	ec, err := DialFailover(context.Background(), []string{dead.URL, live.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ec.Close()
+
+	number, err := ec.BlockNumber(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if number != chain[len(chain)-1].NumberU64() {
+		t.Fatalf("wrong block number: have %d, want %d", number, chain[len(chain)-1].NumberU64())
+	}
+}
@@ -362,6 +362,11 @@ func testCallContract(t *testing.T, client *rpc.Client) {
 	if _, err := ec.CallContract(context.Background(), msg, big.NewInt(0), &mapAcc); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	// CallContract with block overrides
+	blockOverrides := BlockOverrides{Time: 1, BaseFee: big.NewInt(1)}
+	if _, err := ec.CallContractWithBlockOverrides(context.Background(), msg, big.NewInt(0), &mapAcc, blockOverrides); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 }
 
 func TestOverrideAccountMarshal(t *testing.T) {
@@ -413,3 +418,39 @@ func TestOverrideAccountMarshal(t *testing.T) {
 		t.Error("want:", expected)
 	}
 }
+
+func TestBlockOverridesMarshal(t *testing.T) {
+	for i, tt := range []struct {
+		bo   BlockOverrides
+		want string
+	}{
+		{
+			bo:   BlockOverrides{},
+			want: `{}`,
+		},
+		{
+			bo: BlockOverrides{
+				Coinbase: common.HexToAddress("0x1111111111111111111111111111111111111111"),
+			},
+			want: `{"coinbase":"0x1111111111111111111111111111111111111111"}`,
+		},
+		{
+			bo: BlockOverrides{
+				Number:     big.NewInt(1),
+				Difficulty: big.NewInt(2),
+				Time:       3,
+				GasLimit:   4,
+				BaseFee:    big.NewInt(5),
+			},
+			want: `{"number":"0x1","difficulty":"0x2","time":"0x3","gasLimit":"0x4","baseFee":"0x5"}`,
+		},
+	} {
+		marshalled, err := json.Marshal(&tt.bo)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(marshalled) != tt.want {
+			t.Errorf("Testcase #%d failed. expected\n%s\ngot\n%s", i, tt.want, marshalled)
+		}
+	}
+}
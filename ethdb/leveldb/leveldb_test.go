@@ -17,6 +17,7 @@
 package leveldb
 
 import (
+	"bytes"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -39,6 +40,60 @@ func TestLevelDB(t *testing.T) {
 	})
 }
 
+func TestSecondary(t *testing.T) {
+	dir := t.TempDir()
+	primary, err := New(dir, 0, 0, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer primary.Close()
+	if err := primary.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	// Open the database alongside the running primary
+	secondary, err := NewSecondary(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open secondary: %v", err)
+	}
+	defer secondary.Close()
+
+	if val, err := secondary.Get([]byte("a")); err != nil || !bytes.Equal(val, []byte("1")) {
+		t.Fatalf("wrong value: have %x, %v, want 1", val, err)
+	}
+	if err := secondary.Put([]byte("b"), []byte("2")); err == nil {
+		t.Fatal("write into secondary succeeded")
+	}
+	batch := secondary.NewBatch()
+	batch.Put([]byte("b"), []byte("2"))
+	if err := batch.Write(); err == nil {
+		t.Fatal("batch write into secondary succeeded")
+	}
+	// Changes of the primary are only visible after catching up
+	if err := primary.Put([]byte("b"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := secondary.Has([]byte("b")); has {
+		t.Fatal("change visible before catching up")
+	}
+	it := secondary.NewIterator(nil, nil)
+	defer it.Release()
+
+	if err := secondary.TryCatchUpWithPrimary(); err != nil {
+		t.Fatalf("failed to catch up: %v", err)
+	}
+	if val, err := secondary.Get([]byte("b")); err != nil || !bytes.Equal(val, []byte("2")) {
+		t.Fatalf("wrong value after catching up: have %x, %v, want 2", val, err)
+	}
+	// Iterators opened before keep on reading the previous view
+	var keys int
+	for it.Next() {
+		keys++
+	}
+	if err := it.Error(); err != nil || keys != 1 {
+		t.Fatalf("wrong stale iteration: have %d keys, %v, want 1", keys, err)
+	}
+}
+
 func BenchmarkLevelDB(b *testing.B) {
 	dbtest.BenchDatabaseSuite(b, func() ethdb.KeyValueStore {
 		db, err := leveldb.Open(storage.NewMemStorage(), nil)
@@ -0,0 +1,385 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !js
+// +build !js
+
+package leveldb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// errSecondary is returned if a secondary database is asked to modify the
+// files of the primary instance.
+var errSecondary = errors.New("leveldb: secondary database is read-only")
+
+// Secondary is a read-only LevelDB instance opened alongside a primary one,
+// which owns the database directory and keeps writing into it. It doesn't take
+// the file lock of the database and only sees the changes of the primary up to
+// the moment it was opened or last caught up with it.
+//
+// The view is a point-in-time snapshot of the primary, nothing refreshes it in
+// the background. The primary is free to compact and delete the table files the
+// secondary reads though, so long-lived users must call TryCatchUpWithPrimary
+// regularly to keep on serving data.
+type Secondary struct {
+	fn      string // filename for reporting
+	cache   int    // Memory allowance of a single handle
+	handles int    // File handle allowance of a single handle
+
+	db     *Database       // Current view of the primary database
+	refs   *sync.WaitGroup // Iterators and snapshots referencing the current view
+	closed bool
+	lock   sync.RWMutex
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewSecondary opens the leveldb database at the given path in secondary mode,
+// without locking it against the primary instance.
+func NewSecondary(file string, cache int, handles int) (*Secondary, error) {
+	db := &Secondary{
+		fn:      file,
+		cache:   cache,
+		handles: handles,
+		refs:    new(sync.WaitGroup),
+		log:     log.New("database", file),
+	}
+	if db.cache < minCache {
+		db.cache = minCache
+	}
+	if db.handles < minHandles {
+		db.handles = minHandles
+	}
+	db.log.Info("Allocated cache and file handles", "cache", common.StorageSize(db.cache*opt.MiB), "handles", db.handles, "secondary", true)
+
+	view, err := db.open()
+	if err != nil {
+		return nil, err
+	}
+	db.db = view
+	return db, nil
+}
+
+// open loads the current state of the primary database.
+func (db *Secondary) open() (*Database, error) {
+	if _, err := os.Stat(filepath.Join(db.fn, "CURRENT")); err != nil {
+		return nil, fmt.Errorf("no database found in %s: %w", db.fn, err)
+	}
+	options := configureOptions(func(options *opt.Options) {
+		options.OpenFilesCacheCapacity = db.handles
+		options.BlockCacheCapacity = db.cache * opt.MiB
+		options.ReadOnly = true
+	})
+	view, err := leveldb.Open(&secondaryStorage{path: db.fn}, options)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{fn: db.fn, db: view, log: db.log}, nil
+}
+
+// TryCatchUpWithPrimary reloads the manifest and the journals of the primary,
+// making all its changes flushed to disk so far visible. Iterators and snapshots
+// created before keep on reading the previous state until they are released.
+func (db *Secondary) TryCatchUpWithPrimary() error {
+	view, err := db.open()
+	if err != nil {
+		return err
+	}
+	db.lock.Lock()
+	if db.closed {
+		db.lock.Unlock()
+		view.Close()
+		return leveldb.ErrClosed
+	}
+	old, refs := db.db, db.refs
+	db.db, db.refs = view, new(sync.WaitGroup)
+	db.lock.Unlock()
+
+	// Release the previous view once nothing is reading from it anymore
+	go func() {
+		refs.Wait()
+		if err := old.Close(); err != nil {
+			db.log.Warn("Failed to close stale database view", "err", err)
+		}
+	}()
+	return nil
+}
+
+// Close releases the current view of the primary database.
+func (db *Secondary) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	return db.db.Close()
+}
+
+// Has retrieves if a key is present in the key-value store.
+func (db *Secondary) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.Has(key)
+}
+
+// Get retrieves the given key if it's present in the key-value store.
+func (db *Secondary) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.Get(key)
+}
+
+// Put is not supported by a secondary database.
+func (db *Secondary) Put(key []byte, value []byte) error {
+	return errSecondary
+}
+
+// Delete is not supported by a secondary database.
+func (db *Secondary) Delete(key []byte) error {
+	return errSecondary
+}
+
+// NewBatch creates a batch which fails to be written into the secondary database.
+func (db *Secondary) NewBatch() ethdb.Batch {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.NewBatch()
+}
+
+// NewBatchWithSize creates a batch which fails to be written into the secondary
+// database.
+func (db *Secondary) NewBatchWithSize(size int) ethdb.Batch {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.NewBatchWithSize(size)
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of the
+// current view of the database.
+func (db *Secondary) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	db.refs.Add(1)
+	return &secondaryIterator{Iterator: db.db.NewIterator(prefix, start), done: db.refs.Done}
+}
+
+// NewSnapshot creates a database snapshot of the current view of the database.
+func (db *Secondary) NewSnapshot() (ethdb.Snapshot, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	snap, err := db.db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	db.refs.Add(1)
+	return &secondarySnapshot{Snapshot: snap, done: db.refs.Done}, nil
+}
+
+// Stat returns a particular internal stat of the database.
+func (db *Secondary) Stat(property string) (string, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.Stat(property)
+}
+
+// Compact is not supported by a secondary database.
+func (db *Secondary) Compact(start []byte, limit []byte) error {
+	return errSecondary
+}
+
+// Path returns the path to the database directory.
+func (db *Secondary) Path() string {
+	return db.fn
+}
+
+// secondaryIterator is an iterator over a view of the secondary database,
+// releasing its reference to the view when done.
+type secondaryIterator struct {
+	ethdb.Iterator
+	done func()
+	once sync.Once
+}
+
+// Release releases associated resources.
+func (it *secondaryIterator) Release() {
+	it.Iterator.Release()
+	it.once.Do(it.done)
+}
+
+// secondarySnapshot is a snapshot of a view of the secondary database,
+// releasing its reference to the view when done.
+type secondarySnapshot struct {
+	ethdb.Snapshot
+	done func()
+	once sync.Once
+}
+
+// Release releases associated resources.
+func (snap *secondarySnapshot) Release() {
+	snap.Snapshot.Release()
+	snap.once.Do(snap.done)
+}
+
+// secondaryStorage is a read-only view of a leveldb directory which, unlike
+// the default file storage, doesn't lock the files against other processes.
+type secondaryStorage struct {
+	path string
+}
+
+type nopLocker struct{}
+
+func (nopLocker) Unlock() {}
+
+// Lock implements storage.Storage, not locking the database directory.
+func (s *secondaryStorage) Lock() (storage.Locker, error) {
+	return nopLocker{}, nil
+}
+
+// Log implements storage.Storage, dropping the internal leveldb logs.
+func (s *secondaryStorage) Log(str string) {}
+
+// SetMeta implements storage.Storage, rejecting the manifest update.
+func (s *secondaryStorage) SetMeta(fd storage.FileDesc) error {
+	return errSecondary
+}
+
+// GetMeta implements storage.Storage, returning the manifest the CURRENT file
+// of the primary points to. The primary replaces the file atomically.
+func (s *secondaryStorage) GetMeta() (storage.FileDesc, error) {
+	blob, err := os.ReadFile(filepath.Join(s.path, "CURRENT"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = os.ErrNotExist
+		}
+		return storage.FileDesc{}, err
+	}
+	fd, ok := parseFileName(strings.TrimSuffix(string(blob), "\n"))
+	if !ok || fd.Type != storage.TypeManifest {
+		return storage.FileDesc{}, fmt.Errorf("corrupted CURRENT file: %q", blob)
+	}
+	return fd, nil
+}
+
+// List implements storage.Storage, returning the files of the given types.
+func (s *secondaryStorage) List(ft storage.FileType) ([]storage.FileDesc, error) {
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var fds []storage.FileDesc
+	for _, entry := range entries {
+		if fd, ok := parseFileName(entry.Name()); ok && fd.Type&ft != 0 {
+			fds = append(fds, fd)
+		}
+	}
+	return fds, nil
+}
+
+// Open implements storage.Storage, opening the given file read-only.
+func (s *secondaryStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	f, err := os.Open(filepath.Join(s.path, fileName(fd)))
+	if os.IsNotExist(err) && fd.Type == storage.TypeTable {
+		// Tables might still be stored with the legacy extension
+		f, err = os.Open(filepath.Join(s.path, fmt.Sprintf("%06d.sst", fd.Num)))
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = os.ErrNotExist
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// Create implements storage.Storage, rejecting the file creation.
+func (s *secondaryStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	return nil, errSecondary
+}
+
+// Remove implements storage.Storage, rejecting the file removal.
+func (s *secondaryStorage) Remove(fd storage.FileDesc) error {
+	return errSecondary
+}
+
+// Rename implements storage.Storage, rejecting the file rename.
+func (s *secondaryStorage) Rename(oldfd, newfd storage.FileDesc) error {
+	return errSecondary
+}
+
+// Close implements storage.Storage.
+func (s *secondaryStorage) Close() error {
+	return nil
+}
+
+// fileName returns the name of a leveldb file in the database directory.
+func fileName(fd storage.FileDesc) string {
+	switch fd.Type {
+	case storage.TypeManifest:
+		return fmt.Sprintf("MANIFEST-%06d", fd.Num)
+	case storage.TypeJournal:
+		return fmt.Sprintf("%06d.log", fd.Num)
+	case storage.TypeTable:
+		return fmt.Sprintf("%06d.ldb", fd.Num)
+	default:
+		return fmt.Sprintf("%06d.tmp", fd.Num)
+	}
+}
+
+// parseFileName parses the name of a leveldb file in the database directory.
+func parseFileName(name string) (fd storage.FileDesc, ok bool) {
+	var tail string
+	if _, err := fmt.Sscanf(name, "%d.%s", &fd.Num, &tail); err == nil {
+		switch tail {
+		case "log":
+			fd.Type = storage.TypeJournal
+		case "ldb", "sst":
+			fd.Type = storage.TypeTable
+		case "tmp":
+			fd.Type = storage.TypeTemp
+		default:
+			return fd, false
+		}
+		return fd, true
+	}
+	if n, _ := fmt.Sscanf(name, "MANIFEST-%d%s", &fd.Num, &tail); n == 1 {
+		fd.Type = storage.TypeManifest
+		return fd, true
+	}
+	return fd, false
+}
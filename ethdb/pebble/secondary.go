@@ -0,0 +1,307 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build (arm64 || amd64) && !openbsd
+
+package pebble
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/bloom"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errSecondary is returned if a secondary database is asked to modify the
+// files of the primary instance.
+var errSecondary = errors.New("pebble: secondary database is read-only")
+
+// Secondary is a read-only pebble instance opened alongside a primary one,
+// which owns the database directory and keeps writing into it. It doesn't take
+// the file lock of the database and only sees the changes of the primary up to
+// the moment it was opened or last caught up with it.
+//
+// The view is a point-in-time snapshot of the primary, nothing refreshes it in
+// the background. The primary is free to compact and delete the table files the
+// secondary reads though, so long-lived users must call TryCatchUpWithPrimary
+// regularly to keep on serving data.
+type Secondary struct {
+	fn      string // filename for reporting
+	cache   int    // Memory allowance of a single handle
+	handles int    // File handle allowance of a single handle
+
+	db     *Database       // Current view of the primary database
+	refs   *sync.WaitGroup // Iterators and snapshots referencing the current view
+	closed bool
+	lock   sync.RWMutex
+
+	log log.Logger // Contextual logger tracking the database path
+}
+
+// NewSecondary opens the pebble database at the given path in secondary mode,
+// without locking it against the primary instance.
+func NewSecondary(file string, cache int, handles int) (*Secondary, error) {
+	db := &Secondary{
+		fn:      file,
+		cache:   cache,
+		handles: handles,
+		refs:    new(sync.WaitGroup),
+		log:     log.New("database", file),
+	}
+	if db.cache < minCache {
+		db.cache = minCache
+	}
+	if db.handles < minHandles {
+		db.handles = minHandles
+	}
+	db.log.Info("Allocated cache and file handles", "cache", common.StorageSize(db.cache*1024*1024), "handles", db.handles, "secondary", true)
+
+	view, err := db.open()
+	if err != nil {
+		return nil, err
+	}
+	db.db = view
+	return db, nil
+}
+
+// open loads the current state of the primary database.
+func (db *Secondary) open() (*Database, error) {
+	opt := &pebble.Options{
+		Cache:        pebble.NewCache(int64(db.cache * 1024 * 1024)),
+		MaxOpenFiles: db.handles,
+		Levels: []pebble.LevelOptions{
+			{FilterPolicy: bloom.FilterPolicy(10)},
+		},
+		ReadOnly: true,
+		FS:       secondaryFS{vfs.Default},
+	}
+	defer opt.Cache.Unref()
+
+	view, err := pebble.Open(db.fn, opt)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{fn: db.fn, db: view, log: db.log}, nil
+}
+
+// TryCatchUpWithPrimary reloads the manifest and the WAL of the primary,
+// making all its changes flushed to disk so far visible. Iterators and snapshots
+// created before keep on reading the previous state until they are released.
+func (db *Secondary) TryCatchUpWithPrimary() error {
+	view, err := db.open()
+	if err != nil {
+		return err
+	}
+	db.lock.Lock()
+	if db.closed {
+		db.lock.Unlock()
+		view.Close()
+		return pebble.ErrClosed
+	}
+	old, refs := db.db, db.refs
+	db.db, db.refs = view, new(sync.WaitGroup)
+	db.lock.Unlock()
+
+	// Release the previous view once nothing is reading from it anymore
+	go func() {
+		refs.Wait()
+		if err := old.Close(); err != nil {
+			db.log.Warn("Failed to close stale database view", "err", err)
+		}
+	}()
+	return nil
+}
+
+// Close releases the current view of the primary database.
+func (db *Secondary) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	return db.db.Close()
+}
+
+// Has retrieves if a key is present in the key-value store.
+func (db *Secondary) Has(key []byte) (bool, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.Has(key)
+}
+
+// Get retrieves the given key if it's present in the key-value store.
+func (db *Secondary) Get(key []byte) ([]byte, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.Get(key)
+}
+
+// Put is not supported by a secondary database.
+func (db *Secondary) Put(key []byte, value []byte) error {
+	return errSecondary
+}
+
+// Delete is not supported by a secondary database.
+func (db *Secondary) Delete(key []byte) error {
+	return errSecondary
+}
+
+// NewBatch creates a batch which fails to be written into the secondary database.
+func (db *Secondary) NewBatch() ethdb.Batch {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.NewBatch()
+}
+
+// NewBatchWithSize creates a batch which fails to be written into the secondary
+// database.
+func (db *Secondary) NewBatchWithSize(size int) ethdb.Batch {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.NewBatchWithSize(size)
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of the
+// current view of the database.
+func (db *Secondary) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	db.refs.Add(1)
+	return &secondaryIterator{Iterator: db.db.NewIterator(prefix, start), done: db.refs.Done}
+}
+
+// NewSnapshot creates a database snapshot of the current view of the database.
+func (db *Secondary) NewSnapshot() (ethdb.Snapshot, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	snap, err := db.db.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	db.refs.Add(1)
+	return &secondarySnapshot{Snapshot: snap, done: db.refs.Done}, nil
+}
+
+// Stat returns a particular internal stat of the database.
+func (db *Secondary) Stat(property string) (string, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.db.Stat(property)
+}
+
+// Compact is not supported by a secondary database.
+func (db *Secondary) Compact(start []byte, limit []byte) error {
+	return errSecondary
+}
+
+// Path returns the path to the database directory.
+func (db *Secondary) Path() string {
+	return db.fn
+}
+
+// secondaryIterator is an iterator over a view of the secondary database,
+// releasing its reference to the view when done.
+type secondaryIterator struct {
+	ethdb.Iterator
+	done func()
+	once sync.Once
+}
+
+// Release releases associated resources.
+func (it *secondaryIterator) Release() {
+	it.Iterator.Release()
+	it.once.Do(it.done)
+}
+
+// secondarySnapshot is a snapshot of a view of the secondary database,
+// releasing its reference to the view when done.
+type secondarySnapshot struct {
+	ethdb.Snapshot
+	done func()
+	once sync.Once
+}
+
+// Release releases associated resources.
+func (snap *secondarySnapshot) Release() {
+	snap.Snapshot.Release()
+	snap.once.Do(snap.done)
+}
+
+// secondaryFS is a read-only view of the file system which, unlike the default
+// one, doesn't lock the database directory against other processes.
+type secondaryFS struct {
+	vfs.FS
+}
+
+type nopLock struct{}
+
+func (nopLock) Close() error { return nil }
+
+// Lock implements vfs.FS, not locking the database directory.
+func (fs secondaryFS) Lock(name string) (io.Closer, error) {
+	return nopLock{}, nil
+}
+
+// Create implements vfs.FS, rejecting the file creation.
+func (fs secondaryFS) Create(name string) (vfs.File, error) {
+	return nil, errSecondary
+}
+
+// Link implements vfs.FS, rejecting the link creation.
+func (fs secondaryFS) Link(oldname, newname string) error {
+	return errSecondary
+}
+
+// Remove implements vfs.FS, rejecting the file removal.
+func (fs secondaryFS) Remove(name string) error {
+	return errSecondary
+}
+
+// RemoveAll implements vfs.FS, rejecting the file removal.
+func (fs secondaryFS) RemoveAll(name string) error {
+	return errSecondary
+}
+
+// Rename implements vfs.FS, rejecting the file rename.
+func (fs secondaryFS) Rename(oldname, newname string) error {
+	return errSecondary
+}
+
+// ReuseForWrite implements vfs.FS, rejecting the file reuse.
+func (fs secondaryFS) ReuseForWrite(oldname, newname string) (vfs.File, error) {
+	return nil, errSecondary
+}
+
+// MkdirAll implements vfs.FS, rejecting the directory creation.
+func (fs secondaryFS) MkdirAll(dir string, perm os.FileMode) error {
+	return errSecondary
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// accumulatorDepth is the depth of the merkle tree over the header records of an
+// epoch, the record list is limited to MaxEra1Size items.
+const accumulatorDepth = 13
+
+// ComputeAccumulator calculates the SSZ hash tree root of the Era1 accumulator,
+// a list of header records made of the block hash and the total difficulty.
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, fmt.Errorf("must have equal number hashes as td values: have %d hashes, %d tds", len(hashes), len(tds))
+	}
+	if len(hashes) > MaxEra1Size {
+		return common.Hash{}, fmt.Errorf("too many records: have %d, max %d", len(hashes), MaxEra1Size)
+	}
+	// Hash the header records, each a container of two 32 byte fields
+	layer := make([]common.Hash, len(hashes))
+	for i := range hashes {
+		td, err := bigToBytes32(tds[i])
+		if err != nil {
+			return common.Hash{}, err
+		}
+		layer[i] = hashPair(hashes[i], common.Hash(td))
+	}
+	// Merkleize the records, padding the list with zero subtrees up to its limit
+	zero := common.Hash{}
+	for depth := 0; depth < accumulatorDepth; depth++ {
+		next := make([]common.Hash, (len(layer)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(layer) {
+				next[i] = hashPair(layer[2*i], layer[2*i+1])
+			} else {
+				next[i] = hashPair(layer[2*i], zero)
+			}
+		}
+		layer, zero = next, hashPair(zero, zero)
+	}
+	root := zero
+	if len(layer) > 0 {
+		root = layer[0]
+	}
+	// Mix in the length of the list
+	var length common.Hash
+	binary.LittleEndian.PutUint64(length[:], uint64(len(hashes)))
+	return hashPair(root, length), nil
+}
+
+// hashPair returns the SHA256 hash of two concatenated chunks.
+func hashPair(a, b common.Hash) common.Hash {
+	h := sha256.New()
+	h.Write(a[:])
+	h.Write(b[:])
+	return common.BytesToHash(h.Sum(nil))
+}
+
+// bigToBytes32 converts a big.Int into a little-endian 32-byte array.
+func bigToBytes32(n *big.Int) (b [32]byte, err error) {
+	if n.Sign() < 0 || n.BitLen() > 256 {
+		return b, fmt.Errorf("number does not fit in 32 bytes: %v", n)
+	}
+	n.FillBytes(b[:])
+	reverseOrder(b[:])
+	return b, nil
+}
+
+// reverseOrder reverses the byte order of a slice.
+func reverseOrder(b []byte) []byte {
+	for i := 0; i < len(b)/2; i++ {
+		b[i], b[len(b)-i-1] = b[len(b)-i-1], b[i]
+	}
+	return b
+}
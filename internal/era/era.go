@@ -0,0 +1,361 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements the Era1 archive format, an indexed e2store container
+// of pre-merge block history: headers, bodies, receipts and total difficulties.
+package era
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/internal/era/e2store"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+// The e2store entry types of Era1 files.
+const (
+	TypeVersion            uint16 = 0x3265
+	TypeCompressedHeader   uint16 = 0x03
+	TypeCompressedBody     uint16 = 0x04
+	TypeCompressedReceipts uint16 = 0x05
+	TypeTotalDifficulty    uint16 = 0x06
+	TypeAccumulator        uint16 = 0x07
+	TypeBlockIndex         uint16 = 0x3266
+
+	MaxEra1Size = 8192 // Maximum number of blocks in an Era1 file
+)
+
+// headerSize is the size of the e2store entry header.
+const headerSize = 8
+
+// Filename returns a recognizable Era1-formatted file name for the specified
+// epoch and network.
+func Filename(network string, epoch int, root common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%s.era1", network, epoch, root.Hex()[2:10])
+}
+
+// ReadDir reads all the era1 files of the given network in a directory and
+// returns their names sorted by epoch. The epochs must be contiguous.
+func ReadDir(dir, network string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory %s: %w", dir, err)
+	}
+	var (
+		next  = -1
+		names []string
+	)
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".era1" {
+			continue
+		}
+		parts := strings.Split(entry.Name(), "-")
+		if len(parts) != 3 || parts[0] != network {
+			// Invalid era1 filename, skip.
+			continue
+		}
+		epoch, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed era1 filename: %s", entry.Name())
+		}
+		if next != -1 && int(epoch) != next {
+			return nil, fmt.Errorf("missing epoch %d", next)
+		}
+		next = int(epoch) + 1
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// ReadAtSeekCloser is the file access needed to read an Era1 archive.
+type ReadAtSeekCloser interface {
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// Era reads an Era1 file.
+type Era struct {
+	f     ReadAtSeekCloser // backing era1 file
+	s     *e2store.Reader  // e2store reader over f
+	start uint64           // number of the first block
+	count uint64           // number of blocks in the file
+	index int64            // offset of the block index entry
+}
+
+// Open opens an Era1 file.
+func Open(filename string) (*Era, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	e, err := From(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// From returns an Era backed by f.
+func From(f ReadAtSeekCloser) (*Era, error) {
+	length, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	// The block count is the last field of the block index
+	b := make([]byte, 16)
+	if length < 16 {
+		return nil, errors.New("era1 file too short")
+	}
+	if _, err := f.ReadAt(b[:8], length-8); err != nil {
+		return nil, err
+	}
+	count := binary.LittleEndian.Uint64(b)
+	if count > MaxEra1Size {
+		return nil, fmt.Errorf("too many blocks in era1 file: %d", count)
+	}
+	e := &Era{
+		f:     f,
+		s:     e2store.NewReader(f),
+		count: count,
+		index: length - headerSize - 16 - 8*int64(count),
+	}
+	if e.index < 0 {
+		return nil, errors.New("era1 file too short")
+	}
+	typ, _, err := e.s.ReadMetadataAt(e.index)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TypeBlockIndex {
+		return nil, fmt.Errorf("invalid block index entry type %d", typ)
+	}
+	if _, err := f.ReadAt(b[:8], e.index+headerSize); err != nil {
+		return nil, err
+	}
+	e.start = binary.LittleEndian.Uint64(b)
+	return e, nil
+}
+
+// Close closes the Era1 file.
+func (e *Era) Close() error {
+	return e.f.Close()
+}
+
+// Start returns the number of the first block in the file.
+func (e *Era) Start() uint64 {
+	return e.start
+}
+
+// Count returns the number of blocks in the file.
+func (e *Era) Count() uint64 {
+	return e.count
+}
+
+// GetBlockByNumber returns the block with the given number.
+func (e *Era) GetBlockByNumber(num uint64) (*types.Block, error) {
+	off, err := e.blockOffset(num)
+	if err != nil {
+		return nil, err
+	}
+	var header types.Header
+	n, err := e.decodeAt(TypeCompressedHeader, off, &header)
+	if err != nil {
+		return nil, err
+	}
+	var body types.Body
+	if _, err := e.decodeAt(TypeCompressedBody, off+int64(n), &body); err != nil {
+		return nil, err
+	}
+	return types.NewBlockWithHeader(&header).WithBody(body.Transactions, body.Uncles), nil
+}
+
+// GetReceiptsByNumber returns the receipts of the block with the given number.
+func (e *Era) GetReceiptsByNumber(num uint64) (types.Receipts, error) {
+	off, err := e.skipEntries(num, 2)
+	if err != nil {
+		return nil, err
+	}
+	var receipts types.Receipts
+	if _, err := e.decodeAt(TypeCompressedReceipts, off, &receipts); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+// GetTotalDifficultyByNumber returns the total difficulty of the chain up to
+// and including the block with the given number.
+func (e *Era) GetTotalDifficultyByNumber(num uint64) (*big.Int, error) {
+	off, err := e.skipEntries(num, 3)
+	if err != nil {
+		return nil, err
+	}
+	entry, _, err := e.s.ReadAt(off)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != TypeTotalDifficulty {
+		return nil, fmt.Errorf("wrong type, want %d have %d", TypeTotalDifficulty, entry.Type)
+	}
+	return new(big.Int).SetBytes(reverseOrder(entry.Value)), nil
+}
+
+// Accumulator returns the accumulator root stored in the file.
+func (e *Era) Accumulator() (common.Hash, error) {
+	if e.count == 0 {
+		return common.Hash{}, errors.New("empty era1 file")
+	}
+	// The accumulator follows the last block tuple
+	off, err := e.skipEntries(e.start+e.count-1, 4)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	entry, err := e.findFrom(off, TypeAccumulator)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(entry.Value), nil
+}
+
+// InitialTD returns the total difficulty before the first block of the file.
+func (e *Era) InitialTD() (*big.Int, error) {
+	block, err := e.GetBlockByNumber(e.start)
+	if err != nil {
+		return nil, err
+	}
+	td, err := e.GetTotalDifficultyByNumber(e.start)
+	if err != nil {
+		return nil, err
+	}
+	return td.Sub(td, block.Difficulty()), nil
+}
+
+// blockOffset returns the offset of the block tuple of the given block number.
+func (e *Era) blockOffset(num uint64) (int64, error) {
+	if num < e.start || num >= e.start+e.count {
+		return 0, fmt.Errorf("out-of-bounds: %d not in [%d, %d)", num, e.start, e.start+e.count)
+	}
+	b := make([]byte, 8)
+	if _, err := e.f.ReadAt(b, e.index+headerSize+8+int64(num-e.start)*8); err != nil {
+		return 0, err
+	}
+	return e.index + int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+// skipEntries returns the offset of the entry following the given number of
+// entries of a block tuple.
+func (e *Era) skipEntries(num uint64, skip int) (int64, error) {
+	off, err := e.blockOffset(num)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < skip; i++ {
+		_, length, err := e.s.ReadMetadataAt(off)
+		if err != nil {
+			return 0, err
+		}
+		off += headerSize + int64(length)
+	}
+	return off, nil
+}
+
+// findFrom returns the first entry of the given type starting at the offset.
+func (e *Era) findFrom(off int64, typ uint16) (*e2store.Entry, error) {
+	for off < e.index {
+		have, length, err := e.s.ReadMetadataAt(off)
+		if err != nil {
+			return nil, err
+		}
+		if have == typ {
+			entry, _, err := e.s.ReadAt(off)
+			return entry, err
+		}
+		off += headerSize + int64(length)
+	}
+	return nil, fmt.Errorf("entry type %d not found", typ)
+}
+
+// decodeAt decodes the snappy compressed RLP value of the entry at the offset,
+// returning the length of the entry.
+func (e *Era) decodeAt(typ uint16, off int64, val interface{}) (int, error) {
+	r, n, err := e.s.ReaderAt(typ, off)
+	if err != nil {
+		return 0, err
+	}
+	if err := rlp.Decode(snappy.NewReader(r), val); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Iterator walks over the blocks of an Era1 file in order.
+type Iterator struct {
+	e    *Era
+	next uint64
+
+	block    *types.Block
+	receipts types.Receipts
+	td       *big.Int
+	err      error
+}
+
+// NewIterator returns an iterator over the blocks of the Era1 file.
+func NewIterator(e *Era) *Iterator {
+	return &Iterator{e: e, next: e.start}
+}
+
+// Next loads the next block, returning false when the end of the file is
+// reached or an error occurs.
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.next >= it.e.start+it.e.count {
+		return false
+	}
+	if it.block, it.err = it.e.GetBlockByNumber(it.next); it.err != nil {
+		return false
+	}
+	if it.receipts, it.err = it.e.GetReceiptsByNumber(it.next); it.err != nil {
+		return false
+	}
+	if it.td, it.err = it.e.GetTotalDifficultyByNumber(it.next); it.err != nil {
+		return false
+	}
+	it.next++
+	return true
+}
+
+// Block returns the current block.
+func (it *Iterator) Block() *types.Block { return it.block }
+
+// Receipts returns the receipts of the current block.
+func (it *Iterator) Receipts() types.Receipts { return it.receipts }
+
+// TotalDifficulty returns the total difficulty up to and including the current block.
+func (it *Iterator) TotalDifficulty() *big.Int { return it.td }
+
+// Error returns the error that stopped the iteration, if any.
+func (it *Iterator) Error() error { return it.err }
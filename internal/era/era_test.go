@@ -0,0 +1,138 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestEra1Builder(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "era1-test")
+	if err != nil {
+		t.Fatalf("error creating temp file: %v", err)
+	}
+	defer f.Close()
+
+	var (
+		builder = NewBuilder(f)
+		blocks  []*types.Block
+		tds     []*big.Int
+		hashes  []common.Hash
+		td      = big.NewInt(1000)
+	)
+	for i := uint64(0); i < 128; i++ {
+		header := &types.Header{
+			Number:     new(big.Int).SetUint64(100 + i),
+			Difficulty: big.NewInt(int64(i + 1)),
+			Extra:      []byte{byte(i)},
+		}
+		receipts := types.Receipts{{CumulativeGasUsed: i, Logs: []*types.Log{}}}
+		block := types.NewBlock(header, nil, nil, receipts, trie.NewStackTrie(nil))
+		td = new(big.Int).Add(td, header.Difficulty)
+
+		if err := builder.Add(block, receipts, td); err != nil {
+			t.Fatalf("error adding entry: %v", err)
+		}
+		blocks, tds, hashes = append(blocks, block), append(tds, td), append(hashes, block.Hash())
+	}
+	root, err := builder.Finalize()
+	if err != nil {
+		t.Fatalf("error finalizing era1: %v", err)
+	}
+	if want, _ := ComputeAccumulator(hashes, tds); root != want {
+		t.Fatalf("accumulator mismatch: have %x, want %x", root, want)
+	}
+	e, err := From(f)
+	if err != nil {
+		t.Fatalf("failed to open era1: %v", err)
+	}
+	if e.Start() != 100 || e.Count() != 128 {
+		t.Fatalf("range mismatch: have [%d, +%d), want [100, +128)", e.Start(), e.Count())
+	}
+	if have, err := e.Accumulator(); err != nil || have != root {
+		t.Fatalf("stored accumulator mismatch: have %x (%v), want %x", have, err, root)
+	}
+	if have, err := e.InitialTD(); err != nil || have.Cmp(big.NewInt(1000)) != 0 {
+		t.Fatalf("initial td mismatch: have %v (%v), want 1000", have, err)
+	}
+	// Random access by block number
+	for _, i := range []int{70, 0, 127, 3} {
+		num := blocks[i].NumberU64()
+		block, err := e.GetBlockByNumber(num)
+		if err != nil {
+			t.Fatalf("block %d: %v", num, err)
+		}
+		if block.Hash() != hashes[i] {
+			t.Fatalf("block %d: hash mismatch", num)
+		}
+		receipts, err := e.GetReceiptsByNumber(num)
+		if err != nil {
+			t.Fatalf("block %d: %v", num, err)
+		}
+		if types.DeriveSha(receipts, trie.NewStackTrie(nil)) != block.ReceiptHash() {
+			t.Fatalf("block %d: receipts mismatch", num)
+		}
+		if td, err := e.GetTotalDifficultyByNumber(num); err != nil || td.Cmp(tds[i]) != 0 {
+			t.Fatalf("block %d: td mismatch: have %v (%v), want %v", num, td, err, tds[i])
+		}
+	}
+	if _, err := e.GetBlockByNumber(99); err == nil {
+		t.Fatal("out of range block returned")
+	}
+	// Sequential iteration
+	it := NewIterator(e)
+	for i := 0; it.Next(); i++ {
+		if it.Block().Hash() != hashes[i] || it.TotalDifficulty().Cmp(tds[i]) != 0 || len(it.Receipts()) != 1 {
+			t.Fatalf("item %d: iterator mismatch", i)
+		}
+	}
+	if it.Error() != nil {
+		t.Fatalf("iteration failed: %v", it.Error())
+	}
+}
+
+func TestEra1Filenames(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		Filename("mainnet", 1, common.Hash{0x1}),
+		Filename("mainnet", 0, common.Hash{0x2}),
+		Filename("sepolia", 0, common.Hash{0x3}),
+		"notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, err := ReadDir(dir, "mainnet")
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(names) != 2 || names[0] != "mainnet-00000-02000000.era1" || names[1] != "mainnet-00001-01000000.era1" {
+		t.Fatalf("unexpected files: %v", names)
+	}
+	os.WriteFile(filepath.Join(dir, Filename("mainnet", 3, common.Hash{})), nil, 0644)
+	if _, err := ReadDir(dir, "mainnet"); err == nil {
+		t.Fatal("missing epoch not detected")
+	}
+}
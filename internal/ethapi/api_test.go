@@ -17,14 +17,26 @@
 package ethapi
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 func TestTransaction_RoundTripRpcJSON(t *testing.T) {
@@ -157,3 +169,161 @@ func allTransactionTypes(addr common.Address, config *params.ChainConfig) []type
 		},
 	}
 }
+
+// testBackend is a Backend backed by a real, in-memory blockchain. Methods not
+// needed by the tests fall through to backendMock.
+type testBackend struct {
+	*backendMock
+	db     ethdb.Database
+	chain  *core.BlockChain
+	engine consensus.Engine
+}
+
+func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) *testBackend {
+	var (
+		engine      = ethash.NewFaker()
+		db          = rawdb.NewMemoryDatabase()
+		cacheConfig = &core.CacheConfig{
+			TrieCleanLimit:    256,
+			TrieDirtyLimit:    256,
+			TrieTimeLimit:     5 * time.Minute,
+			SnapshotLimit:     0,
+			TrieDirtyDisabled: true, // Archive mode
+		}
+	)
+	// Generate blocks for testing
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, n, generator)
+	chain, err := core.NewBlockChain(db, cacheConfig, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	t.Cleanup(chain.Stop)
+
+	mock := newBackendMock()
+	mock.config = gspec.Config
+	return &testBackend{backendMock: mock, db: db, chain: chain, engine: engine}
+}
+
+func (b *testBackend) ChainDb() ethdb.Database          { return b.db }
+func (b *testBackend) ChainConfig() *params.ChainConfig { return b.chain.Config() }
+func (b *testBackend) Engine() consensus.Engine         { return b.engine }
+func (b *testBackend) RPCGasCap() uint64                { return 10000000 }
+func (b *testBackend) CurrentHeader() *types.Header     { return b.chain.CurrentHeader() }
+func (b *testBackend) CurrentBlock() *types.Header      { return b.chain.CurrentBlock() }
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber || number == rpc.PendingBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return b.chain.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.HeaderByNumber(ctx, blockNr)
+	}
+	if blockHash, ok := blockNrOrHash.Hash(); ok {
+		return b.HeaderByHash(ctx, blockHash)
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+func (b *testBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if header == nil || err != nil {
+		return nil, err
+	}
+	return b.chain.GetBlock(header.Hash(), header.Number.Uint64()), nil
+}
+
+func (b *testBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return nil, nil, errors.New("header not found")
+	}
+	stateDb, err := b.chain.StateAt(header.Root)
+	return stateDb, header, err
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg *core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config, blockCtx *vm.BlockContext) (*vm.EVM, func() error, error) {
+	if vmConfig == nil {
+		vmConfig = b.chain.GetVMConfig()
+	}
+	txContext := core.NewEVMTxContext(msg)
+	context := core.NewEVMBlockContext(header, b.chain, nil)
+	if blockCtx != nil {
+		context = *blockCtx
+	}
+	return vm.NewEVM(context, txContext, state, b.chain.Config(), *vmConfig), state.Error, nil
+}
+
+func TestCallBlockOverrides(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sender  = common.Address{0xaa}
+		clock   = common.Address{0xcc}
+		genesis = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+				// Returns the current block timestamp.
+				clock: {Balance: common.Big0, Code: common.FromHex("0x4260005260206000f3")},
+			},
+		}
+		backend = newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {})
+		api     = NewBlockChainAPI(backend)
+		latest  = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		args    = TransactionArgs{From: &sender, To: &clock}
+	)
+	// Without overrides the timestamp of the head is used.
+	res, err := api.Call(context.Background(), args, latest, nil, nil)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if have, want := new(big.Int).SetBytes(res).Uint64(), backend.CurrentHeader().Time; have != want {
+		t.Errorf("wrong timestamp: have %d, want %d", have, want)
+	}
+	// With a time override the call sees the future.
+	future := hexutil.Uint64(4_000_000_000)
+	res, err = api.Call(context.Background(), args, latest, nil, &BlockOverrides{Time: &future})
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if have := new(big.Int).SetBytes(res).Uint64(); have != uint64(future) {
+		t.Errorf("wrong timestamp: have %d, want %d", have, future)
+	}
+	// Gas estimation is capped by the overridden gas limit.
+	limit := hexutil.Uint64(params.TxGas - 1)
+	if _, err := api.EstimateGas(context.Background(), args, &latest, nil, &BlockOverrides{GasLimit: &limit}); err == nil {
+		t.Errorf("expected estimation to fail below the intrinsic gas")
+	}
+	limit = hexutil.Uint64(100_000)
+	if _, err := api.EstimateGas(context.Background(), args, &latest, nil, &BlockOverrides{GasLimit: &limit}); err != nil {
+		t.Errorf("estimation failed: %v", err)
+	}
+	// State overrides precede the block overrides and fund an empty sender.
+	var (
+		pauper    = common.Address{0xbb}
+		price     = (*hexutil.Big)(big.NewInt(params.InitialBaseFee))
+		balance   = (*hexutil.Big)(big.NewInt(params.Ether))
+		overrides = &StateOverride{pauper: OverrideAccount{Balance: &balance}}
+	)
+	args = TransactionArgs{From: &pauper, To: &clock, GasPrice: price}
+	if _, err := api.EstimateGas(context.Background(), args, &latest, nil, &BlockOverrides{GasLimit: &limit}); err == nil {
+		t.Errorf("expected estimation to fail without funds")
+	}
+	if _, err := api.EstimateGas(context.Background(), args, &latest, overrides, &BlockOverrides{GasLimit: &limit}); err != nil {
+		t.Errorf("estimation with state override failed: %v", err)
+	}
+}
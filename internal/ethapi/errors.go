@@ -0,0 +1,78 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+// Error codes returned by the multi-block simulation API.
+const (
+	errCodeInvalidParams         = -32602
+	errCodeReverted              = 3
+	errCodeVMError               = -32015
+	errCodeBlockNumberInvalid    = -38020
+	errCodeBlockTimestampInvalid = -38021
+	errCodeBlockGasLimitReached  = -38015
+	errCodeClientLimitExceeded   = -38026
+	errCodeTxValidation          = -38014
+)
+
+// callError is the error object attached to the result of a single simulated
+// call. It mirrors the JSON-RPC error object so clients can handle it alike.
+type callError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	Data    string `json:"data,omitempty"`
+}
+
+// invalidParamsError is returned when the simulation request is malformed.
+type invalidParamsError struct{ message string }
+
+func (e *invalidParamsError) Error() string  { return e.message }
+func (e *invalidParamsError) ErrorCode() int { return errCodeInvalidParams }
+
+// invalidBlockNumberError is returned when the simulated block numbers are
+// not strictly increasing.
+type invalidBlockNumberError struct{ message string }
+
+func (e *invalidBlockNumberError) Error() string  { return e.message }
+func (e *invalidBlockNumberError) ErrorCode() int { return errCodeBlockNumberInvalid }
+
+// invalidBlockTimestampError is returned when the simulated block timestamps
+// are not strictly increasing.
+type invalidBlockTimestampError struct{ message string }
+
+func (e *invalidBlockTimestampError) Error() string  { return e.message }
+func (e *invalidBlockTimestampError) ErrorCode() int { return errCodeBlockTimestampInvalid }
+
+// blockGasLimitReachedError is returned when the calls of a simulated block
+// request more gas than the block has left.
+type blockGasLimitReachedError struct{ message string }
+
+func (e *blockGasLimitReachedError) Error() string  { return e.message }
+func (e *blockGasLimitReachedError) ErrorCode() int { return errCodeBlockGasLimitReached }
+
+// clientLimitExceededError is returned when the request exceeds a limit set
+// by the node operator.
+type clientLimitExceededError struct{ message string }
+
+func (e *clientLimitExceededError) Error() string  { return e.message }
+func (e *clientLimitExceededError) ErrorCode() int { return errCodeClientLimitExceeded }
+
+// txValidationError is returned in validation mode when a simulated call
+// would not be a valid transaction.
+type txValidationError struct{ message string }
+
+func (e *txValidationError) Error() string  { return e.message }
+func (e *txValidationError) ErrorCode() int { return errCodeTxValidation }
@@ -0,0 +1,154 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// keccak256("Transfer(address,address,uint256)")
+	transferTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+	// ERC-7528
+	transferAddress = common.HexToAddress("0xEeeeeEeeeEeEeeEeEeEeeEEEeeeeEeeeeeeeEEeE")
+)
+
+// tracer is a simple tracer that records all logs and ether transfers. Transfers
+// are recorded as if they were logs emitted by the ERC-7528 pseudo-address.
+//
+// The logs are tracked per call frame, so that logs of reverted frames can be
+// discarded together with the frame.
+type tracer struct {
+	logs           [][]*types.Log
+	traceTransfers bool
+	blockNumber    uint64
+	txHash         common.Hash
+	txIdx          uint
+}
+
+func newTracer(traceTransfers bool, blockNumber uint64, txHash common.Hash, txIndex uint) *tracer {
+	return &tracer{
+		traceTransfers: traceTransfers,
+		blockNumber:    blockNumber,
+		txHash:         txHash,
+		txIdx:          txIndex,
+	}
+}
+
+func (t *tracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *tracer) CaptureTxEnd(restGas uint64) {}
+
+func (t *tracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.logs = append(t.logs, make([]*types.Log, 0))
+	if t.traceTransfers && value != nil && value.Sign() > 0 {
+		t.captureTransfer(from, to, value)
+	}
+}
+
+func (t *tracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if err != nil {
+		t.logs[0] = nil
+	}
+}
+
+func (t *tracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	t.logs = append(t.logs, make([]*types.Log, 0))
+	// DELEGATECALL carries the value of the parent frame but doesn't move it.
+	if t.traceTransfers && typ != vm.DELEGATECALL && value != nil && value.Sign() > 0 {
+		t.captureTransfer(from, to, value)
+	}
+}
+
+func (t *tracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	size := len(t.logs)
+	if size <= 1 {
+		return
+	}
+	// Pop the frame and merge its logs into the parent unless it failed.
+	frame := t.logs[size-1]
+	t.logs = t.logs[:size-1]
+	if err == nil {
+		t.logs[size-2] = append(t.logs[size-2], frame...)
+	}
+}
+
+func (t *tracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if err != nil || op < vm.LOG0 || op > vm.LOG4 {
+		return
+	}
+	var (
+		stack  = scope.Stack
+		offset = stack.Back(0)
+		size   = stack.Back(1)
+		topics = make([]common.Hash, int(op-vm.LOG0))
+	)
+	for i := range topics {
+		topics[i] = common.Hash(stack.Back(2 + i).Bytes32())
+	}
+	// The tracer is invoked before the memory expansion, copy what's there
+	// and leave the rest zeroed.
+	data := make([]byte, size.Uint64())
+	if mem := scope.Memory.Data(); offset.IsUint64() && offset.Uint64() < uint64(len(mem)) {
+		copy(data, mem[offset.Uint64():])
+	}
+	t.captureLog(scope.Contract.Address(), topics, data)
+}
+
+func (t *tracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+func (t *tracer) captureLog(address common.Address, topics []common.Hash, data []byte) {
+	t.logs[len(t.logs)-1] = append(t.logs[len(t.logs)-1], &types.Log{
+		Address:     address,
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: t.blockNumber,
+		TxHash:      t.txHash,
+		TxIndex:     t.txIdx,
+	})
+}
+
+func (t *tracer) captureTransfer(from, to common.Address, value *big.Int) {
+	topics := []common.Hash{
+		transferTopic,
+		common.BytesToHash(from.Bytes()),
+		common.BytesToHash(to.Bytes()),
+	}
+	t.captureLog(transferAddress, topics, common.BigToHash(value).Bytes())
+}
+
+// reset prepares the tracer for the next transaction.
+func (t *tracer) reset(txHash common.Hash, txIdx uint) {
+	t.logs = nil
+	t.txHash = txHash
+	t.txIdx = txIdx
+}
+
+// Logs returns the logs of the last traced transaction.
+func (t *tracer) Logs() []*types.Log {
+	if len(t.logs) == 0 {
+		return nil
+	}
+	return t.logs[0]
+}
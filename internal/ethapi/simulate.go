@@ -0,0 +1,478 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// maxSimulateBlocks is the maximum number of blocks that can be simulated
+	// in a single request, including the empty blocks filling number gaps.
+	maxSimulateBlocks = 256
+
+	// timestampIncrement is the default increment between block timestamps.
+	timestampIncrement = 12
+)
+
+// simBlock is a batch of calls to be simulated sequentially.
+type simBlock struct {
+	BlockOverrides *BlockOverrides
+	StateOverrides *StateOverride
+	Calls          []TransactionArgs
+}
+
+// simCallResult is the result of a simulated call.
+type simCallResult struct {
+	ReturnValue hexutil.Bytes  `json:"returnData"`
+	Logs        []*types.Log   `json:"logs"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+	Status      hexutil.Uint64 `json:"status"`
+	Error       *callError     `json:"error,omitempty"`
+}
+
+// simOpts are the inputs to eth_simulateV1.
+type simOpts struct {
+	BlockStateCalls        []simBlock
+	TraceTransfers         bool
+	Validation             bool
+	ReturnFullTransactions bool
+}
+
+// simulator is a stateful object that simulates a series of blocks.
+// It is not safe for concurrent use.
+type simulator struct {
+	b              Backend
+	state          *state.StateDB
+	base           *types.Header
+	chainConfig    *params.ChainConfig
+	gasCap         uint64
+	traceTransfers bool
+	validate       bool
+	fullTx         bool
+}
+
+// execute runs the simulation of a series of blocks.
+func (sim *simulator) execute(ctx context.Context, blocks []simBlock) ([]map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var (
+		cancel  context.CancelFunc
+		timeout = sim.b.RPCEVMTimeout()
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// Make sure the context is cancelled when the call has completed
+	// this makes sure resources are cleaned up.
+	defer cancel()
+
+	var err error
+	blocks, err = sim.sanitizeChain(blocks)
+	if err != nil {
+		return nil, err
+	}
+	// Prepare the headers upfront; the hashes of the simulated blocks are
+	// only known once they have been processed.
+	headers, err := sim.makeHeaders(blocks)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		results = make([]map[string]interface{}, len(blocks))
+		parent  = sim.base
+	)
+	for bi, block := range blocks {
+		result, callResults, senders, err := sim.processBlock(ctx, &block, headers[bi], parent, headers[:bi], timeout)
+		if err != nil {
+			return nil, err
+		}
+		enc, err := RPCMarshalBlock(result, true, sim.fullTx, sim.chainConfig)
+		if err != nil {
+			return nil, err
+		}
+		// The simulated transactions are unsigned, patch in the actual senders.
+		if sim.fullTx {
+			for i, tx := range enc["transactions"].([]interface{}) {
+				tx.(*RPCTransaction).From = senders[i]
+			}
+		}
+		enc["calls"] = callResults
+		results[bi] = enc
+
+		headers[bi] = result.Header()
+		parent = headers[bi]
+	}
+	return results, nil
+}
+
+func (sim *simulator) processBlock(ctx context.Context, block *simBlock, header, parent *types.Header, headers []*types.Header, timeout time.Duration) (*types.Block, []simCallResult, []common.Address, error) {
+	// Set header fields that depend only on parent block.
+	// Parent hash is needed for evm.GetHashFn to work.
+	header.ParentHash = parent.Hash()
+	if sim.chainConfig.IsLondon(header.Number) {
+		// In non-validation mode base fee is set to 0 if it is not overridden.
+		// This is because it creates an edge case in EVM where gasPrice < baseFee.
+		// Base fee could have been overridden.
+		if header.BaseFee == nil {
+			if sim.validate {
+				header.BaseFee = misc.CalcBaseFee(sim.chainConfig, parent)
+			} else {
+				header.BaseFee = big.NewInt(0)
+			}
+		}
+	}
+	blockContext := core.NewEVMBlockContext(header, NewChainContext(ctx, sim.b), nil)
+	if block.BlockOverrides != nil && block.BlockOverrides.Random != nil {
+		blockContext.Random = block.BlockOverrides.Random
+	}
+	blockContext.GetHash = sim.newGetHashFn(ctx, headers)
+
+	// State overrides are applied prior to execution of a block
+	if err := block.StateOverrides.Apply(sim.state); err != nil {
+		return nil, nil, nil, err
+	}
+	var (
+		gasUsed     uint64
+		txes        = make([]*types.Transaction, len(block.Calls))
+		callResults = make([]simCallResult, len(block.Calls))
+		receipts    = make([]*types.Receipt, len(block.Calls))
+		senders     = make([]common.Address, len(block.Calls))
+		allLogs     []*types.Log
+		gp          = new(core.GasPool).AddGas(header.GasLimit)
+		tracer      = newTracer(sim.traceTransfers, header.Number.Uint64(), common.Hash{}, 0)
+		vmConfig    = &vm.Config{NoBaseFee: !sim.validate, Debug: true, Tracer: tracer}
+	)
+	for i, call := range block.Calls {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, err
+		}
+		if err := sim.sanitizeCall(&call, header, gasUsed); err != nil {
+			return nil, nil, nil, err
+		}
+		tx := call.ToTransaction()
+		txes[i] = tx
+		senders[i] = call.from()
+
+		msg, err := call.ToMessage(sim.gasCap, header.BaseFee)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		msg.Nonce = uint64(*call.Nonce)
+		msg.SkipAccountChecks = !sim.validate
+
+		tracer.reset(tx.Hash(), uint(i))
+		sim.state.SetTxContext(tx.Hash(), i)
+
+		result, err := sim.applyMessage(ctx, msg, header, vmConfig, &blockContext, gp, timeout)
+		if err != nil {
+			if sim.validate {
+				return nil, nil, nil, &txValidationError{fmt.Sprintf("call %d: %v", i, err)}
+			}
+			return nil, nil, nil, fmt.Errorf("call %d: %w", i, err)
+		}
+		// Update the state with pending changes.
+		var root []byte
+		if sim.chainConfig.IsByzantium(header.Number) {
+			sim.state.Finalise(true)
+		} else {
+			root = sim.state.IntermediateRoot(sim.chainConfig.IsEIP158(header.Number)).Bytes()
+		}
+		gasUsed += result.UsedGas
+
+		logs := tracer.Logs()
+		for _, l := range logs {
+			l.Index = uint(len(allLogs))
+			allLogs = append(allLogs, l)
+		}
+		callRes := simCallResult{ReturnValue: result.Return(), Logs: logs, GasUsed: hexutil.Uint64(result.UsedGas)}
+		if logs == nil {
+			callRes.Logs = []*types.Log{}
+		}
+		receipt := &types.Receipt{
+			Type:              tx.Type(),
+			PostState:         root,
+			CumulativeGasUsed: gasUsed,
+			TxHash:            tx.Hash(),
+			GasUsed:           result.UsedGas,
+			Logs:              logs,
+			BlockNumber:       new(big.Int).Set(header.Number),
+			TransactionIndex:  uint(i),
+		}
+		if result.Failed() {
+			receipt.Status = types.ReceiptStatusFailed
+			callRes.Status = hexutil.Uint64(types.ReceiptStatusFailed)
+			if errors.Is(result.Err, vm.ErrExecutionReverted) {
+				// If the result contains a revert reason, try to unpack it.
+				revertErr := newRevertError(result)
+				callRes.Error = &callError{Message: revertErr.Error(), Code: errCodeReverted, Data: revertErr.reason}
+			} else {
+				callRes.Error = &callError{Message: result.Err.Error(), Code: errCodeVMError}
+			}
+		} else {
+			receipt.Status = types.ReceiptStatusSuccessful
+			callRes.Status = hexutil.Uint64(types.ReceiptStatusSuccessful)
+		}
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		if msg.To == nil {
+			receipt.ContractAddress = crypto.CreateAddress(msg.From, tx.Nonce())
+		}
+		receipts[i] = receipt
+		callResults[i] = callRes
+	}
+	header.Root = sim.state.IntermediateRoot(sim.chainConfig.IsEIP158(header.Number))
+	header.GasUsed = gasUsed
+
+	var result *types.Block
+	if sim.chainConfig.IsShanghai(header.Time) {
+		result = types.NewBlockWithWithdrawals(header, txes, nil, receipts, []*types.Withdrawal{}, trie.NewStackTrie(nil))
+	} else {
+		result = types.NewBlock(header, txes, nil, receipts, trie.NewStackTrie(nil))
+	}
+	// The block hash is only known now, fill it into the emitted logs.
+	hash := result.Hash()
+	for _, l := range allLogs {
+		l.BlockHash = hash
+	}
+	return result, callResults, senders, nil
+}
+
+// applyMessage executes a single message on top of the simulator state.
+func (sim *simulator) applyMessage(ctx context.Context, msg *core.Message, header *types.Header, vmConfig *vm.Config, blockContext *vm.BlockContext, gp *core.GasPool, timeout time.Duration) (*core.ExecutionResult, error) {
+	evm, vmError, err := sim.b.GetEVM(ctx, msg, sim.state, header, vmConfig, blockContext)
+	if err != nil {
+		return nil, err
+	}
+	// Wait for the context to be done and cancel the evm. Even if the
+	// EVM has finished, cancelling may be done (repeatedly)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			evm.Cancel()
+		case <-done:
+		}
+	}()
+	result, err := core.ApplyMessage(evm, msg, gp)
+	if err := vmError(); err != nil {
+		return nil, err
+	}
+	// If the timer caused an abort, return an appropriate error message
+	if evm.Cancelled() {
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("err: %w (supplied gas %d)", err, msg.GasLimit)
+	}
+	return result, nil
+}
+
+// sanitizeCall fills in the call fields left empty by the user, based on the
+// simulator state and the gas left in the block.
+func (sim *simulator) sanitizeCall(call *TransactionArgs, header *types.Header, gasUsed uint64) error {
+	if call.Nonce == nil {
+		nonce := sim.state.GetNonce(call.from())
+		call.Nonce = (*hexutil.Uint64)(&nonce)
+	}
+	// Let the call run out of gas at the block gas limit unless specified.
+	remaining := header.GasLimit - gasUsed
+	if call.Gas == nil {
+		call.Gas = (*hexutil.Uint64)(&remaining)
+	}
+	if uint64(*call.Gas) > remaining {
+		return &blockGasLimitReachedError{fmt.Sprintf("block gas limit reached: %d >= %d", gasUsed, header.GasLimit)}
+	}
+	if call.ChainID == nil {
+		call.ChainID = (*hexutil.Big)(sim.chainConfig.ChainID)
+	}
+	if call.MaxFeePerGas != nil && call.MaxPriorityFeePerGas == nil {
+		call.MaxPriorityFeePerGas = new(hexutil.Big)
+	}
+	return nil
+}
+
+// sanitizeChain checks the chain integrity. Specifically it checks that
+// block numbers and timestamp are strictly increasing, setting default values
+// when necessary. Gaps in block numbers are filled with empty blocks.
+func (sim *simulator) sanitizeChain(blocks []simBlock) ([]simBlock, error) {
+	var (
+		res           = make([]simBlock, 0, len(blocks))
+		base          = sim.base
+		prevNumber    = base.Number
+		prevTimestamp = base.Time
+	)
+	for _, block := range blocks {
+		if block.BlockOverrides == nil {
+			block.BlockOverrides = new(BlockOverrides)
+		}
+		if block.BlockOverrides.Number == nil {
+			n := new(big.Int).Add(prevNumber, common.Big1)
+			block.BlockOverrides.Number = (*hexutil.Big)(n)
+		}
+		diff := new(big.Int).Sub(block.BlockOverrides.Number.ToInt(), prevNumber)
+		if diff.Cmp(common.Big0) <= 0 {
+			return nil, &invalidBlockNumberError{fmt.Sprintf("block numbers must be in order: %d <= %d", block.BlockOverrides.Number.ToInt().Uint64(), prevNumber)}
+		}
+		if total := new(big.Int).Sub(block.BlockOverrides.Number.ToInt(), base.Number); total.Cmp(big.NewInt(maxSimulateBlocks)) > 0 {
+			return nil, &clientLimitExceededError{message: "too many blocks"}
+		}
+		if diff.Cmp(common.Big1) > 0 {
+			// Fill the gap with empty blocks.
+			gap := new(big.Int).Sub(diff, common.Big1)
+			// Assign block number to the empty blocks.
+			for i := uint64(0); i < gap.Uint64(); i++ {
+				n := new(big.Int).Add(prevNumber, new(big.Int).SetUint64(i+1))
+				t := prevTimestamp + timestampIncrement
+				b := simBlock{BlockOverrides: &BlockOverrides{Number: (*hexutil.Big)(n), Time: (*hexutil.Uint64)(&t)}}
+				prevTimestamp = t
+				res = append(res, b)
+			}
+		}
+		// Only append block after filling a potential gap.
+		prevNumber = block.BlockOverrides.Number.ToInt()
+		var t uint64
+		if block.BlockOverrides.Time == nil {
+			t = prevTimestamp + timestampIncrement
+			block.BlockOverrides.Time = (*hexutil.Uint64)(&t)
+		} else {
+			t = uint64(*block.BlockOverrides.Time)
+			if t <= prevTimestamp {
+				return nil, &invalidBlockTimestampError{fmt.Sprintf("block timestamps must be in order: %d <= %d", t, prevTimestamp)}
+			}
+		}
+		prevTimestamp = t
+		res = append(res, block)
+	}
+	return res, nil
+}
+
+// makeHeaders makes header object with preliminary fields based on a simulated block.
+// Some fields have to be filled post-execution.
+// It assumes blocks are in order and numbers have been validated.
+func (sim *simulator) makeHeaders(blocks []simBlock) ([]*types.Header, error) {
+	var (
+		res    = make([]*types.Header, len(blocks))
+		base   = sim.base
+		header = base
+	)
+	for bi, block := range blocks {
+		if block.BlockOverrides == nil || block.BlockOverrides.Number == nil {
+			return nil, errors.New("empty block number")
+		}
+		overrides := block.BlockOverrides
+
+		var difficulty *big.Int
+		if overrides.Difficulty != nil {
+			difficulty = overrides.Difficulty.ToInt()
+		} else {
+			difficulty = new(big.Int).Set(header.Difficulty)
+		}
+		header = &types.Header{
+			UncleHash:  types.EmptyUncleHash,
+			Coinbase:   base.Coinbase,
+			Difficulty: difficulty,
+			Number:     overrides.Number.ToInt(),
+			GasLimit:   base.GasLimit,
+			Time:       uint64(*overrides.Time),
+		}
+		if overrides.Coinbase != nil {
+			header.Coinbase = *overrides.Coinbase
+		}
+		if overrides.GasLimit != nil {
+			header.GasLimit = uint64(*overrides.GasLimit)
+		}
+		if overrides.Random != nil {
+			header.MixDigest = *overrides.Random
+		}
+		if overrides.BaseFee != nil {
+			header.BaseFee = overrides.BaseFee.ToInt()
+		}
+		res[bi] = header
+	}
+	return res, nil
+}
+
+// newGetHashFn returns a BLOCKHASH resolver which is aware of the already
+// processed simulated blocks and falls back to the canonical chain below them.
+func (sim *simulator) newGetHashFn(ctx context.Context, headers []*types.Header) vm.GetHashFunc {
+	chainFn := core.GetHashFn(sim.base, NewChainContext(ctx, sim.b))
+	return func(n uint64) common.Hash {
+		if n <= sim.base.Number.Uint64() {
+			return chainFn(n)
+		}
+		for _, header := range headers {
+			if header.Number.Uint64() == n {
+				return header.Hash()
+			}
+		}
+		return common.Hash{}
+	}
+}
+
+// SimulateV1 executes series of transactions on top of a base state.
+// The transactions are packed into blocks. For each block, block header
+// fields can be overridden. The state can also be overridden prior to
+// execution of each block.
+//
+// Note, this function doesn't make any changes in the state/blockchain and is
+// useful to execute and retrieve values.
+func (s *BlockChainAPI) SimulateV1(ctx context.Context, opts simOpts, blockNrOrHash *rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+	if len(opts.BlockStateCalls) == 0 {
+		return nil, &invalidParamsError{message: "empty input"}
+	} else if len(opts.BlockStateCalls) > maxSimulateBlocks {
+		return nil, &clientLimitExceededError{message: "too many blocks"}
+	}
+	if blockNrOrHash == nil {
+		n := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &n
+	}
+	state, base, err := s.b.StateAndHeaderByNumberOrHash(ctx, *blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	sim := &simulator{
+		b:              s.b,
+		state:          state,
+		base:           base,
+		chainConfig:    s.b.ChainConfig(),
+		gasCap:         s.b.RPCGasCap(),
+		traceTransfers: opts.TraceTransfers,
+		validate:       opts.Validation,
+		fullTx:         opts.ReturnFullTransactions,
+	}
+	return sim.execute(ctx, opts.BlockStateCalls)
+}
@@ -0,0 +1,235 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// returnNumber returns the current block number.
+	returnNumber = hexutil.Bytes(common.FromHex("0x4360005260206000f3"))
+	// returnTimestamp returns the current block timestamp.
+	returnTimestamp = hexutil.Bytes(common.FromHex("0x4260005260206000f3"))
+	// revertCode reverts without any data.
+	revertCode = hexutil.Bytes(common.FromHex("0x60006000fd"))
+	// emitLog emits a LOG1 with topic 0x01 and no data.
+	emitLog = hexutil.Bytes(common.FromHex("0x600160006000a100"))
+)
+
+func newSimulateBackend(t *testing.T) (*testBackend, common.Address, common.Address) {
+	var (
+		sender   = common.Address{0xaa}
+		receiver = common.Address{0xbb}
+		genesis  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				sender: {Balance: big.NewInt(params.Ether)},
+			},
+		}
+	)
+	return newTestBackend(t, 4, genesis, func(i int, b *core.BlockGen) {}), sender, receiver
+}
+
+func TestSimulateV1(t *testing.T) {
+	t.Parallel()
+
+	var (
+		backend, sender, receiver = newSimulateBackend(t)
+		api                       = NewBlockChainAPI(backend)
+		contract                  = common.Address{0xcc}
+		reverter                  = common.Address{0xdd}
+		logger                    = common.Address{0xee}
+		value                     = (*hexutil.Big)(big.NewInt(1000))
+		number                    = (*hexutil.Big)(big.NewInt(10))
+		timestamp                 = hexutil.Uint64(1_000_000)
+	)
+	opts := simOpts{
+		TraceTransfers: true,
+		BlockStateCalls: []simBlock{
+			{
+				Calls: []TransactionArgs{{From: &sender, To: &receiver, Value: value}},
+			},
+			{
+				BlockOverrides: &BlockOverrides{Number: number, Time: &timestamp},
+				StateOverrides: &StateOverride{
+					contract: OverrideAccount{Code: &returnNumber},
+					reverter: OverrideAccount{Code: &revertCode},
+					logger:   OverrideAccount{Code: &emitLog},
+				},
+				Calls: []TransactionArgs{
+					{From: &sender, To: &contract},
+					{From: &sender, To: &reverter},
+					{From: &sender, To: &logger},
+				},
+			},
+		},
+	}
+	results, err := api.SimulateV1(context.Background(), opts, nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	// Block 5 is the follow-up of the head, 6-9 fill the gap up to 10.
+	if len(results) != 6 {
+		t.Fatalf("wrong number of blocks: have %d, want %d", len(results), 6)
+	}
+	for i, res := range results {
+		if have, want := res["number"].(*hexutil.Big).ToInt().Uint64(), uint64(5+i); have != want {
+			t.Errorf("block %d: wrong number: have %d, want %d", i, have, want)
+		}
+		if i > 0 && res["parentHash"] != results[i-1]["hash"] {
+			t.Errorf("block %d: parent hash mismatch", i)
+		}
+	}
+	// Check the ether transfer was traced as a log.
+	first := results[0]["calls"].([]simCallResult)
+	if len(first) != 1 || first[0].Status != 1 {
+		t.Fatalf("unexpected transfer result: %+v", first)
+	}
+	if len(first[0].Logs) != 1 {
+		t.Fatalf("wrong number of transfer logs: have %d, want 1", len(first[0].Logs))
+	}
+	if l := first[0].Logs[0]; l.Address != transferAddress || l.Topics[0] != transferTopic || new(big.Int).SetBytes(l.Data).Cmp(value.ToInt()) != 0 {
+		t.Errorf("unexpected transfer log: %+v", l)
+	}
+	// Check the overridden block and its calls.
+	last := results[len(results)-1]
+	if have := uint64(last["timestamp"].(hexutil.Uint64)); have != uint64(timestamp) {
+		t.Errorf("wrong timestamp: have %d, want %d", have, timestamp)
+	}
+	calls := last["calls"].([]simCallResult)
+	if have := new(big.Int).SetBytes(calls[0].ReturnValue); have.Cmp(number.ToInt()) != 0 {
+		t.Errorf("wrong NUMBER result: have %v, want %v", have, number)
+	}
+	if calls[1].Status != 0 || calls[1].Error == nil || calls[1].Error.Code != errCodeReverted {
+		t.Errorf("expected revert, have %+v", calls[1])
+	}
+	if len(calls[2].Logs) != 1 || calls[2].Logs[0].Address != logger || calls[2].Logs[0].BlockHash != last["hash"] {
+		t.Errorf("unexpected logs: %+v", calls[2].Logs)
+	}
+}
+
+func TestSimulateV1StateCarryOver(t *testing.T) {
+	t.Parallel()
+
+	var (
+		backend, sender, receiver = newSimulateBackend(t)
+		api                       = NewBlockChainAPI(backend)
+		value                     = (*hexutil.Big)(big.NewInt(1000))
+		clock                     = common.Address{0xcc}
+	)
+	opts := simOpts{
+		BlockStateCalls: []simBlock{
+			{Calls: []TransactionArgs{{From: &sender, To: &receiver, Value: value}}},
+			{Calls: []TransactionArgs{{From: &sender, To: &receiver, Value: value}}},
+			{
+				StateOverrides: &StateOverride{clock: OverrideAccount{Code: &returnTimestamp}},
+				Calls:          []TransactionArgs{{From: &sender, To: &clock}},
+			},
+		},
+		ReturnFullTransactions: true,
+	}
+	results, err := api.SimulateV1(context.Background(), opts, nil)
+	if err != nil {
+		t.Fatalf("simulation failed: %v", err)
+	}
+	// The nonce of the sender must be carried over between blocks.
+	for i := 0; i < 2; i++ {
+		txs := results[i]["transactions"].([]interface{})
+		tx := txs[0].(*RPCTransaction)
+		if uint64(tx.Nonce) != uint64(i) {
+			t.Errorf("block %d: wrong nonce: have %d, want %d", i, tx.Nonce, i)
+		}
+		if tx.From != sender {
+			t.Errorf("block %d: wrong sender: have %x, want %x", i, tx.From, sender)
+		}
+	}
+	// Timestamps are incremented by default.
+	calls := results[2]["calls"].([]simCallResult)
+	head := backend.CurrentHeader()
+	if have, want := new(big.Int).SetBytes(calls[0].ReturnValue).Uint64(), head.Time+3*timestampIncrement; have != want {
+		t.Errorf("wrong timestamp: have %d, want %d", have, want)
+	}
+}
+
+func TestSimulateV1Errors(t *testing.T) {
+	t.Parallel()
+
+	var (
+		backend, sender, receiver = newSimulateBackend(t)
+		api                       = NewBlockChainAPI(backend)
+		head                      = backend.CurrentHeader()
+		past                      = (*hexutil.Big)(new(big.Int).Set(head.Number))
+		far                       = (*hexutil.Big)(new(big.Int).Add(head.Number, big.NewInt(maxSimulateBlocks+1)))
+		early                     = hexutil.Uint64(head.Time)
+		gas                       = hexutil.Uint64(head.GasLimit + 1)
+		nonce                     = hexutil.Uint64(5)
+	)
+	tests := []struct {
+		blocks   []simBlock
+		validate bool
+		code     int
+	}{
+		{
+			blocks: nil,
+			code:   errCodeInvalidParams,
+		},
+		{
+			blocks: []simBlock{{BlockOverrides: &BlockOverrides{Number: past}}},
+			code:   errCodeBlockNumberInvalid,
+		},
+		{
+			blocks: []simBlock{{BlockOverrides: &BlockOverrides{Number: far}}},
+			code:   errCodeClientLimitExceeded,
+		},
+		{
+			blocks: []simBlock{{BlockOverrides: &BlockOverrides{Time: &early}}},
+			code:   errCodeBlockTimestampInvalid,
+		},
+		{
+			blocks: []simBlock{{Calls: []TransactionArgs{{From: &sender, To: &receiver, Gas: &gas}}}},
+			code:   errCodeBlockGasLimitReached,
+		},
+		{
+			blocks:   []simBlock{{Calls: []TransactionArgs{{From: &sender, To: &receiver, Nonce: &nonce}}}},
+			validate: true,
+			code:     errCodeTxValidation,
+		},
+	}
+	for i, tt := range tests {
+		_, err := api.SimulateV1(context.Background(), simOpts{BlockStateCalls: tt.blocks, Validation: tt.validate}, nil)
+		if err == nil {
+			t.Errorf("test %d: expected error", i)
+			continue
+		}
+		coded, ok := err.(interface{ ErrorCode() int })
+		if !ok {
+			t.Errorf("test %d: error without code: %v", i, err)
+			continue
+		}
+		if coded.ErrorCode() != tt.code {
+			t.Errorf("test %d: wrong error code: have %d, want %d (%v)", i, coded.ErrorCode(), tt.code, err)
+		}
+	}
+}
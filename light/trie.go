@@ -164,6 +164,10 @@ func (t *odrTrie) DeleteAccount(address common.Address) error {
 	})
 }
 
+func (t *odrTrie) UpdateContractCode(address common.Address, codeHash common.Hash, code []byte) error {
+	return nil
+}
+
 func (t *odrTrie) Commit(collectLeaf bool) (common.Hash, *trie.NodeSet) {
 	if t.trie == nil {
 		return t.id.Root, nil
@@ -190,6 +194,14 @@ func (t *odrTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter
 	return errors.New("not implemented, needs client/server interface split")
 }
 
+func (t *odrTrie) Witness() [][]byte {
+	return nil
+}
+
+func (t *odrTrie) IsVerkle() bool {
+	return false
+}
+
 // do tries and retries to execute a function until it returns with no error or
 // an error type other than MissingNodeError
 func (t *odrTrie) do(key []byte, fn func() error) error {
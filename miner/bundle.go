@@ -0,0 +1,303 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Names of the built-in bundle scoring functions.
+const (
+	BundleScoringProfit   = "profit"   // Total payment to the fee recipient
+	BundleScoringGasPrice = "gasprice" // Payment to the fee recipient per unit of gas
+)
+
+const (
+	// maxBundles is the maximum number of bundles waiting for inclusion.
+	maxBundles = 1024
+
+	// maxBundleTxs is the maximum number of transactions in a single bundle.
+	maxBundleTxs = 64
+)
+
+var (
+	errEmptyBundle     = errors.New("bundle contains no transactions")
+	errBundleTooLarge  = fmt.Errorf("bundle exceeds %d transactions", maxBundleTxs)
+	errBundleRange     = errors.New("invalid bundle block range")
+	errBundleExpired   = errors.New("bundle block range already passed")
+	errBundlePoolFull  = errors.New("bundle pool is full")
+	errBundleReverted  = errors.New("bundle transaction reverted")
+	errBundleDuplicate = errors.New("bundle already known")
+)
+
+// Bundle is an ordered group of transactions that must be included into a block
+// together and in order, or not at all.
+type Bundle struct {
+	Txs      types.Transactions // Transactions to include, in order
+	MinBlock uint64             // First block number the bundle may be included in
+	MaxBlock uint64             // Last block number the bundle may be included in
+}
+
+// Hash returns the identifier of the bundle, derived from its content.
+func (b *Bundle) Hash() common.Hash {
+	hashes := make([]common.Hash, len(b.Txs))
+	for i, tx := range b.Txs {
+		hashes[i] = tx.Hash()
+	}
+	enc, _ := rlp.EncodeToBytes([]interface{}{hashes, b.MinBlock, b.MaxBlock})
+	return crypto.Keccak256Hash(enc)
+}
+
+// BundleScorer ranks simulated bundles, the bundle with the highest score is
+// included first.
+type BundleScorer func(profit *big.Int, gasUsed uint64) *big.Int
+
+// NewBundleScorer returns the built-in bundle scoring function with the given
+// name. An empty name selects scoring by total profit.
+func NewBundleScorer(name string) (BundleScorer, error) {
+	switch name {
+	case "", BundleScoringProfit:
+		return func(profit *big.Int, gasUsed uint64) *big.Int {
+			return profit
+		}, nil
+	case BundleScoringGasPrice:
+		return func(profit *big.Int, gasUsed uint64) *big.Int {
+			if gasUsed == 0 {
+				return new(big.Int)
+			}
+			return new(big.Int).Div(profit, new(big.Int).SetUint64(gasUsed))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown bundle scoring %q", name)
+	}
+}
+
+// bundlePool tracks the bundles submitted for inclusion.
+type bundlePool struct {
+	bundles map[common.Hash]*Bundle
+	lock    sync.Mutex
+}
+
+func newBundlePool() *bundlePool {
+	return &bundlePool{bundles: make(map[common.Hash]*Bundle)}
+}
+
+// add validates a bundle against the current chain head and stores it.
+func (p *bundlePool) add(bundle *Bundle, head uint64) (common.Hash, error) {
+	switch {
+	case len(bundle.Txs) == 0:
+		return common.Hash{}, errEmptyBundle
+	case len(bundle.Txs) > maxBundleTxs:
+		return common.Hash{}, errBundleTooLarge
+	case bundle.MinBlock > bundle.MaxBlock:
+		return common.Hash{}, errBundleRange
+	case bundle.MaxBlock <= head:
+		return common.Hash{}, errBundleExpired
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.prune(head + 1)
+	hash := bundle.Hash()
+	if _, ok := p.bundles[hash]; ok {
+		return common.Hash{}, errBundleDuplicate
+	}
+	if len(p.bundles) >= maxBundles {
+		return common.Hash{}, errBundlePoolFull
+	}
+	p.bundles[hash] = bundle
+	return hash, nil
+}
+
+// eligible returns at most limit bundles which may be included in the block
+// with the given number, dropping all the expired ones. If more are eligible,
+// a random subset is returned, so that every bundle gets a chance over the
+// blocks built.
+func (p *bundlePool) eligible(number uint64, limit int) []*Bundle {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.prune(number)
+	var bundles []*Bundle
+	for _, bundle := range p.bundles {
+		if len(bundles) >= limit {
+			break
+		}
+		if bundle.MinBlock <= number {
+			bundles = append(bundles, bundle)
+		}
+	}
+	return bundles
+}
+
+// reset drops the bundles which can't be included on top of the given new chain
+// head anymore, either because their block range passed or because some of their
+// transactions were included already.
+func (p *bundlePool) reset(head *types.Block) {
+	included := make(map[common.Hash]struct{}, len(head.Transactions()))
+	for _, tx := range head.Transactions() {
+		included[tx.Hash()] = struct{}{}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.prune(head.NumberU64() + 1)
+	for hash, bundle := range p.bundles {
+		for _, tx := range bundle.Txs {
+			if _, ok := included[tx.Hash()]; ok {
+				delete(p.bundles, hash)
+				break
+			}
+		}
+	}
+}
+
+// prune drops the bundles which can't be included in the block with the given
+// number or any later one.
+//
+// Note, this method assumes the pool lock is held!
+func (p *bundlePool) prune(number uint64) {
+	for hash, bundle := range p.bundles {
+		if bundle.MaxBlock < number {
+			delete(p.bundles, hash)
+		}
+	}
+}
+
+// addBundle submits a bundle for inclusion into the blocks built by the worker.
+func (w *worker) addBundle(bundle *Bundle) (common.Hash, error) {
+	signer := types.LatestSigner(w.chainConfig)
+	for i, tx := range bundle.Txs {
+		if _, err := types.Sender(signer, tx); err != nil {
+			return common.Hash{}, fmt.Errorf("bundle transaction %d: %w", i, err)
+		}
+	}
+	return w.bundles.add(bundle, w.chain.CurrentBlock().Number.Uint64())
+}
+
+// commitBundles simulates the eligible bundles against the pending state, ranks
+// them by the configured scoring and includes as many of them as possible, each
+// one either completely or not at all. As every simulation copies the state, at
+// most the configured number of bundles are simulated per block.
+func (w *worker) commitBundles(env *environment, interrupt *int32) error {
+	bundles := w.bundles.eligible(env.header.Number.Uint64(), w.bundleLimit)
+	if len(bundles) == 0 {
+		return nil
+	}
+	if env.gasPool == nil {
+		env.gasPool = new(core.GasPool).AddGas(env.header.GasLimit)
+	}
+	interrupted := func() error {
+		if interrupt != nil {
+			if signal := atomic.LoadInt32(interrupt); signal != commitInterruptNone {
+				return signalToErr(signal)
+			}
+		}
+		return nil
+	}
+	// Simulate every bundle in isolation to rank them, retaining the simulation
+	// result of the best one
+	type simulatedBundle struct {
+		bundle *Bundle
+		score  *big.Int
+	}
+	var (
+		simulated []simulatedBundle
+		best      = -1         // Index of the best simulated bundle
+		bestEnv   *environment // Environment the best bundle was simulated in
+	)
+	for _, bundle := range bundles {
+		if err := interrupted(); err != nil {
+			return err
+		}
+		result := env.copy()
+		profit, gasUsed, err := w.applyBundle(result, bundle)
+		if err != nil {
+			log.Trace("Bundle simulation failed", "hash", bundle.Hash(), "err", err)
+			continue
+		}
+		score := w.bundleScorer(profit, gasUsed)
+		if best < 0 || score.Cmp(simulated[best].score) > 0 {
+			best, bestEnv = len(simulated), result
+		}
+		simulated = append(simulated, simulatedBundle{bundle, score})
+	}
+	if best < 0 {
+		return nil
+	}
+	// The best bundle is included first, on top of the very state it was simulated
+	// against, so its simulation result can be taken over without applying it again.
+	*env = *bestEnv
+	simulated = append(simulated[:best], simulated[best+1:]...)
+	sort.SliceStable(simulated, func(i, j int) bool {
+		return simulated[i].score.Cmp(simulated[j].score) > 0
+	})
+	// Include the remaining bundles best first. Once a bundle was included the
+	// state differs from the one simulated against, so every bundle is applied to
+	// a copy of the environment, which only replaces the original if the whole
+	// bundle succeeded.
+	for _, sim := range simulated {
+		if err := interrupted(); err != nil {
+			return err
+		}
+		candidate := env.copy()
+		if _, _, err := w.applyBundle(candidate, sim.bundle); err != nil {
+			log.Trace("Bundle skipped", "hash", sim.bundle.Hash(), "err", err)
+			continue
+		}
+		*env = *candidate
+	}
+	return nil
+}
+
+// applyBundle applies all transactions of a bundle to the environment and
+// returns the payment to the fee recipient along with the gas used. If any of
+// the transactions fails or reverts, an error is returned and the environment
+// is left partially modified, so bundles must only be applied to copies until
+// they are known to succeed. Multi-transaction state snapshots can't be used
+// for rolling back, as the state is finalised after every transaction.
+func (w *worker) applyBundle(env *environment, bundle *Bundle) (*big.Int, uint64, error) {
+	var (
+		gasUsed = env.header.GasUsed
+		balance = env.state.GetBalance(env.coinbase)
+	)
+	for _, tx := range bundle.Txs {
+		env.state.SetTxContext(tx.Hash(), env.tcount)
+
+		if _, err := w.commitTransaction(env, tx); err != nil {
+			return nil, 0, fmt.Errorf("transaction %x: %w", tx.Hash(), err)
+		}
+		env.tcount++
+		if env.receipts[len(env.receipts)-1].Status == types.ReceiptStatusFailed {
+			return nil, 0, fmt.Errorf("transaction %x: %w", tx.Hash(), errBundleReverted)
+		}
+	}
+	profit := new(big.Int).Sub(env.state.GetBalance(env.coinbase), balance)
+	return profit, env.header.GasUsed - gasUsed, nil
+}
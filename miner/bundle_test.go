@@ -0,0 +1,159 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func bundleTx(key *ecdsa.PrivateKey, nonce uint64, gasPrice int64) *types.Transaction {
+	return types.MustSignNewTx(key, types.LatestSigner(params.TestChainConfig), &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &testUserAddress,
+		Value:    big.NewInt(1000),
+		Gas:      params.TxGas,
+		GasPrice: big.NewInt(gasPrice),
+	})
+}
+
+func TestBundleInclusion(t *testing.T) {
+	var (
+		recipient = common.HexToAddress("0xdeadbeef")
+		cheap     = &Bundle{
+			Txs:      types.Transactions{bundleTx(testBankKey, 0, 2*params.GWei), bundleTx(testBankKey, 1, 2*params.GWei)},
+			MinBlock: 1,
+			MaxBlock: 1,
+		}
+		reverting = &Bundle{
+			Txs:      types.Transactions{bundleTx(testBankKey, 0, 100*params.GWei), bundleTx(testUserKey, 0, 100*params.GWei)},
+			MinBlock: 1,
+			MaxBlock: 1,
+		}
+		rich = &Bundle{
+			Txs:      types.Transactions{bundleTx(testBankKey, 0, 10*params.GWei)},
+			MinBlock: 1,
+			MaxBlock: 2,
+		}
+	)
+	tests := []struct {
+		bundles []*Bundle
+		want    types.Transactions
+	}{
+		// A failing bundle must be dropped as a whole
+		{[]*Bundle{cheap, reverting}, cheap.Txs},
+		// The most profitable bundle must win, the conflicting one dropped as a whole
+		{[]*Bundle{cheap, rich}, rich.Txs},
+	}
+	for i, tt := range tests {
+		w, b := newTestWorker(t, params.TestChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 0)
+		for _, bundle := range tt.bundles {
+			if _, err := w.addBundle(bundle); err != nil {
+				t.Fatalf("test %d: failed to add bundle: %v", i, err)
+			}
+		}
+		w.start()
+		block, _, err := w.getSealingBlock(b.chain.CurrentBlock().Hash(), uint64(time.Now().Unix()), recipient, common.Hash{}, nil, false)
+		w.close()
+		if err != nil {
+			t.Fatalf("test %d: failed to build block: %v", i, err)
+		}
+		if have := block.Transactions(); len(have) != len(tt.want) {
+			t.Errorf("test %d: transaction count mismatch: have %d, want %d", i, len(have), len(tt.want))
+			continue
+		}
+		for j, tx := range block.Transactions() {
+			if tx.Hash() != tt.want[j].Hash() {
+				t.Errorf("test %d: transaction %d mismatch", i, j)
+			}
+		}
+	}
+}
+
+func TestBundleValidation(t *testing.T) {
+	w, _ := newTestWorker(t, params.TestChainConfig, ethash.NewFaker(), rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	tx := bundleTx(testBankKey, 0, params.GWei)
+	tests := []struct {
+		bundle *Bundle
+		err    error
+	}{
+		{&Bundle{MinBlock: 1, MaxBlock: 1}, errEmptyBundle},
+		{&Bundle{Txs: types.Transactions{tx}, MinBlock: 2, MaxBlock: 1}, errBundleRange},
+		{&Bundle{Txs: types.Transactions{tx}, MinBlock: 0, MaxBlock: 0}, errBundleExpired},
+		{&Bundle{Txs: types.Transactions{tx}, MinBlock: 1, MaxBlock: 1}, nil},
+		{&Bundle{Txs: types.Transactions{tx}, MinBlock: 1, MaxBlock: 1}, errBundleDuplicate},
+	}
+	for i, tt := range tests {
+		if _, err := w.addBundle(tt.bundle); !errors.Is(err, tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, tt.err)
+		}
+	}
+	if have := len(w.bundles.eligible(1, maxBundles)); have != 1 {
+		t.Errorf("eligible bundle count mismatch: have %d, want %d", have, 1)
+	}
+	if have := len(w.bundles.eligible(2, maxBundles)); have != 0 {
+		t.Errorf("expired bundles not pruned: have %d", have)
+	}
+}
+
+func TestBundlePoolReset(t *testing.T) {
+	var (
+		pool     = newBundlePool()
+		included = &Bundle{Txs: types.Transactions{bundleTx(testBankKey, 0, params.GWei)}, MinBlock: 1, MaxBlock: 3}
+		expired  = &Bundle{Txs: types.Transactions{bundleTx(testUserKey, 0, params.GWei)}, MinBlock: 1, MaxBlock: 1}
+		pending  = &Bundle{Txs: types.Transactions{bundleTx(testUserKey, 0, params.GWei)}, MinBlock: 2, MaxBlock: 3}
+	)
+	for i, bundle := range []*Bundle{included, expired, pending} {
+		if _, err := pool.add(bundle, 0); err != nil {
+			t.Fatalf("bundle %d: failed to add: %v", i, err)
+		}
+	}
+	// Bundles past their range or with included transactions are dropped
+	head := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}).WithBody(included.Txs, nil)
+	pool.reset(head)
+
+	if have := pool.eligible(2, maxBundles); len(have) != 1 || have[0] != pending {
+		t.Fatalf("wrong bundles retained: have %d", len(have))
+	}
+}
+
+func TestBundlePoolEligibleLimit(t *testing.T) {
+	pool := newBundlePool()
+	for i := 0; i < 3; i++ {
+		bundle := &Bundle{Txs: types.Transactions{bundleTx(testBankKey, uint64(i), params.GWei)}, MinBlock: 1, MaxBlock: 1}
+		if _, err := pool.add(bundle, 0); err != nil {
+			t.Fatalf("bundle %d: failed to add: %v", i, err)
+		}
+	}
+	if have := len(pool.eligible(1, 2)); have != 2 {
+		t.Errorf("eligible bundle count mismatch: have %d, want %d", have, 2)
+	}
+	if have := len(pool.eligible(1, maxBundles)); have != 3 {
+		t.Errorf("eligible bundle count mismatch: have %d, want %d", have, 3)
+	}
+}
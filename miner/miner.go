@@ -56,6 +56,10 @@ type Config struct {
 	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
 
 	NewPayloadTimeout time.Duration // The maximum time allowance for creating a new payload
+
+	TxOrdering         string `toml:",omitempty"` // Transaction ordering policy of the built blocks (tip, fifo or fairness)
+	BundleScoring      string `toml:",omitempty"` // Scoring ranking the bundles competing for inclusion (profit or gasprice)
+	MaxBundlesPerBlock int    `toml:",omitempty"` // Maximum number of bundles simulated for inclusion into a block
 }
 
 // DefaultConfig contains default settings for miner.
@@ -69,6 +73,10 @@ var DefaultConfig = Config{
 	// run 3 rounds.
 	Recommit:          2 * time.Second,
 	NewPayloadTimeout: 2 * time.Second,
+
+	TxOrdering:         TxOrderingTip,
+	BundleScoring:      BundleScoringProfit,
+	MaxBundlesPerBlock: 100,
 }
 
 // Miner creates blocks and searches for proof-of-work values.
@@ -194,6 +202,18 @@ func (miner *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetTxOrdering sets the policy ordering the transactions of the built blocks,
+// including the payloads built for the consensus layer.
+func (miner *Miner) SetTxOrdering(policy TxOrderingPolicy) {
+	miner.worker.setTxOrdering(policy)
+}
+
+// SendBundle submits an atomic bundle of transactions for inclusion into the
+// built blocks within the bundle's block range.
+func (miner *Miner) SendBundle(bundle *Bundle) (common.Hash, error) {
+	return miner.worker.addBundle(bundle)
+}
+
 // SetRecommitInterval sets the interval for sealing work resubmitting.
 func (miner *Miner) SetRecommitInterval(interval time.Duration) {
 	miner.worker.setRecommitInterval(interval)
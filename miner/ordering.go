@@ -0,0 +1,235 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Names of the built-in transaction ordering policies.
+const (
+	TxOrderingTip      = "tip"      // Highest effective miner tip first
+	TxOrderingFIFO     = "fifo"     // Earliest arrival first
+	TxOrderingFairness = "fairness" // Round-robin across senders
+)
+
+// TxSet is an ordered set of transactions the block builder consumes, honouring
+// the nonce order of the transactions sent by the same account.
+type TxSet interface {
+	// Peek returns the next transaction to include, or nil if the set is empty.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one from the same account.
+	Shift()
+
+	// Pop removes the current transaction, *not* replacing it with the next one
+	// from the same account. It is used when a transaction cannot be executed and
+	// hence all subsequent ones of the same account should be discarded.
+	Pop()
+}
+
+// TxOrderingPolicy decides the order in which pending transactions are
+// included into the blocks built by the miner.
+type TxOrderingPolicy interface {
+	// Name returns the identifier of the policy.
+	Name() string
+
+	// Order creates an ordered transaction set out of the per-account, nonce
+	// sorted pending transactions. The input map is reowned by the set.
+	Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TxSet
+}
+
+// NewTxOrderingPolicy returns the built-in ordering policy with the given name.
+// An empty name selects the default tip based ordering.
+func NewTxOrderingPolicy(name string) (TxOrderingPolicy, error) {
+	switch name {
+	case "", TxOrderingTip:
+		return tipOrdering{}, nil
+	case TxOrderingFIFO:
+		return fifoOrdering{}, nil
+	case TxOrderingFairness:
+		return fairnessOrdering{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering policy %q", name)
+	}
+}
+
+// tipOrdering orders transactions by their effective miner tip, falling back to
+// the arrival time for equal tips.
+type tipOrdering struct{}
+
+func (tipOrdering) Name() string { return TxOrderingTip }
+
+func (tipOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TxSet {
+	return types.NewTransactionsByPriceAndNonce(signer, txs, baseFee)
+}
+
+// fifoOrdering orders transactions by the time they were first seen, regardless
+// of the fees they pay.
+type fifoOrdering struct{}
+
+func (fifoOrdering) Name() string { return TxOrderingFIFO }
+
+func (fifoOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TxSet {
+	set := &txsByArrival{txs: txs, signer: signer, baseFee: baseFee}
+	for from, accTxs := range txs {
+		if !validHead(signer, from, accTxs[0], baseFee) {
+			delete(txs, from)
+			continue
+		}
+		set.heads = append(set.heads, accTxs[0])
+		txs[from] = accTxs[1:]
+	}
+	heap.Init(&set.heads)
+	return set
+}
+
+// validHead reports whether the head transaction of an account was signed by
+// it and pays at least the base fee.
+func validHead(signer types.Signer, from common.Address, tx *types.Transaction, baseFee *big.Int) bool {
+	if acc, _ := types.Sender(signer, tx); acc != from {
+		return false
+	}
+	_, err := tx.EffectiveGasTip(baseFee)
+	return err == nil
+}
+
+// txsByTime implements the heap interface, ordering transactions by the time
+// they were first seen and their hash for equal times.
+type txsByTime []*types.Transaction
+
+func (s txsByTime) Len() int { return len(s) }
+func (s txsByTime) Less(i, j int) bool {
+	if s[i].Time().Equal(s[j].Time()) {
+		a, b := s[i].Hash(), s[j].Hash()
+		return bytes.Compare(a[:], b[:]) < 0
+	}
+	return s[i].Time().Before(s[j].Time())
+}
+func (s txsByTime) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *txsByTime) Push(x interface{}) {
+	*s = append(*s, x.(*types.Transaction))
+}
+
+func (s *txsByTime) Pop() interface{} {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*s = old[0 : n-1]
+	return x
+}
+
+// txsByArrival is the transaction set of the FIFO ordering policy.
+type txsByArrival struct {
+	txs     map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads   txsByTime                             // Next transaction for each unique account (arrival heap)
+	signer  types.Signer                          // Signer for the set of transactions
+	baseFee *big.Int                              // Current base fee
+}
+
+func (t *txsByArrival) Peek() *types.Transaction {
+	if len(t.heads) == 0 {
+		return nil
+	}
+	return t.heads[0]
+}
+
+func (t *txsByArrival) Shift() {
+	acc, _ := types.Sender(t.signer, t.heads[0])
+	if txs := t.txs[acc]; len(txs) > 0 {
+		if _, err := txs[0].EffectiveGasTip(t.baseFee); err == nil {
+			t.heads[0], t.txs[acc] = txs[0], txs[1:]
+			heap.Fix(&t.heads, 0)
+			return
+		}
+	}
+	heap.Pop(&t.heads)
+}
+
+func (t *txsByArrival) Pop() {
+	heap.Pop(&t.heads)
+}
+
+// fairnessOrdering includes a single transaction of every sender in turn, so a
+// sender with many pending transactions cannot crowd out the others. Within a
+// round, senders are ordered by the effective tip of their first transaction.
+type fairnessOrdering struct{}
+
+func (fairnessOrdering) Name() string { return TxOrderingFairness }
+
+func (fairnessOrdering) Order(signer types.Signer, txs map[common.Address]types.Transactions, baseFee *big.Int) TxSet {
+	set := &txsRoundRobin{txs: txs, baseFee: baseFee}
+	for from, accTxs := range txs {
+		if !validHead(signer, from, accTxs[0], baseFee) {
+			delete(txs, from)
+			continue
+		}
+		set.senders = append(set.senders, from)
+	}
+	sort.Slice(set.senders, func(i, j int) bool {
+		a, b := txs[set.senders[i]][0], txs[set.senders[j]][0]
+		if cmp := a.EffectiveGasTipCmp(b, baseFee); cmp != 0 {
+			return cmp > 0
+		}
+		return bytes.Compare(set.senders[i][:], set.senders[j][:]) < 0
+	})
+	return set
+}
+
+// txsRoundRobin is the transaction set of the sender fairness ordering policy.
+type txsRoundRobin struct {
+	txs     map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	senders []common.Address                      // Accounts with transactions left, in round order
+	next    int                                   // Index of the account whose turn it is
+	baseFee *big.Int                              // Current base fee
+}
+
+func (t *txsRoundRobin) Peek() *types.Transaction {
+	if len(t.senders) == 0 {
+		return nil
+	}
+	return t.txs[t.senders[t.next]][0]
+}
+
+func (t *txsRoundRobin) Shift() {
+	acc := t.senders[t.next]
+	if txs := t.txs[acc][1:]; len(txs) > 0 {
+		if _, err := txs[0].EffectiveGasTip(t.baseFee); err == nil {
+			t.txs[acc] = txs
+			t.next = (t.next + 1) % len(t.senders)
+			return
+		}
+	}
+	t.Pop()
+}
+
+func (t *txsRoundRobin) Pop() {
+	delete(t.txs, t.senders[t.next])
+	t.senders = append(t.senders[:t.next], t.senders[t.next+1:]...)
+	if t.next >= len(t.senders) {
+		t.next = 0
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// orderingTestTxs creates two transactions for each of the given keys, in the
+// order of the keys and with the given gas prices, and returns them grouped by
+// sender along with the creation order.
+func orderingTestTxs(keys []*ecdsa.PrivateKey, prices []int64) (map[common.Address]types.Transactions, []*types.Transaction) {
+	var (
+		signer  = types.HomesteadSigner{}
+		grouped = make(map[common.Address]types.Transactions)
+		created []*types.Transaction
+	)
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		for i, key := range keys {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(prices[i]), nil), signer, key)
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+			grouped[addr] = append(grouped[addr], tx)
+			created = append(created, tx)
+		}
+	}
+	return grouped, created
+}
+
+// drainTxSet retrieves all transactions of a set, shifting after each one.
+func drainTxSet(set TxSet) []*types.Transaction {
+	var txs []*types.Transaction
+	for tx := set.Peek(); tx != nil; tx = set.Peek() {
+		txs = append(txs, tx)
+		set.Shift()
+	}
+	return txs
+}
+
+func TestTxOrderingPolicies(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	prices := []int64{1, 3, 2}
+
+	// FIFO must return the transactions in creation order, regardless of price
+	fifo, _ := NewTxOrderingPolicy(TxOrderingFIFO)
+	grouped, created := orderingTestTxs(keys, prices)
+	have := drainTxSet(fifo.Order(types.HomesteadSigner{}, grouped, nil))
+	if len(have) != len(created) {
+		t.Fatalf("fifo: transaction count mismatch: have %d, want %d", len(have), len(created))
+	}
+	for i := range have {
+		if have[i].Hash() != created[i].Hash() {
+			t.Errorf("fifo: transaction %d mismatch", i)
+		}
+	}
+	// Fairness must take one transaction per sender and round, with the senders
+	// ordered by the price of their first transaction
+	fairness, _ := NewTxOrderingPolicy(TxOrderingFairness)
+	grouped, _ = orderingTestTxs(keys, prices)
+	have = drainTxSet(fairness.Order(types.HomesteadSigner{}, grouped, nil))
+
+	order := []int{1, 2, 0, 1, 2, 0}
+	if len(have) != len(order) {
+		t.Fatalf("fairness: transaction count mismatch: have %d, want %d", len(have), len(order))
+	}
+	for i, tx := range have {
+		from, _ := types.Sender(types.HomesteadSigner{}, tx)
+		if want := crypto.PubkeyToAddress(keys[order[i]].PublicKey); from != want || tx.Nonce() != uint64(i/len(keys)) {
+			t.Errorf("fairness: transaction %d mismatch: have %x/%d, want %x/%d", i, from, tx.Nonce(), want, i/len(keys))
+		}
+	}
+	// Popping must drop all remaining transactions of the sender
+	grouped, _ = orderingTestTxs(keys, prices)
+	set := fairness.Order(types.HomesteadSigner{}, grouped, nil)
+	set.Pop()
+	if have := drainTxSet(set); len(have) != 4 {
+		t.Fatalf("fairness: transaction count mismatch after pop: have %d, want %d", len(have), 4)
+	}
+	if _, err := NewTxOrderingPolicy("random"); err == nil {
+		t.Fatalf("unknown policy accepted")
+	}
+}
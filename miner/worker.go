@@ -226,9 +226,14 @@ type worker struct {
 	remoteUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
 	unconfirmed  *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
+	mu       sync.RWMutex // The lock used to protect the coinbase, extra and ordering fields
 	coinbase common.Address
 	extra    []byte
+	ordering TxOrderingPolicy
+
+	bundles      *bundlePool  // Bundles waiting for inclusion
+	bundleScorer BundleScorer // Function ranking the simulated bundles
+	bundleLimit  int          // Maximum number of bundles simulated per block
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
@@ -297,6 +302,29 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		resubmitIntervalCh: make(chan time.Duration),
 		resubmitAdjustCh:   make(chan *intervalAdjust, resubmitAdjustChanSize),
 	}
+	// Resolve the transaction ordering policy, falling back to the default one
+	ordering, err := NewTxOrderingPolicy(config.TxOrdering)
+	if err != nil {
+		log.Warn("Invalid transaction ordering policy, using default", "err", err, "default", TxOrderingTip)
+		ordering, _ = NewTxOrderingPolicy(TxOrderingTip)
+	}
+	worker.ordering = ordering
+
+	// Resolve the bundle scoring, falling back to the default one
+	scorer, err := NewBundleScorer(config.BundleScoring)
+	if err != nil {
+		log.Warn("Invalid bundle scoring, using default", "err", err, "default", BundleScoringProfit)
+		scorer, _ = NewBundleScorer(BundleScoringProfit)
+	}
+	worker.bundles, worker.bundleScorer = newBundlePool(), scorer
+
+	// Sanitize the bundle simulation limit
+	worker.bundleLimit = config.MaxBundlesPerBlock
+	if worker.bundleLimit <= 0 {
+		log.Warn("Sanitizing invalid bundle limit", "provided", config.MaxBundlesPerBlock, "updated", DefaultConfig.MaxBundlesPerBlock)
+		worker.bundleLimit = DefaultConfig.MaxBundlesPerBlock
+	}
+
 	// Subscribe NewTxsEvent for tx pool
 	worker.txsSub = eth.TxPool().SubscribeNewTxsEvent(worker.txsCh)
 	// Subscribe events for blockchain
@@ -362,6 +390,20 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setTxOrdering sets the policy used to order the transactions of new blocks.
+func (w *worker) setTxOrdering(policy TxOrderingPolicy) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ordering = policy
+}
+
+// txOrdering returns the policy used to order the transactions of new blocks.
+func (w *worker) txOrdering() TxOrderingPolicy {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.ordering
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	select {
@@ -500,6 +542,7 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 
 		case head := <-w.chainHeadCh:
 			clearPending(head.Block.NumberU64())
+			w.bundles.reset(head.Block)
 			timestamp = time.Now().Unix()
 			commit(false, commitInterruptNewHead)
 
@@ -633,7 +676,7 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs, w.current.header.BaseFee)
+				txset := w.txOrdering().Order(w.current.signer, txs, w.current.header.BaseFee)
 				tcount := w.current.tcount
 				w.commitTransactions(w.current, txset, nil)
 
@@ -877,7 +920,7 @@ func (w *worker) commitTransaction(env *environment, tx *types.Transaction) ([]*
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByPriceAndNonce, interrupt *int32) error {
+func (w *worker) commitTransactions(env *environment, txs TxSet, interrupt *int32) error {
 	gasLimit := env.header.GasLimit
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(gasLimit)
@@ -1065,9 +1108,15 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 }
 
 // fillTransactions retrieves the pending transactions from the txpool and fills them
-// into the given sealing block. The transaction selection and ordering strategy can
-// be customized with the plugin in the future.
+// into the given sealing block. Submitted bundles are included first, followed by
+// the pool transactions ordered by the configured ordering policy, with the local
+// ones always preceding the remote ones.
 func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
+	if err := w.commitBundles(env, interrupt); err != nil {
+		return err
+	}
+	ordering := w.txOrdering()
+
 	// Split the pending transactions into locals and remotes
 	// Fill the block with all available pending transactions.
 	pending := w.eth.TxPool().Pending(true)
@@ -1079,13 +1128,13 @@ func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
 		}
 	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(env.signer, localTxs, env.header.BaseFee)
+		txs := ordering.Order(env.signer, localTxs, env.header.BaseFee)
 		if err := w.commitTransactions(env, txs, interrupt); err != nil {
 			return err
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(env.signer, remoteTxs, env.header.BaseFee)
+		txs := ordering.Order(env.signer, remoteTxs, env.header.BaseFee)
 		if err := w.commitTransactions(env, txs, interrupt); err != nil {
 			return err
 		}
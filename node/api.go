@@ -18,6 +18,7 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -176,6 +177,9 @@ func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *stri
 		CorsAllowedOrigins: api.node.config.HTTPCors,
 		Vhosts:             api.node.config.HTTPVirtualHosts,
 		Modules:            api.node.config.HTTPModules,
+		AllowMethods:       api.node.config.HTTPAllowMethods,
+		DenyMethods:        api.node.config.HTTPDenyMethods,
+		rpcEndpointConfig:  api.node.rpcEndpointConfig(),
 	}
 	if cors != nil {
 		config.CorsAllowedOrigins = nil
@@ -247,8 +251,11 @@ func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, ap
 
 	// Determine config.
 	config := wsConfig{
-		Modules: api.node.config.WSModules,
-		Origins: api.node.config.WSOrigins,
+		Modules:           api.node.config.WSModules,
+		AllowMethods:      api.node.config.WSAllowMethods,
+		DenyMethods:       api.node.config.WSDenyMethods,
+		Origins:           api.node.config.WSOrigins,
+		rpcEndpointConfig: api.node.rpcEndpointConfig(),
 		// ExposeAll: api.node.config.WSExposeAll,
 	}
 	if apis != nil {
@@ -287,6 +294,15 @@ func (api *adminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// RateLimits retrieves the remaining quotas of the clients of the HTTP and
+// WebSocket endpoints, keyed by client and by method group.
+func (api *adminAPI) RateLimits() (map[string]map[string]RateLimitQuota, error) {
+	if api.node.rateLimiter == nil {
+		return nil, errors.New("rate limiting is disabled")
+	}
+	return api.node.rateLimiter.quotas(), nil
+}
+
 // Peers retrieves all the information we know about each individual peer at the
 // protocol granularity.
 func (api *adminAPI) Peers() ([]*p2p.PeerInfo, error) {
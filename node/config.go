@@ -101,6 +101,13 @@ type Config struct {
 	// relative), then that specific path is enforced. An empty path disables IPC.
 	IPCPath string
 
+	// IPCAllowMethods and IPCDenyMethods restrict the methods served over IPC. If
+	// the allow list is not empty only the methods matching it are served, and the
+	// methods matching the deny list never are. Patterns may contain wildcards,
+	// e.g. "debug_trace*".
+	IPCAllowMethods []string `toml:",omitempty"`
+	IPCDenyMethods  []string `toml:",omitempty"`
+
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
 	// field is empty, no HTTP API endpoint will be started.
 	HTTPHost string
@@ -129,6 +136,11 @@ type Config struct {
 	// exposed.
 	HTTPModules []string
 
+	// HTTPAllowMethods and HTTPDenyMethods restrict the methods of the modules
+	// served over HTTP, like their IPC counterparts.
+	HTTPAllowMethods []string `toml:",omitempty"`
+	HTTPDenyMethods  []string `toml:",omitempty"`
+
 	// HTTPTimeouts allows for customization of the timeout values used by the HTTP RPC
 	// interface.
 	HTTPTimeouts rpc.HTTPTimeouts
@@ -168,6 +180,11 @@ type Config struct {
 	// exposed.
 	WSModules []string
 
+	// WSAllowMethods and WSDenyMethods restrict the methods of the modules served
+	// over WebSocket, like their IPC counterparts.
+	WSAllowMethods []string `toml:",omitempty"`
+	WSDenyMethods  []string `toml:",omitempty"`
+
 	// WSExposeAll exposes all API modules via the WebSocket RPC interface rather
 	// than just the public ones.
 	//
@@ -197,6 +214,18 @@ type Config struct {
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
 
+	// BatchRequestLimit is the maximum number of requests in a batch served over
+	// HTTP and WebSocket, zero being unlimited.
+	BatchRequestLimit int `toml:",omitempty"`
+
+	// BatchResponseMaxSize is the maximum number of bytes returned from a batch
+	// served over HTTP and WebSocket, zero being unlimited.
+	BatchResponseMaxSize int `toml:",omitempty"`
+
+	// RPCRateLimit configures the per-client rate limiting of the HTTP and
+	// WebSocket endpoints, the authenticated ones never being limited.
+	RPCRateLimit RateLimitConfig `toml:",omitempty"`
+
 	// JWTSecret is the path to the hex-encoded jwt secret.
 	JWTSecret string `toml:",omitempty"`
 
@@ -204,6 +233,12 @@ type Config struct {
 	EnablePersonal bool `toml:"-"`
 
 	DBEngine string `toml:",omitempty"`
+
+	// DBSecondary opens the databases read-only next to another node, which owns
+	// the data directory and keeps on writing into it. The data directory isn't
+	// locked against the other node. The databases are a point-in-time view of
+	// the other node's data, which is only refreshed by TryCatchUpWithPrimary.
+	DBSecondary bool `toml:"-"`
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
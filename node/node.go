@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -55,14 +56,15 @@ type Node struct {
 	state         int           // Tracks state of node lifecycle
 
 	lock          sync.Mutex
-	lifecycles    []Lifecycle // All registered backends, services, and auxiliary services that have a lifecycle
-	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
-	http          *httpServer //
-	ws            *httpServer //
-	httpAuth      *httpServer //
-	wsAuth        *httpServer //
-	ipc           *ipcServer  // Stores information about the ipc http server
-	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	lifecycles    []Lifecycle  // All registered backends, services, and auxiliary services that have a lifecycle
+	rpcAPIs       []rpc.API    // List of APIs currently provided by the node
+	http          *httpServer  //
+	ws            *httpServer  //
+	httpAuth      *httpServer  //
+	wsAuth        *httpServer  //
+	ipc           *ipcServer   // Stores information about the ipc http server
+	inprocHandler *rpc.Server  // In-process RPC request handler to process the API requests
+	rateLimiter   *rateLimiter // Per-client rate limiter of the HTTP and WS endpoints, nil if disabled
 
 	databases map[*closeTrackingDB]struct{} // All open databases
 }
@@ -145,17 +147,35 @@ func New(conf *Config) (*Node, error) {
 	if err := validatePrefix("WebSocket", conf.WSPathPrefix); err != nil {
 		return nil, err
 	}
+	// Set up the rate limiting of the RPC endpoints.
+	if conf.RPCRateLimit.enabled() {
+		limiter, err := newRateLimiter(conf.RPCRateLimit, mclock.System{})
+		if err != nil {
+			return nil, err
+		}
+		node.rateLimiter = limiter
+	}
 
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.httpAuth = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
 	node.wsAuth = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
-	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
+	node.ipc = newIPCServer(node.log, conf.IPCEndpoint(), conf.IPCAllowMethods, conf.IPCDenyMethods)
 
 	return node, nil
 }
 
+// rpcEndpointConfig returns the settings shared by the unauthenticated HTTP and
+// WebSocket endpoints.
+func (n *Node) rpcEndpointConfig() rpcEndpointConfig {
+	return rpcEndpointConfig{
+		batchItemLimit:         n.config.BatchRequestLimit,
+		batchResponseSizeLimit: n.config.BatchResponseMaxSize,
+		rateLimiter:            n.rateLimiter,
+	}
+}
+
 // Start starts all registered lifecycles, RPC services and p2p networking.
 // Node can only be started once.
 func (n *Node) Start() error {
@@ -313,7 +333,9 @@ func (n *Node) openDataDir() error {
 	if n.config.DataDir == "" {
 		return nil // ephemeral
 	}
-
+	if n.config.DBSecondary {
+		return nil // owned by another instance
+	}
 	instdir := filepath.Join(n.config.DataDir, n.config.name())
 	if err := os.MkdirAll(instdir, 0700); err != nil {
 		return err
@@ -402,6 +424,7 @@ func (n *Node) startRPC() error {
 		servers           []*httpServer
 		openAPIs, allAPIs = n.getAPIs()
 	)
+	rpcConfig := n.rpcEndpointConfig()
 
 	initHttp := func(server *httpServer, port int) error {
 		if err := server.setListenAddr(n.config.HTTPHost, port); err != nil {
@@ -411,7 +434,10 @@ func (n *Node) startRPC() error {
 			CorsAllowedOrigins: n.config.HTTPCors,
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
+			AllowMethods:       n.config.HTTPAllowMethods,
+			DenyMethods:        n.config.HTTPDenyMethods,
 			prefix:             n.config.HTTPPathPrefix,
+			rpcEndpointConfig:  rpcConfig,
 		}); err != nil {
 			return err
 		}
@@ -425,9 +451,12 @@ func (n *Node) startRPC() error {
 			return err
 		}
 		if err := server.enableWS(openAPIs, wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:           n.config.WSModules,
+			AllowMethods:      n.config.WSAllowMethods,
+			DenyMethods:       n.config.WSDenyMethods,
+			Origins:           n.config.WSOrigins,
+			prefix:            n.config.WSPathPrefix,
+			rpcEndpointConfig: rpcConfig,
 		}); err != nil {
 			return err
 		}
@@ -436,6 +465,10 @@ func (n *Node) startRPC() error {
 	}
 
 	initAuth := func(port int, secret []byte) error {
+		authConfig := rpcConfig
+		authConfig.jwtSecret = secret
+		authConfig.rateLimiter = nil
+
 		// Enable auth via HTTP
 		server := n.httpAuth
 		if err := server.setListenAddr(n.config.AuthAddr, port); err != nil {
@@ -446,7 +479,7 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.AuthVirtualHosts,
 			Modules:            DefaultAuthModules,
 			prefix:             DefaultAuthPrefix,
-			jwtSecret:          secret,
+			rpcEndpointConfig:  authConfig,
 		}); err != nil {
 			return err
 		}
@@ -457,10 +490,10 @@ func (n *Node) startRPC() error {
 			return err
 		}
 		if err := server.enableWS(allAPIs, wsConfig{
-			Modules:   DefaultAuthModules,
-			Origins:   DefaultAuthOrigins,
-			prefix:    DefaultAuthPrefix,
-			jwtSecret: secret,
+			Modules:           DefaultAuthModules,
+			Origins:           DefaultAuthOrigins,
+			prefix:            DefaultAuthPrefix,
+			rpcEndpointConfig: authConfig,
 		}); err != nil {
 			return err
 		}
@@ -716,6 +749,7 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string, r
 			Cache:     cache,
 			Handles:   handles,
 			ReadOnly:  readonly,
+			Secondary: n.config.DBSecondary,
 		})
 	}
 
@@ -728,9 +762,10 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string, r
 // OpenDatabaseWithFreezer opens an existing database with the given name (or
 // creates one if no previous can be found) from within the node's data directory,
 // also attaching a chain freezer to it that moves ancient chain data from the
-// database to immutable append-only files. If the node is an ephemeral one, a
-// memory database is returned.
-func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient string, namespace string, readonly bool) (ethdb.Database, error) {
+// database to immutable append-only files. The freezer also stores the state
+// diffs if requested. If the node is an ephemeral one, a memory database is
+// returned.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient string, namespace string, readonly bool, stateDiffs bool) (ethdb.Database, error) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	if n.state == closedState {
@@ -749,6 +784,8 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient
 			Cache:             cache,
 			Handles:           handles,
 			ReadOnly:          readonly,
+			Secondary:         n.config.DBSecondary,
+			StateDiffs:        stateDiffs,
 		})
 	}
 
@@ -768,6 +805,8 @@ func (n *Node) ResolveAncient(name string, ancient string) string {
 	switch {
 	case ancient == "":
 		ancient = filepath.Join(n.ResolvePath(name), "ancient")
+	case rawdb.IsRemoteAncient(ancient):
+		// Served over HTTP, nothing to resolve
 	case !filepath.IsAbs(ancient):
 		ancient = n.ResolvePath(ancient)
 	}
@@ -789,6 +828,12 @@ func (db *closeTrackingDB) Close() error {
 	return db.Database.Close()
 }
 
+// TryCatchUpWithPrimary makes the changes of the primary instance visible to a
+// database opened in secondary mode.
+func (db *closeTrackingDB) TryCatchUpWithPrimary() error {
+	return rawdb.TryCatchUpWithPrimary(db.Database)
+}
+
 // wrapDatabase ensures the database will be auto-closed when Node is closed.
 func (n *Node) wrapDatabase(db ethdb.Database) ethdb.Database {
 	wrapper := &closeTrackingDB{db, n}
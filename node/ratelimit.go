@@ -0,0 +1,506 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// rateLimitBodyLimit is the largest request body inspected for computing the
+	// cost of a request, bigger ones are rejected by the RPC server anyway.
+	rateLimitBodyLimit = 5 * 1024 * 1024
+
+	// rateLimitPruneInterval is how often the clients with full buckets are
+	// dropped from the limiter.
+	rateLimitPruneInterval = time.Minute
+
+	// rateLimitDefaultGroup is the name of the bucket used by the methods not
+	// belonging to any configured group.
+	rateLimitDefaultGroup = "default"
+
+	// rateLimitMaxClients is the number of clients tracked by the limiter. Once
+	// reached, the least recently seen client is forgotten to track a new one.
+	rateLimitMaxClients = 10000
+
+	// rateLimitIPv6PrefixLen is the length of the prefix identifying a client by
+	// its IPv6 address, as hosts usually get whole subnets assigned.
+	rateLimitIPv6PrefixLen = 64
+
+	// errcodeRateLimited is the JSON-RPC error code of the calls exceeding the
+	// quota of their client.
+	errcodeRateLimited = -32005
+)
+
+// DefaultRateLimitCosts are the tokens charged for the expensive methods when
+// no costs are configured. Every other method costs a single token.
+var DefaultRateLimitCosts = map[string]int{
+	"eth_call":        5,
+	"eth_estimateGas": 5,
+	"eth_getLogs":     10,
+	"debug_trace*":    20,
+}
+
+// RateLimitConfig configures the per-client rate limiting of the HTTP and
+// WebSocket RPC endpoints. Calls are charged against token buckets which are
+// refilled at a constant rate, clients running out of tokens receiving HTTP
+// 429 responses until enough of them are available again.
+//
+// Over HTTP every call of a request is charged before serving it. Over WebSocket
+// the connection handshake is charged as a call, and every call made over the
+// connection is charged by the RPC server, failing with a JSON-RPC error if the
+// client is out of tokens.
+type RateLimitConfig struct {
+	// Key selects what identifies a client: "ip" for the remote address (the
+	// default), its /64 prefix for IPv6, "header:<name>" for the value of a
+	// request header such as an API key, or "jwt:<claim>" for a claim of the
+	// bearer token.
+	//
+	// Header values and claims can be chosen freely by the clients, so only the
+	// ones listed in Keys identify a client. Requests lacking the header or the
+	// claim, or with a value not listed, are charged to their remote address.
+	Key string `toml:",omitempty"`
+
+	// Keys are the header values or claims identifying the clients when the key
+	// is a header or a JWT claim. Note the bearer token is not verified, the
+	// listed claims must be as secret as API keys.
+	Keys []string `toml:",omitempty"`
+
+	// Rate is the number of tokens refilled per second into the bucket of the
+	// methods not belonging to any group. Zero disables limiting them.
+	Rate float64 `toml:",omitempty"`
+
+	// Burst is the capacity of the bucket of the methods not belonging to any
+	// group, defaulting to the rate.
+	Burst int `toml:",omitempty"`
+
+	// Groups are buckets shared by the methods matching their patterns.
+	Groups []RateLimitGroup `toml:",omitempty"`
+
+	// Costs maps method patterns to the tokens charged per call, methods not
+	// matching any costing a single token. Nil means DefaultRateLimitCosts.
+	Costs map[string]int `toml:",omitempty"`
+}
+
+// RateLimitGroup is a bucket of tokens shared by a set of methods.
+type RateLimitGroup struct {
+	Name    string
+	Methods []string // Method patterns, with '*' matching any suffix, e.g. "debug_trace*"
+	Rate    float64  // Tokens refilled per second, zero being unlimited
+	Burst   int      `toml:",omitempty"` // Capacity of the bucket, defaulting to the rate
+}
+
+// enabled returns whether any of the methods is rate limited.
+func (c *RateLimitConfig) enabled() bool {
+	if c.Rate > 0 {
+		return true
+	}
+	for _, group := range c.Groups {
+		if group.Rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimitQuota is the state of a bucket of a client, as reported by the
+// admin_rateLimits RPC method.
+type RateLimitQuota struct {
+	Tokens float64 `json:"tokens"`
+	Rate   float64 `json:"rate"`
+	Burst  int     `json:"burst"`
+}
+
+// rateLimitBucket is the configuration of a token bucket.
+type rateLimitBucket struct {
+	name     string
+	patterns []string
+	rate     float64
+	burst    float64
+}
+
+// tokenBucket is the state of a bucket of a client.
+type tokenBucket struct {
+	tokens  float64
+	updated mclock.AbsTime
+}
+
+// rateLimitCost is the cost of a method matching a pattern.
+type rateLimitCost struct {
+	pattern string
+	cost    int
+}
+
+// rateLimiter tracks the token buckets of the clients of the RPC endpoints.
+type rateLimiter struct {
+	clock   mclock.Clock
+	keyFunc func(remoteAddr string, header http.Header) string
+	buckets []*rateLimitBucket // Bucket configs, the default one first
+	costs   []rateLimitCost    // Method costs, exact names before the longer patterns
+
+	mu        sync.Mutex
+	clients   lru.BasicLRU[string, []tokenBucket]
+	lastPrune mclock.AbsTime
+}
+
+// newRateLimiter creates a rate limiter from the config.
+func newRateLimiter(config RateLimitConfig, clock mclock.Clock) (*rateLimiter, error) {
+	keyFunc, err := rateLimitKeyFunc(config.Key, config.Keys)
+	if err != nil {
+		return nil, err
+	}
+	l := &rateLimiter{
+		clock:     clock,
+		keyFunc:   keyFunc,
+		buckets:   []*rateLimitBucket{newRateLimitBucket(rateLimitDefaultGroup, nil, config.Rate, config.Burst)},
+		clients:   lru.NewBasicLRU[string, []tokenBucket](rateLimitMaxClients),
+		lastPrune: clock.Now(),
+	}
+	for _, group := range config.Groups {
+		if group.Name == "" || group.Name == rateLimitDefaultGroup {
+			return nil, fmt.Errorf("invalid rate limit group name %q", group.Name)
+		}
+		for _, pattern := range group.Methods {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid method pattern %q in rate limit group %q", pattern, group.Name)
+			}
+		}
+		l.buckets = append(l.buckets, newRateLimitBucket(group.Name, group.Methods, group.Rate, group.Burst))
+	}
+	costs := config.Costs
+	if costs == nil {
+		costs = DefaultRateLimitCosts
+	}
+	for pattern, cost := range costs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid method pattern %q in rate limit costs", pattern)
+		}
+		if cost < 0 {
+			return nil, fmt.Errorf("negative rate limit cost for %q", pattern)
+		}
+		l.costs = append(l.costs, rateLimitCost{pattern: pattern, cost: cost})
+	}
+	sort.Slice(l.costs, func(i, j int) bool {
+		iexact := !strings.ContainsAny(l.costs[i].pattern, "*?[")
+		jexact := !strings.ContainsAny(l.costs[j].pattern, "*?[")
+		if iexact != jexact {
+			return iexact
+		}
+		if len(l.costs[i].pattern) != len(l.costs[j].pattern) {
+			return len(l.costs[i].pattern) > len(l.costs[j].pattern)
+		}
+		return l.costs[i].pattern < l.costs[j].pattern
+	})
+	return l, nil
+}
+
+func newRateLimitBucket(name string, patterns []string, rate float64, burst int) *rateLimitBucket {
+	b := &rateLimitBucket{name: name, patterns: patterns, rate: rate, burst: float64(burst)}
+	if b.burst <= 0 {
+		b.burst = math.Max(1, math.Ceil(rate))
+	}
+	return b
+}
+
+// rateLimitKeyFunc returns the function identifying the clients according to
+// the key config. Header values and claims identify a client only if listed in
+// keys, the requests are charged to their remote address otherwise.
+func rateLimitKeyFunc(spec string, keys []string) (func(remoteAddr string, header http.Header) string, error) {
+	kind, name, _ := strings.Cut(spec, ":")
+	switch {
+	case spec == "" || spec == "ip":
+		return func(remoteAddr string, header http.Header) string {
+			return remoteHost(remoteAddr)
+		}, nil
+
+	case (kind == "header" || kind == "jwt") && name != "":
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("rate limit key %q requires a list of client keys", spec)
+		}
+		known := make(map[string]struct{}, len(keys))
+		for _, key := range keys {
+			known[key] = struct{}{}
+		}
+		lookup := func(header http.Header) string { return header.Get(name) }
+		if kind == "jwt" {
+			lookup = jwtClaimLookup(name)
+		}
+		return func(remoteAddr string, header http.Header) string {
+			if key := lookup(header); key != "" {
+				if _, ok := known[key]; ok {
+					return key
+				}
+			}
+			return remoteHost(remoteAddr)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid rate limit key %q, want \"ip\", \"header:<name>\" or \"jwt:<claim>\"", spec)
+	}
+}
+
+// jwtClaimLookup returns a function retrieving a claim of the bearer token of
+// a request, or the empty string if missing.
+func jwtClaimLookup(name string) func(header http.Header) string {
+	parser := jwt.NewParser()
+	return func(header http.Header) string {
+		auth := header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return ""
+		}
+		claims := make(jwt.MapClaims)
+		if _, _, err := parser.ParseUnverified(strings.TrimPrefix(auth, "Bearer "), claims); err != nil {
+			return ""
+		}
+		if claim, ok := claims[name]; ok && claim != nil {
+			return fmt.Sprint(claim)
+		}
+		return ""
+	}
+}
+
+// remoteHost returns the host of a remote address, without the port. IPv6
+// addresses are truncated to their rateLimitIPv6PrefixLen long prefix.
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		prefix := net.IPNet{IP: ip.Mask(net.CIDRMask(rateLimitIPv6PrefixLen, 128)), Mask: net.CIDRMask(rateLimitIPv6PrefixLen, 128)}
+		return prefix.String()
+	}
+	return host
+}
+
+// bucket returns the index of the bucket charged for a method.
+func (l *rateLimiter) bucket(method string) int {
+	for i := 1; i < len(l.buckets); i++ {
+		for _, pattern := range l.buckets[i].patterns {
+			if ok, _ := path.Match(pattern, method); ok {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// cost returns the number of tokens charged for a call of a method.
+func (l *rateLimiter) cost(method string) int {
+	for _, c := range l.costs {
+		if ok, _ := path.Match(c.pattern, method); ok {
+			return c.cost
+		}
+	}
+	return 1
+}
+
+// charges returns the tokens to take from every bucket for calling the methods.
+func (l *rateLimiter) charges(methods []string) []float64 {
+	charges := make([]float64, len(l.buckets))
+	for _, method := range methods {
+		charges[l.bucket(method)] += float64(l.cost(method))
+	}
+	return charges
+}
+
+// refill adds the tokens accumulated since the last update of a bucket.
+func (l *rateLimiter) refill(bucket *tokenBucket, config *rateLimitBucket, now mclock.AbsTime) {
+	elapsed := time.Duration(now - bucket.updated).Seconds()
+	bucket.tokens = math.Min(config.burst, bucket.tokens+elapsed*config.rate)
+	bucket.updated = now
+}
+
+// take charges the calls of a client against its buckets. If any of them lacks
+// tokens nothing is charged, and the time to wait before retrying is returned.
+//
+// Calls costing more than a bucket holds are let through once the bucket is
+// full, leaving it in debt.
+func (l *rateLimiter) take(key string, methods []string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if time.Duration(now-l.lastPrune) >= rateLimitPruneInterval {
+		l.prune(now)
+	}
+	buckets, _ := l.clients.Get(key)
+	if buckets == nil {
+		buckets = make([]tokenBucket, len(l.buckets))
+		for i, config := range l.buckets {
+			buckets[i] = tokenBucket{tokens: config.burst, updated: now}
+		}
+	}
+	var (
+		charges = l.charges(methods)
+		wait    float64
+	)
+	for i, config := range l.buckets {
+		if config.rate <= 0 || charges[i] == 0 {
+			continue
+		}
+		l.refill(&buckets[i], config, now)
+		if need := math.Min(charges[i], config.burst); buckets[i].tokens < need {
+			wait = math.Max(wait, (need-buckets[i].tokens)/config.rate)
+		}
+	}
+	l.clients.Add(key, buckets) // Evicts the least recently seen client if full
+	if wait > 0 {
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	for i, config := range l.buckets {
+		if config.rate > 0 {
+			buckets[i].tokens -= charges[i]
+		}
+	}
+	return true, 0
+}
+
+// prune drops the clients whose buckets are all full, the limiter creating
+// them again full if needed. The caller must hold l.mu.
+func (l *rateLimiter) prune(now mclock.AbsTime) {
+	for _, key := range l.clients.Keys() {
+		buckets, _ := l.clients.Peek(key)
+		full := true
+		for i, config := range l.buckets {
+			l.refill(&buckets[i], config, now)
+			if config.rate > 0 && buckets[i].tokens < config.burst {
+				full = false
+			}
+		}
+		if full {
+			l.clients.Remove(key)
+		}
+	}
+	l.lastPrune = now
+}
+
+// quotas returns the state of the limited buckets of all the clients seen
+// lately.
+func (l *rateLimiter) quotas() map[string]map[string]RateLimitQuota {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var (
+		now    = l.clock.Now()
+		quotas = make(map[string]map[string]RateLimitQuota, l.clients.Len())
+	)
+	for _, key := range l.clients.Keys() {
+		buckets, _ := l.clients.Peek(key)
+		quota := make(map[string]RateLimitQuota)
+		for i, config := range l.buckets {
+			if config.rate <= 0 {
+				continue
+			}
+			l.refill(&buckets[i], config, now)
+			quota[config.name] = RateLimitQuota{Tokens: buckets[i].tokens, Rate: config.rate, Burst: int(config.burst)}
+		}
+		quotas[key] = quota
+	}
+	return quotas
+}
+
+// rateLimitError is returned by the RPC server for the calls exceeding the
+// quota of their client.
+type rateLimitError struct {
+	wait time.Duration
+}
+
+func (e *rateLimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry in %ds", int(math.Ceil(e.wait.Seconds())))
+}
+
+func (e *rateLimitError) ErrorCode() int { return errcodeRateLimited }
+
+// limitCall charges a call made over a persistent connection to the client,
+// identified by the request which opened the connection.
+func (l *rateLimiter) limitCall(ctx context.Context, method string) error {
+	peer := rpc.PeerInfoFromContext(ctx)
+	if ok, wait := l.take(l.keyFunc(peer.RemoteAddr, peer.HTTP.Header), []string{method}); !ok {
+		return &rateLimitError{wait: wait}
+	}
+	return nil
+}
+
+// rateLimitCall is the part of a JSON-RPC call needed to charge it.
+type rateLimitCall struct {
+	Method string `json:"method"`
+}
+
+// requestMethods returns the methods called by a request. The body of the
+// request is read and replaced by a reader returning the same content.
+func requestMethods(r *http.Request) []string {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return []string{""} // WebSocket handshakes and health checks
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, rateLimitBodyLimit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > rateLimitBodyLimit {
+		return []string{""}
+	}
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		var calls []rateLimitCall
+		if err := json.Unmarshal(body, &calls); err != nil || len(calls) == 0 {
+			return []string{""}
+		}
+		methods := make([]string, len(calls))
+		for i, call := range calls {
+			methods[i] = call.Method
+		}
+		return methods
+	}
+	var call rateLimitCall
+	if err := json.Unmarshal(body, &call); err != nil {
+		return []string{""}
+	}
+	return []string{call.Method}
+}
+
+// newRateLimitHandler creates a http.Handler rejecting the requests of the
+// clients exceeding their quotas with 429 Too Many Requests.
+func newRateLimitHandler(limiter *rateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := limiter.take(limiter.keyFunc(r.RemoteAddr, r.Header), requestMethods(r))
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
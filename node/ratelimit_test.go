@@ -0,0 +1,268 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRateLimiterBuckets(t *testing.T) {
+	clock := new(mclock.Simulated)
+	limiter, err := newRateLimiter(RateLimitConfig{
+		Rate:  1,
+		Burst: 2,
+		Groups: []RateLimitGroup{
+			{Name: "trace", Methods: []string{"debug_trace*"}, Rate: 1, Burst: 20},
+		},
+	}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(methods []string, wantOK bool, wantWait time.Duration) {
+		t.Helper()
+		ok, wait := limiter.take("client", methods)
+		if ok != wantOK || wait != wantWait {
+			t.Fatalf("%v: have (%v, %v), want (%v, %v)", methods, ok, wait, wantOK, wantWait)
+		}
+	}
+	// Cheap calls are limited by the default bucket
+	check([]string{"eth_blockNumber"}, true, 0)
+	check([]string{"eth_blockNumber"}, true, 0)
+	check([]string{"eth_blockNumber"}, false, time.Second)
+	clock.Run(time.Second)
+	check([]string{"eth_blockNumber"}, true, 0)
+
+	// Traces are charged to their own group, leaving the default bucket intact
+	clock.Run(2 * time.Second)
+	check([]string{"debug_traceTransaction"}, true, 0)
+	check([]string{"debug_traceCall"}, false, 20*time.Second)
+	check([]string{"eth_chainId", "eth_chainId"}, true, 0)
+
+	// Batches are charged as a whole, calls over the burst waiting for a full bucket
+	clock.Run(2 * time.Second)
+	check([]string{"eth_chainId", "eth_getLogs"}, true, 0)
+	check([]string{"eth_chainId"}, false, 10*time.Second)
+	clock.Run(10 * time.Second)
+	check([]string{"eth_chainId"}, true, 0)
+
+	quotas := limiter.quotas()["client"]
+	if quotas["default"].Tokens != 0 || quotas["default"].Burst != 2 {
+		t.Errorf("wrong default quota: %+v", quotas["default"])
+	}
+	if quotas["trace"].Tokens != 12 || quotas["trace"].Rate != 1 {
+		t.Errorf("wrong trace quota: %+v", quotas["trace"])
+	}
+	// Clients with full buckets are eventually forgotten
+	clock.Run(rateLimitPruneInterval)
+	check([]string{"eth_chainId"}, true, 0)
+	if len(limiter.quotas()) != 1 {
+		t.Fatal("idle client not pruned")
+	}
+}
+
+func TestRateLimiterConfig(t *testing.T) {
+	tests := []RateLimitConfig{
+		{Rate: 1, Key: "cookie"},
+		{Rate: 1, Key: "header:"},
+		{Rate: 1, Key: "header:X-Api-Key"},
+		{Rate: 1, Key: "jwt:sub"},
+		{Rate: 1, Groups: []RateLimitGroup{{Name: "default", Rate: 1}}},
+		{Rate: 1, Groups: []RateLimitGroup{{Name: "bad", Methods: []string{"eth_["}, Rate: 1}}},
+		{Rate: 1, Costs: map[string]int{"eth_call": -1}},
+	}
+	for i, config := range tests {
+		if _, err := newRateLimiter(config, new(mclock.Simulated)); err == nil {
+			t.Errorf("test %d: invalid config accepted", i)
+		}
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	limiter, err := newRateLimiter(RateLimitConfig{Key: "header:X-Api-Key", Keys: []string{"alice", "bob"}, Rate: 1, Burst: 5}, new(mclock.Simulated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newRateLimitHandler(limiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	post := func(key, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+		req.Header.Set("X-Api-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	// The request body must reach the server intact
+	body := `[{"jsonrpc":"2.0","id":1,"method":"eth_chainId"},{"jsonrpc":"2.0","id":2,"method":"eth_chainId"}]`
+	resp := post("alice", body)
+	if echoed, _ := io.ReadAll(resp.Body); string(echoed) != body {
+		t.Fatalf("wrong body forwarded: %s", echoed)
+	}
+	resp.Body.Close()
+
+	// Calls over the quota get 429 responses
+	resp = post("alice", `{"jsonrpc":"2.0","id":1,"method":"eth_call"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("wrong status: %d", resp.StatusCode)
+	}
+	if retry := resp.Header.Get("Retry-After"); retry != "2" {
+		t.Fatalf("wrong Retry-After: %q", retry)
+	}
+	// Other clients have quotas of their own
+	resp = post("bob", `{"jsonrpc":"2.0","id":1,"method":"eth_call"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status: %d", resp.StatusCode)
+	}
+	if quotas := limiter.quotas(); len(quotas) != 2 || quotas["alice"]["default"].Tokens != 3 {
+		t.Fatalf("wrong quotas: %v", quotas)
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "partner"}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		spec   string
+		header string
+		value  string
+		want   string
+	}{
+		{"ip", "", "", "192.0.2.1"},
+		{"header:X-Api-Key", "X-Api-Key", "key", "key"},
+		{"header:X-Api-Key", "X-Api-Key", "random", "192.0.2.1"},
+		{"header:X-Api-Key", "", "", "192.0.2.1"},
+		{"jwt:sub", "Authorization", "Bearer " + token, "partner"},
+		{"jwt:aud", "Authorization", "Bearer " + token, "192.0.2.1"},
+		{"jwt:sub", "Authorization", "Bearer invalid", "192.0.2.1"},
+	}
+	for i, test := range tests {
+		keyFunc, err := rateLimitKeyFunc(test.spec, []string{"key", "partner"})
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if test.header != "" {
+			req.Header.Set(test.header, test.value)
+		}
+		if key := keyFunc(req.RemoteAddr, req.Header); key != test.want {
+			t.Errorf("test %d: wrong key: have %q, want %q", i, key, test.want)
+		}
+	}
+}
+
+// Tests that the least recently seen clients are forgotten once too many of them
+// are tracked.
+func TestRateLimiterMaxClients(t *testing.T) {
+	limiter, err := newRateLimiter(RateLimitConfig{Rate: 1}, new(mclock.Simulated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < rateLimitMaxClients; i++ {
+		limiter.take(fmt.Sprint(i), []string{"eth_chainId"})
+	}
+	// Seeing a client again keeps it around
+	if ok, _ := limiter.take("0", []string{"eth_chainId"}); ok {
+		t.Fatal("tracked client not charged")
+	}
+	// New clients get their own buckets, evicting the least recently seen one
+	for _, key := range []string{"new1", "new2"} {
+		if ok, _ := limiter.take(key, []string{"eth_chainId"}); !ok {
+			t.Fatalf("new client %s rejected", key)
+		}
+	}
+	quotas := limiter.quotas()
+	if len(quotas) != rateLimitMaxClients {
+		t.Fatalf("wrong number of tracked clients: have %d, want %d", len(quotas), rateLimitMaxClients)
+	}
+	for key, tracked := range map[string]bool{"0": true, "1": false, "2": false, "3": true, "new1": true, "new2": true} {
+		if _, ok := quotas[key]; ok != tracked {
+			t.Errorf("client %s: tracked %v, want %v", key, ok, tracked)
+		}
+	}
+}
+
+func TestRateLimitRemoteHost(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.1:30303":              "192.0.2.1",
+		"[2001:db8:1:2:3:4:5:6]:30303": "2001:db8:1:2::/64",
+		"[2001:db8:1:2:ffff::1]:30303": "2001:db8:1:2::/64",
+		"[::ffff:192.0.2.1]:30303":     "::ffff:192.0.2.1",
+		"pipe":                         "pipe",
+	}
+	for addr, want := range tests {
+		if host := remoteHost(addr); host != want {
+			t.Errorf("%s: wrong host: have %s, want %s", addr, host, want)
+		}
+	}
+}
+
+// Tests that every call made over a WebSocket connection is charged.
+func TestRateLimitWebSocketCalls(t *testing.T) {
+	limiter, err := newRateLimiter(RateLimitConfig{Rate: 1, Burst: 3}, new(mclock.Simulated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := rpc.NewServer()
+	srv.SetCallLimiter(limiter.limitCall)
+	httpsrv := httptest.NewServer(newRateLimitHandler(limiter, srv.WebsocketHandler([]string{"*"})))
+	defer httpsrv.Close()
+
+	client, err := rpc.Dial("ws:" + strings.TrimPrefix(httpsrv.URL, "http:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// The handshake took a token, two calls take the rest
+	for i := 0; i < 2; i++ {
+		if _, err := client.SupportedModules(); err != nil {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+	}
+	var rpcErr rpc.Error
+	_, err = client.SupportedModules()
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != errcodeRateLimited {
+		t.Fatalf("call over the quota not rejected: %v", err)
+	}
+	// Subscriptions are charged too
+	_, err = client.Subscribe(context.Background(), "eth", make(chan struct{}), "newHeads")
+	if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != errcodeRateLimited {
+		t.Fatalf("subscription over the quota not rejected: %v", err)
+	}
+}
@@ -38,18 +38,31 @@ import (
 // httpConfig is the JSON-RPC/HTTP configuration.
 type httpConfig struct {
 	Modules            []string
+	AllowMethods       []string
+	DenyMethods        []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
 	prefix             string // path prefix on which to mount http handler
-	jwtSecret          []byte // optional JWT secret
+	rpcEndpointConfig
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins   []string
-	Modules   []string
-	prefix    string // path prefix on which to mount ws handler
-	jwtSecret []byte // optional JWT secret
+	Origins      []string
+	Modules      []string
+	AllowMethods []string
+	DenyMethods  []string
+	prefix       string // path prefix on which to mount ws handler
+	rpcEndpointConfig
+}
+
+// rpcEndpointConfig is the configuration shared by the HTTP and WebSocket endpoints.
+type rpcEndpointConfig struct {
+	jwtSecret              []byte // optional JWT secret
+	batchItemLimit         int    // maximum number of requests in a batch, zero if unlimited
+	batchResponseSizeLimit int    // maximum size of the responses of a batch, zero if unlimited
+
+	rateLimiter *rateLimiter // optional per-client rate limiter
 }
 
 type rpcHandler struct {
@@ -297,12 +310,20 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	if err := srv.SetMethodFilter(config.AllowMethods, config.DenyMethods); err != nil {
+		return err
+	}
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
 	h.httpConfig = config
+	var handler http.Handler = srv
+	if config.rateLimiter != nil {
+		handler = newRateLimitHandler(config.rateLimiter, handler)
+	}
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
+		Handler: NewHTTPHandlerStack(handler, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
 		server:  srv,
 	})
 	return nil
@@ -328,12 +349,22 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	}
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	srv.SetBatchLimits(config.batchItemLimit, config.batchResponseSizeLimit)
+	if err := srv.SetMethodFilter(config.AllowMethods, config.DenyMethods); err != nil {
+		return err
+	}
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
 	h.wsConfig = config
+	handler := srv.WebsocketHandler(config.Origins)
+	if config.rateLimiter != nil {
+		// Charge the handshake, then every call made over the connection
+		srv.SetCallLimiter(config.rateLimiter.limitCall)
+		handler = newRateLimitHandler(config.rateLimiter, handler)
+	}
 	h.wsHandler.Store(&rpcHandler{
-		Handler: NewWSHandlerStack(srv.WebsocketHandler(config.Origins), config.jwtSecret),
+		Handler: NewWSHandlerStack(handler, config.jwtSecret),
 		server:  srv,
 	})
 	return nil
@@ -569,16 +600,18 @@ func newGzipHandler(next http.Handler) http.Handler {
 }
 
 type ipcServer struct {
-	log      log.Logger
-	endpoint string
+	log          log.Logger
+	endpoint     string
+	allowMethods []string
+	denyMethods  []string
 
 	mu       sync.Mutex
 	listener net.Listener
 	srv      *rpc.Server
 }
 
-func newIPCServer(log log.Logger, endpoint string) *ipcServer {
-	return &ipcServer{log: log, endpoint: endpoint}
+func newIPCServer(log log.Logger, endpoint string, allowMethods, denyMethods []string) *ipcServer {
+	return &ipcServer{log: log, endpoint: endpoint, allowMethods: allowMethods, denyMethods: denyMethods}
 }
 
 // Start starts the httpServer's http.Server
@@ -589,7 +622,14 @@ func (is *ipcServer) start(apis []rpc.API) error {
 	if is.listener != nil {
 		return nil // already running
 	}
-	listener, srv, err := rpc.StartIPCEndpoint(is.endpoint, apis)
+	srv := rpc.NewServer()
+	if err := srv.SetMethodFilter(is.allowMethods, is.denyMethods); err != nil {
+		return err
+	}
+	if err := RegisterApis(apis, nil, srv); err != nil {
+		return err
+	}
+	listener, err := rpc.ServeIPC(srv, is.endpoint)
 	if err != nil {
 		is.log.Warn("IPC opening failed", "url", is.endpoint, "error", err)
 		return err
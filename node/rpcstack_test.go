@@ -339,8 +339,8 @@ func TestJWT(t *testing.T) {
 		ss, _ := jwt.NewWithClaims(method, testClaim(input)).SignedString(secret)
 		return ss
 	}
-	srv := createAndStartServer(t, &httpConfig{jwtSecret: []byte("secret")},
-		true, &wsConfig{Origins: []string{"*"}, jwtSecret: []byte("secret")}, nil)
+	srv := createAndStartServer(t, &httpConfig{rpcEndpointConfig: rpcEndpointConfig{jwtSecret: []byte("secret")}},
+		true, &wsConfig{Origins: []string{"*"}, rpcEndpointConfig: rpcEndpointConfig{jwtSecret: []byte("secret")}}, nil)
 	wsUrl := fmt.Sprintf("ws://%v", srv.listenAddr())
 	htUrl := fmt.Sprintf("http://%v", srv.listenAddr())
 
@@ -594,6 +594,26 @@ func TestHTTPWriteTimeout(t *testing.T) {
 	})
 }
 
+func TestHTTPMethodFilter(t *testing.T) {
+	const (
+		greetRes  = `{"jsonrpc":"2.0","id":1,"result":"Hello"}`
+		deniedRes = `{"jsonrpc":"2.0","id":1,"error":{"code":-32004,"message":"the method test_sleep is not allowed on this endpoint"}}`
+	)
+	srv := createAndStartServer(t, &httpConfig{Modules: []string{"test"}, DenyMethods: []string{"test_sl*"}}, false, &wsConfig{}, nil)
+	defer srv.stop()
+	url := fmt.Sprintf("http://%v", srv.listenAddr())
+
+	resp := batchRpcRequest(t, url, []string{"test_greet", "test_sleep"})
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("[%s,%s]", greetRes, deniedRes); strings.TrimSpace(string(body)) != want {
+		t.Errorf("wrong response. have %s, want %s", string(body), want)
+	}
+}
+
 func apis() []rpc.API {
 	return []rpc.API{
 		{
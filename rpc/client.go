@@ -35,6 +35,7 @@ var (
 	ErrBadResult                 = errors.New("bad result in JSON-RPC response")
 	ErrClientQuit                = errors.New("client is closed")
 	ErrNoResult                  = errors.New("no result in JSON-RPC response")
+	ErrMissingBatchResponse      = errors.New("response batch did not contain a response to this call")
 	ErrSubscriptionQueueOverflow = errors.New("subscription queue overflow")
 	errClientReconnected         = errors.New("client reconnected")
 	errDead                      = errors.New("connection lost")
@@ -79,11 +80,21 @@ type Client struct {
 	isHTTP   bool      // connection type: http, ws or ipc
 	services *serviceRegistry
 
+	// Limits of the batches served to the other end, see Server.SetBatchLimits
+	batchItemLimit     int
+	batchResponseLimit int
+
+	// Maximum number of items sent in a single batch request, zero if unlimited
+	batchChunkSize int
+
 	idCounter uint32
 
 	// This function, if non-nil, is called when the connection is lost.
 	reconnectFunc reconnectFunc
 
+	// Endpoint selection of clients created by DialFailover, nil otherwise
+	failover *failover
+
 	// writeConn is used for writing to the connection on the caller's goroutine. It should
 	// only be accessed outside of dispatch, with the write lock held. The write lock is
 	// taken by sending on reqInit and released by sending on reqSent.
@@ -114,7 +125,7 @@ func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, clientContextKey{}, c)
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, conn.peerInfo())
-	handler := newHandler(ctx, conn, c.idgen, c.services)
+	handler := newHandler(ctx, conn, c.idgen, c.services, c.batchItemLimit, c.batchResponseLimit)
 	return &clientConn{conn, handler}
 }
 
@@ -133,6 +144,8 @@ type requestOp struct {
 	err  error
 	resp chan *jsonrpcMessage // receives up to len(ids) responses
 	sub  *ClientSubscription  // only set for EthSubscribe requests
+
+	resubscribe bool // sub is already running, set when re-establishing it after failover
 }
 
 func (op *requestOp) wait(ctx context.Context, c *Client) (*jsonrpcMessage, error) {
@@ -211,7 +224,12 @@ func DialOptions(ctx context.Context, rawurl string, options ...ClientOption) (*
 		return nil, fmt.Errorf("no known transport for URL scheme %q", u.Scheme)
 	}
 
-	return newClient(ctx, reconnect)
+	c, err := newClient(ctx, reconnect)
+	if err != nil {
+		return nil, err
+	}
+	c.batchChunkSize = cfg.batchChunkSize
+	return c, nil
 }
 
 // ClientFromContext retrieves the client from the context, if any. This can be used to perform
@@ -226,27 +244,30 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry))
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), 0, 0, nil)
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, batchItemLimit, batchResponseLimit int, failover *failover) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
-		isHTTP:      isHTTP,
-		idgen:       idgen,
-		services:    services,
-		writeConn:   conn,
-		close:       make(chan struct{}),
-		closing:     make(chan struct{}),
-		didClose:    make(chan struct{}),
-		reconnected: make(chan ServerCodec),
-		readOp:      make(chan readOp),
-		readErr:     make(chan error),
-		reqInit:     make(chan *requestOp),
-		reqSent:     make(chan error, 1),
-		reqTimeout:  make(chan *requestOp),
+		isHTTP:             isHTTP,
+		idgen:              idgen,
+		services:           services,
+		batchItemLimit:     batchItemLimit,
+		batchResponseLimit: batchResponseLimit,
+		failover:           failover,
+		writeConn:          conn,
+		close:              make(chan struct{}),
+		closing:            make(chan struct{}),
+		didClose:           make(chan struct{}),
+		reconnected:        make(chan ServerCodec),
+		readOp:             make(chan readOp),
+		readErr:            make(chan error),
+		reqInit:            make(chan *requestOp),
+		reqSent:            make(chan error, 1),
+		reqTimeout:         make(chan *requestOp),
 	}
 	if !isHTTP {
 		go c.dispatch(conn)
@@ -279,6 +300,9 @@ func (c *Client) SupportedModules() (map[string]string, error) {
 
 // Close closes the client, aborting any in-flight requests.
 func (c *Client) Close() {
+	if c.failover != nil {
+		c.failover.close()
+	}
 	if c.isHTTP {
 		return
 	}
@@ -370,10 +394,32 @@ func (c *Client) BatchCall(b []BatchElem) error {
 //
 // In contrast to CallContext, BatchCallContext only returns errors that have occurred
 // while sending the request. Any error specific to a request is reported through the
-// Error field of the corresponding BatchElem.
+// Error field of the corresponding BatchElem. Calls the server didn't answer, e.g.
+// because the batch exceeded its limits, fail with ErrMissingBatchResponse.
 //
-// Note that batch calls may not be executed atomically on the server side.
+// Note that batch calls may not be executed atomically on the server side. Batches
+// larger than the size configured by WithBatchChunkSize are sent as several batches.
 func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
+	// Split the batch if it exceeds the configured size, the chunks are sent
+	// one after the other.
+	if c.batchChunkSize > 0 && len(b) > c.batchChunkSize {
+		for start := 0; start < len(b); start += c.batchChunkSize {
+			end := start + c.batchChunkSize
+			if end > len(b) {
+				end = len(b)
+			}
+			if err := c.sendBatch(ctx, b[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return c.sendBatch(ctx, b)
+}
+
+// sendBatch sends all given requests as a single batch and waits for the server
+// to return a response for all of them.
+func (c *Client) sendBatch(ctx context.Context, b []BatchElem) error {
 	var (
 		msgs = make([]*jsonrpcMessage, len(b))
 		byID = make(map[string]int, len(b))
@@ -399,17 +445,23 @@ func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
 		err = c.send(ctx, op, msgs)
 	}
 
-	// Wait for all responses to come back.
+	// Wait for all responses to come back. The response channel is closed early
+	// if the server answered the batch without responding to every call.
 	for n := 0; n < len(b) && err == nil; n++ {
 		var resp *jsonrpcMessage
 		resp, err = op.wait(ctx, c)
-		if err != nil {
+		if err != nil || resp == nil {
 			break
 		}
-		// Find the element corresponding to this response.
-		// The element is guaranteed to be present because dispatch
-		// only sends valid IDs to our channel.
-		elem := &b[byID[string(resp.ID)]]
+		// Find the element corresponding to this response, the servers may
+		// answer the whole batch with the ID of its first call.
+		index, ok := byID[string(resp.ID)]
+		if !ok {
+			continue
+		}
+		delete(byID, string(resp.ID))
+
+		elem := &b[index]
 		if resp.Error != nil {
 			elem.Error = resp.Error
 			continue
@@ -420,6 +472,11 @@ func (c *Client) BatchCallContext(ctx context.Context, b []BatchElem) error {
 		}
 		elem.Error = json.Unmarshal(resp.Result, elem.Result)
 	}
+	if err == nil {
+		for _, index := range byID {
+			b[index].Error = ErrMissingBatchResponse
+		}
+	}
 	return err
 }
 
@@ -483,6 +540,7 @@ func (c *Client) Subscribe(ctx context.Context, namespace string, channel interf
 		resp: make(chan *jsonrpcMessage),
 		sub:  newClientSubscription(c, namespace, chanVal),
 	}
+	op.sub.args = args
 
 	// Send the subscription request.
 	// The arrival and validity of the response is signaled on sub.quit.
@@ -532,6 +590,9 @@ func (c *Client) write(ctx context.Context, msg interface{}, retry bool) error {
 	}
 	err := c.writeConn.writeJSON(ctx, msg, false)
 	if err != nil {
+		if c.failover != nil {
+			c.failover.connFailed(c.writeConn)
+		}
 		c.writeConn = nil
 		if !retry {
 			return c.write(ctx, msg, true)
@@ -602,8 +663,10 @@ func (c *Client) dispatch(codec ServerCodec) {
 
 		case err := <-c.readErr:
 			conn.handler.log.Debug("RPC connection read error", "err", err)
+			subs := c.detachSubscriptions(conn)
 			conn.close(err, lastOp)
 			reading = false
+			c.resubscribe(subs)
 
 		// Reconnect:
 		case newcodec := <-c.reconnected:
@@ -614,8 +677,10 @@ func (c *Client) dispatch(codec ServerCodec) {
 				// In those cases the caller will notice first and reconnect. Closing the
 				// handler terminates all waiting requests (closing op.resp) except for
 				// lastOp, which will be transferred to the new handler.
+				subs := c.detachSubscriptions(conn)
 				conn.close(errClientReconnected, lastOp)
 				c.drainRead()
+				c.resubscribe(subs)
 			}
 			go c.read(newcodec)
 			reading = true
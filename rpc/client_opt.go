@@ -17,7 +17,9 @@
 package rpc
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -33,6 +35,13 @@ type clientConfig struct {
 	httpAuth    HTTPAuth
 
 	wsDialer *websocket.Dialer
+
+	batchChunkSize int
+
+	// Failover settings, see DialFailover
+	healthCheckInterval time.Duration
+	healthCheck         func(context.Context, *Client) error
+	resubscribeHook     func(Resubscription)
 }
 
 func (cfg *clientConfig) initHeaders() {
@@ -98,6 +107,37 @@ func WithHTTPAuth(a HTTPAuth) ClientOption {
 	})
 }
 
+// WithBatchChunkSize configures the maximum number of items sent in a single batch
+// request. Larger batches passed to BatchCall are split and sent as several
+// consecutive batches, which is useful with servers limiting the batch size.
+func WithBatchChunkSize(size int) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.batchChunkSize = size
+	})
+}
+
+// WithHealthCheck configures the periodic health check of the endpoints of clients created
+// by DialFailover. Every interval, check is called with a temporary client connected to
+// each endpoint, endpoints returning an error are avoided until they pass the check again.
+// If check is nil, endpoints are only required to answer web3_clientVersion.
+func WithHealthCheck(interval time.Duration, check func(ctx context.Context, c *Client) error) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.healthCheckInterval = interval
+		cfg.healthCheck = check
+	})
+}
+
+// WithResubscribeHook configures a function which clients created by DialFailover call
+// whenever a subscription has been re-established on a new connection. Notifications
+// sent while the subscription was being moved are lost, so the hook can be used to
+// detect gaps, e.g. in the block numbers received through a newHeads subscription.
+// The hook is called on a background goroutine.
+func WithResubscribeHook(hook func(Resubscription)) ClientOption {
+	return optionFunc(func(cfg *clientConfig) {
+		cfg.resubscribeHook = hook
+	})
+}
+
 // A HTTPAuth function is called by the client whenever a HTTP request is sent.
 // The function must be safe for concurrent use.
 //
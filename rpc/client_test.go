@@ -193,14 +193,22 @@ func TestClientBatchRequest_len(t *testing.T) {
 
 	t.Run("too-few", func(t *testing.T) {
 		batch := []BatchElem{
-			{Method: "foo"},
-			{Method: "bar"},
-			{Method: "baz"},
+			{Method: "foo", Result: new(string)},
+			{Method: "bar", Result: new(string)},
+			{Method: "baz", Result: new(string)},
 		}
 		ctx, cancelFn := context.WithTimeout(context.Background(), time.Second)
 		defer cancelFn()
-		if err := client.BatchCallContext(ctx, batch); !errors.Is(err, ErrBadResult) {
-			t.Errorf("expected %q but got: %v", ErrBadResult, err)
+		if err := client.BatchCallContext(ctx, batch); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		for i, elem := range batch[:2] {
+			if elem.Error != nil {
+				t.Errorf("batch item %d: unexpected error %v", i, elem.Error)
+			}
+		}
+		if batch[2].Error != ErrMissingBatchResponse {
+			t.Errorf("missing batch item: wrong error %v", batch[2].Error)
 		}
 	})
 
@@ -216,6 +224,96 @@ func TestClientBatchRequest_len(t *testing.T) {
 	})
 }
 
+func TestClientBatchRequestLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetBatchLimits(2, 0)
+
+	makeBatch := func() []BatchElem {
+		batch := make([]BatchElem, 5)
+		for i := range batch {
+			batch[i] = BatchElem{Method: "test_echo", Args: []interface{}{"x", i, nil}, Result: new(echoResult)}
+		}
+		return batch
+	}
+	// Batches over the limit must be rejected with a single error, over both
+	// HTTP and persistent connections
+	client, hs := httpTestClient(server, "http", nil)
+	defer hs.Close()
+	defer client.Close()
+
+	inproc := DialInProc(server)
+	defer inproc.Close()
+
+	for _, client := range []*Client{client, inproc} {
+		batch := makeBatch()
+		if err := client.BatchCall(batch); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		for i, elem := range batch {
+			if i > 0 {
+				if elem.Error != ErrMissingBatchResponse {
+					t.Errorf("batch item %d: wrong error %v", i, elem.Error)
+				}
+				continue
+			}
+			err, ok := elem.Error.(Error)
+			if !ok || err.ErrorCode() != -32600 || err.Error() != errMsgBatchTooLarge {
+				t.Errorf("batch item %d: wrong error %v", i, elem.Error)
+			}
+		}
+	}
+	// Clients splitting the batches must get all the results
+	chunked, err := DialOptions(context.Background(), hs.URL, WithBatchChunkSize(2))
+	if err != nil {
+		t.Fatal("failed to dial test server:", err)
+	}
+	defer chunked.Close()
+
+	batch := makeBatch()
+	if err := chunked.BatchCall(batch); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for i, elem := range batch {
+		if elem.Error != nil {
+			t.Errorf("batch item %d: unexpected error %v", i, elem.Error)
+		}
+		if result := elem.Result.(*echoResult); result.Int != i {
+			t.Errorf("batch item %d: wrong result %d", i, result.Int)
+		}
+	}
+}
+
+func TestClientBatchResponseSizeLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetBatchLimits(0, 140)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Every response is 68 bytes, only the first two fit in the limit
+	batch := make([]BatchElem, 4)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "test_echo", Args: []interface{}{"x", i, nil}, Result: new(echoResult)}
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	for i, elem := range batch {
+		if i < 2 {
+			if elem.Error != nil {
+				t.Errorf("batch item %d: unexpected error %v", i, elem.Error)
+			}
+			continue
+		}
+		err, ok := elem.Error.(Error)
+		if !ok || err.ErrorCode() != errcodeResponseTooLarge || err.Error() != errMsgResponseTooLarge {
+			t.Errorf("batch item %d: wrong error %v", i, elem.Error)
+		}
+	}
+}
+
 func TestClientNotify(t *testing.T) {
 	server := newTestServer()
 	defer server.Stop()
@@ -43,10 +43,19 @@ func StartIPCEndpoint(ipcEndpoint string, apis []API) (net.Listener, *Server, er
 	}
 	log.Debug("IPCs registered", "namespaces", strings.Join(registered, ","))
 	// All APIs registered, start the IPC listener.
-	listener, err := ipcListen(ipcEndpoint)
+	listener, err := ServeIPC(handler, ipcEndpoint)
 	if err != nil {
 		return nil, nil, err
 	}
-	go handler.ServeListener(listener)
 	return listener, handler, nil
 }
+
+// ServeIPC starts serving the given server on an IPC endpoint.
+func ServeIPC(srv *Server, ipcEndpoint string) (net.Listener, error) {
+	listener, err := ipcListen(ipcEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	go srv.ServeListener(listener)
+	return listener, nil
+}
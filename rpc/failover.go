@@ -0,0 +1,394 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	healthCheckTimeout    = 5 * time.Second // timeout of a single endpoint health check
+	resubscribeRetryDelay = time.Second     // delay between attempts to re-establish a subscription
+)
+
+// Resubscription describes a subscription which was re-established by a client created
+// by DialFailover after the connection serving it was lost. Notifications sent by the
+// server while there was no connection are not delivered.
+type Resubscription struct {
+	Sub       *ClientSubscription
+	Namespace string
+	Args      []interface{} // arguments of the subscribe call, starting with the subscription name
+	Endpoint  string        // endpoint serving the subscription now
+}
+
+// DialFailover creates a client which is connected to one of several endpoints serving
+// the same API. The endpoints are given in order of preference and must either all use
+// HTTP, or all use WebSocket and IPC.
+//
+// The client sends requests to the most preferred healthy endpoint. When an endpoint
+// can't be reached, it is marked unhealthy and the client moves on to the next one.
+// HTTP requests failing this way, or with a 5xx or 429 status, are retried on the next
+// endpoint. Subscriptions of WebSocket and IPC clients are re-established transparently
+// when the connection is lost, see WithResubscribeHook for detecting missed
+// notifications. Note that WebSocket and IPC clients stay connected to an endpoint until
+// the connection breaks or the endpoint fails its health check.
+//
+// Unhealthy endpoints become healthy again when the client connects to them successfully,
+// or when they pass the health check configured by WithHealthCheck.
+func DialFailover(ctx context.Context, endpoints []string, options ...ClientOption) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("no endpoints given")
+	}
+	cfg := new(clientConfig)
+	for _, opt := range options {
+		opt.applyOption(cfg)
+	}
+
+	f := &failover{
+		interval: cfg.healthCheckInterval,
+		check:    cfg.healthCheck,
+		onResub:  cfg.resubscribeHook,
+		quit:     make(chan struct{}),
+	}
+	var isHTTP bool
+	for i, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		var connect reconnectFunc
+		switch u.Scheme {
+		case "http", "https":
+			connect = newClientTransportHTTP(endpoint, cfg)
+		case "ws", "wss":
+			if connect, err = newClientTransportWS(endpoint, cfg); err != nil {
+				return nil, err
+			}
+		case "":
+			connect = newClientTransportIPC(endpoint)
+		default:
+			return nil, fmt.Errorf("no known failover transport for URL scheme %q", u.Scheme)
+		}
+		useHTTP := u.Scheme == "http" || u.Scheme == "https"
+		if i > 0 && useHTTP != isHTTP {
+			return nil, errors.New("failover endpoints must either all use HTTP or all use WebSocket and IPC")
+		}
+		isHTTP = useHTTP
+		f.endpoints = append(f.endpoints, &failoverEndpoint{url: endpoint, connect: connect, healthy: true})
+	}
+
+	conn, err := f.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), 0, 0, f)
+	c.reconnectFunc = f.dial
+	c.batchChunkSize = cfg.batchChunkSize
+	if f.interval > 0 {
+		go f.healthLoop()
+	}
+	return c, nil
+}
+
+type failoverEndpoint struct {
+	url     string
+	connect reconnectFunc
+	healthy bool
+}
+
+// failover tracks the endpoints of a client created by DialFailover.
+type failover struct {
+	endpoints []*failoverEndpoint
+	interval  time.Duration
+	check     func(context.Context, *Client) error
+	onResub   func(Resubscription)
+
+	mu      sync.Mutex
+	current int         // index of the endpoint in use
+	active  ServerCodec // connection to the current endpoint, nil for HTTP
+
+	closeOnce sync.Once
+	quit      chan struct{}
+}
+
+// order returns the indexes of the endpoints in the order they should be tried:
+// healthy endpoints by preference, followed by the unhealthy ones.
+func (f *failover) order() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	order := make([]int, 0, len(f.endpoints))
+	for _, healthy := range []bool{true, false} {
+		for i, e := range f.endpoints {
+			if e.healthy == healthy {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
+
+// setActive records a successful connection or request to an endpoint.
+func (f *failover) setActive(index int, conn ServerCodec) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if index != f.current {
+		log.Debug("RPC client switched endpoint", "from", f.endpoints[f.current].url, "to", f.endpoints[index].url)
+	}
+	f.current, f.active = index, conn
+	f.endpoints[index].healthy = true
+}
+
+func (f *failover) setHealthy(index int, healthy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.endpoints[index].healthy && !healthy {
+		log.Debug("RPC endpoint unhealthy", "url", f.endpoints[index].url)
+	}
+	f.endpoints[index].healthy = healthy
+}
+
+// connFailed marks the endpoint of a broken connection unhealthy. Connections which
+// have been replaced already are ignored.
+func (f *failover) connFailed(conn jsonWriter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active != nil && f.active == conn {
+		log.Debug("RPC endpoint connection lost", "url", f.endpoints[f.current].url)
+		f.endpoints[f.current].healthy = false
+		f.active = nil
+	}
+}
+
+func (f *failover) currentURL() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.endpoints[f.current].url
+}
+
+// dial connects to the first reachable endpoint. It is the reconnectFunc of the client.
+func (f *failover) dial(ctx context.Context) (ServerCodec, error) {
+	var err error
+	for _, index := range f.order() {
+		var conn ServerCodec
+		if conn, err = f.endpoints[index].connect(ctx); err == nil {
+			f.setActive(index, conn)
+			return conn, nil
+		}
+		f.setHealthy(index, false)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, err
+}
+
+// doRequest posts msg to the first endpoint able to serve it.
+func (f *failover) doRequest(ctx context.Context, hc *httpConn, msg interface{}) (io.ReadCloser, error) {
+	var err error
+	for _, index := range f.order() {
+		var body io.ReadCloser
+		if body, err = hc.doRequest(ctx, f.endpoints[index].url, msg); err == nil {
+			f.setActive(index, nil)
+			return body, nil
+		}
+		if ctx.Err() != nil || !isEndpointError(err) {
+			return nil, err
+		}
+		f.setHealthy(index, false)
+	}
+	return nil, err
+}
+
+// isEndpointError reports whether an HTTP request failed because of the endpoint rather
+// than the request itself, in which case it is worth sending to another endpoint.
+func isEndpointError(err error) bool {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// healthLoop periodically checks all endpoints until the client is closed.
+func (f *failover) healthLoop() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for index, e := range f.endpoints {
+				err := f.probe(e)
+				if err != nil {
+					log.Debug("RPC endpoint health check failed", "url", e.url, "err", err)
+				}
+				f.setHealthy(index, err == nil)
+			}
+			f.leaveUnhealthy()
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// probe runs the health check against an endpoint.
+func (f *failover) probe(e *failoverEndpoint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	c, err := newClient(ctx, e.connect)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if f.check != nil {
+		return f.check(ctx, c)
+	}
+	var version string
+	return c.CallContext(ctx, &version, "web3_clientVersion")
+}
+
+// leaveUnhealthy closes the connection to the current endpoint if it failed its
+// health check while another endpoint is healthy. The client then reconnects to
+// the healthy endpoint, moving its subscriptions along.
+func (f *failover) leaveUnhealthy() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.active == nil || f.endpoints[f.current].healthy {
+		return
+	}
+	for _, e := range f.endpoints {
+		if e.healthy {
+			f.active.close()
+			f.active = nil
+			return
+		}
+	}
+}
+
+func (f *failover) close() {
+	f.closeOnce.Do(func() { close(f.quit) })
+}
+
+// detachSubscriptions removes the subscriptions from a lost connection of a failover
+// client, so they can be re-established instead of being closed.
+func (c *Client) detachSubscriptions(conn *clientConn) []*ClientSubscription {
+	if c.failover == nil {
+		return nil
+	}
+	c.failover.connFailed(conn.codec)
+	return conn.handler.detachClientSubs()
+}
+
+// resubscribe re-establishes the given subscriptions in the background.
+func (c *Client) resubscribe(subs []*ClientSubscription) {
+	for _, sub := range subs {
+		go c.resubscribeLoop(sub)
+	}
+}
+
+// resubscribeLoop repeats the subscribe call of sub until it succeeds, the subscription
+// is closed or the server rejects the call.
+func (c *Client) resubscribeLoop(sub *ClientSubscription) {
+	for {
+		select {
+		case <-sub.forwardDone:
+			return // unsubscribed in the meantime
+		default:
+		}
+		err := c.subscribeAgain(sub)
+		if err == nil {
+			break
+		}
+		var rpcErr Error
+		switch {
+		case errors.Is(err, ErrClientQuit):
+			sub.close(ErrClientQuit)
+			return
+		case errors.As(err, &rpcErr):
+			sub.close(err)
+			return
+		}
+		log.Debug("RPC client resubscribe failed", "namespace", sub.namespace, "err", err)
+
+		select {
+		case <-time.After(resubscribeRetryDelay):
+		case <-sub.forwardDone:
+			return
+		case <-c.closing:
+			sub.close(ErrClientQuit)
+			return
+		}
+	}
+	select {
+	case <-sub.forwardDone:
+		// The subscription was closed while being re-established, so the new one
+		// has to be cancelled on the server.
+		sub.requestUnsubscribe()
+		return
+	default:
+	}
+	log.Debug("RPC client resubscribed", "namespace", sub.namespace, "id", sub.id())
+	if c.failover.onResub != nil {
+		c.failover.onResub(Resubscription{
+			Sub:       sub,
+			Namespace: sub.namespace,
+			Args:      sub.args,
+			Endpoint:  c.failover.currentURL(),
+		})
+	}
+}
+
+// subscribeAgain sends the subscribe call of a running subscription, which is registered
+// with the new subscription ID when the call succeeds.
+func (c *Client) subscribeAgain(sub *ClientSubscription) error {
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+
+	msg, err := c.newMessage(sub.namespace+subscribeMethodSuffix, sub.args...)
+	if err != nil {
+		return err
+	}
+	op := &requestOp{
+		ids:         []json.RawMessage{msg.ID},
+		resp:        make(chan *jsonrpcMessage),
+		sub:         sub,
+		resubscribe: true,
+	}
+	if err := c.send(ctx, op, msg); err != nil {
+		return err
+	}
+	_, err = op.wait(ctx, c)
+	return err
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailoverHTTP(t *testing.T) {
+	var (
+		down atomic.Bool
+		hits atomic.Int32
+		srv  = newTestServer()
+	)
+	defer srv.Stop()
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		hits.Add(1)
+		srv.ServeHTTP(w, r)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(srv)
+	defer backup.Close()
+	dead := httptest.NewServer(srv)
+	dead.Close()
+
+	client, err := DialFailover(context.Background(), []string{dead.URL, primary.URL, backup.URL}, WithHealthCheck(10*time.Millisecond, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// Calls skip the unreachable endpoint
+	var resp echoResult
+	if err := client.Call(&resp, "test_echo", "hello", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("primary endpoint not used, %d hits", hits.Load())
+	}
+	// Failing endpoints are avoided, errors of the call itself are not retried
+	down.Store(true)
+	if err := client.Call(&resp, "test_echo", "hello", 2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(nil, "test_returnError"); err == nil {
+		t.Fatal("expected error")
+	}
+	// The primary endpoint is used again after passing the health check
+	down.Store(false)
+	hits.Store(0)
+	for start := time.Now(); hits.Load() == 0; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("primary endpoint not used after recovery")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if err := client.Call(&resp, "test_echo", "hello", 3, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFailoverResubscribe(t *testing.T) {
+	startServer := func() (*Server, net.Listener) {
+		srv := newTestServer()
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal("can't listen:", err)
+		}
+		go http.Serve(l, srv.WebsocketHandler([]string{"*"}))
+		return srv, l
+	}
+	s1, l1 := startServer()
+	defer l1.Close()
+	s2, l2 := startServer()
+	defer l2.Close()
+	defer s2.Stop()
+
+	var (
+		url1, url2 = "ws://" + l1.Addr().String(), "ws://" + l2.Addr().String()
+		resubs     = make(chan Resubscription, 1)
+	)
+	client, err := DialFailover(context.Background(), []string{url1, url2}, WithResubscribeHook(func(r Resubscription) {
+		resubs <- r
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ch := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", ch, "someSubscription", 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	receive := func(want int) {
+		t.Helper()
+		select {
+		case v := <-ch:
+			if v != want {
+				t.Fatalf("wrong notification: have %d, want %d", v, want)
+			}
+		case err := <-sub.Err():
+			t.Fatal("subscription failed:", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for notification")
+		}
+	}
+	receive(10)
+	receive(11)
+
+	// Stopping the first server moves the subscription to the second one
+	l1.Close()
+	s1.Stop()
+
+	select {
+	case r := <-resubs:
+		if r.Sub != sub || r.Namespace != "nftest" || r.Endpoint != url2 || len(r.Args) != 3 {
+			t.Fatalf("wrong resubscription: %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription not re-established")
+	}
+	receive(10)
+	receive(11)
+
+	var resp echoResult
+	if err := client.Call(&resp, "test_echo", "hello", 1, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailoverConfig(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{"http://127.0.0.1:8545", "ws://127.0.0.1:8546"},
+		{"stdio://"},
+	}
+	for i, endpoints := range tests {
+		if _, err := DialFailover(context.Background(), endpoints); err == nil {
+			t.Errorf("test %d: invalid endpoints accepted", i)
+		}
+	}
+}
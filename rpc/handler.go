@@ -61,6 +61,9 @@ type handler struct {
 	log            log.Logger
 	allowSubscribe bool
 
+	batchRequestLimit    int // maximum number of calls in a batch, zero if unlimited
+	batchResponseMaxSize int // maximum size of the results of a batch, zero if unlimited
+
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
 }
@@ -70,19 +73,21 @@ type callProc struct {
 	notifiers []*Notifier
 }
 
-func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry) *handler {
+func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *serviceRegistry, batchRequestLimit, batchResponseMaxSize int) *handler {
 	rootCtx, cancelRoot := context.WithCancel(connCtx)
 	h := &handler{
-		reg:            reg,
-		idgen:          idgen,
-		conn:           conn,
-		respWait:       make(map[string]*requestOp),
-		clientSubs:     make(map[string]*ClientSubscription),
-		rootCtx:        rootCtx,
-		cancelRoot:     cancelRoot,
-		allowSubscribe: true,
-		serverSubs:     make(map[ID]*Subscription),
-		log:            log.Root(),
+		reg:                  reg,
+		idgen:                idgen,
+		conn:                 conn,
+		respWait:             make(map[string]*requestOp),
+		clientSubs:           make(map[string]*ClientSubscription),
+		rootCtx:              rootCtx,
+		cancelRoot:           cancelRoot,
+		allowSubscribe:       true,
+		serverSubs:           make(map[ID]*Subscription),
+		log:                  log.Root(),
+		batchRequestLimit:    batchRequestLimit,
+		batchResponseMaxSize: batchResponseMaxSize,
 	}
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
@@ -149,6 +154,20 @@ func (b *batchCallBuffer) timeout(ctx context.Context, conn jsonWriter) {
 	b.doWrite(ctx, conn, true)
 }
 
+// respondWithError sends the responses added so far. For the remaining unanswered call
+// messages, it responds with the given error.
+func (b *batchCallBuffer) respondWithError(ctx context.Context, conn jsonWriter, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, msg := range b.calls {
+		if !msg.isNotification() {
+			b.resp = append(b.resp, msg.errorResponse(err))
+		}
+	}
+	b.doWrite(ctx, conn, true)
+}
+
 // doWrite actually writes the response.
 // This assumes b.mutex is held.
 func (b *batchCallBuffer) doWrite(ctx context.Context, conn jsonWriter, isErrorResponse bool) {
@@ -172,13 +191,36 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		return
 	}
 
+	// Reject batches with too many items with a single error, whatever their size.
+	if h.batchRequestLimit != 0 && len(msgs) > h.batchRequestLimit {
+		h.startCallProc(func(cp *callProc) {
+			h.respondWithBatchTooLarge(cp, msgs)
+		})
+		return
+	}
+
 	// Handle non-call messages first:
-	calls := make([]*jsonrpcMessage, 0, len(msgs))
+	var (
+		calls    = make([]*jsonrpcMessage, 0, len(msgs))
+		answered = make(map[*requestOp]struct{})
+	)
 	for _, msg := range msgs {
+		if msg.isResponse() {
+			if op := h.respWait[string(msg.ID)]; op != nil && op.sub == nil {
+				answered[op] = struct{}{}
+			}
+		}
 		if handled := h.handleImmediate(msg); !handled {
 			calls = append(calls, msg)
 		}
 	}
+	// A response batch answers all the calls of the request batch, stop waiting
+	// for the ones the server didn't respond to.
+	for op := range answered {
+		if h.removeRequestOp(op) {
+			close(op.resp)
+		}
+	}
 	if len(calls) == 0 {
 		return
 	}
@@ -203,6 +245,7 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 			})
 		}
 
+		responseBytes := 0
 		for {
 			// No need to handle rest of calls if timed out.
 			if cp.ctx.Err() != nil {
@@ -213,12 +256,26 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 				break
 			}
 			resp := h.handleCallMsg(cp, msg)
+
+			// Stop processing once the responses don't fit in the response size
+			// limit, answering the current and the remaining calls with errors.
+			if resp != nil && h.batchResponseMaxSize != 0 {
+				size, _ := json.Marshal(resp)
+				responseBytes += len(size)
+				if responseBytes > h.batchResponseMaxSize {
+					break
+				}
+			}
 			callBuffer.pushResponse(resp)
 		}
 		if timer != nil {
 			timer.Stop()
 		}
-		callBuffer.write(cp.ctx, h.conn)
+		if h.batchResponseMaxSize != 0 && responseBytes > h.batchResponseMaxSize {
+			callBuffer.respondWithError(cp.ctx, h.conn, &internalServerError{errcodeResponseTooLarge, errMsgResponseTooLarge})
+		} else {
+			callBuffer.write(cp.ctx, h.conn)
+		}
 		h.addSubscriptions(cp.notifiers)
 		for _, n := range cp.notifiers {
 			n.activate()
@@ -226,6 +283,19 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	})
 }
 
+// respondWithBatchTooLarge answers a batch exceeding the item limit with a single
+// error, carrying the ID of its first call.
+func (h *handler) respondWithBatchTooLarge(cp *callProc, batch []*jsonrpcMessage) {
+	resp := errorMessage(&invalidRequestError{errMsgBatchTooLarge})
+	for _, msg := range batch {
+		if msg.isCall() {
+			resp.ID = msg.ID
+			break
+		}
+	}
+	h.conn.writeJSON(cp.ctx, []*jsonrpcMessage{resp}, true)
+}
+
 // handleMsg handles a single message.
 func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	if ok := h.handleImmediate(msg); ok {
@@ -285,11 +355,17 @@ func (h *handler) addRequestOp(op *requestOp) {
 	}
 }
 
-// removeRequestOps stops waiting for the given request IDs.
-func (h *handler) removeRequestOp(op *requestOp) {
+// removeRequestOps stops waiting for the given request IDs, reporting whether
+// any of them was still awaited.
+func (h *handler) removeRequestOp(op *requestOp) bool {
+	var removed bool
 	for _, id := range op.ids {
-		delete(h.respWait, string(id))
+		if h.respWait[string(id)] == op {
+			delete(h.respWait, string(id))
+			removed = true
+		}
 	}
+	return removed
 }
 
 // cancelAllRequests unblocks and removes pending requests and active subscriptions.
@@ -315,6 +391,17 @@ func (h *handler) cancelAllRequests(err error, inflightReq *requestOp) {
 	}
 }
 
+// detachClientSubs removes all client subscriptions from the handler without closing
+// them, so they can be re-established on another connection.
+func (h *handler) detachClientSubs() []*ClientSubscription {
+	subs := make([]*ClientSubscription, 0, len(h.clientSubs))
+	for id, sub := range h.clientSubs {
+		delete(h.clientSubs, id)
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
 func (h *handler) addSubscriptions(nn []*Notifier) {
 	h.subLock.Lock()
 	defer h.subLock.Unlock()
@@ -402,9 +489,13 @@ func (h *handler) handleResponse(msg *jsonrpcMessage) {
 		op.err = msg.Error
 		return
 	}
-	if op.err = json.Unmarshal(msg.Result, &op.sub.subid); op.err == nil {
-		go op.sub.run()
-		h.clientSubs[op.sub.subid] = op.sub
+	var subid string
+	if op.err = json.Unmarshal(msg.Result, &subid); op.err == nil {
+		op.sub.setID(subid)
+		if !op.resubscribe {
+			go op.sub.run()
+		}
+		h.clientSubs[subid] = op.sub
 	}
 }
 
@@ -439,6 +530,14 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 
 // handleCall processes method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if !msg.isUnsubscribe() {
+		if !h.allowed(msg) {
+			return msg.errorResponse(&methodNotAllowedError{method: msg.Method})
+		}
+		if err := h.reg.limit(cp.ctx, msg.Method); err != nil {
+			return msg.errorResponse(err)
+		}
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -451,7 +550,7 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if callb == nil {
 		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
 	}
-	args, err := parsePositionalArguments(msg.Params, callb.argTypes)
+	args, err := parsePositionalArguments(msg.Params, callb.argTypes, callb.variadic)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
@@ -472,6 +571,17 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	return answer
 }
 
+// allowed reports whether a call passes the method filter, subscriptions being
+// filtered by their name too.
+func (h *handler) allowed(msg *jsonrpcMessage) bool {
+	if msg.isSubscribe() {
+		if name, err := parseSubscriptionName(msg.Params); err == nil {
+			return h.reg.allowedSubscription(msg.Method, name)
+		}
+	}
+	return h.reg.allowed(msg.Method)
+}
+
 // handleSubscribe processes *_subscribe method calls.
 func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
 	if !h.allowSubscribe {
@@ -494,7 +604,7 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 
 	// Parse subscription name arg too, but remove it before calling the callback.
 	argTypes := append([]reflect.Type{stringType}, callb.argTypes...)
-	args, err := parsePositionalArguments(msg.Params, argTypes)
+	args, err := parsePositionalArguments(msg.Params, argTypes, callb.variadic)
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
@@ -30,11 +30,12 @@ import (
 )
 
 const (
-	vsn                      = "2.0"
-	serviceMethodSeparator   = "_"
-	subscribeMethodSuffix    = "_subscribe"
-	unsubscribeMethodSuffix  = "_unsubscribe"
-	notificationMethodSuffix = "_subscription"
+	vsn                       = "2.0"
+	serviceMethodSeparator    = "_"
+	subscribeMethodSuffix     = "_subscribe"
+	unsubscribeMethodSuffix   = "_unsubscribe"
+	subscriptionNameSeparator = ":"
+	notificationMethodSuffix  = "_subscription"
 
 	defaultWriteTimeout = 10 * time.Second // used if context has no deadline
 )
@@ -294,8 +295,9 @@ func isBatch(raw json.RawMessage) bool {
 
 // parsePositionalArguments tries to parse the given args to an array of values with the
 // given types. It returns the parsed values or an error when the args could not be
-// parsed. Missing optional arguments are returned as reflect.Zero values.
-func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]reflect.Value, error) {
+// parsed. Missing optional arguments are returned as reflect.Zero values. For variadic
+// methods, the trailing arguments are gathered in a slice for the last parameter.
+func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type, variadic bool) ([]reflect.Value, error) {
 	dec := json.NewDecoder(bytes.NewReader(rawArgs))
 	var args []reflect.Value
 	tok, err := dec.Token()
@@ -307,7 +309,7 @@ func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]
 		return nil, err
 	case tok == json.Delim('['):
 		// Read argument array.
-		if args, err = parseArgumentArray(dec, types); err != nil {
+		if args, err = parseArgumentArray(dec, types, variadic); err != nil {
 			return nil, err
 		}
 	default:
@@ -315,6 +317,10 @@ func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]
 	}
 	// Set any missing args to nil.
 	for i := len(args); i < len(types); i++ {
+		if variadic && i == len(types)-1 {
+			args = append(args, reflect.MakeSlice(types[i], 0, 0))
+			continue
+		}
 		if types[i].Kind() != reflect.Ptr {
 			return nil, fmt.Errorf("missing value for required argument %d", i)
 		}
@@ -323,9 +329,21 @@ func parsePositionalArguments(rawArgs json.RawMessage, types []reflect.Type) ([]
 	return args, nil
 }
 
-func parseArgumentArray(dec *json.Decoder, types []reflect.Type) ([]reflect.Value, error) {
+func parseArgumentArray(dec *json.Decoder, types []reflect.Type, variadic bool) ([]reflect.Value, error) {
 	args := make([]reflect.Value, 0, len(types))
 	for i := 0; dec.More(); i++ {
+		// The trailing arguments of variadic methods are gathered in a slice.
+		if variadic && i >= len(types)-1 {
+			if i == len(types)-1 {
+				args = append(args, reflect.MakeSlice(types[i], 0, 0))
+			}
+			argval := reflect.New(types[len(types)-1].Elem())
+			if err := dec.Decode(argval.Interface()); err != nil {
+				return args, fmt.Errorf("invalid argument %d: %v", i, err)
+			}
+			args[len(args)-1] = reflect.Append(args[len(args)-1], argval.Elem())
+			continue
+		}
 		if i >= len(types) {
 			return args, fmt.Errorf("too many arguments, want at most %d", len(types))
 		}
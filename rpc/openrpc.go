@@ -0,0 +1,400 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// discoverMethod is the name of the OpenRPC service discovery method.
+	discoverMethod = "rpc.discover"
+
+	// openrpcVersion is the version of the OpenRPC specification followed.
+	openrpcVersion = "1.2.6"
+)
+
+// OpenRPCDocument describes the methods served by a server, following the OpenRPC
+// specification. It is returned by the rpc.discover method.
+//
+// Go doesn't retain the names of the parameters, so they are named after their
+// position. Subscriptions are described on the <namespace>_subscribe method of
+// their service, in the "x-subscriptions" extension.
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []OpenRPCMethod   `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+// OpenRPCInfo is the metadata of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod describes a method.
+type OpenRPCMethod struct {
+	Name          string                     `json:"name"`
+	Params        []OpenRPCContentDescriptor `json:"params"`
+	Result        *OpenRPCContentDescriptor  `json:"result,omitempty"`
+	Subscriptions []OpenRPCSubscription      `json:"x-subscriptions,omitempty"`
+}
+
+// OpenRPCSubscription describes a subscription, created by calling the subscribe
+// method of its service with its name followed by its parameters.
+type OpenRPCSubscription struct {
+	Name   string                     `json:"name"`
+	Params []OpenRPCContentDescriptor `json:"params"`
+}
+
+// OpenRPCContentDescriptor describes a parameter or a result. Variadic parameters
+// are always last, taking any number of trailing arguments matching the schema.
+type OpenRPCContentDescriptor struct {
+	Name     string         `json:"name"`
+	Required bool           `json:"required,omitempty"`
+	Variadic bool           `json:"x-variadic,omitempty"`
+	Schema   *OpenRPCSchema `json:"schema"`
+}
+
+// OpenRPCComponents holds the schemas of the named struct types, referenced from
+// the other schemas.
+type OpenRPCComponents struct {
+	Schemas map[string]*OpenRPCSchema `json:"schemas"`
+}
+
+// OpenRPCSchema is the JSON schema of a value. An empty schema allows any value.
+type OpenRPCSchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Title                string                    `json:"title,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	ContentEncoding      string                    `json:"contentEncoding,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Items                *OpenRPCSchema            `json:"items,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+	Properties           map[string]*OpenRPCSchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *OpenRPCSchema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	bigIntType          = reflect.TypeOf(big.Int{})
+
+	// hexPatterns are the formats of the hex encoded types used throughout the APIs.
+	hexPatterns = map[reflect.Type]string{
+		reflect.TypeOf(common.Hash{}):     "^0x[0-9a-fA-F]{64}$",
+		reflect.TypeOf(common.Address{}):  "^0x[0-9a-fA-F]{40}$",
+		reflect.TypeOf(hexutil.Bytes{}):   "^0x([0-9a-fA-F]{2})*$",
+		reflect.TypeOf(hexutil.Big{}):     "^0x(0|[1-9a-fA-F][0-9a-fA-F]*)$",
+		reflect.TypeOf(hexutil.Uint64(0)): "^0x(0|[1-9a-fA-F][0-9a-fA-F]*)$",
+		reflect.TypeOf(hexutil.Uint(0)):   "^0x(0|[1-9a-fA-F][0-9a-fA-F]*)$",
+	}
+)
+
+// Discover returns the OpenRPC document of the methods served. It is available as
+// rpc.discover.
+//
+// The document is generated once and cached until a service is registered or the
+// method filter changes, it must not be modified.
+func (s *RPCService) Discover() *OpenRPCDocument {
+	r := &s.server.services
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.openrpc == nil {
+		r.openrpc = r.openrpcLocked()
+	}
+	return r.openrpc
+}
+
+// openrpcLocked generates the OpenRPC document of the methods passing the method
+// filter. The caller must hold r.mu.
+func (r *serviceRegistry) openrpcLocked() *OpenRPCDocument {
+	var (
+		gen = newSchemaGenerator()
+		doc = &OpenRPCDocument{
+			OpenRPC: openrpcVersion,
+			Info:    OpenRPCInfo{Title: "JSON-RPC API", Version: "1.0"},
+			Methods: []OpenRPCMethod{},
+		}
+	)
+	for _, svc := range r.services {
+		for name, cb := range svc.callbacks {
+			method := svc.name + serviceMethodSeparator + name
+			if method == MetadataApi+serviceMethodSeparator+"discover" {
+				method = discoverMethod
+			}
+			if !r.allowedLocked(method) {
+				continue
+			}
+			doc.Methods = append(doc.Methods, OpenRPCMethod{
+				Name:   method,
+				Params: gen.params(cb),
+				Result: gen.result(cb),
+			})
+		}
+		var subscriptions []string
+		for name := range svc.subscriptions {
+			if r.allowedSubscriptionLocked(svc.name+subscribeMethodSuffix, name) {
+				subscriptions = append(subscriptions, name)
+			}
+		}
+		if len(subscriptions) == 0 {
+			continue
+		}
+		sort.Strings(subscriptions)
+
+		subscribe := OpenRPCMethod{
+			Name: svc.name + subscribeMethodSuffix,
+			Params: []OpenRPCContentDescriptor{{
+				Name:     "subscription",
+				Required: true,
+				Schema:   &OpenRPCSchema{Type: "string"},
+			}},
+			Result: &OpenRPCContentDescriptor{Name: "subscriptionID", Schema: &OpenRPCSchema{Type: "string"}},
+		}
+		for _, name := range subscriptions {
+			subscribe.Params[0].Schema.Enum = append(subscribe.Params[0].Schema.Enum, name)
+			subscribe.Subscriptions = append(subscribe.Subscriptions, OpenRPCSubscription{
+				Name:   name,
+				Params: gen.params(svc.subscriptions[name]),
+			})
+		}
+		unsubscribe := OpenRPCMethod{
+			Name: svc.name + unsubscribeMethodSuffix,
+			Params: []OpenRPCContentDescriptor{{
+				Name:     "subscriptionID",
+				Required: true,
+				Schema:   &OpenRPCSchema{Type: "string"},
+			}},
+			Result: &OpenRPCContentDescriptor{Name: "result", Schema: &OpenRPCSchema{Type: "boolean"}},
+		}
+		doc.Methods = append(doc.Methods, subscribe, unsubscribe)
+	}
+	sort.Slice(doc.Methods, func(i, j int) bool {
+		return doc.Methods[i].Name < doc.Methods[j].Name
+	})
+	doc.Components.Schemas = gen.defs
+	return doc
+}
+
+// schemaGenerator derives JSON schemas from Go types, the named struct types being
+// defined once as components.
+type schemaGenerator struct {
+	defs  map[string]*OpenRPCSchema // Component schemas by name
+	names map[reflect.Type]string   // Component names of the struct types
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		defs:  make(map[string]*OpenRPCSchema),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// params describes the parameters of a callback. The trailing pointer parameters
+// are optional, like the variadic parameter.
+func (g *schemaGenerator) params(cb *callback) []OpenRPCContentDescriptor {
+	params := make([]OpenRPCContentDescriptor, len(cb.argTypes))
+	required := len(cb.argTypes)
+	for required > 0 && cb.argTypes[required-1].Kind() == reflect.Ptr {
+		required--
+	}
+	if cb.variadic {
+		required = len(cb.argTypes) - 1
+	}
+	for i, typ := range cb.argTypes {
+		params[i] = OpenRPCContentDescriptor{
+			Name:     fmt.Sprintf("arg%d", i),
+			Required: i < required,
+		}
+		if cb.variadic && i == len(cb.argTypes)-1 {
+			params[i].Variadic = true
+			typ = typ.Elem()
+		}
+		params[i].Schema = g.schema(typ)
+	}
+	return params
+}
+
+// result describes the result of a callback, null for the callbacks returning
+// nothing but an error.
+func (g *schemaGenerator) result(cb *callback) *OpenRPCContentDescriptor {
+	fntype := cb.fn.Type()
+	if fntype.NumOut() == 0 || (fntype.NumOut() == 1 && cb.errPos == 0) {
+		return &OpenRPCContentDescriptor{Name: "result", Schema: &OpenRPCSchema{Type: "null"}}
+	}
+	return &OpenRPCContentDescriptor{Name: "result", Schema: g.schema(fntype.Out(0))}
+}
+
+// schema returns the JSON schema of the encoding of a Go type.
+func (g *schemaGenerator) schema(typ reflect.Type) *OpenRPCSchema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	// Types with custom encodings are only described when well known, their
+	// Go fields telling nothing about their JSON format
+	if pattern, ok := hexPatterns[typ]; ok {
+		return &OpenRPCSchema{Title: typ.String(), Type: "string", Pattern: pattern}
+	}
+	if typ == bigIntType {
+		return &OpenRPCSchema{Type: "integer"}
+	}
+	if implements(typ, jsonMarshalerType) || implements(typ, jsonUnmarshalerType) {
+		return &OpenRPCSchema{Title: typ.String()}
+	}
+	if implements(typ, textMarshalerType) || implements(typ, textUnmarshalerType) {
+		return &OpenRPCSchema{Title: typ.String(), Type: "string"}
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &OpenRPCSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &OpenRPCSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &OpenRPCSchema{Type: "number"}
+	case reflect.String:
+		return &OpenRPCSchema{Type: "string"}
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &OpenRPCSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &OpenRPCSchema{Type: "array", Items: g.schema(typ.Elem())}
+	case reflect.Array:
+		n := typ.Len()
+		return &OpenRPCSchema{Type: "array", Items: g.schema(typ.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &OpenRPCSchema{Type: "object", AdditionalProperties: g.schema(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return g.object(typ)
+		}
+		return &OpenRPCSchema{Ref: "#/components/schemas/" + g.define(typ)}
+	default:
+		return &OpenRPCSchema{} // interfaces, and types which can't be encoded
+	}
+}
+
+// implements reports whether the type or a pointer to it implements the interface.
+func implements(typ, iface reflect.Type) bool {
+	return typ.Implements(iface) || reflect.PtrTo(typ).Implements(iface)
+}
+
+// define adds the schema of a named struct type to the components, returning
+// its name.
+func (g *schemaGenerator) define(typ reflect.Type) string {
+	if name, ok := g.names[typ]; ok {
+		return name
+	}
+	name := typ.String()
+	for i := 2; g.defs[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", typ.String(), i)
+	}
+	// Reserve the name before generating the schema, the type may be recursive
+	g.names[typ] = name
+	g.defs[name] = &OpenRPCSchema{}
+
+	schema := g.object(typ)
+	schema.Title = typ.String()
+	g.defs[name] = schema
+	return name
+}
+
+// object returns the schema of a struct type, following the field rules of
+// encoding/json.
+func (g *schemaGenerator) object(typ reflect.Type) *OpenRPCSchema {
+	schema := &OpenRPCSchema{Type: "object", Properties: make(map[string]*OpenRPCSchema)}
+	g.addFields(schema, typ, make(map[reflect.Type]bool))
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// addFields adds the properties of the fields of a struct type to a schema, the
+// ones of embedded structs without a JSON name included.
+func (g *schemaGenerator) addFields(schema *OpenRPCSchema, typ reflect.Type, visited map[reflect.Type]bool) {
+	if visited[typ] {
+		return
+	}
+	visited[typ] = true
+
+	var embedded []reflect.Type
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ftype := field.Type
+			if ftype.Kind() == reflect.Ptr {
+				ftype = ftype.Elem()
+			}
+			if ftype.Kind() == reflect.Struct {
+				embedded = append(embedded, ftype)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := g.schema(field.Type)
+		for _, opt := range strings.Split(opts, ",") {
+			if opt == "string" {
+				prop = &OpenRPCSchema{Type: "string"}
+			}
+		}
+		schema.Properties[name] = prop
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	// Fields of embedded structs are shadowed by the direct ones
+	for _, ftype := range embedded {
+		inner := &OpenRPCSchema{Properties: make(map[string]*OpenRPCSchema)}
+		g.addFields(inner, ftype, visited)
+		for name, prop := range inner.Properties {
+			if _, ok := schema.Properties[name]; !ok {
+				schema.Properties[name] = prop
+			}
+		}
+		for _, name := range inner.Required {
+			if schema.Properties[name] == inner.Properties[name] {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+}
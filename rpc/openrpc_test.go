@@ -0,0 +1,192 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+type openrpcTestService struct{}
+
+type openrpcTestResult struct {
+	Hash  common.Hash        `json:"hash"`
+	Count hexutil.Uint64     `json:"count,omitempty"`
+	Next  *openrpcTestResult `json:"next"`
+	Tags  []string           `json:"tags"`
+}
+
+func (s *openrpcTestService) Lookup(hash common.Hash, full *bool) (*openrpcTestResult, error) {
+	return nil, nil
+}
+
+func (s *openrpcTestService) Sum(base int, values ...int) int {
+	for _, v := range values {
+		base += v
+	}
+	return base
+}
+
+func (s *openrpcTestService) Events(ctx context.Context, from *hexutil.Uint64) (*Subscription, error) {
+	return nil, nil
+}
+
+func TestOpenRPCDiscover(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("ortest", new(openrpcTestService)); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	var doc OpenRPCDocument
+	if err := client.Call(&doc, "rpc.discover"); err != nil {
+		t.Fatal(err)
+	}
+	methods := make(map[string]OpenRPCMethod)
+	var names []string
+	for _, method := range doc.Methods {
+		methods[method.Name] = method
+		names = append(names, method.Name)
+	}
+	want := []string{"ortest_lookup", "ortest_subscribe", "ortest_sum", "ortest_unsubscribe", "rpc.discover", "rpc_modules"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("wrong methods: have %v, want %v", names, want)
+	}
+	// Trailing pointer parameters are optional
+	lookup := methods["ortest_lookup"]
+	if len(lookup.Params) != 2 || !lookup.Params[0].Required || lookup.Params[1].Required {
+		t.Fatalf("wrong lookup params: %+v", lookup.Params)
+	}
+	if lookup.Params[0].Schema.Pattern == "" || lookup.Params[1].Schema.Type != "boolean" {
+		t.Errorf("wrong lookup param schemas: %+v %+v", lookup.Params[0].Schema, lookup.Params[1].Schema)
+	}
+	// Named structs are components, which may be recursive
+	const ref = "#/components/schemas/rpc.openrpcTestResult"
+	if lookup.Result.Schema.Ref != ref {
+		t.Fatalf("wrong lookup result: %+v", lookup.Result.Schema)
+	}
+	result := doc.Components.Schemas["rpc.openrpcTestResult"]
+	if result == nil || result.Properties["next"].Ref != ref || result.Properties["tags"].Items.Type != "string" {
+		t.Fatalf("wrong result schema: %+v", result)
+	}
+	if !reflect.DeepEqual(result.Required, []string{"hash", "next", "tags"}) {
+		t.Errorf("wrong required properties: %v", result.Required)
+	}
+	// Variadic parameters take the trailing arguments
+	sum := methods["ortest_sum"]
+	if len(sum.Params) != 2 || !sum.Params[0].Required || sum.Params[1].Required || !sum.Params[1].Variadic {
+		t.Fatalf("wrong sum params: %+v", sum.Params)
+	}
+	if sum.Params[1].Schema.Type != "integer" || sum.Result.Schema.Type != "integer" {
+		t.Errorf("wrong sum schemas: %+v %+v", sum.Params[1].Schema, sum.Result.Schema)
+	}
+	// Subscriptions are described on the subscribe method
+	subscribe := methods["ortest_subscribe"]
+	if !reflect.DeepEqual(subscribe.Params[0].Schema.Enum, []string{"events"}) || len(subscribe.Subscriptions) != 1 {
+		t.Fatalf("wrong subscribe method: %+v", subscribe)
+	}
+	if params := subscribe.Subscriptions[0].Params; len(params) != 1 || params[0].Required {
+		t.Errorf("wrong subscription params: %+v", params)
+	}
+	// Only the methods passing the filter are described
+	if err := server.SetMethodFilter(nil, []string{"ortest_s*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call(&doc, "rpc.discover"); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range doc.Methods {
+		if method.Name == "ortest_sum" || method.Name == "ortest_subscribe" {
+			t.Errorf("filtered method %s described", method.Name)
+		}
+	}
+	// The discovery method itself can be filtered out
+	if err := server.SetMethodFilter([]string{"ortest_*"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := client.Call(&doc, "rpc.discover")
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != errcodeMethodNotAllowed {
+		t.Errorf("filtered rpc.discover: wrong error %v", err)
+	}
+}
+
+// Tests that the OpenRPC document is cached until the services or the method
+// filter change.
+func TestOpenRPCCache(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("ortest", new(openrpcTestService)); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		service = &RPCService{server}
+		doc     = service.Discover()
+	)
+	if service.Discover() != doc {
+		t.Fatal("document not cached")
+	}
+	if err := server.RegisterName("test", new(testService)); err != nil {
+		t.Fatal(err)
+	}
+	if service.Discover() == doc {
+		t.Fatal("document not regenerated after registering a service")
+	}
+	doc = service.Discover()
+	if err := server.SetMethodFilter(nil, []string{"test_*"}); err != nil {
+		t.Fatal(err)
+	}
+	if service.Discover() == doc {
+		t.Fatal("document not regenerated after changing the method filter")
+	}
+}
+
+func TestVariadicArguments(t *testing.T) {
+	server := NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("ortest", new(openrpcTestService)); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	tests := []struct {
+		args []interface{}
+		want int
+	}{
+		{[]interface{}{1}, 1},
+		{[]interface{}{1, 2}, 3},
+		{[]interface{}{1, 2, 3, 4}, 10},
+	}
+	for _, test := range tests {
+		var sum int
+		if err := client.Call(&sum, "ortest_sum", test.args...); err != nil {
+			t.Fatalf("%v: %v", test.args, err)
+		}
+		if sum != test.want {
+			t.Errorf("%v: wrong sum %d, want %d", test.args, sum, test.want)
+		}
+	}
+	if err := client.Call(nil, "ortest_sum", 1, "x"); err == nil {
+		t.Error("invalid variadic argument accepted")
+	}
+}
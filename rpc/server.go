@@ -18,7 +18,10 @@ package rpc
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"path"
 	"sync"
 	"sync/atomic"
 
@@ -46,9 +49,11 @@ type Server struct {
 	services serviceRegistry
 	idgen    func() ID
 
-	mutex  sync.Mutex
-	codecs map[ServerCodec]struct{}
-	run    int32
+	mutex              sync.Mutex
+	codecs             map[ServerCodec]struct{}
+	run                int32
+	batchItemLimit     int
+	batchResponseLimit int
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -65,6 +70,49 @@ func NewServer() *Server {
 	return server
 }
 
+// SetBatchLimits sets limits applied to batch requests. There are two limits: 'itemLimit'
+// is the maximum number of items in a batch. 'maxResponseSize' is the maximum number of
+// response bytes across all requests in a batch. Zero disables the respective limit.
+//
+// This method should be called before processing any requests via ServeCodec, ServeHTTP,
+// ServeListener etc.
+func (s *Server) SetBatchLimits(itemLimit, maxResponseSize int) {
+	s.batchItemLimit = itemLimit
+	s.batchResponseLimit = maxResponseSize
+}
+
+// SetMethodFilter restricts the methods served. When 'allow' is not empty only the
+// methods matching any of its patterns are served, and the methods matching any of
+// the 'deny' patterns are never served. Patterns use the syntax of path.Match, e.g.
+// "debug_trace*". Calls to filtered out methods fail with a distinct error code.
+//
+// Subscriptions are filtered by their subscribe method, e.g. "eth_subscribe", and
+// by their name appended to it, e.g. "eth_subscribe:logs".
+//
+// The methods of the rpc namespace are always available, rpc_modules only listing
+// the services with methods passing the filter. The rpc.discover method is filtered
+// like the others, only describing the methods passing the filter.
+func (s *Server) SetMethodFilter(allow, deny []string) error {
+	for _, pattern := range append(append([]string{}, allow...), deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid method pattern %q: %w", pattern, err)
+		}
+	}
+	s.services.setFilter(allow, deny)
+	return nil
+}
+
+// CallLimiter decides whether a call may be served, returning the error to respond
+// with otherwise. The PeerInfo of the client can be retrieved from the context.
+type CallLimiter func(ctx context.Context, method string) error
+
+// SetCallLimiter sets the limiter consulted before serving every call, subscriptions
+// included, e.g. for charging the calls of the clients against their quotas. Calls
+// rejected by the limiter fail with the error it returned.
+func (s *Server) SetCallLimiter(limiter CallLimiter) {
+	s.services.setLimiter(limiter)
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -86,7 +134,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	}
 	defer s.untrackCodec(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.batchItemLimit, s.batchResponseLimit, nil)
 	<-codec.closed()
 	c.Close()
 }
@@ -118,7 +166,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 		return
 	}
 
-	h := newHandler(ctx, codec, s.idgen, &s.services)
+	h := newHandler(ctx, codec, s.idgen, &s.services, s.batchItemLimit, s.batchResponseLimit)
 	h.allowSubscribe = false
 	defer h.close(io.EOF, nil)
 
@@ -164,8 +212,10 @@ func (s *RPCService) Modules() map[string]string {
 	defer s.server.services.mu.Unlock()
 
 	modules := make(map[string]string)
-	for name := range s.server.services.services {
-		modules[name] = "1.0"
+	for name, svc := range s.server.services.services {
+		if s.server.services.exposesLocked(svc) {
+			modules[name] = "1.0"
+		}
 	}
 	return modules
 }
@@ -191,6 +241,8 @@ type PeerInfo struct {
 		UserAgent string
 		Origin    string
 		Host      string
+		// All the headers of the request, or of the WebSocket handshake.
+		Header http.Header
 	}
 }
 
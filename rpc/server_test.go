@@ -19,6 +19,7 @@ package rpc
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
 	"net"
 	"os"
@@ -113,6 +114,57 @@ func runTestScript(t *testing.T, file string) {
 
 // This test checks that responses are delivered for very short-lived connections that
 // only carry a single request.
+func TestServerMethodFilter(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	if err := server.SetMethodFilter([]string{"test_*", "nftest_subscribe"}, []string{"test_sleep*", "test_subscribe", "nftest_subscribe:hangSubscription"}); err != nil {
+		t.Fatal(err)
+	}
+	client := DialInProc(server)
+	defer client.Close()
+
+	// Allowed methods and subscriptions are served
+	var result echoResult
+	if err := client.Call(&result, "test_echo", "x", 1, nil); err != nil {
+		t.Fatal("allowed method failed:", err)
+	}
+	ch := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", ch, "someSubscription", 1, 1)
+	if err != nil {
+		t.Fatal("allowed subscription failed:", err)
+	}
+	sub.Unsubscribe()
+
+	// Denied methods and the ones not allowed are rejected
+	for _, method := range []string{"test_sleep", "nftest_echo"} {
+		err := client.Call(nil, method, 1)
+		if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != errcodeMethodNotAllowed {
+			t.Errorf("%s: wrong error %v", method, err)
+		}
+	}
+	// Denied subscriptions are rejected, by name or by namespace
+	for _, args := range [][]interface{}{{"nftest", "hangSubscription", 1}, {"test", "subscription"}} {
+		_, err := client.Subscribe(context.Background(), args[0].(string), make(chan int), args[1:]...)
+		if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != errcodeMethodNotAllowed {
+			t.Errorf("%s subscription %s: wrong error %v", args[0], args[1], err)
+		}
+	}
+	// Only the services with allowed methods are reported
+	if err := server.SetMethodFilter([]string{"test_echo"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var modules map[string]string
+	if err := client.Call(&modules, "rpc_modules"); err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 2 || modules["rpc"] == "" || modules["test"] == "" {
+		t.Errorf("wrong modules: %v", modules)
+	}
+	if err := server.SetMethodFilter(nil, []string{"test_["}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
 func TestServerShortLivedConn(t *testing.T) {
 	server := newTestServer()
 	defer server.Stop()
@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"fmt"
+	"path"
 	"reflect"
 	"runtime"
 	"strings"
@@ -38,6 +39,11 @@ var (
 type serviceRegistry struct {
 	mu       sync.Mutex
 	services map[string]service
+	allow    []string    // patterns of the methods served, all if empty
+	deny     []string    // patterns of the methods never served
+	limiter  CallLimiter // decides whether calls may be served, nil if unlimited
+
+	openrpc *OpenRPCDocument // cached description of the methods served, nil if stale
 }
 
 // service represents a registered object.
@@ -53,6 +59,7 @@ type callback struct {
 	rcvr        reflect.Value  // receiver object of method, set if fn is method
 	argTypes    []reflect.Type // input argument types
 	hasCtx      bool           // method's first argument is a context (not included in argTypes)
+	variadic    bool           // method's last argument is variadic, taking the trailing arguments
 	errPos      int            // err return idx, of -1 when method cannot return error
 	isSubscribe bool           // true if this is a subscription callback
 }
@@ -69,6 +76,7 @@ func (r *serviceRegistry) registerName(name string, rcvr interface{}) error {
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.openrpc = nil
 	if r.services == nil {
 		r.services = make(map[string]service)
 	}
@@ -93,6 +101,9 @@ func (r *serviceRegistry) registerName(name string, rcvr interface{}) error {
 
 // callback returns the callback corresponding to the given RPC method name.
 func (r *serviceRegistry) callback(method string) *callback {
+	if method == discoverMethod {
+		method = MetadataApi + serviceMethodSeparator + "discover"
+	}
 	elem := strings.SplitN(method, serviceMethodSeparator, 2)
 	if len(elem) != 2 {
 		return nil
@@ -102,6 +113,105 @@ func (r *serviceRegistry) callback(method string) *callback {
 	return r.services[elem[0]].callbacks[elem[1]]
 }
 
+// setFilter sets the patterns of the methods allowed and denied.
+func (r *serviceRegistry) setFilter(allow, deny []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allow, r.deny = allow, deny
+	r.openrpc = nil
+}
+
+// setLimiter sets the limiter consulted before serving calls.
+func (r *serviceRegistry) setLimiter(limiter CallLimiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limiter = limiter
+}
+
+// limit consults the call limiter, returning the error to respond with if the
+// call must not be served.
+func (r *serviceRegistry) limit(ctx context.Context, method string) error {
+	r.mu.Lock()
+	limiter := r.limiter
+	r.mu.Unlock()
+
+	if limiter == nil {
+		return nil
+	}
+	return limiter(ctx, method)
+}
+
+// allowed reports whether the method passes the method filter. The methods of
+// the rpc namespace describe the filtered surface and are always allowed, except
+// for rpc.discover whose document may be expensive to generate.
+func (r *serviceRegistry) allowed(method string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.allowedLocked(method)
+}
+
+func (r *serviceRegistry) allowedLocked(method string) bool {
+	if method == discoverMethod || method == MetadataApi+serviceMethodSeparator+"discover" {
+		return r.filterLocked(discoverMethod)
+	}
+	if strings.HasPrefix(method, MetadataApi+serviceMethodSeparator) {
+		return true
+	}
+	return r.filterLocked(method)
+}
+
+// allowedSubscription reports whether a subscription passes the method filter,
+// the patterns matching either its subscribe method or the method followed by
+// its name, e.g. "eth_subscribe" or "eth_subscribe:logs".
+func (r *serviceRegistry) allowedSubscription(method, name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.allowedSubscriptionLocked(method, name)
+}
+
+func (r *serviceRegistry) allowedSubscriptionLocked(method, name string) bool {
+	return r.filterLocked(method, method+subscriptionNameSeparator+name)
+}
+
+// filterLocked reports whether the method filter lets a call through, none of
+// its names being denied and any of them being allowed. The caller must hold r.mu.
+func (r *serviceRegistry) filterLocked(names ...string) bool {
+	for _, name := range names {
+		for _, pattern := range r.deny {
+			if ok, _ := path.Match(pattern, name); ok {
+				return false
+			}
+		}
+	}
+	if len(r.allow) == 0 {
+		return true
+	}
+	for _, name := range names {
+		for _, pattern := range r.allow {
+			if ok, _ := path.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// exposesLocked reports whether any of the methods of the service passes the
+// method filter. The caller must hold r.mu.
+func (r *serviceRegistry) exposesLocked(svc service) bool {
+	for name := range svc.callbacks {
+		if r.allowedLocked(svc.name + serviceMethodSeparator + name) {
+			return true
+		}
+	}
+	for name := range svc.subscriptions {
+		if r.allowedSubscriptionLocked(svc.name+subscribeMethodSuffix, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // subscription returns a subscription callback in the given service.
 func (r *serviceRegistry) subscription(service, name string) *callback {
 	r.mu.Lock()
@@ -173,6 +283,7 @@ func (c *callback) makeArgTypes() {
 		firstArg++
 	}
 	// Add all remaining parameters.
+	c.variadic = fntype.IsVariadic()
 	c.argTypes = make([]reflect.Type, fntype.NumIn()-firstArg)
 	for i := firstArg; i < fntype.NumIn(); i++ {
 		c.argTypes[i-firstArg] = fntype.In(i)
@@ -202,7 +313,12 @@ func (c *callback) call(ctx context.Context, method string, args []reflect.Value
 		}
 	}()
 	// Run the callback.
-	results := c.fn.Call(fullargs)
+	var results []reflect.Value
+	if c.variadic {
+		results = c.fn.CallSlice(fullargs)
+	} else {
+		results = c.fn.Call(fullargs)
+	}
 	if len(results) == 0 {
 		return nil, nil
 	}
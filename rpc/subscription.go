@@ -209,7 +209,10 @@ type ClientSubscription struct {
 	etype     reflect.Type
 	channel   reflect.Value
 	namespace string
-	subid     string
+	args      []interface{} // arguments of the subscribe call, for resubscribing
+
+	mu    sync.Mutex // protects subid, which changes when resubscribing
+	subid string
 
 	// The in channel receives notification values from client dispatcher.
 	in chan json.RawMessage
@@ -371,7 +374,19 @@ func (sub *ClientSubscription) unmarshal(result json.RawMessage) (interface{}, e
 	return val.Elem().Interface(), err
 }
 
+func (sub *ClientSubscription) id() string {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.subid
+}
+
+func (sub *ClientSubscription) setID(id string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.subid = id
+}
+
 func (sub *ClientSubscription) requestUnsubscribe() error {
 	var result interface{}
-	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.subid)
+	return sub.client.Call(&result, sub.namespace+unsubscribeMethodSuffix, sub.id())
 }
@@ -18,10 +18,21 @@ package trie
 
 import (
 	"fmt"
+	"runtime"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// parallelCommitThreshold is the number of uncommitted changes above which a
+// trie commits its subtries concurrently.
+const parallelCommitThreshold = 100
+
+// commitSlots bounds the number of extra goroutines used for committing
+// subtries across all tries, which may already be committed concurrently
+// themselves (e.g. the storage tries of a state).
+var commitSlots = make(chan struct{}, runtime.NumCPU())
+
 // leaf represents a trie leaf node
 type leaf struct {
 	blob   []byte      // raw blob of leaf
@@ -34,13 +45,15 @@ type leaf struct {
 type committer struct {
 	nodes       *NodeSet
 	collectLeaf bool
+	parallel    bool // Whether to commit the subtries of the first fullnode concurrently
 }
 
 // newCommitter creates a new committer or picks one from the pool.
-func newCommitter(nodeset *NodeSet, collectLeaf bool) *committer {
+func newCommitter(nodeset *NodeSet, collectLeaf bool, parallel bool) *committer {
 	return &committer{
 		nodes:       nodeset,
 		collectLeaf: collectLeaf,
+		parallel:    parallel,
 	}
 }
 
@@ -96,6 +109,10 @@ func (c *committer) commit(path []byte, n node) node {
 // commitChildren commits the children of the given fullnode
 func (c *committer) commitChildren(path []byte, n *fullNode) [17]node {
 	var children [17]node
+	if c.parallel {
+		c.commitChildrenParallel(path, n, &children)
+		return children
+	}
 	for i := 0; i < 16; i++ {
 		child := n.Children[i]
 		if child == nil {
@@ -120,6 +137,59 @@ func (c *committer) commitChildren(path []byte, n *fullNode) [17]node {
 	return children
 }
 
+// commitChildrenParallel commits the subtries of the given fullnode concurrently,
+// each of them into a separate nodeset, and merges the nodesets in the order of
+// the children afterwards so that the result matches a sequential commit. Only
+// the first fullnode reached from the root is split, the subtries below it are
+// committed sequentially. Subtries which find no free slot in commitSlots are
+// committed on the calling goroutine.
+func (c *committer) commitChildrenParallel(path []byte, n *fullNode, children *[17]node) {
+	var (
+		wg   sync.WaitGroup
+		sets [16]*NodeSet
+	)
+	for i := 0; i < 16; i++ {
+		child := n.Children[i]
+		if child == nil {
+			continue
+		}
+		if hn, ok := child.(hashNode); ok {
+			children[i] = hn
+			continue
+		}
+		sets[i] = NewNodeSet(c.nodes.owner, c.nodes.accessList)
+
+		// The path is shared by all children, each of them needs its own copy
+		var (
+			sub  = newCommitter(sets[i], c.collectLeaf, false)
+			path = append(common.CopyBytes(path), byte(i))
+		)
+		select {
+		case commitSlots <- struct{}{}:
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					<-commitSlots
+					wg.Done()
+				}()
+				children[i] = sub.commit(path, child)
+			}(i)
+		default:
+			children[i] = sub.commit(path, child)
+		}
+	}
+	wg.Wait()
+
+	for _, set := range sets {
+		if set != nil {
+			c.nodes.merge(set)
+		}
+	}
+	if n.Children[16] != nil {
+		children[16] = n.Children[16]
+	}
+}
+
 // store hashes the node n and adds it to the modified nodeset. If leaf collection
 // is enabled, leaf nodes will be tracked in the modified nodeset as well.
 func (c *committer) store(path []byte, n node) node {
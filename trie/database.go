@@ -87,6 +87,11 @@ type Database struct {
 	childrenSize common.StorageSize // Storage size of the external children tracking
 	preimages    *preimageStore     // The store for caching preimages
 
+	path   *pathDB      // Backend of the path-based node scheme, nil for the hash-based one
+	verkle *verkleNodes // Committed nodes of the verkle trees, nil if the state is stored in tries
+
+	onFlush func(hash common.Hash) // Hook invoked before a node is flushed into the disk
+
 	lock sync.RWMutex
 }
 
@@ -268,6 +273,9 @@ type Config struct {
 	Cache     int    // Memory allowance (MB) to use for caching trie nodes in memory
 	Journal   string // Journal of clean cache to survive node restarts
 	Preimages bool   // Flag whether the preimage of trie key is recorded
+
+	PathDB *PathConfig // Configs of the path-based node scheme, nil selects the hash-based one
+	Verkle bool        // Flag whether the state is stored in a verkle tree instead of tries
 }
 
 // NewDatabase creates a new trie database to store ephemeral trie content before
@@ -301,6 +309,12 @@ func NewDatabaseWithConfig(diskdb ethdb.Database, config *Config) *Database {
 		}},
 		preimages: preimage,
 	}
+	if config != nil && config.PathDB != nil {
+		db.path = newPathDB(diskdb, cleans, config.PathDB)
+	}
+	if config != nil && config.Verkle {
+		db.verkle = newVerkleNodes()
+	}
 	return db
 }
 
@@ -384,6 +398,10 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	if hash == (common.Hash{}) {
 		return nil, errors.New("not found")
 	}
+	// Nodes can't be looked up by hash alone in the path-based scheme
+	if db.path != nil {
+		return nil, errors.New("not supported by the path scheme")
+	}
 	// Retrieve the node from the clean cache if available
 	if db.cleans != nil {
 		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
@@ -438,6 +456,9 @@ func (db *Database) Nodes() []common.Hash {
 // and external node(e.g. storage trie root), all internal trie nodes
 // are referenced together by database itself.
 func (db *Database) Reference(child common.Hash, parent common.Hash) {
+	if db.path != nil {
+		return // States are not reference counted in the path-based scheme
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -472,6 +493,9 @@ func (db *Database) Dereference(root common.Hash) {
 		log.Error("Attempted to dereference the trie cache meta root")
 		return
 	}
+	if db.path != nil {
+		return // States are not reference counted in the path-based scheme
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -546,6 +570,11 @@ func (db *Database) dereference(child common.Hash, parent common.Hash) {
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Cap(limit common.StorageSize) error {
+	// The memory used by the path-based scheme is bounded by the number of
+	// retained diff layers instead, see CapLayers.
+	if db.path != nil {
+		return nil
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -571,6 +600,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	for size > limit && oldest != (common.Hash{}) {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
+		if db.onFlush != nil {
+			db.onFlush(oldest)
+		}
 		rawdb.WriteLegacyTrieNode(batch, oldest, node.rlp())
 
 		// If we exceeded the ideal batch size, commit and reset
@@ -633,6 +665,16 @@ func (db *Database) Cap(limit common.StorageSize) error {
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
 func (db *Database) Commit(node common.Hash, report bool) error {
+	// Verkle tree nodes are not reference counted, all of them are written out
+	// regardless of the given root
+	if db.verkle != nil {
+		if db.preimages != nil {
+			if err := db.preimages.commit(true); err != nil {
+				return err
+			}
+		}
+		return db.verkle.commit(db.diskdb)
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -646,6 +688,18 @@ func (db *Database) Commit(node common.Hash, report bool) error {
 			return err
 		}
 	}
+	if db.path != nil {
+		if err := db.path.capLayers(node, 0); err != nil {
+			log.Error("Failed to persist trie state", "err", err)
+			return err
+		}
+		logger := log.Info
+		if !report {
+			logger = log.Debug
+		}
+		logger("Persisted trie state", "root", node, "time", common.PrettyDuration(time.Since(start)))
+		return nil
+	}
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.dirties), db.dirtiesSize
 
@@ -703,6 +757,9 @@ func (db *Database) commit(hash common.Hash, batch ethdb.Batch, uncacher *cleane
 		return err
 	}
 	// If we've reached an optimal batch size, commit and start over
+	if db.onFlush != nil {
+		db.onFlush(hash)
+	}
 	rawdb.WriteLegacyTrieNode(batch, hash, node.rlp())
 	if batch.ValueSize() >= ethdb.IdealBatchSize {
 		if err := batch.Write(); err != nil {
@@ -771,6 +828,9 @@ func (c *cleaner) Delete(key []byte) error {
 // Update inserts the dirty nodes in provided nodeset into database and
 // link the account trie with multiple storage tries if necessary.
 func (db *Database) Update(nodes *MergedNodeSet) error {
+	if db.path != nil {
+		return errors.New("state roots are required by the path scheme, use UpdateState")
+	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
@@ -815,9 +875,64 @@ func (db *Database) Update(nodes *MergedNodeSet) error {
 	return nil
 }
 
+// UpdateState inserts the dirty nodes of the state transition from the parent
+// to the given root into the database. With the hash-based scheme it's the
+// same as Update, with the path-based one the nodes form a new diff layer on
+// top of the parent state.
+func (db *Database) UpdateState(root common.Hash, parent common.Hash, nodes *MergedNodeSet) error {
+	if db.path != nil {
+		return db.path.update(root, parent, nodes)
+	}
+	return db.Update(nodes)
+}
+
+// CapLayers merges the in-memory diff layers below the given state into the
+// persisted state, keeping at most the given number of them. It's a no-op for
+// the hash-based scheme, which is capped by memory usage instead.
+func (db *Database) CapLayers(root common.Hash, layers int) error {
+	if db.path == nil {
+		return nil
+	}
+	return db.path.capLayers(root, layers)
+}
+
+// Recoverable reports whether the persisted state can be rolled back to the
+// given one using the state history of the path-based scheme.
+func (db *Database) Recoverable(root common.Hash) bool {
+	if db.path == nil {
+		return false
+	}
+	return db.path.recoverable(root)
+}
+
+// Recover rolls the persisted state back to the given one using the state
+// history of the path-based scheme, discarding all newer in-memory states.
+func (db *Database) Recover(root common.Hash) error {
+	if db.path == nil {
+		return errors.New("state recovery is only supported by the path scheme")
+	}
+	return db.path.recover(root)
+}
+
+// Close releases the resources held by the database. The in-memory states are
+// not persisted, Commit needs to be called for that beforehand.
+func (db *Database) Close() error {
+	if db.path == nil {
+		return nil
+	}
+	return db.path.close()
+}
+
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {
+	if db.path != nil {
+		var preimageSize common.StorageSize
+		if db.preimages != nil {
+			preimageSize = db.preimages.size()
+		}
+		return db.path.size(), preimageSize
+	}
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
@@ -835,6 +950,13 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 
 // GetReader retrieves a node reader belonging to the given state root.
 func (db *Database) GetReader(root common.Hash) Reader {
+	if db.path != nil {
+		layer := db.path.reader(root)
+		if layer == nil {
+			return nil
+		}
+		return &pathReader{layer: layer}
+	}
 	return newHashReader(db)
 }
 
@@ -914,7 +1036,45 @@ func (db *Database) CommitPreimages() error {
 	return db.preimages.commit(true)
 }
 
+// SetFlushHook registers a callback which is invoked with the hash of every
+// node right before it's flushed from the dirty cache into the disk. It's only
+// used by the hash-based scheme and must not be changed while nodes are being
+// flushed concurrently.
+func (db *Database) SetFlushHook(hook func(hash common.Hash)) {
+	db.onFlush = hook
+}
+
+// Evict drops the given nodes from the clean cache, after they have been
+// deleted from the disk by an external pruner.
+func (db *Database) Evict(hashes []common.Hash) {
+	if db.cleans == nil {
+		return
+	}
+	for _, hash := range hashes {
+		db.cleans.Del(hash[:])
+	}
+}
+
+// Initialized reports whether the database holds a persisted state. In hash
+// mode the genesis state is looked up directly, in path mode any persisted
+// state counts, as the genesis one is overwritten by the later ones.
+func (db *Database) Initialized(genesisRoot common.Hash) bool {
+	if db.path != nil {
+		return persistedRoot(db.diskdb) != types.EmptyRootHash
+	}
+	return rawdb.HasLegacyTrieNode(db.diskdb, genesisRoot)
+}
+
+// IsVerkle reports whether the state is stored in a verkle tree, whose nodes
+// are kept aside from the trie nodes until committed.
+func (db *Database) IsVerkle() bool {
+	return db.verkle != nil
+}
+
 // Scheme returns the node scheme used in the database.
 func (db *Database) Scheme() string {
+	if db.path != nil {
+		return rawdb.PathScheme
+	}
 	return rawdb.HashScheme
 }
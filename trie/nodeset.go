@@ -147,6 +147,17 @@ func (set *NodeSet) addLeaf(node *leaf) {
 	set.leaves = append(set.leaves, node)
 }
 
+// merge moves the dirty nodes and leaves of a nodeset collected from a subtrie
+// of the same trie into this set.
+func (set *NodeSet) merge(other *NodeSet) {
+	for path, n := range other.nodes {
+		set.nodes[path] = n
+	}
+	set.leaves = append(set.leaves, other.leaves...)
+	set.updates += other.updates
+	set.deletes += other.deletes
+}
+
 // Size returns the number of dirty nodes in set.
 func (set *NodeSet) Size() (int, int) {
 	return set.updates, set.deletes
@@ -0,0 +1,556 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	// errPathLayerStale is returned from a node lookup in a layer which was
+	// already merged into the persistent state or discarded.
+	errPathLayerStale = errors.New("layer stale")
+
+	// errUnexpectedNode is returned if the node stored at the requested path
+	// doesn't match the requested hash.
+	errUnexpectedNode = errors.New("unexpected node")
+
+	// errStateUnrecoverable is returned if the state can't be restored from
+	// the state history.
+	errStateUnrecoverable = errors.New("state is unrecoverable")
+)
+
+// PathConfig contains the settings of the path-based node scheme.
+type PathConfig struct {
+	StateHistory uint64 // Number of recent state transitions to keep reverse diffs for, 0 keeps all
+	ReadOnly     bool   // Flag whether the database is only opened for reading
+}
+
+// pathLayer is a version of the state in the path-based node scheme, either
+// the single persisted one or an in-memory diff on top of it.
+type pathLayer interface {
+	// rootHash returns the root hash of the state the layer represents.
+	rootHash() common.Hash
+
+	// stateID returns the sequence number of the state the layer represents.
+	stateID() uint64
+
+	// node retrieves the trie node blob with the given owner, path and hash.
+	// No error is returned if the node is not found.
+	node(owner common.Hash, path []byte, hash common.Hash) ([]byte, error)
+}
+
+// pathNode is a trie node in a diff layer, a nil blob marks a deleted node.
+type pathNode struct {
+	hash common.Hash
+	blob []byte
+}
+
+// pathDiffLayer is the set of trie nodes changed by a single state transition,
+// kept in memory on top of its parent layer.
+type pathDiffLayer struct {
+	root  common.Hash                          // Root hash of the state after the transition
+	id    uint64                               // Sequence number of the state after the transition
+	nodes map[common.Hash]map[string]*pathNode // Changed nodes, keyed by owner and path
+	size  uint64                               // Approximate memory used by the nodes
+
+	parent pathLayer // Parent layer, replaced when it's persisted
+	stale  bool      // Flag whether the layer was persisted or discarded
+	lock   sync.RWMutex
+}
+
+// newPathDiffLayer creates a diff layer on top of the parent from the dirty
+// nodes of a state transition.
+func newPathDiffLayer(parent pathLayer, root common.Hash, nodes *MergedNodeSet) *pathDiffLayer {
+	dl := &pathDiffLayer{
+		root:   root,
+		id:     parent.stateID() + 1,
+		nodes:  make(map[common.Hash]map[string]*pathNode),
+		parent: parent,
+	}
+	for owner, set := range nodes.sets {
+		subset := make(map[string]*pathNode)
+		for path, n := range set.nodes {
+			if n.isDeleted() {
+				subset[path] = &pathNode{}
+			} else {
+				subset[path] = &pathNode{hash: n.hash, blob: n.rlp()}
+			}
+			dl.size += uint64(len(path) + len(subset[path].blob) + common.HashLength)
+		}
+		dl.nodes[owner] = subset
+	}
+	return dl
+}
+
+func (dl *pathDiffLayer) rootHash() common.Hash { return dl.root }
+func (dl *pathDiffLayer) stateID() uint64       { return dl.id }
+
+// parentLayer returns the current parent of the diff layer.
+func (dl *pathDiffLayer) parentLayer() pathLayer {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.parent
+}
+
+// node implements pathLayer, looking up the node in the layer itself and then
+// in its parents.
+func (dl *pathDiffLayer) node(owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	if dl.stale {
+		dl.lock.RUnlock()
+		return nil, errPathLayerStale
+	}
+	if n, ok := dl.nodes[owner][string(path)]; ok {
+		dl.lock.RUnlock()
+		if n.hash != hash {
+			return nil, fmt.Errorf("%w: owner %x path %x, have %x, want %x", errUnexpectedNode, owner, path, n.hash, hash)
+		}
+		return n.blob, nil
+	}
+	parent := dl.parent
+	dl.lock.RUnlock()
+
+	return parent.node(owner, path, hash)
+}
+
+// markStale flags the layer as no longer accessible.
+func (dl *pathDiffLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// pathDiskLayer is the single version of the state persisted by path.
+type pathDiskLayer struct {
+	root common.Hash // Root hash of the persisted state
+	id   uint64      // Sequence number of the persisted state
+	db   *pathDB
+
+	stale bool // Flag whether the persisted state was changed since
+	lock  sync.RWMutex
+}
+
+func (dl *pathDiskLayer) rootHash() common.Hash { return dl.root }
+func (dl *pathDiskLayer) stateID() uint64       { return dl.id }
+
+// node implements pathLayer, loading the node from the database and checking
+// it against the requested hash.
+func (dl *pathDiskLayer) node(owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, errPathLayerStale
+	}
+	// Nodes are content addressed in the clean cache, so a hit is always
+	// correct regardless of the path.
+	if dl.db.cleans != nil {
+		if blob := dl.db.cleans.Get(nil, hash[:]); len(blob) > 0 {
+			memcacheCleanHitMeter.Mark(1)
+			memcacheCleanReadMeter.Mark(int64(len(blob)))
+			return blob, nil
+		}
+	}
+	var (
+		blob  []byte
+		nHash common.Hash
+	)
+	if owner == (common.Hash{}) {
+		blob, nHash = rawdb.ReadAccountTrieNode(dl.db.diskdb, path)
+	} else {
+		blob, nHash = rawdb.ReadStorageTrieNode(dl.db.diskdb, owner, path)
+	}
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	if nHash != hash {
+		return nil, fmt.Errorf("%w: owner %x path %x, have %x, want %x", errUnexpectedNode, owner, path, nHash, hash)
+	}
+	if dl.db.cleans != nil {
+		dl.db.cleans.Set(hash[:], blob)
+		memcacheCleanMissMeter.Mark(1)
+		memcacheCleanWriteMeter.Mark(int64(len(blob)))
+	}
+	return blob, nil
+}
+
+// markStale flags the layer as no longer accessible.
+func (dl *pathDiskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// pathDB is the backend of the trie database for the path-based node scheme.
+//
+// Only the latest persisted state is stored in the key-value store, keyed by
+// the node paths, so that outdated nodes are overwritten instead of piling up.
+// Recent states are kept in memory as a tree of diff layers on top of it. When
+// the oldest diff layers are merged into the persisted state, the overwritten
+// nodes are written to the state freezer as reverse diffs, which allows rolling
+// the persisted state back by a bounded number of transitions.
+type pathDB struct {
+	diskdb  ethdb.Database
+	cleans  *fastcache.Cache
+	freezer *rawdb.ResettableFreezer // Reverse diffs of persisted transitions, nil if unavailable
+	config  *PathConfig
+
+	layers map[common.Hash]pathLayer // All accessible layers, keyed by state root
+	disk   *pathDiskLayer            // The persisted layer at the bottom of the tree
+	lock   sync.RWMutex
+}
+
+// newPathDB opens the path-based node storage on top of the given database.
+func newPathDB(diskdb ethdb.Database, cleans *fastcache.Cache, config *PathConfig) *pathDB {
+	db := &pathDB{
+		diskdb: diskdb,
+		cleans: cleans,
+		config: config,
+		layers: make(map[common.Hash]pathLayer),
+	}
+	// The state history requires a freezer next to the chain ancients, skip
+	// it for pure in-memory databases.
+	if !config.ReadOnly {
+		if datadir, err := diskdb.AncientDatadir(); err == nil {
+			freezer, err := rawdb.NewStateFreezer(datadir, false)
+			if err != nil {
+				log.Crit("Failed to open state history freezer", "err", err)
+			}
+			db.freezer = freezer
+		}
+	}
+	db.disk = &pathDiskLayer{
+		root: persistedRoot(diskdb),
+		id:   rawdb.ReadPersistentStateID(diskdb),
+		db:   db,
+	}
+	db.layers[db.disk.root] = db.disk
+
+	if db.freezer != nil {
+		if err := db.repairHistory(); err != nil {
+			log.Crit("Failed to repair state history", "err", err)
+		}
+	}
+	return db
+}
+
+// persistedRoot returns the root hash of the state persisted by path.
+func persistedRoot(diskdb ethdb.KeyValueReader) common.Hash {
+	blob, hash := rawdb.ReadAccountTrieNode(diskdb, nil)
+	if len(blob) == 0 {
+		return types.EmptyRootHash
+	}
+	return hash
+}
+
+// layerRoot maps the zero hash, which the state package uses for empty
+// states, to the root of the empty trie the layers are keyed by.
+func layerRoot(root common.Hash) common.Hash {
+	if root == (common.Hash{}) {
+		return types.EmptyRootHash
+	}
+	return root
+}
+
+// repairHistory aligns the state history with the persisted state after an
+// unclean shutdown.
+func (db *pathDB) repairHistory() error {
+	items, err := db.freezer.Ancients()
+	if err != nil {
+		return err
+	}
+	id := db.disk.id
+	switch {
+	case items > id:
+		// The history was written, but the transition itself wasn't persisted
+		log.Warn("Truncating dangling state history", "number", items-id)
+		return db.freezer.TruncateHead(id)
+
+	case items < id:
+		// The history is gone, the state ids are meaningless without it
+		log.Warn("State history is missing, resetting", "have", items, "want", id)
+		if err := db.freezer.Reset(); err != nil {
+			return err
+		}
+		rawdb.DeleteStateIDs(db.diskdb)
+		rawdb.WritePersistentStateID(db.diskdb, 0)
+		db.disk.id = 0
+	}
+	return nil
+}
+
+// reader returns the layer of the given state, or nil if it's not available.
+func (db *pathDB) reader(root common.Hash) pathLayer {
+	root = layerRoot(root)
+
+	db.lock.RLock()
+	layer := db.layers[root]
+	db.lock.RUnlock()
+
+	if layer != nil {
+		return layer
+	}
+	// The state may have been written into the database directly by the snap
+	// syncer, adopt it if there's nothing built on top of the persisted one.
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(db.layers) != 1 || root == db.disk.root || persistedRoot(db.diskdb) != root {
+		return db.layers[root]
+	}
+	db.disk.markStale()
+	db.disk = &pathDiskLayer{root: root, id: db.disk.id, db: db}
+	db.layers = map[common.Hash]pathLayer{root: db.disk}
+	return db.disk
+}
+
+// update adds the dirty nodes of a state transition as a new diff layer on
+// top of the parent state.
+func (db *pathDB) update(root common.Hash, parent common.Hash, nodes *MergedNodeSet) error {
+	if db.config.ReadOnly {
+		return errors.New("read only database")
+	}
+	root, parent = layerRoot(root), layerRoot(parent)
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if _, ok := db.layers[root]; ok {
+		return nil
+	}
+	layer := db.layers[parent]
+	if layer == nil {
+		return fmt.Errorf("parent state %x not found", parent)
+	}
+	db.layers[root] = newPathDiffLayer(layer, root, nodes)
+	return nil
+}
+
+// capLayers merges the diff layers below the given state into the persisted
+// one, keeping at most the given number of diff layers. Zero layers persists
+// the given state itself.
+func (db *pathDB) capLayers(root common.Hash, layers int) error {
+	root = layerRoot(root)
+
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	layer := db.layers[root]
+	if layer == nil {
+		return fmt.Errorf("state %x not found", root)
+	}
+	diff, ok := layer.(*pathDiffLayer)
+	if !ok {
+		return nil // Already persisted
+	}
+	// Find the topmost layer to persist
+	bottom := diff
+	if layers > 0 {
+		for i := 0; i < layers; i++ {
+			parent, ok := bottom.parentLayer().(*pathDiffLayer)
+			if !ok {
+				return nil // Not enough layers to persist any
+			}
+			bottom = parent
+		}
+	}
+	// Persist the layers from the bottom up to it
+	var chain []*pathDiffLayer
+	for l := bottom; ; {
+		chain = append(chain, l)
+		parent, ok := l.parentLayer().(*pathDiffLayer)
+		if !ok {
+			break
+		}
+		l = parent
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := db.persist(chain[i]); err != nil {
+			return err
+		}
+	}
+	// Rebase the layers built on the persisted state and drop the ones which
+	// forked off below it.
+	for _, l := range db.layers {
+		if child, ok := l.(*pathDiffLayer); ok && child.parentLayer() == bottom {
+			child.lock.Lock()
+			child.parent = db.disk
+			child.lock.Unlock()
+		}
+	}
+	for root, l := range db.layers {
+		if !db.reachable(l) {
+			if child, ok := l.(*pathDiffLayer); ok {
+				child.markStale()
+			}
+			delete(db.layers, root)
+		}
+	}
+	return nil
+}
+
+// reachable reports whether the layer is built on the current persisted one.
+//
+// Note, this method assumes the database lock is held!
+func (db *pathDB) reachable(layer pathLayer) bool {
+	for {
+		diff, ok := layer.(*pathDiffLayer)
+		if !ok {
+			return layer == db.disk
+		}
+		layer = diff.parentLayer()
+	}
+}
+
+// persist merges the bottom-most diff layer into the persisted state, saving
+// the overwritten nodes into the state history.
+//
+// Note, this method assumes the database lock is held!
+func (db *pathDB) persist(bottom *pathDiffLayer) error {
+	var (
+		disk    = db.disk
+		id      = disk.id + 1
+		batch   = db.diskdb.NewBatch()
+		history = &stateHistory{Parent: disk.root, Root: bottom.root}
+	)
+	owners := make([]common.Hash, 0, len(bottom.nodes))
+	for owner := range bottom.nodes {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return bytes.Compare(owners[i][:], owners[j][:]) < 0 })
+
+	for _, owner := range owners {
+		subset := bottom.nodes[owner]
+		paths := make([]string, 0, len(subset))
+		for path := range subset {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		trie := historyTrie{Owner: owner}
+		for _, path := range paths {
+			n := subset[path]
+			if owner == (common.Hash{}) {
+				if db.freezer != nil {
+					prev, _ := rawdb.ReadAccountTrieNode(db.diskdb, []byte(path))
+					trie.Nodes = append(trie.Nodes, historyNode{Path: []byte(path), Blob: prev})
+				}
+				if n.blob == nil {
+					rawdb.DeleteAccountTrieNode(batch, []byte(path))
+				} else {
+					rawdb.WriteAccountTrieNode(batch, []byte(path), n.blob)
+				}
+			} else {
+				if db.freezer != nil {
+					prev, _ := rawdb.ReadStorageTrieNode(db.diskdb, owner, []byte(path))
+					trie.Nodes = append(trie.Nodes, historyNode{Path: []byte(path), Blob: prev})
+				}
+				if n.blob == nil {
+					rawdb.DeleteStorageTrieNode(batch, owner, []byte(path))
+				} else {
+					rawdb.WriteStorageTrieNode(batch, owner, []byte(path), n.blob)
+				}
+			}
+		}
+		history.Tries = append(history.Tries, trie)
+	}
+	// Write the history ahead of the state, a dangling history is truncated
+	// on startup.
+	if db.freezer != nil {
+		if err := writeStateHistory(db.freezer, id, history); err != nil {
+			return err
+		}
+	}
+	rawdb.WriteStateID(batch, disk.root, disk.id)
+	rawdb.WriteStateID(batch, bottom.root, id)
+	rawdb.WritePersistentStateID(batch, id)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Debug("Persisted trie layer", "root", bottom.root, "id", id, "size", common.StorageSize(bottom.size))
+
+	disk.markStale()
+	bottom.markStale()
+	delete(db.layers, disk.root)
+	delete(db.layers, bottom.root)
+
+	db.disk = &pathDiskLayer{root: bottom.root, id: id, db: db}
+	db.layers[bottom.root] = db.disk
+
+	if db.freezer != nil && db.config.StateHistory > 0 && id > db.config.StateHistory {
+		return db.truncateHistory(id - db.config.StateHistory)
+	}
+	return nil
+}
+
+// size returns the memory used by the diff layers.
+func (db *pathDB) size() common.StorageSize {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var size uint64
+	for _, l := range db.layers {
+		if diff, ok := l.(*pathDiffLayer); ok {
+			size += diff.size
+		}
+	}
+	return common.StorageSize(size)
+}
+
+// close releases the state history freezer.
+func (db *pathDB) close() error {
+	if db.freezer == nil {
+		return nil
+	}
+	return db.freezer.Close()
+}
+
+// pathReader is the node reader of a state in the path-based node scheme.
+type pathReader struct {
+	layer pathLayer
+}
+
+// Node retrieves the trie node with the given owner, path and hash.
+// No error will be returned if the node is not found.
+func (r *pathReader) Node(owner common.Hash, path []byte, hash common.Hash) (node, error) {
+	blob, err := r.layer.node(owner, path, hash)
+	if err != nil || len(blob) == 0 {
+		return nil, err
+	}
+	return decodeNode(hash[:], blob)
+}
+
+// NodeBlob retrieves the RLP-encoded trie node blob with the given owner, path
+// and hash. No error will be returned if the node is not found.
+func (r *pathReader) NodeBlob(owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	return r.layer.node(owner, path, hash)
+}
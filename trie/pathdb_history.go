@@ -0,0 +1,190 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// stateHistory is the reverse diff of a persisted state transition, holding the
+// values of all the nodes it overwrote.
+type stateHistory struct {
+	Parent common.Hash   // Root hash of the state before the transition
+	Root   common.Hash   // Root hash of the state after the transition
+	Tries  []historyTrie // Overwritten nodes, grouped by trie
+}
+
+// historyTrie is the set of overwritten nodes of a single trie.
+type historyTrie struct {
+	Owner common.Hash
+	Nodes []historyNode
+}
+
+// historyNode is the value of a node before the transition, an empty blob
+// means the node didn't exist.
+type historyNode struct {
+	Path []byte
+	Blob []byte
+}
+
+// writeStateHistory appends the reverse diff of the transition with the given
+// id to the state freezer.
+func writeStateHistory(freezer ethdb.AncientWriter, id uint64, history *stateHistory) error {
+	blob, err := rlp.EncodeToBytes(history)
+	if err != nil {
+		return err
+	}
+	return rawdb.WriteStateHistory(freezer, id, blob)
+}
+
+// readStateHistory loads the reverse diff of the transition with the given id
+// from the state freezer.
+func readStateHistory(freezer ethdb.AncientReaderOp, id uint64) (*stateHistory, error) {
+	blob := rawdb.ReadStateHistory(freezer, id)
+	if len(blob) == 0 {
+		return nil, fmt.Errorf("state history #%d not found", id)
+	}
+	var history stateHistory
+	if err := rlp.DecodeBytes(blob, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// truncateHistory drops the oldest state histories, keeping the ones from the
+// given freezer index on. The states which can't be recovered any more are
+// forgotten.
+//
+// Note, this method assumes the database lock is held!
+func (db *pathDB) truncateHistory(tail uint64) error {
+	oldTail, err := db.freezer.Tail()
+	if err != nil {
+		return err
+	}
+	if tail <= oldTail {
+		return nil
+	}
+	batch := db.diskdb.NewBatch()
+	for index := oldTail; index < tail; index++ {
+		history, err := readStateHistory(db.freezer, index+1)
+		if err != nil {
+			return err
+		}
+		// The state may have been reached again later on, keep it in that case
+		if id := rawdb.ReadStateID(db.diskdb, history.Parent); id != nil && *id == index {
+			rawdb.DeleteStateID(batch, history.Parent)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	return db.freezer.TruncateTail(tail)
+}
+
+// recoverable reports whether the persisted state can be rolled back to the
+// given one using the state history.
+func (db *pathDB) recoverable(root common.Hash) bool {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	_, ok := db.recoveryTarget(root)
+	return ok
+}
+
+// recoveryTarget returns the id of the state the persisted one can be rolled
+// back to.
+//
+// Note, this method assumes the database lock is held!
+func (db *pathDB) recoveryTarget(root common.Hash) (uint64, bool) {
+	if db.freezer == nil {
+		return 0, false
+	}
+	id := rawdb.ReadStateID(db.diskdb, root)
+	if id == nil || *id >= db.disk.id {
+		return 0, false
+	}
+	tail, err := db.freezer.Tail()
+	if err != nil || *id < tail {
+		return 0, false
+	}
+	return *id, true
+}
+
+// recover rolls the persisted state back to the given one by applying the
+// reverse diffs of the state history. All diff layers are discarded as they
+// are built on top of the state being reverted.
+func (db *pathDB) recover(root common.Hash) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	target, ok := db.recoveryTarget(root)
+	if !ok {
+		return fmt.Errorf("%w: %x", errStateUnrecoverable, root)
+	}
+	for _, l := range db.layers {
+		if diff, ok := l.(*pathDiffLayer); ok {
+			diff.markStale()
+		}
+	}
+	db.disk.markStale()
+
+	current := db.disk.root
+	for id := db.disk.id; id > target; id-- {
+		history, err := readStateHistory(db.freezer, id)
+		if err != nil {
+			return err
+		}
+		if history.Root != current {
+			return fmt.Errorf("state history #%d mismatch, want %x, have %x", id, current, history.Root)
+		}
+		batch := db.diskdb.NewBatch()
+		for _, trie := range history.Tries {
+			for _, n := range trie.Nodes {
+				switch {
+				case trie.Owner == (common.Hash{}) && len(n.Blob) == 0:
+					rawdb.DeleteAccountTrieNode(batch, n.Path)
+				case trie.Owner == (common.Hash{}):
+					rawdb.WriteAccountTrieNode(batch, n.Path, n.Blob)
+				case len(n.Blob) == 0:
+					rawdb.DeleteStorageTrieNode(batch, trie.Owner, n.Path)
+				default:
+					rawdb.WriteStorageTrieNode(batch, trie.Owner, n.Path, n.Blob)
+				}
+			}
+		}
+		rawdb.DeleteStateID(batch, history.Root)
+		rawdb.WritePersistentStateID(batch, id-1)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		if err := db.freezer.TruncateHead(id - 1); err != nil {
+			return err
+		}
+		current = history.Parent
+	}
+	log.Info("Rolled back persisted state", "from", db.disk.root, "to", current, "transitions", db.disk.id-target)
+
+	db.disk = &pathDiskLayer{root: current, id: target, db: db}
+	db.layers = map[common.Hash]pathLayer{current: db.disk}
+	return nil
+}
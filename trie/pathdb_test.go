@@ -0,0 +1,190 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+// pathTester builds a sequence of states in a path-based trie database and
+// remembers their content.
+type pathTester struct {
+	db     *Database
+	roots  []common.Hash
+	states []map[string]string
+}
+
+func newPathTester(t *testing.T, diskdb ethdb.Database, config *PathConfig, n int) *pathTester {
+	tester := &pathTester{
+		db:     NewDatabaseWithConfig(diskdb, &Config{PathDB: config}),
+		roots:  []common.Hash{types.EmptyRootHash},
+		states: []map[string]string{{}},
+	}
+	for i := 1; i <= n; i++ {
+		parent := tester.roots[len(tester.roots)-1]
+		tr, err := New(TrieID(parent), tester.db)
+		if err != nil {
+			t.Fatalf("state %d: failed to open parent: %v", i, err)
+		}
+		state := make(map[string]string)
+		for k, v := range tester.states[len(tester.states)-1] {
+			state[k] = v
+		}
+		// Overwrite, insert and delete some entries on every transition
+		for j := 0; j < 16; j++ {
+			key := fmt.Sprintf("key-%d", (i*7+j)%40)
+			if j%5 == 4 {
+				tr.Delete([]byte(key))
+				delete(state, key)
+			} else {
+				val := fmt.Sprintf("value-%d-%d", i, j)
+				tr.Update([]byte(key), []byte(val))
+				state[key] = val
+			}
+		}
+		root, nodes := tr.Commit(false)
+		if err := tester.db.UpdateState(root, parent, NewWithNodeSet(nodes)); err != nil {
+			t.Fatalf("state %d: failed to update database: %v", i, err)
+		}
+		tester.roots = append(tester.roots, root)
+		tester.states = append(tester.states, state)
+	}
+	return tester
+}
+
+// verify checks whether the state with the given index is accessible with the
+// expected content.
+func (tester *pathTester) verify(index int) error {
+	tr, err := New(TrieID(tester.roots[index]), tester.db)
+	if err != nil {
+		return err
+	}
+	for k, v := range tester.states[index] {
+		have, err := tr.TryGet([]byte(k))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(have, []byte(v)) {
+			return fmt.Errorf("value mismatch for %s: have %s, want %s", k, have, v)
+		}
+	}
+	it := NewIterator(tr.NodeIterator(nil))
+	var count int
+	for it.Next() {
+		count++
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	if count != len(tester.states[index]) {
+		return fmt.Errorf("entry count mismatch: have %d, want %d", count, len(tester.states[index]))
+	}
+	return nil
+}
+
+func TestPathDatabaseLayers(t *testing.T) {
+	tester := newPathTester(t, rawdb.NewMemoryDatabase(), &PathConfig{}, 10)
+	head := tester.roots[10]
+
+	for i := range tester.roots {
+		if err := tester.verify(i); err != nil {
+			t.Fatalf("state %d: %v", i, err)
+		}
+	}
+	// Keep three diff layers, the 7th state becomes persisted
+	if err := tester.db.CapLayers(head, 3); err != nil {
+		t.Fatalf("failed to cap layers: %v", err)
+	}
+	for i := range tester.roots {
+		err := tester.verify(i)
+		if i < 7 && err == nil {
+			t.Errorf("state %d: merged state still accessible", i)
+		}
+		if i >= 7 && err != nil {
+			t.Errorf("state %d: %v", i, err)
+		}
+	}
+	if have := persistedRoot(tester.db.path.diskdb); have != tester.roots[7] {
+		t.Errorf("persisted root mismatch: have %x, want %x", have, tester.roots[7])
+	}
+	// Commit the head, all diff layers are merged
+	if err := tester.db.Commit(head, false); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if err := tester.verify(10); err != nil {
+		t.Fatalf("head state: %v", err)
+	}
+	if size, _ := tester.db.Size(); size != 0 {
+		t.Errorf("diff layers left after commit: %v", size)
+	}
+	// Reopen the database, the persisted state must be picked up
+	tester.db = NewDatabaseWithConfig(tester.db.path.diskdb, &Config{PathDB: &PathConfig{}})
+	if err := tester.verify(10); err != nil {
+		t.Fatalf("reopened head state: %v", err)
+	}
+	if tester.db.Recoverable(tester.roots[9]) {
+		t.Error("state recoverable without history")
+	}
+}
+
+func TestPathDatabaseRecover(t *testing.T) {
+	diskdb, err := rawdb.NewDatabaseWithFreezer(memorydb.New(), t.TempDir(), "", false)
+	if err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	defer diskdb.Close()
+
+	tester := newPathTester(t, diskdb, &PathConfig{StateHistory: 6}, 10)
+	defer tester.db.Close()
+
+	if err := tester.db.Commit(tester.roots[10], false); err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	// Only the last six transitions can be reverted
+	for i := range tester.roots {
+		if have, want := tester.db.Recoverable(tester.roots[i]), i >= 4 && i < 10; have != want {
+			t.Errorf("state %d: recoverable mismatch: have %v, want %v", i, have, want)
+		}
+	}
+	if err := tester.db.Recover(tester.roots[5]); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	if err := tester.verify(5); err != nil {
+		t.Fatalf("recovered state: %v", err)
+	}
+	if err := tester.verify(10); err == nil {
+		t.Fatal("reverted state still accessible")
+	}
+	if tester.db.Recoverable(tester.roots[3]) || !tester.db.Recoverable(tester.roots[4]) {
+		t.Error("recoverable states mismatch after recovery")
+	}
+	// Roll back once more, the recovered state is now the persisted one
+	if err := tester.db.Recover(tester.roots[4]); err != nil {
+		t.Fatalf("failed to recover again: %v", err)
+	}
+	if err := tester.verify(4); err != nil {
+		t.Fatalf("recovered state: %v", err)
+	}
+}
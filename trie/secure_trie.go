@@ -184,6 +184,12 @@ func (t *StateTrie) DeleteStorage(_ common.Address, key []byte) error {
 	return t.trie.TryDelete(hk)
 }
 
+// UpdateContractCode is a no-op, the code of the accounts is not stored in
+// the trie.
+func (t *StateTrie) UpdateContractCode(address common.Address, codeHash common.Hash, code []byte) error {
+	return nil
+}
+
 // DeleteAccount abstracts an account deletion from the trie.
 func (t *StateTrie) DeleteAccount(address common.Address) error {
 	hk := t.hashKey(address.Bytes())
@@ -247,6 +253,17 @@ func (t *StateTrie) NodeIterator(start []byte) NodeIterator {
 	return t.trie.NodeIterator(start)
 }
 
+// Witness returns the RLP encoded nodes resolved from the database since the
+// trie was opened or last committed.
+func (t *StateTrie) Witness() [][]byte {
+	return t.trie.Witness()
+}
+
+// IsVerkle reports that the trie is a Merkle Patricia trie.
+func (t *StateTrie) IsVerkle() bool {
+	return false
+}
+
 // hashKey returns the hash of key as an ephemeral buffer.
 // The caller must not hold onto the return value because it will become
 // invalid on the next call to hashKey or secKey.
@@ -43,6 +43,10 @@ type Trie struct {
 	// actually unhashed nodes.
 	unhashed int
 
+	// Keep track of the number of leaves which have been inserted since the
+	// last commit operation, deciding whether to commit concurrently.
+	uncommitted int
+
 	// reader is the handler trie can retrieve nodes from.
 	reader *trieReader
 
@@ -59,11 +63,12 @@ func (t *Trie) newFlag() nodeFlag {
 // Copy returns a copy of Trie.
 func (t *Trie) Copy() *Trie {
 	return &Trie{
-		root:     t.root,
-		owner:    t.owner,
-		unhashed: t.unhashed,
-		reader:   t.reader,
-		tracer:   t.tracer.copy(),
+		root:        t.root,
+		owner:       t.owner,
+		unhashed:    t.unhashed,
+		uncommitted: t.uncommitted,
+		reader:      t.reader,
+		tracer:      t.tracer.copy(),
 	}
 }
 
@@ -105,6 +110,17 @@ func (t *Trie) NodeIterator(start []byte) NodeIterator {
 	return newNodeIterator(t, start)
 }
 
+// Witness returns the RLP encoded nodes resolved from the database since the
+// trie was opened or last committed, the ones needed to repeat all the
+// operations done on the trie without access to the database.
+func (t *Trie) Witness() [][]byte {
+	nodes := make([][]byte, 0, len(t.tracer.accessList))
+	for _, blob := range t.tracer.accessList {
+		nodes = append(nodes, blob)
+	}
+	return nodes
+}
+
 // Get returns the value for key stored in the trie.
 // The value bytes must not be modified by the caller.
 func (t *Trie) Get(key []byte) []byte {
@@ -267,6 +283,7 @@ func (t *Trie) TryUpdate(key, value []byte) error {
 // for TryUpdate and TryUpdateAccount.
 func (t *Trie) tryUpdate(key, value []byte) error {
 	t.unhashed++
+	t.uncommitted++
 	k := keybytesToHex(key)
 	if len(value) != 0 {
 		_, n, err := t.insert(t.root, nil, k, valueNode(value))
@@ -374,6 +391,7 @@ func (t *Trie) Delete(key []byte) {
 // If a node was not found in the database, a MissingNodeError is returned.
 func (t *Trie) TryDelete(key []byte) error {
 	t.unhashed++
+	t.uncommitted++
 	k := keybytesToHex(key)
 	_, n, err := t.delete(t.root, nil, k)
 	if err != nil {
@@ -559,7 +577,10 @@ func (t *Trie) Hash() common.Hash {
 // Once the trie is committed, it's not usable anymore. A new trie must
 // be created with new root and updated trie database for following usage
 func (t *Trie) Commit(collectLeaf bool) (common.Hash, *NodeSet) {
-	defer t.tracer.reset()
+	defer func() {
+		t.tracer.reset()
+		t.uncommitted = 0
+	}()
 
 	nodes := NewNodeSet(t.owner, t.tracer.accessList)
 	t.tracer.markDeletions(nodes)
@@ -582,7 +603,8 @@ func (t *Trie) Commit(collectLeaf bool) (common.Hash, *NodeSet) {
 		t.root = hashedNode
 		return rootHash, nil
 	}
-	t.root = newCommitter(nodes, collectLeaf).Commit(t.root)
+	// Large changes are committed concurrently, same as they are hashed
+	t.root = newCommitter(nodes, collectLeaf, t.uncommitted >= parallelCommitThreshold).Commit(t.root)
 	return rootHash, nodes
 }
 
@@ -606,5 +628,6 @@ func (t *Trie) Reset() {
 	t.root = nil
 	t.owner = common.Hash{}
 	t.unhashed = 0
+	t.uncommitted = 0
 	t.tracer.reset()
 }
@@ -726,6 +726,65 @@ func TestCommitAfterHash(t *testing.T) {
 	}
 }
 
+// Tests that committing the subtries of the root concurrently collects the same
+// nodes, in the same order, as committing them sequentially.
+func TestCommitParallel(t *testing.T) {
+	addresses, accounts := makeAccounts(1000)
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	trie := NewEmpty(db)
+	for i := 0; i < len(addresses); i++ {
+		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[i])
+	}
+	root, nodes := trie.Commit(true)
+	db.Update(NewWithNodeSet(nodes))
+
+	// Modify and delete some of the accounts, tracking the original nodes too
+	trie, _ = New(TrieID(root), db)
+	for i := 0; i < 500; i++ {
+		trie.Update(crypto.Keccak256(addresses[i][:]), accounts[len(accounts)-1-i])
+	}
+	for i := 500; i < 700; i++ {
+		trie.Delete(crypto.Keccak256(addresses[i][:]))
+	}
+	seq := trie.Copy()
+	seq.uncommitted = 0
+
+	root, nodes = trie.Commit(true)
+	seqRoot, seqNodes := seq.Commit(true)
+	if root != seqRoot {
+		t.Fatalf("root mismatch: parallel %x, sequential %x", root, seqRoot)
+	}
+	if !reflect.DeepEqual(nodes, seqNodes) {
+		t.Fatal("nodeset mismatch between parallel and sequential commit")
+	}
+}
+
+// Tests that the parallel commit splits the first fullnode below a shortnode root
+// correctly, with each subtrie committed under its own path.
+func TestCommitParallelShortRoot(t *testing.T) {
+	db := NewDatabase(rawdb.NewMemoryDatabase())
+	trie := NewEmpty(db)
+	for i := 0; i < 1000; i++ {
+		key := crypto.Keccak256([]byte{byte(i), byte(i >> 8)})
+		key[0], key[1] = 0xaa, 0xbb
+		trie.Update(key, key)
+	}
+	if _, ok := trie.root.(*shortNode); !ok {
+		t.Fatalf("root is not a shortnode: %T", trie.root)
+	}
+	seq := trie.Copy()
+	seq.uncommitted = 0
+
+	root, nodes := trie.Commit(true)
+	seqRoot, seqNodes := seq.Commit(true)
+	if root != seqRoot {
+		t.Fatalf("root mismatch: parallel %x, sequential %x", root, seqRoot)
+	}
+	if !reflect.DeepEqual(nodes, seqNodes) {
+		t.Fatal("nodeset mismatch between parallel and sequential commit")
+	}
+}
+
 func makeAccounts(size int) (addresses [][20]byte, accounts [][]byte) {
 	// Make the random benchmark deterministic
 	random := rand.New(rand.NewSource(0))
@@ -0,0 +1,175 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package utils implements the key derivation of the verkle tree, mapping the
+// account headers, the code chunks and the storage slots of the accounts onto
+// the stems and suffixes of the tree.
+package utils
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gballet/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+const (
+	// Suffixes of the account header leaves, all of them sharing the stem of
+	// the account at tree index zero.
+	VersionLeafKey    = 0
+	BalanceLeafKey    = 1
+	NonceLeafKey      = 2
+	CodeKeccakLeafKey = 3
+	CodeSizeLeafKey   = 4
+
+	// ChunkSize is the number of code bytes stored in a single code chunk,
+	// the first byte of the 32 byte leaf being the number of leading bytes
+	// which are push data of an instruction of a previous chunk.
+	ChunkSize = 31
+
+	push1  = byte(0x60)
+	push32 = byte(0x7f)
+)
+
+var (
+	zero                = uint256.NewInt(0)
+	HeaderStorageOffset = uint256.NewInt(64)                           // Position of the first storage slot in the account stem
+	CodeOffset          = uint256.NewInt(128)                          // Position of the first code chunk in the account stem
+	MainStorageOffset   = new(uint256.Int).Lsh(uint256.NewInt(1), 248) // Position of the storage slots not fitting in the account stem
+	VerkleNodeWidth     = uint256.NewInt(256)                          // Number of leaves sharing a single stem
+
+	codeStorageDelta = new(uint256.Int).Sub(CodeOffset, HeaderStorageOffset)
+)
+
+// GetTreeKey computes the tree key of the leaf at the given tree index and
+// suffix of an account. The stem is the pedersen hash of the address and the
+// tree index, truncated to 31 bytes.
+func GetTreeKey(address []byte, treeIndex *uint256.Int, subIndex byte) []byte {
+	var (
+		poly    [verkle.NodeWidth]verkle.Fr
+		addr32  = common.LeftPadBytes(address, 32)
+		index32 = treeIndex.Bytes32()
+	)
+	// The index is committed to as a 32 byte little-endian integer
+	for i, j := 0, len(index32)-1; i < j; i, j = i+1, j-1 {
+		index32[i], index32[j] = index32[j], index32[i]
+	}
+	verkle.FromLEBytes(&poly[0], []byte{2, 64}) // 2 + 256 * 64, the domain separator
+	verkle.FromLEBytes(&poly[1], addr32[:16])
+	verkle.FromLEBytes(&poly[2], addr32[16:])
+	verkle.FromLEBytes(&poly[3], index32[:16])
+	verkle.FromLEBytes(&poly[4], index32[16:])
+	for i := 5; i < len(poly); i++ {
+		verkle.CopyFr(&poly[i], &verkle.FrZero)
+	}
+	cfg, _ := verkle.GetConfig()
+	commitment := cfg.CommitToPoly(poly[:], 0).Bytes()
+
+	// The commitment is serialized in big-endian, which leaves the top bits
+	// of the first byte unused and would unbalance the tree. Use it in little
+	// endian instead and chop the most significant byte.
+	for i := 0; i < 16; i++ {
+		commitment[31-i], commitment[i] = commitment[i], commitment[31-i]
+	}
+	commitment[31] = subIndex
+	return commitment[:]
+}
+
+// GetTreeKeyVersion computes the tree key of the version of an account.
+func GetTreeKeyVersion(address []byte) []byte {
+	return GetTreeKey(address, zero, VersionLeafKey)
+}
+
+// GetTreeKeyBalance computes the tree key of the balance of an account.
+func GetTreeKeyBalance(address []byte) []byte {
+	return GetTreeKey(address, zero, BalanceLeafKey)
+}
+
+// GetTreeKeyNonce computes the tree key of the nonce of an account.
+func GetTreeKeyNonce(address []byte) []byte {
+	return GetTreeKey(address, zero, NonceLeafKey)
+}
+
+// GetTreeKeyCodeKeccak computes the tree key of the code hash of an account.
+func GetTreeKeyCodeKeccak(address []byte) []byte {
+	return GetTreeKey(address, zero, CodeKeccakLeafKey)
+}
+
+// GetTreeKeyCodeSize computes the tree key of the code size of an account.
+func GetTreeKeyCodeSize(address []byte) []byte {
+	return GetTreeKey(address, zero, CodeSizeLeafKey)
+}
+
+// GetTreeKeyCodeChunk computes the tree key of the given code chunk of an account.
+func GetTreeKeyCodeChunk(address []byte, chunk *uint256.Int) []byte {
+	pos := new(uint256.Int).Add(CodeOffset, chunk)
+	return getTreeKeyAtPosition(address, pos)
+}
+
+// GetTreeKeyStorageSlot computes the tree key of the given storage slot of an
+// account. The first slots share the stem of the account header, the rest of
+// them are spread over the main storage area.
+func GetTreeKeyStorageSlot(address []byte, slot []byte) []byte {
+	pos := new(uint256.Int).SetBytes(slot)
+	if pos.Lt(codeStorageDelta) {
+		pos.Add(HeaderStorageOffset, pos)
+	} else {
+		pos.Add(MainStorageOffset, pos)
+	}
+	return getTreeKeyAtPosition(address, pos)
+}
+
+// getTreeKeyAtPosition computes the tree key of the leaf at the given absolute
+// position in the storage area of an account.
+func getTreeKeyAtPosition(address []byte, pos *uint256.Int) []byte {
+	var (
+		treeIndex = new(uint256.Int).Div(pos, VerkleNodeWidth)
+		subIndex  = new(uint256.Int).Mod(pos, VerkleNodeWidth)
+	)
+	return GetTreeKey(address, treeIndex, byte(subIndex.Uint64()))
+}
+
+// ChunkifyCode splits the code into 32 byte chunks holding 31 bytes of code
+// each, prefixed by the number of leading bytes of the chunk which are push
+// data of an instruction started in a previous chunk.
+func ChunkifyCode(code []byte) []byte {
+	var (
+		count  = (len(code) + ChunkSize - 1) / ChunkSize
+		chunks = make([]byte, count*32)
+		pc     int // Position of the next instruction in the code
+	)
+	for i := 0; i < count; i++ {
+		start, end := i*ChunkSize, (i+1)*ChunkSize
+		if end > len(code) {
+			end = len(code)
+		}
+		copy(chunks[i*32+1:], code[start:end])
+
+		if lead := pc - start; lead > 0 {
+			if lead > ChunkSize {
+				lead = ChunkSize
+			}
+			chunks[i*32] = byte(lead)
+		}
+		for pc < end {
+			op := code[pc]
+			pc++
+			if op >= push1 && op <= push32 {
+				pc += int(op-push1) + 1
+			}
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,58 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChunkifyCode(t *testing.T) {
+	push4 := push1 + 3
+	tests := []struct {
+		code  []byte
+		leads []byte // Expected push data prefix of each chunk
+	}{
+		{nil, nil},
+		{[]byte{push1, 0x01}, []byte{0}},
+		// Push data spilling over into the second chunk
+		{append(make([]byte, 29), push4, 1, 2, 3, 4, 0), []byte{0, 3}},
+		// Push data covering the entire second chunk
+		{append(append(make([]byte, 30), push32), make([]byte, 40)...), []byte{0, 31, 1}},
+		// Push opcode at the end of the code without its data
+		{append(make([]byte, 30), push32), []byte{0}},
+	}
+	for i, tt := range tests {
+		chunks := ChunkifyCode(tt.code)
+		if len(chunks) != len(tt.leads)*32 {
+			t.Fatalf("test %d: chunk count mismatch: have %d, want %d", i, len(chunks)/32, len(tt.leads))
+		}
+		for j, lead := range tt.leads {
+			chunk := chunks[j*32 : (j+1)*32]
+			if chunk[0] != lead {
+				t.Errorf("test %d, chunk %d: push data prefix mismatch: have %d, want %d", i, j, chunk[0], lead)
+			}
+			end := (j + 1) * ChunkSize
+			if end > len(tt.code) {
+				end = len(tt.code)
+			}
+			if !bytes.HasPrefix(chunk[1:], tt.code[j*ChunkSize:end]) {
+				t.Errorf("test %d, chunk %d: code mismatch: have %x", i, j, chunk[1:])
+			}
+		}
+	}
+}
@@ -0,0 +1,511 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie/utils"
+	"github.com/gballet/go-verkle"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// zero is the value of the deleted leaves of a verkle tree.
+	zero [32]byte
+
+	// errVerkleIterator is returned by the node iterators of verkle tries.
+	errVerkleIterator = errors.New("node iteration is not supported by verkle tries")
+
+	// errInvalidVerkleProof is returned if a verkle proof doesn't match the
+	// root commitment of the tree.
+	errInvalidVerkleProof = errors.New("invalid verkle proof")
+)
+
+// VerkleTrie is a state trie storing the accounts, their code and storage
+// slots in the single verkle tree of the stateless Ethereum proposal. The
+// tree keys are derived with the functions of the trie/utils package.
+//
+// The nodes of the tree are addressed by their commitment. Committing the tree
+// hands them over to the trie database, which keeps them in memory until the
+// database itself is committed. They are stored under a dedicated key prefix,
+// but are neither reference counted nor garbage collected, so verkle trees are
+// only suitable for building states which are never reorged, such as a state
+// conversion.
+//
+// VerkleTrie is not safe for concurrent use.
+type VerkleTrie struct {
+	root verkle.VerkleNode
+	db   *Database
+}
+
+// NewVerkleTrie opens the verkle tree with the given root commitment. If root
+// is the zero hash or the empty root hash, the tree is initially empty.
+func NewVerkleTrie(root common.Hash, db *Database) (*VerkleTrie, error) {
+	if db == nil {
+		panic("trie.NewVerkleTrie called without a database")
+	}
+	t := &VerkleTrie{root: verkle.New(), db: db}
+	if root == (common.Hash{}) || root == types.EmptyRootHash {
+		return t, nil
+	}
+	blob, err := t.resolve(root[:])
+	if err != nil {
+		return nil, &MissingNodeError{NodeHash: root, err: err}
+	}
+	if t.root, err = verkle.ParseNode(blob, 0, root[:]); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// resolve retrieves the serialized node with the given commitment.
+func (t *VerkleTrie) resolve(commitment []byte) ([]byte, error) {
+	if blob := t.db.verkle.node(commitment); blob != nil {
+		return blob, nil
+	}
+	if blob := rawdb.ReadVerkleNode(t.db.diskdb, commitment); len(blob) > 0 {
+		return blob, nil
+	}
+	return nil, fmt.Errorf("verkle node %x missing", commitment)
+}
+
+// get retrieves the value of the given tree key, nil if it's absent or has
+// been deleted.
+func (t *VerkleTrie) get(key []byte) ([]byte, error) {
+	value, err := t.root.Get(key, t.resolve)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 || bytes.Equal(value, zero[:]) {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// delete removes the value of the given tree key if it's present.
+func (t *VerkleTrie) delete(key []byte) error {
+	value, err := t.get(key)
+	if value == nil || err != nil {
+		return err
+	}
+	return t.root.Delete(key, t.resolve)
+}
+
+// GetKey returns the sha3 preimage of a hashed key that was previously used
+// to store a value.
+func (t *VerkleTrie) GetKey(key []byte) []byte {
+	if t.db.preimages == nil {
+		return nil
+	}
+	return t.db.preimages.preimage(common.BytesToHash(key))
+}
+
+// GetStorage returns the RLP encoded value of the given storage slot of an
+// account, nil if it's not present in the tree.
+func (t *VerkleTrie) GetStorage(addr common.Address, key []byte) ([]byte, error) {
+	value, err := t.get(utils.GetTreeKeyStorageSlot(addr[:], key))
+	if value == nil || err != nil {
+		return nil, err
+	}
+	// Storage slots are kept in full, return them in the encoding of the tries
+	return rlp.EncodeToBytes(common.TrimLeftZeroes(value))
+}
+
+// GetAccount retrieves the header of an account from the tree, nil if the
+// account is not present. Verkle accounts have no storage root, their slots
+// are stored in the same tree.
+func (t *VerkleTrie) GetAccount(addr common.Address) (*types.StateAccount, error) {
+	var (
+		stem   = utils.GetTreeKeyVersion(addr[:])
+		values = make([][]byte, utils.CodeSizeLeafKey+1)
+	)
+	for i := range values {
+		key := make([]byte, len(stem))
+		copy(key, stem[:31])
+		key[31] = byte(i)
+
+		value, err := t.get(key)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	// Existing accounts always have a code hash, even if without any code
+	if values[utils.CodeKeccakLeafKey] == nil {
+		return nil, nil
+	}
+	acc := &types.StateAccount{
+		Balance:  new(big.Int),
+		Root:     types.EmptyRootHash,
+		CodeHash: values[utils.CodeKeccakLeafKey],
+	}
+	if balance := values[utils.BalanceLeafKey]; balance != nil {
+		acc.Balance.SetBytes(reverse(balance))
+	}
+	if nonce := values[utils.NonceLeafKey]; nonce != nil {
+		acc.Nonce = binary.LittleEndian.Uint64(nonce)
+	}
+	return acc, nil
+}
+
+// UpdateStorage sets the given storage slot of an account to the RLP encoded
+// value. If the value has length zero, the slot is deleted.
+func (t *VerkleTrie) UpdateStorage(addr common.Address, key, value []byte) error {
+	if len(value) == 0 {
+		return t.DeleteStorage(addr, key)
+	}
+	content, _, err := rlp.SplitString(value)
+	if err != nil {
+		return err
+	}
+	if len(content) > 32 {
+		return fmt.Errorf("storage value too long: %d bytes", len(content))
+	}
+	var leaf [32]byte
+	copy(leaf[32-len(content):], content)
+	return t.root.Insert(utils.GetTreeKeyStorageSlot(addr[:], key), leaf[:], t.resolve)
+}
+
+// UpdateAccount writes the header of an account into the tree. The code size
+// is maintained by UpdateContractCode.
+func (t *VerkleTrie) UpdateAccount(addr common.Address, acc *types.StateAccount) error {
+	var (
+		stem    = utils.GetTreeKeyVersion(addr[:])
+		balance [32]byte
+		nonce   [32]byte
+	)
+	if acc.Balance.BitLen() > 256 {
+		return fmt.Errorf("balance too large: %v", acc.Balance)
+	}
+	copy(balance[:], reverse(acc.Balance.Bytes()))
+	binary.LittleEndian.PutUint64(nonce[:], acc.Nonce)
+
+	values := map[byte][]byte{
+		utils.VersionLeafKey:    zero[:],
+		utils.BalanceLeafKey:    balance[:],
+		utils.NonceLeafKey:      nonce[:],
+		utils.CodeKeccakLeafKey: acc.CodeHash,
+	}
+	for suffix, value := range values {
+		key := make([]byte, len(stem))
+		copy(key, stem[:31])
+		key[31] = suffix
+
+		if err := t.root.Insert(key, value, t.resolve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateContractCode writes the size and the chunks of the code of an account
+// into the tree.
+func (t *VerkleTrie) UpdateContractCode(addr common.Address, codeHash common.Hash, code []byte) error {
+	var size [32]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(code)))
+	if err := t.root.Insert(utils.GetTreeKeyCodeSize(addr[:]), size[:], t.resolve); err != nil {
+		return err
+	}
+	chunks := utils.ChunkifyCode(code)
+	return forEachCodeChunkKey(addr, len(chunks)/32, func(i int, key []byte) error {
+		return t.root.Insert(key, chunks[i*32:(i+1)*32], t.resolve)
+	})
+}
+
+// forEachCodeChunkKey invokes fn with the tree keys of the given number of code
+// chunks of an account.
+func forEachCodeChunkKey(addr common.Address, chunks int, fn func(i int, key []byte) error) error {
+	var stem []byte
+	for i, pos := 0, utils.CodeOffset.Uint64(); i < chunks; i, pos = i+1, pos+1 {
+		// Consecutive chunks share the stem up until the next tree index
+		if stem == nil || pos%256 == 0 {
+			stem = utils.GetTreeKeyCodeChunk(addr[:], uint256.NewInt(uint64(i)))
+		}
+		key := make([]byte, len(stem))
+		copy(key, stem[:31])
+		key[31] = byte(pos)
+
+		if err := fn(i, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteStorage removes the given storage slot of an account from the tree.
+func (t *VerkleTrie) DeleteStorage(addr common.Address, key []byte) error {
+	return t.delete(utils.GetTreeKeyStorageSlot(addr[:], key))
+}
+
+// DeleteAccount removes the header and the code chunks of an account from the
+// tree. The storage slots of the account are scattered across the tree and can't
+// be enumerated, so they are left in place. Accounts with storage must hence not
+// be deleted, which the state database ensures by rejecting self-destructs.
+func (t *VerkleTrie) DeleteAccount(addr common.Address) error {
+	size, err := t.get(utils.GetTreeKeyCodeSize(addr[:]))
+	if err != nil {
+		return err
+	}
+	if size != nil {
+		chunks := (binary.LittleEndian.Uint64(size) + utils.ChunkSize - 1) / utils.ChunkSize
+		if err := forEachCodeChunkKey(addr, int(chunks), func(_ int, key []byte) error {
+			return t.delete(key)
+		}); err != nil {
+			return err
+		}
+	}
+	stem := utils.GetTreeKeyVersion(addr[:])
+	for i := 0; i <= utils.CodeSizeLeafKey; i++ {
+		key := make([]byte, len(stem))
+		copy(key, stem[:31])
+		key[31] = byte(i)
+
+		if err := t.delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hash returns the root commitment of the tree.
+func (t *VerkleTrie) Hash() common.Hash {
+	return common.Hash(t.root.ComputeCommitment().Bytes())
+}
+
+// Commit hands all the nodes of the tree loaded into memory over to the trie
+// database and returns the root commitment. They are written into the disk when
+// the trie database is committed, which also reports any failure to serialize
+// them. The nodes are not collected into a nodeset, so the returned one is
+// always nil. Contrary to the other tries, the tree remains usable after commit.
+func (t *VerkleTrie) Commit(_ bool) (common.Hash, *NodeSet) {
+	root, ok := t.root.(*verkle.InternalNode)
+	if !ok {
+		panic(fmt.Errorf("invalid verkle root node %T", t.root))
+	}
+	root.Flush(func(node verkle.VerkleNode) {
+		blob, err := node.Serialize()
+		commitment := node.ComputeCommitment().Bytes()
+		t.db.verkle.insert(commitment[:], blob, err)
+	})
+	return t.Hash(), nil
+}
+
+// NodeIterator returns an iterator which fails right away, node iteration is
+// not supported by verkle tries.
+func (t *VerkleTrie) NodeIterator(startKey []byte) NodeIterator {
+	return &verkleIterator{nodeIterator: new(nodeIterator)}
+}
+
+// Prove constructs a verkle proof for the given tree key and writes the
+// serialized proof into proofDb, keyed by the tree key itself.
+func (t *VerkleTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	proof, err := t.ProveKeys([][]byte{key})
+	if err != nil {
+		return err
+	}
+	return proofDb.Put(key, proof)
+}
+
+// ProveAccount constructs a verkle multiproof of all the header leaves of the
+// given account.
+func (t *VerkleTrie) ProveAccount(addr common.Address) ([]byte, error) {
+	stem := utils.GetTreeKeyVersion(addr[:])
+
+	keys := make([][]byte, utils.CodeSizeLeafKey+1)
+	for i := range keys {
+		keys[i] = make([]byte, len(stem))
+		copy(keys[i], stem[:31])
+		keys[i][31] = byte(i)
+	}
+	return t.ProveKeys(keys)
+}
+
+// ProveKeys constructs a verkle multiproof of the given tree keys, proving the
+// absence of the ones not present in the tree.
+func (t *VerkleTrie) ProveKeys(keys [][]byte) ([]byte, error) {
+	// Proofs can't be constructed over hashed nodes, resolve the paths first
+	keys = append([][]byte{}, keys...)
+	values := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		value, err := t.root.Get(key, t.resolve)
+		if err != nil {
+			return nil, err
+		}
+		values[string(key)] = value
+	}
+	proof, _, _, _, err := verkle.MakeVerkleMultiProof(t.root, keys, values)
+	if err != nil {
+		return nil, err
+	}
+	blob, _, err := verkle.SerializeProof(proof)
+	return blob, err
+}
+
+// VerifyVerkleProof checks a verkle multiproof of the given tree keys and their
+// values against the root commitment of the tree. Absent keys have nil values.
+func VerifyVerkleProof(root common.Hash, proof []byte, keys [][]byte, values [][]byte) error {
+	if len(keys) != len(values) {
+		return fmt.Errorf("key and value count mismatch: %d != %d", len(keys), len(values))
+	}
+	// The proof covers the keys in sorted order
+	keyvals := make([]verkle.KeyValuePair, len(keys))
+	for i := range keys {
+		keyvals[i] = verkle.KeyValuePair{Key: keys[i], Value: values[i]}
+	}
+	sort.Slice(keyvals, func(i, j int) bool {
+		return bytes.Compare(keyvals[i].Key, keyvals[j].Key) < 0
+	})
+	keys = make([][]byte, len(keyvals))
+	for i, kv := range keyvals {
+		keys[i] = kv.Key
+	}
+	decoded, err := verkle.DeserializeProof(proof, keyvals)
+	if err != nil {
+		return err
+	}
+	var commitment verkle.Point
+	if err := commitment.SetBytes(root[:]); err != nil {
+		return err
+	}
+	tree, err := verkle.TreeFromProof(decoded, &commitment)
+	if err != nil {
+		return err
+	}
+	cfg, err := verkle.GetConfig()
+	if err != nil {
+		return err
+	}
+	elements, _, _ := verkle.GetCommitmentsForMultiproof(tree, keys)
+	if !verkle.VerifyVerkleProof(decoded, elements.Cis, elements.Zis, elements.Yis, cfg) {
+		return errInvalidVerkleProof
+	}
+	return nil
+}
+
+// Copy returns a copy of the tree.
+func (t *VerkleTrie) Copy() *VerkleTrie {
+	return &VerkleTrie{root: t.root.Copy(), db: t.db}
+}
+
+// Witness returns nothing, the witnesses of verkle trees are proofs.
+func (t *VerkleTrie) Witness() [][]byte {
+	return nil
+}
+
+// IsVerkle reports that the trie is a verkle tree.
+func (t *VerkleTrie) IsVerkle() bool {
+	return true
+}
+
+// verkleNodes keeps the committed nodes of verkle trees in memory until the
+// trie database is committed.
+type verkleNodes struct {
+	nodes map[string][]byte // Serialized nodes keyed by their commitment
+	err   error             // First failure to serialize a node
+	lock  sync.RWMutex
+}
+
+func newVerkleNodes() *verkleNodes {
+	return &verkleNodes{nodes: make(map[string][]byte)}
+}
+
+// node retrieves the committed node with the given commitment, nil if it's not
+// held in memory.
+func (v *verkleNodes) node(commitment []byte) []byte {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+
+	return v.nodes[string(commitment)]
+}
+
+// insert adds a committed node, or records the failure to serialize it.
+func (v *verkleNodes) insert(commitment []byte, blob []byte, err error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if err != nil {
+		if v.err == nil {
+			v.err = fmt.Errorf("failed to serialize verkle node %x: %w", commitment, err)
+		}
+		return
+	}
+	v.nodes[string(commitment)] = blob
+}
+
+// commit writes the committed nodes into the disk, unless any of them failed to
+// be serialized.
+func (v *verkleNodes) commit(db ethdb.KeyValueStore) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.err != nil {
+		return v.err
+	}
+	batch := db.NewBatch()
+	for commitment, blob := range v.nodes {
+		rawdb.WriteVerkleNode(batch, []byte(commitment), blob)
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	v.nodes = make(map[string][]byte)
+	return nil
+}
+
+// verkleIterator is the node iterator of verkle tries, which is always exhausted
+// and reports the lack of support for node iteration.
+type verkleIterator struct {
+	*nodeIterator
+}
+
+// Next implements NodeIterator, never advancing the iterator.
+func (it *verkleIterator) Next(bool) bool {
+	return false
+}
+
+// Error implements NodeIterator, returning why the iteration failed.
+func (it *verkleIterator) Error() error {
+	return errVerkleIterator
+}
+
+// reverse returns the given bytes in reverse order, converting between the
+// big-endian integers of the state and the little-endian ones of the tree.
+func reverse(b []byte) []byte {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+	return reversed
+}
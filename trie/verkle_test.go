@@ -0,0 +1,179 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie/utils"
+	"github.com/holiman/uint256"
+)
+
+var (
+	verkleAccounts = map[common.Address]*types.StateAccount{
+		common.HexToAddress("0x01"): {
+			Nonce:    1,
+			Balance:  big.NewInt(100),
+			Root:     types.EmptyRootHash,
+			CodeHash: types.EmptyCodeHash.Bytes(),
+		},
+		common.HexToAddress("0x02"): {
+			Nonce:    0,
+			Balance:  new(big.Int).Lsh(big.NewInt(1), 200),
+			Root:     types.EmptyRootHash,
+			CodeHash: crypto.Keccak256([]byte{0x60, 0x00}),
+		},
+	}
+	verkleStorage = map[common.Hash][]byte{
+		common.HexToHash("0x00"):   {0x01},
+		common.HexToHash("0x3f"):   {0x02, 0x03},
+		common.HexToHash("0xffff"): common.HexToHash("0xabcd").Bytes(),
+	}
+)
+
+func TestVerkleTrieAccounts(t *testing.T) {
+	diskdb := rawdb.NewMemoryDatabase()
+	db := NewDatabaseWithConfig(diskdb, &Config{Verkle: true})
+	tr, _ := NewVerkleTrie(common.Hash{}, db)
+
+	for addr, acc := range verkleAccounts {
+		if err := tr.UpdateAccount(addr, acc); err != nil {
+			t.Fatalf("failed to update account %x: %v", addr, err)
+		}
+	}
+	contract := common.HexToAddress("0x02")
+	for key, value := range verkleStorage {
+		enc, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(value))
+		if err := tr.UpdateStorage(contract, key[:], enc); err != nil {
+			t.Fatalf("failed to update slot %x: %v", key, err)
+		}
+	}
+	if err := tr.UpdateContractCode(contract, common.BytesToHash(verkleAccounts[contract].CodeHash), []byte{0x60, 0x00}); err != nil {
+		t.Fatalf("failed to update code: %v", err)
+	}
+	// Reopen the committed tree from the disk and check its contents
+	root, _ := tr.Commit(false)
+	if root != tr.Hash() {
+		t.Fatalf("root mismatch after commit: have %x, want %x", tr.Hash(), root)
+	}
+	if blob := rawdb.ReadVerkleNode(diskdb, root[:]); blob != nil {
+		t.Fatal("verkle node written before the database commit")
+	}
+	if err := db.Commit(root, false); err != nil {
+		t.Fatalf("failed to commit database: %v", err)
+	}
+	if rawdb.HasLegacyTrieNode(diskdb, root) {
+		t.Fatal("verkle node stored as a trie node")
+	}
+	db = NewDatabaseWithConfig(diskdb, &Config{Verkle: true})
+	tr, err := NewVerkleTrie(root, db)
+	if err != nil {
+		t.Fatalf("failed to reopen tree: %v", err)
+	}
+	for addr, want := range verkleAccounts {
+		have, err := tr.GetAccount(addr)
+		if err != nil {
+			t.Fatalf("failed to retrieve account %x: %v", addr, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("account %x mismatch: have %+v, want %+v", addr, have, want)
+		}
+	}
+	for key, value := range verkleStorage {
+		have, err := tr.GetStorage(contract, key[:])
+		if err != nil {
+			t.Fatalf("failed to retrieve slot %x: %v", key, err)
+		}
+		want, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(value))
+		if !bytes.Equal(have, want) {
+			t.Fatalf("slot %x mismatch: have %x, want %x", key, have, want)
+		}
+	}
+	size, _ := tr.get(utils.GetTreeKeyCodeSize(contract[:]))
+	if size[0] != 2 {
+		t.Fatalf("code size mismatch: have %d, want 2", size[0])
+	}
+	chunk, _ := tr.get(utils.GetTreeKeyCodeChunk(contract[:], uint256.NewInt(0)))
+	if !bytes.Equal(chunk[:3], []byte{0, 0x60, 0x00}) {
+		t.Fatalf("code chunk mismatch: have %x", chunk)
+	}
+	// Delete the contents and check they're gone
+	for key := range verkleStorage {
+		if err := tr.UpdateStorage(contract, key[:], nil); err != nil {
+			t.Fatalf("failed to delete slot %x: %v", key, err)
+		}
+		if have, _ := tr.GetStorage(contract, key[:]); have != nil {
+			t.Fatalf("slot %x not deleted: %x", key, have)
+		}
+	}
+	if err := tr.DeleteAccount(contract); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+	if have, _ := tr.GetAccount(contract); have != nil {
+		t.Fatalf("account not deleted: %+v", have)
+	}
+	if chunk, _ := tr.get(utils.GetTreeKeyCodeChunk(contract[:], uint256.NewInt(0))); chunk != nil {
+		t.Fatalf("code chunk not deleted: %x", chunk)
+	}
+	if tr.NodeIterator(nil).Next(true) {
+		t.Fatal("node iteration succeeded")
+	}
+}
+
+func TestVerkleProof(t *testing.T) {
+	db := NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), &Config{Verkle: true})
+	tr, _ := NewVerkleTrie(common.Hash{}, db)
+
+	for addr, acc := range verkleAccounts {
+		tr.UpdateAccount(addr, acc)
+	}
+	root, _ := tr.Commit(false)
+	tr, _ = NewVerkleTrie(root, db)
+
+	// Prove the balance of an account, together with a missing one
+	var (
+		addr   = common.HexToAddress("0x01")
+		keys   = [][]byte{utils.GetTreeKeyBalance(addr[:]), utils.GetTreeKeyBalance(common.HexToAddress("0x03").Bytes())}
+		values = [][]byte{common.LeftPadBytes([]byte{100}, 32), nil}
+	)
+	for i, j := 0, len(values[0])-1; i < j; i, j = i+1, j-1 {
+		values[0][i], values[0][j] = values[0][j], values[0][i]
+	}
+	proof, err := tr.ProveKeys(keys)
+	if err != nil {
+		t.Fatalf("failed to prove keys: %v", err)
+	}
+	if err := VerifyVerkleProof(root, proof, keys, values); err != nil {
+		t.Fatalf("failed to verify proof: %v", err)
+	}
+	values[0] = common.LeftPadBytes([]byte{101}, 32)
+	if err := VerifyVerkleProof(root, proof, keys, values); err == nil {
+		t.Fatal("proof of wrong value verified")
+	}
+	// Proofs of the account headers are produced too
+	if _, err := tr.ProveAccount(addr); err != nil {
+		t.Fatalf("failed to prove account: %v", err)
+	}
+}